@@ -2,7 +2,9 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -77,6 +79,20 @@ type Common interface {
 	LogList(context.Context) ([]string, error)         //perm:write
 	LogSetLevel(context.Context, string, string) error //perm:write
 
+	// MethodGroup: Journal
+
+	// JournalQuery returns the journal events recorded between since and
+	// until (zero times are unbounded), oldest first. Empty or "*" system and
+	// event match any value. At most 10000 events are returned, the latest
+	// ones are kept.
+	JournalQuery(ctx context.Context, system, event string, since, until time.Time) ([]JournalEvent, error) //perm:read
+
+	// JournalSubscribe returns the journal events matching the system and
+	// event filters as they get recorded.
+	JournalSubscribe(ctx context.Context, system, event string) (<-chan JournalEvent, error) //perm:read
+
+	// MethodGroup: Common
+
 	// trigger graceful shutdown
 	Shutdown(context.Context) error //perm:admin
 
@@ -106,6 +122,14 @@ func (v APIVersion) String() string {
 	return fmt.Sprintf("%s+api%s", v.Version, v.APIVersion.String())
 }
 
+// JournalEvent is an entry of the node's event journal
+type JournalEvent struct {
+	System    string
+	Event     string
+	Timestamp time.Time
+	Data      json.RawMessage
+}
+
 type NatInfo struct {
 	Reachability network.Reachability
 	PublicAddr   string
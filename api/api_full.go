@@ -19,6 +19,7 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/go-state-types/dline"
+	"github.com/filecoin-project/go-state-types/exitcode"
 
 	apitypes "github.com/filecoin-project/lotus/api/types"
 	"github.com/filecoin-project/lotus/chain/actors/builtin"
@@ -160,6 +161,12 @@ type FullNode interface {
 	// If oldmsgskip is set, messages from before the requested roots are also not included.
 	ChainExport(ctx context.Context, nroots abi.ChainEpoch, oldmsgskip bool, tsk types.TipSetKey) (<-chan []byte, error) //perm:read
 
+	// ChainIndexBackfill adds the messages executed in the given number of
+	// epochs, up to and including the given tipset, to the enabled chain
+	// indexes (see Index in the config), and returns the number of entries
+	// added.
+	ChainIndexBackfill(ctx context.Context, tsk types.TipSetKey, epochs abi.ChainEpoch) (int, error) //perm:admin
+
 	// MethodGroup: Beacon
 	// The Beacon method group contains methods for interacting with the random beacon (DRAND)
 
@@ -303,6 +310,11 @@ type FullNode interface {
 	WalletDelete(context.Context, address.Address) error //perm:admin
 	// WalletValidateAddress validates whether a given string can be decoded as a well-formed address
 	WalletValidateAddress(context.Context, string) (address.Address, error) //perm:read
+	// WalletLock locks the wallet if its keystore is encrypted, after which its
+	// keys can't be used until it's unlocked with WalletUnlock.
+	WalletLock(context.Context) error //perm:admin
+	// WalletUnlock unlocks the encrypted keystore of the wallet with its passphrase.
+	WalletUnlock(ctx context.Context, passphrase string) error //perm:admin
 
 	// Other
 
@@ -402,6 +414,12 @@ type FullNode interface {
 	StateReadState(ctx context.Context, actor address.Address, tsk types.TipSetKey) (*ActorState, error) //perm:read
 	// StateListMessages looks back and returns all messages with a matching to or from address, stopping at the given height.
 	StateListMessages(ctx context.Context, match *MessageMatch, tsk types.TipSetKey, toht abi.ChainEpoch) ([]cid.Cid, error) //perm:read
+	// StateAddressHistory returns up to limit messages sent or received by the
+	// address, and value transfers made to or from it by actors, from the most
+	// recent. Pass the returned cursor to get the following entries, it's empty
+	// once there are none left. The address index must be enabled with
+	// Index.EnableAddrIndex in the config.
+	StateAddressHistory(ctx context.Context, addr address.Address, cursor string, limit int) (*AddressHistory, error) //perm:read
 	// StateDecodeParams attempts to decode the provided params, based on the recipient actor address and method number.
 	StateDecodeParams(ctx context.Context, toAddr address.Address, method abi.MethodNum, params []byte, tsk types.TipSetKey) (interface{}, error) //perm:read
 
@@ -438,6 +456,10 @@ type FullNode interface {
 	StateMinerInitialPledgeCollateral(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error) //perm:read
 	// StateMinerAvailableBalance returns the portion of a miner's balance that can be withdrawn or spent
 	StateMinerAvailableBalance(context.Context, address.Address, types.TipSetKey) (types.BigInt, error) //perm:read
+	// StateMinerVestingSchedule returns the locked funds of a miner by category,
+	// and the epochs at which the reward vesting table, the PoS vesting table
+	// and the initial pledge of the sectors unlock
+	StateMinerVestingSchedule(context.Context, address.Address, types.TipSetKey) (*MinerVestingSchedule, error) //perm:read
 	// StateMinerSectorAllocated checks if a sector is allocated
 	StateMinerSectorAllocated(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (bool, error) //perm:read
 	// StateSectorPreCommitInfo returns the PreCommit info for the specified miner's sector
@@ -560,6 +582,12 @@ type FullNode interface {
 	// StateVMCirculatingSupplyInternal returns an approximation of the circulating supply of Filecoin at the given tipset.
 	// This is the value reported by the runtime interface to actors code.
 	StateVMCirculatingSupplyInternal(context.Context, types.TipSetKey) (CirculatingSupply, error) //perm:read
+	// StateSupply returns the minted, vested, burnt and locked funds at the
+	// given tipset, with the locked funds split between pledge collateral,
+	// market collateral and PoS deposits, and the resulting circulating supply.
+	// Unlike StateVMCirculatingSupplyInternal, the circulating supply deducts the
+	// PoS deposits.
+	StateSupply(context.Context, types.TipSetKey) (*NetworkSupply, error) //perm:read
 	// StateNetworkVersion returns the network version at the given tipset
 	StateNetworkVersion(context.Context, types.TipSetKey) (apitypes.NetworkVersion, error) //perm:read
 
@@ -837,6 +865,21 @@ type MinerPower struct {
 	HasMinPower   bool
 }
 
+type MinerVestingSchedule struct {
+	Epoch     abi.ChainEpoch
+	Balance   abi.TokenAmount
+	Available abi.TokenAmount
+	Locked    miner.LockedFunds
+
+	// Vesting are the entries of the reward vesting table
+	Vesting []miner.VestingFund
+	// PosVesting are the entries of the PoS vesting table, which can be
+	// withdrawn once their epoch has passed
+	PosVesting []miner.VestingFund
+	// Pledge is the initial pledge of the sectors, by expiration epoch
+	Pledge []miner.VestingFund
+}
+
 type QueryOffer struct {
 	Err string
 
@@ -959,9 +1002,27 @@ type ActiveSync struct {
 type SyncState struct {
 	ActiveSyncs []ActiveSync
 
+	// Peers are the chain exchange statistics of the peers chain data is
+	// fetched from, best scored first
+	Peers []SyncPeerStats
+
 	VMApplied uint64
 }
 
+type SyncPeerStats struct {
+	Peer  peer.ID
+	Score float64
+
+	Successes int
+	Failures  int
+	Invalid   int
+	Slow      int
+
+	// AvgTipSetTime is the moving average of the time taken per tipset served
+	AvgTipSetTime time.Duration
+	FirstSeen     time.Time
+}
+
 type SyncStateStage int
 
 const (
@@ -1026,6 +1087,30 @@ type CirculatingSupply struct {
 	FilReserveDisbursed abi.TokenAmount
 }
 
+type NetworkSupply struct {
+	Epoch abi.ChainEpoch
+
+	Minted           abi.TokenAmount
+	Vested           abi.TokenAmount
+	ReserveDisbursed abi.TokenAmount
+	Burnt            abi.TokenAmount
+
+	// Pledged is the pledge collateral of the miners
+	Pledged abi.TokenAmount
+	// MarketLocked are the deal collateral and the storage fees locked in the
+	// market actor
+	MarketLocked abi.TokenAmount
+	// PosLocked are the PoS deposits of the miners
+	PosLocked abi.TokenAmount
+
+	// Circulating is Minted + Vested + ReserveDisbursed - Burnt - Pledged -
+	// MarketLocked - PosLocked
+	Circulating abi.TokenAmount
+	// VMCirculating is the circulating supply used by the VM, which doesn't
+	// deduct PosLocked
+	VMCirculating abi.TokenAmount
+}
+
 type MiningBaseInfo struct {
 	MinerPower        types.BigInt
 	NetworkPower      types.BigInt
@@ -1111,6 +1196,35 @@ type MessageMatch struct {
 	From address.Address
 }
 
+type AddressHistory struct {
+	Transfers []AddressTransfer
+	// Cursor to pass to get the following entries, empty if there are none
+	Cursor string
+}
+
+type AddressTransfer struct {
+	// Message is the top-level message which caused the transfer
+	Message cid.Cid
+	// TipSet is the tipset in which the message was executed
+	TipSet types.TipSetKey
+	Epoch  abi.ChainEpoch
+
+	From   address.Address
+	To     address.Address
+	Value  abi.TokenAmount
+	Method abi.MethodNum
+	// ExitCode is non-zero if the value wasn't transferred
+	ExitCode exitcode.ExitCode
+	// GasCost is the total cost paid by the sender of a top-level message
+	GasCost abi.TokenAmount
+
+	// Internal is set for value transfers made by actors
+	Internal bool
+	// Implicit is set for transfers made by messages which aren't on chain,
+	// such as block rewards and cron
+	Implicit bool
+}
+
 type MsigTransaction struct {
 	ID     int64
 	To     address.Address
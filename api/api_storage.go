@@ -45,6 +45,12 @@ type StorageMiner interface {
 
 	ActorSectorSize(context.Context, address.Address) (abi.SectorSize, error) //perm:read
 	ActorAddressConfig(ctx context.Context) (AddressConfig, error)            //perm:read
+	// ActorControlStatus returns the balances of the control addresses with a
+	// minimum balance, their projected burn, and recent automatic top ups
+	ActorControlStatus(ctx context.Context) (ControlStatus, error) //perm:read
+	// ActorTreasuryStatus returns the schedule and the recent actions of the
+	// scheduled reward withdrawals
+	ActorTreasuryStatus(ctx context.Context) (TreasuryStatus, error) //perm:read
 
 	MiningBase(context.Context) (*types.TipSet, error) //perm:read
 
@@ -129,6 +135,13 @@ type StorageMiner interface {
 	StorageLocal(ctx context.Context) (map[stores.ID]string, error)       //perm:admin
 	StorageStat(ctx context.Context, id stores.ID) (fsutil.FsStat, error) //perm:admin
 
+	// StorageMigrations lists queued, running and recently finished sector
+	// moves between storage tiers
+	StorageMigrations(ctx context.Context) ([]StorageMigration, error) //perm:admin
+	// StorageMigrateSector queues a move of the sector into a local storage
+	// path tagged with the given tier
+	StorageMigrateSector(ctx context.Context, sector abi.SectorNumber, tier string) error //perm:admin
+
 	MarketImportDealData(ctx context.Context, propcid cid.Cid, path string) error                                                                                                        //perm:write
 	MarketListDeals(ctx context.Context) ([]MarketDeal, error)                                                                                                                           //perm:read
 	MarketListRetrievalDeals(ctx context.Context) ([]retrievalmarket.ProviderDealState, error)                                                                                           //perm:read
@@ -179,6 +192,13 @@ type StorageMiner interface {
 
 	CheckProvable(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, expensive bool) (map[abi.SectorNumber]string, error) //perm:admin
 
+	// ProvingScrubStatus returns the result of the latest background integrity
+	// check of each sector checked so far
+	ProvingScrubStatus(ctx context.Context) ([]SectorScrubResult, error) //perm:read
+	// ProvingScrubSectors queues sectors to be checked by the background
+	// scrubber ahead of the regular rotation
+	ProvingScrubSectors(ctx context.Context, sectors []abi.SectorNumber) error //perm:admin
+
 	ComputeProof(ctx context.Context, ssi []builtin.SectorInfo, rand abi.PoStRandomness) ([]builtin.PoStProof, error) //perm:read
 }
 
@@ -282,6 +302,111 @@ type AddressConfig struct {
 	DisableWorkerFallback bool
 }
 
+// ControlStatus describes the balances of the miner control addresses and
+// their automatic top ups
+type ControlStatus struct {
+	Addresses []ControlAddressStatus
+
+	// Undef when top ups are disabled
+	TopUpFrom     address.Address
+	MaxDailyTopUp abi.TokenAmount
+	// Amount sent in top ups in the last 24 hours
+	SentLastDay abi.TokenAmount
+	// Top ups sent in the last 24 hours, and the ones still pending
+	TopUps []ControlTopUp
+}
+
+type ControlAddressStatus struct {
+	Address    address.Address
+	Roles      []string
+	Balance    abi.TokenAmount
+	MinBalance abi.TokenAmount
+	// Average decrease of the balance per day, not counting top ups, since
+	// the miner started watching the address, over at most the last day.
+	// Nil until enough balance samples are collected
+	BurnPerDay *abi.TokenAmount `json:",omitempty"`
+}
+
+type ControlTopUp struct {
+	To     address.Address
+	Amount abi.TokenAmount
+	Msg    cid.Cid
+	Sent   time.Time
+	// Whether the message was included on chain
+	Landed bool
+	// Whether the message didn't land in time, so that the address may be
+	// topped up again
+	Expired bool
+}
+
+// TreasuryStatus describes the scheduled reward withdrawals of the miner
+type TreasuryStatus struct {
+	Enabled bool
+	LastRun time.Time
+	NextRun time.Time
+	// Most recent actions, oldest first
+	Actions []TreasuryAction
+}
+
+type TreasuryAction struct {
+	Time time.Time
+	// withdraw, vote, cold or pledge
+	Action string
+	Amount abi.TokenAmount
+	From   address.Address
+	To     address.Address
+	// Nil when funds were kept without sending a message
+	Msg *cid.Cid `json:",omitempty"`
+	Err string   `json:",omitempty"`
+}
+
+type StorageMigrationState string
+
+const (
+	MigrationQueued StorageMigrationState = "queued"
+	MigrationMoving StorageMigrationState = "moving"
+	MigrationDone   StorageMigrationState = "done"
+	MigrationFailed StorageMigrationState = "failed"
+)
+
+// StorageMigration describes a move of sector files between storage tiers
+type StorageMigration struct {
+	Sector abi.SectorNumber
+	From   stores.ID
+	To     stores.ID
+	ToTier string
+	// Reason is either "manual" or the rule which triggered the move
+	Reason string
+
+	State StorageMigrationState
+	Moved int64 // bytes
+	Total int64 // bytes
+
+	Queued   time.Time
+	Started  time.Time
+	Finished time.Time
+	Err      string
+}
+
+// SectorScrubResult is the result of the latest integrity check of a sector
+type SectorScrubResult struct {
+	Sector    abi.SectorNumber
+	Deadline  uint64
+	Partition uint64
+
+	Checked time.Time
+	Epoch   abi.ChainEpoch
+	// Whether a vanilla proof was generated in addition to the file checks
+	VanillaProof bool
+
+	// Err is empty if the sector passed the check
+	Err string
+	// Number of consecutive failed checks
+	Failures int
+	// DeclareFaults message sent for the sector after the last failure
+	FaultMsg *cid.Cid `json:",omitempty"`
+}
+
 // PendingDealInfo has info about pending deals and when they are due to be
 // published
 type PendingDealInfo struct {
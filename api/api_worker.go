@@ -11,6 +11,7 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 )
 
@@ -33,6 +34,13 @@ type Worker interface {
 	Paths(context.Context) ([]stores.StoragePath, error)                //perm:admin
 	Info(context.Context) (storiface.WorkerInfo, error)                 //perm:admin
 
+	// FetchProgress returns the state of sector fetches running on the worker
+	FetchProgress(context.Context) ([]storiface.FetchProgress, error) //perm:admin
+
+	// GenerateWindowPoSt computes a WindowPoSt proof for the sectors, reading
+	// sector data only from storage local to the worker
+	GenerateWindowPoSt(ctx context.Context, mid abi.ActorID, sectors []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.WindowPoStResult, error) //perm:admin
+
 	// storiface.WorkerCalls
 	AddPiece(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (storiface.CallID, error)                    //perm:admin
 	SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storiface.CallID, error)                                                           //perm:admin
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	address "github.com/filecoin-project/go-address"
 	bitfield "github.com/filecoin-project/go-bitfield"
@@ -344,6 +345,21 @@ func (mr *MockFullNodeMockRecorder) ChainHead(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChainHead", reflect.TypeOf((*MockFullNode)(nil).ChainHead), arg0)
 }
 
+// ChainIndexBackfill mocks base method
+func (m *MockFullNode) ChainIndexBackfill(arg0 context.Context, arg1 types.TipSetKey, arg2 abi.ChainEpoch) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChainIndexBackfill", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChainIndexBackfill indicates an expected call of ChainIndexBackfill
+func (mr *MockFullNodeMockRecorder) ChainIndexBackfill(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChainIndexBackfill", reflect.TypeOf((*MockFullNode)(nil).ChainIndexBackfill), arg0, arg1, arg2)
+}
+
 // ChainNotify mocks base method
 func (m *MockFullNode) ChainNotify(arg0 context.Context) (<-chan []*api.HeadChange, error) {
 	m.ctrl.T.Helper()
@@ -890,6 +906,36 @@ func (mr *MockFullNodeMockRecorder) ID(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ID", reflect.TypeOf((*MockFullNode)(nil).ID), arg0)
 }
 
+// JournalQuery mocks base method
+func (m *MockFullNode) JournalQuery(arg0 context.Context, arg1, arg2 string, arg3, arg4 time.Time) ([]api.JournalEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JournalQuery", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]api.JournalEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JournalQuery indicates an expected call of JournalQuery
+func (mr *MockFullNodeMockRecorder) JournalQuery(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JournalQuery", reflect.TypeOf((*MockFullNode)(nil).JournalQuery), arg0, arg1, arg2, arg3, arg4)
+}
+
+// JournalSubscribe mocks base method
+func (m *MockFullNode) JournalSubscribe(arg0 context.Context, arg1, arg2 string) (<-chan api.JournalEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JournalSubscribe", arg0, arg1, arg2)
+	ret0, _ := ret[0].(<-chan api.JournalEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JournalSubscribe indicates an expected call of JournalSubscribe
+func (mr *MockFullNodeMockRecorder) JournalSubscribe(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JournalSubscribe", reflect.TypeOf((*MockFullNode)(nil).JournalSubscribe), arg0, arg1, arg2)
+}
+
 // LogList mocks base method
 func (m *MockFullNode) LogList(arg0 context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -1975,6 +2021,21 @@ func (mr *MockFullNodeMockRecorder) StateAccountKey(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateAccountKey", reflect.TypeOf((*MockFullNode)(nil).StateAccountKey), arg0, arg1, arg2)
 }
 
+// StateAddressHistory mocks base method
+func (m *MockFullNode) StateAddressHistory(arg0 context.Context, arg1 address.Address, arg2 string, arg3 int) (*api.AddressHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StateAddressHistory", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*api.AddressHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StateAddressHistory indicates an expected call of StateAddressHistory
+func (mr *MockFullNodeMockRecorder) StateAddressHistory(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateAddressHistory", reflect.TypeOf((*MockFullNode)(nil).StateAddressHistory), arg0, arg1, arg2, arg3)
+}
+
 // StateAllMinerFaults mocks base method
 func (m *MockFullNode) StateAllMinerFaults(arg0 context.Context, arg1 abi.ChainEpoch, arg2 types.TipSetKey) ([]*api.Fault, error) {
 	m.ctrl.T.Helper()
@@ -2440,6 +2501,21 @@ func (mr *MockFullNodeMockRecorder) StateMinerSectors(arg0, arg1, arg2, arg3 int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateMinerSectors", reflect.TypeOf((*MockFullNode)(nil).StateMinerSectors), arg0, arg1, arg2, arg3)
 }
 
+// StateMinerVestingSchedule mocks base method
+func (m *MockFullNode) StateMinerVestingSchedule(arg0 context.Context, arg1 address.Address, arg2 types.TipSetKey) (*api.MinerVestingSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StateMinerVestingSchedule", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*api.MinerVestingSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StateMinerVestingSchedule indicates an expected call of StateMinerVestingSchedule
+func (mr *MockFullNodeMockRecorder) StateMinerVestingSchedule(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateMinerVestingSchedule", reflect.TypeOf((*MockFullNode)(nil).StateMinerVestingSchedule), arg0, arg1, arg2)
+}
+
 // StateNetworkName mocks base method
 func (m *MockFullNode) StateNetworkName(arg0 context.Context) (dtypes.NetworkName, error) {
 	m.ctrl.T.Helper()
@@ -2575,6 +2651,21 @@ func (mr *MockFullNodeMockRecorder) StateSectorPreCommitInfo(arg0, arg1, arg2, a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateSectorPreCommitInfo", reflect.TypeOf((*MockFullNode)(nil).StateSectorPreCommitInfo), arg0, arg1, arg2, arg3)
 }
 
+// StateSupply mocks base method
+func (m *MockFullNode) StateSupply(arg0 context.Context, arg1 types.TipSetKey) (*api.NetworkSupply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StateSupply", arg0, arg1)
+	ret0, _ := ret[0].(*api.NetworkSupply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StateSupply indicates an expected call of StateSupply
+func (mr *MockFullNodeMockRecorder) StateSupply(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateSupply", reflect.TypeOf((*MockFullNode)(nil).StateSupply), arg0, arg1)
+}
+
 // StateThisEpochReward mocks base method
 func (m *MockFullNode) StateThisEpochReward(arg0 context.Context, arg1 types.TipSetKey) (*big.Int, error) {
 	m.ctrl.T.Helper()
@@ -2914,6 +3005,20 @@ func (mr *MockFullNodeMockRecorder) WalletList(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WalletList", reflect.TypeOf((*MockFullNode)(nil).WalletList), arg0)
 }
 
+// WalletLock mocks base method
+func (m *MockFullNode) WalletLock(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WalletLock", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WalletLock indicates an expected call of WalletLock
+func (mr *MockFullNodeMockRecorder) WalletLock(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WalletLock", reflect.TypeOf((*MockFullNode)(nil).WalletLock), arg0)
+}
+
 // WalletNew mocks base method
 func (m *MockFullNode) WalletNew(arg0 context.Context, arg1 types.KeyType) (address.Address, error) {
 	m.ctrl.T.Helper()
@@ -2973,6 +3078,20 @@ func (mr *MockFullNodeMockRecorder) WalletSignMessage(arg0, arg1, arg2 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WalletSignMessage", reflect.TypeOf((*MockFullNode)(nil).WalletSignMessage), arg0, arg1, arg2)
 }
 
+// WalletUnlock mocks base method
+func (m *MockFullNode) WalletUnlock(arg0 context.Context, arg1  string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WalletUnlock", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WalletUnlock indicates an expected call of WalletUnlock
+func (mr *MockFullNodeMockRecorder) WalletUnlock(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WalletUnlock", reflect.TypeOf((*MockFullNode)(nil).WalletUnlock), arg0, arg1)
+}
+
 // WalletValidateAddress mocks base method
 func (m *MockFullNode) WalletValidateAddress(arg0 context.Context, arg1 string) (address.Address, error) {
 	m.ctrl.T.Helper()
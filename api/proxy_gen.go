@@ -29,6 +29,7 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
@@ -62,6 +63,10 @@ type CommonStruct struct {
 
 		ID func(p0 context.Context) (peer.ID, error) `perm:"read"`
 
+		JournalQuery func(p0 context.Context, p1 string, p2 string, p3 time.Time, p4 time.Time) ([]JournalEvent, error) `perm:"read"`
+
+		JournalSubscribe func(p0 context.Context, p1 string, p2 string) (<-chan JournalEvent, error) `perm:"read"`
+
 		LogList func(p0 context.Context) ([]string, error) `perm:"write"`
 
 		LogSetLevel func(p0 context.Context, p1 string, p2 string) error `perm:"write"`
@@ -147,6 +152,8 @@ type FullNodeStruct struct {
 
 		ChainHead func(p0 context.Context) (*types.TipSet, error) `perm:"read"`
 
+		ChainIndexBackfill func(p0 context.Context, p1 types.TipSetKey, p2 abi.ChainEpoch) (int, error) `perm:"admin"`
+
 		ChainNotify func(p0 context.Context) (<-chan []*HeadChange, error) `perm:"read"`
 
 		ChainReadObj func(p0 context.Context, p1 cid.Cid) ([]byte, error) `perm:"read"`
@@ -321,6 +328,8 @@ type FullNodeStruct struct {
 
 		StateAccountKey func(p0 context.Context, p1 address.Address, p2 types.TipSetKey) (address.Address, error) `perm:"read"`
 
+		StateAddressHistory func(p0 context.Context, p1 address.Address, p2 string, p3 int) (*AddressHistory, error) `perm:"read"`
+
 		StateAllMinerFaults func(p0 context.Context, p1 abi.ChainEpoch, p2 types.TipSetKey) ([]*Fault, error) `perm:"read"`
 
 		StateCall func(p0 context.Context, p1 *types.Message, p2 types.TipSetKey) (*InvocResult, error) `perm:"read"`
@@ -383,6 +392,8 @@ type FullNodeStruct struct {
 
 		StateMinerSectors func(p0 context.Context, p1 address.Address, p2 *bitfield.BitField, p3 types.TipSetKey) ([]*miner.SectorOnChainInfo, error) `perm:"read"`
 
+		StateMinerVestingSchedule func(p0 context.Context, p1 address.Address, p2 types.TipSetKey) (*MinerVestingSchedule, error) `perm:"read"`
+
 		StateNetworkName func(p0 context.Context) (dtypes.NetworkName, error) `perm:"read"`
 
 		StateNetworkVersion func(p0 context.Context, p1 types.TipSetKey) (apitypes.NetworkVersion, error) `perm:"read"`
@@ -401,6 +412,8 @@ type FullNodeStruct struct {
 
 		StateSectorPreCommitInfo func(p0 context.Context, p1 address.Address, p2 abi.SectorNumber, p3 types.TipSetKey) (miner.SectorPreCommitOnChainInfo, error) `perm:"read"`
 
+		StateSupply func(p0 context.Context, p1 types.TipSetKey) (*NetworkSupply, error) `perm:"read"`
+
 		StateThisEpochReward func(p0 context.Context, p1 types.TipSetKey) (*abi.TokenAmount, error) `perm:"read"`
 
 		StateVMCirculatingSupplyInternal func(p0 context.Context, p1 types.TipSetKey) (CirculatingSupply, error) `perm:"read"`
@@ -445,6 +458,8 @@ type FullNodeStruct struct {
 
 		WalletList func(p0 context.Context) ([]address.Address, error) `perm:"write"`
 
+		WalletLock func(p0 context.Context) error `perm:"admin"`
+
 		WalletNew func(p0 context.Context, p1 types.KeyType) (address.Address, error) `perm:"write"`
 
 		WalletSetDefault func(p0 context.Context, p1 address.Address) error `perm:"write"`
@@ -453,6 +468,8 @@ type FullNodeStruct struct {
 
 		WalletSignMessage func(p0 context.Context, p1 address.Address, p2 *types.Message) (*types.SignedMessage, error) `perm:"sign"`
 
+		WalletUnlock func(p0 context.Context, p1 string) error `perm:"admin"`
+
 		WalletValidateAddress func(p0 context.Context, p1 string) (address.Address, error) `perm:"read"`
 
 		WalletVerify func(p0 context.Context, p1 address.Address, p2 []byte, p3 *crypto.Signature) (bool, error) `perm:"read"`
@@ -545,8 +562,12 @@ type StorageMinerStruct struct {
 
 		ActorAddressConfig func(p0 context.Context) (AddressConfig, error) `perm:"read"`
 
+		ActorControlStatus func(p0 context.Context) (ControlStatus, error) `perm:"read"`
+
 		ActorSectorSize func(p0 context.Context, p1 address.Address) (abi.SectorSize, error) `perm:"read"`
 
+		ActorTreasuryStatus func(p0 context.Context) (TreasuryStatus, error) `perm:"read"`
+
 		CheckProvable func(p0 context.Context, p1 abi.RegisteredPoStProof, p2 []storage.SectorRef, p3 bool) (map[abi.SectorNumber]string, error) `perm:"admin"`
 
 		ComputeProof func(p0 context.Context, p1 []builtin.SectorInfo, p2 abi.PoStRandomness) ([]builtin.PoStProof, error) `perm:"read"`
@@ -627,6 +648,10 @@ type StorageMinerStruct struct {
 
 		PledgeSector func(p0 context.Context) (abi.SectorID, error) `perm:"write"`
 
+		ProvingScrubSectors func(p0 context.Context, p1 []abi.SectorNumber) error `perm:"admin"`
+
+		ProvingScrubStatus func(p0 context.Context) ([]SectorScrubResult, error) `perm:"read"`
+
 		ReturnAddPiece func(p0 context.Context, p1 storiface.CallID, p2 abi.PieceInfo, p3 *storiface.CallError) error `perm:"admin"`
 
 		ReturnFetch func(p0 context.Context, p1 storiface.CallID, p2 *storiface.CallError) error `perm:"admin"`
@@ -705,6 +730,10 @@ type StorageMinerStruct struct {
 
 		StorageLock func(p0 context.Context, p1 abi.SectorID, p2 storiface.SectorFileType, p3 storiface.SectorFileType) error `perm:"admin"`
 
+		StorageMigrateSector func(p0 context.Context, p1 abi.SectorNumber, p2 string) error `perm:"admin"`
+
+		StorageMigrations func(p0 context.Context) ([]StorageMigration, error) `perm:"admin"`
+
 		StorageReportHealth func(p0 context.Context, p1 stores.ID, p2 stores.HealthReport) error `perm:"admin"`
 
 		StorageStat func(p0 context.Context, p1 stores.ID) (fsutil.FsStat, error) `perm:"admin"`
@@ -752,8 +781,12 @@ type WorkerStruct struct {
 
 		Fetch func(p0 context.Context, p1 storage.SectorRef, p2 storiface.SectorFileType, p3 storiface.PathType, p4 storiface.AcquireMode) (storiface.CallID, error) `perm:"admin"`
 
+		FetchProgress func(p0 context.Context) ([]storiface.FetchProgress, error) `perm:"admin"`
+
 		FinalizeSector func(p0 context.Context, p1 storage.SectorRef, p2 []storage.Range) (storiface.CallID, error) `perm:"admin"`
 
+		GenerateWindowPoSt func(p0 context.Context, p1 abi.ActorID, p2 []proof2.SectorInfo, p3 abi.PoStRandomness) (storiface.WindowPoStResult, error) `perm:"admin"`
+
 		Info func(p0 context.Context) (storiface.WorkerInfo, error) `perm:"admin"`
 
 		MoveStorage func(p0 context.Context, p1 storage.SectorRef, p2 storiface.SectorFileType) (storiface.CallID, error) `perm:"admin"`
@@ -855,6 +888,22 @@ func (s *CommonStub) ID(p0 context.Context) (peer.ID, error) {
 	return *new(peer.ID), xerrors.New("method not supported")
 }
 
+func (s *CommonStruct) JournalQuery(p0 context.Context, p1 string, p2 string, p3 time.Time, p4 time.Time) ([]JournalEvent, error) {
+	return s.Internal.JournalQuery(p0, p1, p2, p3, p4)
+}
+
+func (s *CommonStub) JournalQuery(p0 context.Context, p1 string, p2 string, p3 time.Time, p4 time.Time) ([]JournalEvent, error) {
+	return *new([]JournalEvent), xerrors.New("method not supported")
+}
+
+func (s *CommonStruct) JournalSubscribe(p0 context.Context, p1 string, p2 string) (<-chan JournalEvent, error) {
+	return s.Internal.JournalSubscribe(p0, p1, p2)
+}
+
+func (s *CommonStub) JournalSubscribe(p0 context.Context, p1 string, p2 string) (<-chan JournalEvent, error) {
+	return nil, xerrors.New("method not supported")
+}
+
 func (s *CommonStruct) LogList(p0 context.Context) ([]string, error) {
 	return s.Internal.LogList(p0)
 }
@@ -1159,6 +1208,14 @@ func (s *FullNodeStub) ChainHead(p0 context.Context) (*types.TipSet, error) {
 	return nil, xerrors.New("method not supported")
 }
 
+func (s *FullNodeStruct) ChainIndexBackfill(p0 context.Context, p1 types.TipSetKey, p2 abi.ChainEpoch) (int, error) {
+	return s.Internal.ChainIndexBackfill(p0, p1, p2)
+}
+
+func (s *FullNodeStub) ChainIndexBackfill(p0 context.Context, p1 types.TipSetKey, p2 abi.ChainEpoch) (int, error) {
+	return 0, xerrors.New("method not supported")
+}
+
 func (s *FullNodeStruct) ChainNotify(p0 context.Context) (<-chan []*HeadChange, error) {
 	return s.Internal.ChainNotify(p0)
 }
@@ -1855,6 +1912,14 @@ func (s *FullNodeStub) StateAccountKey(p0 context.Context, p1 address.Address, p
 	return *new(address.Address), xerrors.New("method not supported")
 }
 
+func (s *FullNodeStruct) StateAddressHistory(p0 context.Context, p1 address.Address, p2 string, p3 int) (*AddressHistory, error) {
+	return s.Internal.StateAddressHistory(p0, p1, p2, p3)
+}
+
+func (s *FullNodeStub) StateAddressHistory(p0 context.Context, p1 address.Address, p2 string, p3 int) (*AddressHistory, error) {
+	return nil, xerrors.New("method not supported")
+}
+
 func (s *FullNodeStruct) StateAllMinerFaults(p0 context.Context, p1 abi.ChainEpoch, p2 types.TipSetKey) ([]*Fault, error) {
 	return s.Internal.StateAllMinerFaults(p0, p1, p2)
 }
@@ -2103,6 +2168,14 @@ func (s *FullNodeStub) StateMinerSectors(p0 context.Context, p1 address.Address,
 	return *new([]*miner.SectorOnChainInfo), xerrors.New("method not supported")
 }
 
+func (s *FullNodeStruct) StateMinerVestingSchedule(p0 context.Context, p1 address.Address, p2 types.TipSetKey) (*MinerVestingSchedule, error) {
+	return s.Internal.StateMinerVestingSchedule(p0, p1, p2)
+}
+
+func (s *FullNodeStub) StateMinerVestingSchedule(p0 context.Context, p1 address.Address, p2 types.TipSetKey) (*MinerVestingSchedule, error) {
+	return nil, xerrors.New("method not supported")
+}
+
 func (s *FullNodeStruct) StateNetworkName(p0 context.Context) (dtypes.NetworkName, error) {
 	return s.Internal.StateNetworkName(p0)
 }
@@ -2175,6 +2248,14 @@ func (s *FullNodeStub) StateSectorPreCommitInfo(p0 context.Context, p1 address.A
 	return *new(miner.SectorPreCommitOnChainInfo), xerrors.New("method not supported")
 }
 
+func (s *FullNodeStruct) StateSupply(p0 context.Context, p1 types.TipSetKey) (*NetworkSupply, error) {
+	return s.Internal.StateSupply(p0, p1)
+}
+
+func (s *FullNodeStub) StateSupply(p0 context.Context, p1 types.TipSetKey) (*NetworkSupply, error) {
+	return nil, xerrors.New("method not supported")
+}
+
 func (s *FullNodeStruct) StateThisEpochReward(p0 context.Context, p1 types.TipSetKey) (*abi.TokenAmount, error) {
 	return s.Internal.StateThisEpochReward(p0, p1)
 }
@@ -2351,6 +2432,14 @@ func (s *FullNodeStub) WalletList(p0 context.Context) ([]address.Address, error)
 	return *new([]address.Address), xerrors.New("method not supported")
 }
 
+func (s *FullNodeStruct) WalletLock(p0 context.Context) error {
+	return s.Internal.WalletLock(p0)
+}
+
+func (s *FullNodeStub) WalletLock(p0 context.Context) error {
+	return xerrors.New("method not supported")
+}
+
 func (s *FullNodeStruct) WalletNew(p0 context.Context, p1 types.KeyType) (address.Address, error) {
 	return s.Internal.WalletNew(p0, p1)
 }
@@ -2383,6 +2472,14 @@ func (s *FullNodeStub) WalletSignMessage(p0 context.Context, p1 address.Address,
 	return nil, xerrors.New("method not supported")
 }
 
+func (s *FullNodeStruct) WalletUnlock(p0 context.Context, p1 string) error {
+	return s.Internal.WalletUnlock(p0, p1)
+}
+
+func (s *FullNodeStub) WalletUnlock(p0 context.Context, p1 string) error {
+	return xerrors.New("method not supported")
+}
+
 func (s *FullNodeStruct) WalletValidateAddress(p0 context.Context, p1 string) (address.Address, error) {
 	return s.Internal.WalletValidateAddress(p0, p1)
 }
@@ -2655,6 +2752,14 @@ func (s *StorageMinerStub) ActorAddressConfig(p0 context.Context) (AddressConfig
 	return *new(AddressConfig), xerrors.New("method not supported")
 }
 
+func (s *StorageMinerStruct) ActorControlStatus(p0 context.Context) (ControlStatus, error) {
+	return s.Internal.ActorControlStatus(p0)
+}
+
+func (s *StorageMinerStub) ActorControlStatus(p0 context.Context) (ControlStatus, error) {
+	return *new(ControlStatus), xerrors.New("method not supported")
+}
+
 func (s *StorageMinerStruct) ActorSectorSize(p0 context.Context, p1 address.Address) (abi.SectorSize, error) {
 	return s.Internal.ActorSectorSize(p0, p1)
 }
@@ -2663,6 +2768,14 @@ func (s *StorageMinerStub) ActorSectorSize(p0 context.Context, p1 address.Addres
 	return *new(abi.SectorSize), xerrors.New("method not supported")
 }
 
+func (s *StorageMinerStruct) ActorTreasuryStatus(p0 context.Context) (TreasuryStatus, error) {
+	return s.Internal.ActorTreasuryStatus(p0)
+}
+
+func (s *StorageMinerStub) ActorTreasuryStatus(p0 context.Context) (TreasuryStatus, error) {
+	return *new(TreasuryStatus), xerrors.New("method not supported")
+}
+
 func (s *StorageMinerStruct) CheckProvable(p0 context.Context, p1 abi.RegisteredPoStProof, p2 []storage.SectorRef, p3 bool) (map[abi.SectorNumber]string, error) {
 	return s.Internal.CheckProvable(p0, p1, p2, p3)
 }
@@ -2983,6 +3096,22 @@ func (s *StorageMinerStub) PledgeSector(p0 context.Context) (abi.SectorID, error
 	return *new(abi.SectorID), xerrors.New("method not supported")
 }
 
+func (s *StorageMinerStruct) ProvingScrubSectors(p0 context.Context, p1 []abi.SectorNumber) error {
+	return s.Internal.ProvingScrubSectors(p0, p1)
+}
+
+func (s *StorageMinerStub) ProvingScrubSectors(p0 context.Context, p1 []abi.SectorNumber) error {
+	return xerrors.New("method not supported")
+}
+
+func (s *StorageMinerStruct) ProvingScrubStatus(p0 context.Context) ([]SectorScrubResult, error) {
+	return s.Internal.ProvingScrubStatus(p0)
+}
+
+func (s *StorageMinerStub) ProvingScrubStatus(p0 context.Context) ([]SectorScrubResult, error) {
+	return *new([]SectorScrubResult), xerrors.New("method not supported")
+}
+
 func (s *StorageMinerStruct) ReturnAddPiece(p0 context.Context, p1 storiface.CallID, p2 abi.PieceInfo, p3 *storiface.CallError) error {
 	return s.Internal.ReturnAddPiece(p0, p1, p2, p3)
 }
@@ -3295,6 +3424,22 @@ func (s *StorageMinerStub) StorageLock(p0 context.Context, p1 abi.SectorID, p2 s
 	return xerrors.New("method not supported")
 }
 
+func (s *StorageMinerStruct) StorageMigrateSector(p0 context.Context, p1 abi.SectorNumber, p2 string) error {
+	return s.Internal.StorageMigrateSector(p0, p1, p2)
+}
+
+func (s *StorageMinerStub) StorageMigrateSector(p0 context.Context, p1 abi.SectorNumber, p2 string) error {
+	return xerrors.New("method not supported")
+}
+
+func (s *StorageMinerStruct) StorageMigrations(p0 context.Context) ([]StorageMigration, error) {
+	return s.Internal.StorageMigrations(p0)
+}
+
+func (s *StorageMinerStub) StorageMigrations(p0 context.Context) ([]StorageMigration, error) {
+	return *new([]StorageMigration), xerrors.New("method not supported")
+}
+
 func (s *StorageMinerStruct) StorageReportHealth(p0 context.Context, p1 stores.ID, p2 stores.HealthReport) error {
 	return s.Internal.StorageReportHealth(p0, p1, p2)
 }
@@ -3423,6 +3568,14 @@ func (s *WorkerStub) Fetch(p0 context.Context, p1 storage.SectorRef, p2 storifac
 	return *new(storiface.CallID), xerrors.New("method not supported")
 }
 
+func (s *WorkerStruct) FetchProgress(p0 context.Context) ([]storiface.FetchProgress, error) {
+	return s.Internal.FetchProgress(p0)
+}
+
+func (s *WorkerStub) FetchProgress(p0 context.Context) ([]storiface.FetchProgress, error) {
+	return *new([]storiface.FetchProgress), xerrors.New("method not supported")
+}
+
 func (s *WorkerStruct) FinalizeSector(p0 context.Context, p1 storage.SectorRef, p2 []storage.Range) (storiface.CallID, error) {
 	return s.Internal.FinalizeSector(p0, p1, p2)
 }
@@ -3431,6 +3584,14 @@ func (s *WorkerStub) FinalizeSector(p0 context.Context, p1 storage.SectorRef, p2
 	return *new(storiface.CallID), xerrors.New("method not supported")
 }
 
+func (s *WorkerStruct) GenerateWindowPoSt(p0 context.Context, p1 abi.ActorID, p2 []proof2.SectorInfo, p3 abi.PoStRandomness) (storiface.WindowPoStResult, error) {
+	return s.Internal.GenerateWindowPoSt(p0, p1, p2, p3)
+}
+
+func (s *WorkerStub) GenerateWindowPoSt(p0 context.Context, p1 abi.ActorID, p2 []proof2.SectorInfo, p3 abi.PoStRandomness) (storiface.WindowPoStResult, error) {
+	return *new(storiface.WindowPoStResult), xerrors.New("method not supported")
+}
+
 func (s *WorkerStruct) Info(p0 context.Context) (storiface.WorkerInfo, error) {
 	return s.Internal.Info(p0)
 }
@@ -7,6 +7,7 @@ package v0mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	address "github.com/filecoin-project/go-address"
 	bitfield "github.com/filecoin-project/go-bitfield"
@@ -890,6 +891,36 @@ func (mr *MockFullNodeMockRecorder) ID(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ID", reflect.TypeOf((*MockFullNode)(nil).ID), arg0)
 }
 
+// JournalQuery mocks base method
+func (m *MockFullNode) JournalQuery(arg0 context.Context, arg1, arg2 string, arg3, arg4 time.Time) ([]api.JournalEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JournalQuery", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]api.JournalEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JournalQuery indicates an expected call of JournalQuery
+func (mr *MockFullNodeMockRecorder) JournalQuery(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JournalQuery", reflect.TypeOf((*MockFullNode)(nil).JournalQuery), arg0, arg1, arg2, arg3, arg4)
+}
+
+// JournalSubscribe mocks base method
+func (m *MockFullNode) JournalSubscribe(arg0 context.Context, arg1, arg2 string) (<-chan api.JournalEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JournalSubscribe", arg0, arg1, arg2)
+	ret0, _ := ret[0].(<-chan api.JournalEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JournalSubscribe indicates an expected call of JournalSubscribe
+func (mr *MockFullNodeMockRecorder) JournalSubscribe(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JournalSubscribe", reflect.TypeOf((*MockFullNode)(nil).JournalSubscribe), arg0, arg1, arg2)
+}
+
 // LogList mocks base method
 func (m *MockFullNode) LogList(arg0 context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -29,6 +29,9 @@ const (
 	DrandIncentinet
 )
 
+// DrandConfigs are the beacons networks can schedule in DrandSchedule. Entries
+// with Group set are beacon groups run by the network operators with
+// lotus-beacon, instead of drand networks.
 var DrandConfigs = map[DrandEnum]dtypes.DrandConfig{
 	DrandMainnet: {
 		Servers: []string{
@@ -43,6 +43,7 @@ var UpgradeActorsV4Height = abi.ChainEpoch(45)
 // release 13881
 var UpgradeActorsV5Height = abi.ChainEpoch(13881)
 var UpgradeActorsV6Height = abi.ChainEpoch(157820)
+var UpgradeActorsV7Height = abi.ChainEpoch(-1)
 const BlockDelaySecs = uint64(30)
 //test 55
 //var UpgradeActorsV5Height = abi.ChainEpoch(55)
@@ -90,6 +91,7 @@ func init() {
 	UpgradeActorsV4Height = getUpgradeHeight("LOTUS_ACTORSV4_HEIGHT", UpgradeActorsV4Height)
 	UpgradeActorsV5Height = getUpgradeHeight("LOTUS_ACTORSV5_HEIGHT", UpgradeActorsV5Height)
 	UpgradeActorsV6Height = getUpgradeHeight("LOTUS_ACTORSV5_HEIGHT", UpgradeActorsV6Height)
+	UpgradeActorsV7Height = getUpgradeHeight("LOTUS_ACTORSV7_HEIGHT", UpgradeActorsV7Height)
 
 	BuildType |= Build2k
 }
@@ -66,6 +66,9 @@ var UpgradeActorsV4Height = abi.ChainEpoch(712320)
 var UpgradeActorsV5Height = abi.ChainEpoch(712322)
 var UpgradeActorsV6Height = abi.ChainEpoch(712325)
 
+// miner beneficiaries, not scheduled yet
+var UpgradeActorsV7Height = abi.ChainEpoch(math.MaxInt64)
+
 
 func init() {
 	policy.SetConsensusMinerMinPower(abi.NewStoragePower(10 << 40))
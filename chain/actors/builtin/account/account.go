@@ -17,6 +17,7 @@ import (
 	builtin4 "github.com/filecoin-project/specs-actors/v4/actors/builtin"
 	builtin5 "github.com/filecoin-project/specs-actors/v5/actors/builtin"
 	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
 
 )
 
@@ -39,6 +40,9 @@ func init() {
 	builtin.RegisterActorState(builtin6.AccountActorCodeID, func(store adt.Store, root cid.Cid) (cbor.Marshaler, error) {
 		return load6(store, root)
 	})
+	builtin.RegisterActorState(builtin7.AccountActorCodeID, func(store adt.Store, root cid.Cid) (cbor.Marshaler, error) {
+		return load7(store, root)
+	})
 }
 
 var Methods = builtin4.MethodsAccount
@@ -57,6 +61,8 @@ func Load(store adt.Store, act *types.Actor) (State, error) {
 		return load5(store, act.Head)
 	case builtin6.AccountActorCodeID:
 		return load6(store, act.Head)
+	case builtin7.AccountActorCodeID:
+		return load7(store, act.Head)
 	}
 	return nil, xerrors.Errorf("unknown actor code %s", act.Code)
 }
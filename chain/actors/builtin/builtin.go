@@ -13,6 +13,7 @@ import (
 	builtin4 "github.com/filecoin-project/specs-actors/v4/actors/builtin"
 	builtin5 "github.com/filecoin-project/specs-actors/v5/actors/builtin"
 	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
 
 	"github.com/filecoin-project/lotus/chain/actors/adt"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -25,6 +26,7 @@ import (
 	smoothing4 "github.com/filecoin-project/specs-actors/v4/actors/util/smoothing"
 	smoothing5 "github.com/filecoin-project/specs-actors/v5/actors/util/smoothing"
 	smoothing6 "github.com/filecoin-project/specs-actors/v6/actors/util/smoothing"
+	smoothing7 "github.com/filecoin-project/specs-actors/v7/actors/util/smoothing"
 )
 
 var SystemActorAddr = builtin0.SystemActorAddr
@@ -84,6 +86,10 @@ func FromV6FilterEstimate(v6 smoothing6.FilterEstimate) FilterEstimate {
 	return (FilterEstimate)(v6)
 }
 
+func FromV7FilterEstimate(v7 smoothing7.FilterEstimate) FilterEstimate {
+	return (FilterEstimate)(v7)
+}
+
 type ActorStateLoader func(store adt.Store, root cid.Cid) (cbor.Marshaler, error)
 
 var ActorStateLoaders = make(map[cid.Cid]ActorStateLoader)
@@ -114,6 +120,8 @@ func ActorNameByCode(c cid.Cid) string {
 		return builtin5.ActorNameByCode(c)
 	case builtin6.IsBuiltinActor(c):
 		return builtin6.ActorNameByCode(c)
+	case builtin7.IsBuiltinActor(c):
+		return builtin7.ActorNameByCode(c)
 	default:
 		return "<unknown>"
 	}
@@ -125,7 +133,8 @@ func IsBuiltinActor(c cid.Cid) bool {
 		builtin3.IsBuiltinActor(c) ||
 		builtin4.IsBuiltinActor(c) ||
 		builtin5.IsBuiltinActor(c) ||
-		builtin6.IsBuiltinActor(c)
+		builtin6.IsBuiltinActor(c) ||
+		builtin7.IsBuiltinActor(c)
 }
 
 func IsAccountActor(c cid.Cid) bool {
@@ -134,7 +143,8 @@ func IsAccountActor(c cid.Cid) bool {
 		c == builtin3.AccountActorCodeID ||
 		c == builtin4.AccountActorCodeID ||
 		c == builtin5.AccountActorCodeID ||
-		c == builtin6.AccountActorCodeID
+		c == builtin6.AccountActorCodeID ||
+		c == builtin7.AccountActorCodeID
 }
 
 func IsStorageMinerActor(c cid.Cid) bool {
@@ -143,7 +153,8 @@ func IsStorageMinerActor(c cid.Cid) bool {
 		c == builtin3.StorageMinerActorCodeID ||
 		c == builtin4.StorageMinerActorCodeID ||
 		c == builtin5.StorageMinerActorCodeID ||
-		c == builtin6.StorageMinerActorCodeID
+		c == builtin6.StorageMinerActorCodeID ||
+		c == builtin7.StorageMinerActorCodeID
 }
 
 func IsMultisigActor(c cid.Cid) bool {
@@ -152,7 +163,8 @@ func IsMultisigActor(c cid.Cid) bool {
 		c == builtin3.MultisigActorCodeID ||
 		c == builtin4.MultisigActorCodeID ||
 		c == builtin5.MultisigActorCodeID ||
-		c == builtin6.MultisigActorCodeID
+		c == builtin6.MultisigActorCodeID ||
+		c == builtin7.MultisigActorCodeID
 }
 
 func IsPaymentChannelActor(c cid.Cid) bool {
@@ -161,7 +173,8 @@ func IsPaymentChannelActor(c cid.Cid) bool {
 		c == builtin3.PaymentChannelActorCodeID ||
 		c == builtin4.PaymentChannelActorCodeID ||
 		c == builtin5.PaymentChannelActorCodeID ||
-		c == builtin6.PaymentChannelActorCodeID
+		c == builtin6.PaymentChannelActorCodeID ||
+		c == builtin7.PaymentChannelActorCodeID
 }
 
 func makeAddress(addr string) address.Address {
@@ -19,6 +19,7 @@ import (
 	builtin4 "github.com/filecoin-project/specs-actors/v4/actors/builtin"
 	builtin5 "github.com/filecoin-project/specs-actors/v5/actors/builtin"
 	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
 
 )
 
@@ -41,6 +42,9 @@ func init() {
 	builtin.RegisterActorState(builtin6.InitActorCodeID, func(store adt.Store, root cid.Cid) (cbor.Marshaler, error) {
 		return load6(store, root)
 	})
+	builtin.RegisterActorState(builtin7.InitActorCodeID, func(store adt.Store, root cid.Cid) (cbor.Marshaler, error) {
+		return load7(store, root)
+	})
 }
 
 var (
@@ -62,6 +66,8 @@ func Load(store adt.Store, act *types.Actor) (State, error) {
 		return load5(store, act.Head)
 	case builtin6.InitActorCodeID:
 		return load6(store, act.Head)
+	case builtin7.InitActorCodeID:
+		return load7(store, act.Head)
 	}
 
 	return nil, xerrors.Errorf("unknown actor code %s", act.Code)
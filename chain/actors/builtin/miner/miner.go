@@ -27,6 +27,9 @@ import (
 	builtin4 "github.com/filecoin-project/specs-actors/v4/actors/builtin"
 	builtin5 "github.com/filecoin-project/specs-actors/v5/actors/builtin"
 	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
 
 )
 
@@ -49,6 +52,9 @@ func init() {
 	builtin.RegisterActorState(builtin6.StorageMinerActorCodeID, func(store adt.Store, root cid.Cid) (cbor.Marshaler, error) {
 		return load6(store, root)
 	})
+	builtin.RegisterActorState(builtin7.StorageMinerActorCodeID, func(store adt.Store, root cid.Cid) (cbor.Marshaler, error) {
+		return load7(store, root)
+	})
 }
 
 var Methods = builtin4.MethodsMiner
@@ -81,6 +87,8 @@ func Load(store adt.Store, act *types.Actor) (st State, err error) {
 		return load5(store, act.Head)
 	case builtin6.StorageMinerActorCodeID:
 		return load6(store, act.Head)
+	case builtin7.StorageMinerActorCodeID:
+		return load7(store, act.Head)
 	}
 	return nil, xerrors.Errorf("unknown actor code %s", act.Code)
 }
@@ -95,6 +103,10 @@ type State interface {
 	// Funds locked for various reasons.
 	LockedFunds() (LockedFunds, error)
 	FeeDebt() (abi.TokenAmount, error)
+	// Entries of the reward vesting table.
+	VestingFunds() ([]VestingFund, error)
+	// Entries of the PoS vesting table. Empty before v5.
+	PosVestingFunds() ([]VestingFund, error)
 
 	GetSector(abi.SectorNumber) (*SectorOnChainInfo, error)
 	FindSector(abi.SectorNumber) (*SectorLocation, error)
@@ -173,6 +185,13 @@ type DeclareFaultsRecoveredParams = miner0.DeclareFaultsRecoveredParams
 type SubmitWindowedPoStParams = miner0.SubmitWindowedPoStParams
 type ProveCommitSectorParams = miner0.ProveCommitSectorParams
 type DisputeWindowedPoStParams = miner3.DisputeWindowedPoStParams
+type ChangeBeneficiaryParams = miner7.ChangeBeneficiaryParams
+type WithdrawBalanceParams = miner0.WithdrawBalanceParams
+type AddPosParams = miner6.AddPosParams
+
+// Beneficiaries were added to v7 actors, before which the owner is the beneficiary
+type BeneficiaryTerm = miner7.BeneficiaryTerm
+type PendingBeneficiaryChange = miner7.PendingBeneficiaryChange
 
 func PreferredSealProofTypeFromWindowPoStType(nver network.Version, proof abi.RegisteredPoStProof) (abi.RegisteredSealProof, error) {
 	// We added support for the new proofs in network version 7, and removed support for the old
@@ -245,6 +264,9 @@ type MinerInfo struct {
 	SectorSize                 abi.SectorSize
 	WindowPoStPartitionSectors uint64
 	ConsensusFaultElapsed      abi.ChainEpoch
+	Beneficiary                address.Address // Must be an ID-address.
+	BeneficiaryTerm            BeneficiaryTerm
+	PendingBeneficiaryTerm     *PendingBeneficiaryChange
 }
 
 func (mi MinerInfo) IsController(addr address.Address) bool {
@@ -300,3 +322,9 @@ type LockedFunds struct {
 func (lf LockedFunds) TotalLockedFunds() abi.TokenAmount {
 	return big.Add(lf.VestingFunds, big.Add(lf.InitialPledgeRequirement, lf.PreCommitDeposits))
 }
+
+// VestingFund is an amount that unlocks at the given epoch.
+type VestingFund struct {
+	Epoch  abi.ChainEpoch
+	Amount abi.TokenAmount
+}
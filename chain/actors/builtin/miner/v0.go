@@ -84,6 +84,24 @@ func (s *state0) PreCommitDeposits() (abi.TokenAmount, error) {
 	return s.State.PreCommitDeposits, nil
 }
 
+func (s *state0) VestingFunds() ([]VestingFund, error) {
+	vf, err := s.State.LoadVestingFunds(s.store)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]VestingFund, 0, len(vf.Funds))
+	for _, f := range vf.Funds {
+		out = append(out, VestingFund{Epoch: f.Epoch, Amount: f.Amount})
+	}
+	return out, nil
+}
+
+func (s *state0) PosVestingFunds() ([]VestingFund, error) {
+	// there is no PoS vesting table before v5
+	return nil, nil
+}
+
 func (s *state0) GetSector(num abi.SectorNumber) (*SectorOnChainInfo, error) {
 	info, ok, err := s.State.GetSector(s.store, num)
 	if !ok || err != nil {
@@ -317,6 +335,12 @@ func (s *state0) Info() (MinerInfo, error) {
 		SectorSize:                 info.SectorSize,
 		WindowPoStPartitionSectors: info.WindowPoStPartitionSectors,
 		ConsensusFaultElapsed:      -1,
+
+		Beneficiary: info.Owner,
+		BeneficiaryTerm: BeneficiaryTerm{
+			Quota:     big.Zero(),
+			UsedQuota: big.Zero(),
+		},
 	}
 
 	if info.PendingWorkerKey != nil {
@@ -7,6 +7,7 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/dline"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -87,6 +88,24 @@ func (s *state4) PosDeposits() (abi.TokenAmount, error) {
 	return s.State.PosDeposits, nil
 }
 
+func (s *state4) VestingFunds() ([]VestingFund, error) {
+	vf, err := s.State.LoadVestingFunds(s.store)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]VestingFund, 0, len(vf.Funds))
+	for _, f := range vf.Funds {
+		out = append(out, VestingFund{Epoch: f.Epoch, Amount: f.Amount})
+	}
+	return out, nil
+}
+
+func (s *state4) PosVestingFunds() ([]VestingFund, error) {
+	// there is no PoS vesting table before v5
+	return nil, nil
+}
+
 func (s *state4) GetSector(num abi.SectorNumber) (*SectorOnChainInfo, error) {
 	info, ok, err := s.State.GetSector(s.store, num)
 	if !ok || err != nil {
@@ -316,6 +335,12 @@ func (s *state4) Info() (MinerInfo, error) {
 		SectorSize:                 info.SectorSize,
 		WindowPoStPartitionSectors: info.WindowPoStPartitionSectors,
 		ConsensusFaultElapsed:      info.ConsensusFaultElapsed,
+
+		Beneficiary: info.Owner,
+		BeneficiaryTerm: BeneficiaryTerm{
+			Quota:     big.Zero(),
+			UsedQuota: big.Zero(),
+		},
 	}
 
 	if info.PendingWorkerKey != nil {
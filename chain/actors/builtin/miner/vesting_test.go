@@ -0,0 +1,116 @@
+package miner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	builtin5 "github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	miner5 "github.com/filecoin-project/specs-actors/v5/actors/builtin/miner"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
+
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var (
+	testVesting = []VestingFund{
+		{Epoch: 100, Amount: abi.NewTokenAmount(10)},
+		{Epoch: 200, Amount: abi.NewTokenAmount(20)},
+	}
+	testPosVesting = []VestingFund{
+		{Epoch: 150, Amount: abi.NewTokenAmount(5)},
+		{Epoch: 300, Amount: abi.NewTokenAmount(7)},
+	}
+)
+
+func testStore() adt.Store {
+	return adt.WrapStore(context.Background(), cbor.NewCborStore(blockstore.NewMemory()))
+}
+
+func testOwner(t *testing.T) address.Address {
+	owner, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	return owner
+}
+
+func TestVestingFundsV5(t *testing.T) {
+	store := testStore()
+	owner := testOwner(t)
+
+	info, err := miner5.ConstructMinerInfo(owner, owner, nil, []byte("peer"), nil, abi.RegisteredPoStProof_StackedDrgWindow2KiBV1)
+	require.NoError(t, err)
+	infoCid, err := store.Put(store.Context(), info)
+	require.NoError(t, err)
+
+	st, err := miner5.ConstructState(store, infoCid, 0, 0)
+	require.NoError(t, err)
+
+	vf := miner5.ConstructVestingFunds()
+	for _, f := range testVesting {
+		vf.Funds = append(vf.Funds, miner5.VestingFund{Epoch: f.Epoch, Amount: f.Amount})
+	}
+	require.NoError(t, st.SaveVestingFunds(store, vf))
+
+	pvf := &miner5.PosVestingFunds{}
+	for _, f := range testPosVesting {
+		pvf.Funds = append(pvf.Funds, miner5.PosVestingFund{Epoch: f.Epoch, Amount: f.Amount})
+	}
+	require.NoError(t, st.SavePosVestingFunds(store, pvf))
+
+	requireVestingTables(t, store, builtin5.StorageMinerActorCodeID, st)
+}
+
+func TestVestingFundsV6(t *testing.T) {
+	store := testStore()
+	owner := testOwner(t)
+
+	info, err := miner6.ConstructMinerInfo(owner, owner, nil, []byte("peer"), nil, abi.RegisteredPoStProof_StackedDrgWindow2KiBV1)
+	require.NoError(t, err)
+	infoCid, err := store.Put(store.Context(), info)
+	require.NoError(t, err)
+
+	st, err := miner6.ConstructState(store, infoCid, 0, 0)
+	require.NoError(t, err)
+
+	vf := miner6.ConstructVestingFunds()
+	for _, f := range testVesting {
+		vf.Funds = append(vf.Funds, miner6.VestingFund{Epoch: f.Epoch, Amount: f.Amount})
+	}
+	require.NoError(t, st.SaveVestingFunds(store, vf))
+
+	pvf := &miner6.PosVestingFunds{}
+	for _, f := range testPosVesting {
+		pvf.Funds = append(pvf.Funds, miner6.PosVestingFund{Epoch: f.Epoch, Amount: f.Amount})
+	}
+	require.NoError(t, st.SavePosVestingFunds(store, pvf))
+
+	requireVestingTables(t, store, builtin6.StorageMinerActorCodeID, st)
+}
+
+// requireVestingTables loads the miner state through the version agnostic
+// wrapper, and checks both vesting tables are read from their own tables
+func requireVestingTables(t *testing.T, store adt.Store, code cid.Cid, st interface{}) {
+	head, err := store.Put(store.Context(), st)
+	require.NoError(t, err)
+
+	mas, err := Load(store, &types.Actor{Code: code, Head: head, Balance: big.Zero()})
+	require.NoError(t, err)
+
+	vesting, err := mas.VestingFunds()
+	require.NoError(t, err)
+	require.Equal(t, testVesting, vesting)
+
+	posVesting, err := mas.PosVestingFunds()
+	require.NoError(t, err)
+	require.Equal(t, testPosVesting, posVesting)
+}
@@ -32,6 +32,8 @@ func Message(version actors.Version, from address.Address) MessageBuilder {
 		return message5{message0{from}}
 	case actors.Version6:
 		return message6{message0{from}}
+	case actors.Version7:
+		return message7{message0{from}}
 	default:
 		panic(fmt.Sprintf("unsupported actors version: %d", version))
 	}
@@ -27,6 +27,8 @@ func Message(version actors.Version, from address.Address) MessageBuilder {
 		return message5{from}
 	case actors.Version6:
 		return message6{from}
+	case actors.Version7:
+		return message7{from}
 	default:
 		panic(fmt.Sprintf("unsupported actors version: %d", version))
 	}
@@ -1,6 +1,11 @@
 package policy
 
 import (
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	market7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	verifreg7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+
 	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
 	market6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/market"
 	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
@@ -72,6 +77,10 @@ func SetSupportedProofTypes(types ...abi.RegisteredSealProof) {
 	miner6.PreCommitSealProofTypesV0 = make(map[abi.RegisteredSealProof]struct{}, len(types))
 	miner6.PreCommitSealProofTypesV7 = make(map[abi.RegisteredSealProof]struct{}, len(types)*2)
 	miner6.PreCommitSealProofTypesV8 = make(map[abi.RegisteredSealProof]struct{}, len(types))
+
+	miner7.PreCommitSealProofTypesV0 = make(map[abi.RegisteredSealProof]struct{}, len(types))
+	miner7.PreCommitSealProofTypesV7 = make(map[abi.RegisteredSealProof]struct{}, len(types)*2)
+	miner7.PreCommitSealProofTypesV8 = make(map[abi.RegisteredSealProof]struct{}, len(types))
 	AddSupportedProofTypes(types...)
 }
 
@@ -109,6 +118,11 @@ func AddSupportedProofTypes(types ...abi.RegisteredSealProof) {
 		miner6.PreCommitSealProofTypesV7[t] = struct{}{}
 		miner6.PreCommitSealProofTypesV7[t+abi.RegisteredSealProof_StackedDrg2KiBV1_1] = struct{}{}
 		miner6.PreCommitSealProofTypesV8[t+abi.RegisteredSealProof_StackedDrg2KiBV1_1] = struct{}{}
+
+		miner7.PreCommitSealProofTypesV0[t] = struct{}{}
+		miner7.PreCommitSealProofTypesV7[t] = struct{}{}
+		miner7.PreCommitSealProofTypesV7[t+abi.RegisteredSealProof_StackedDrg2KiBV1_1] = struct{}{}
+		miner7.PreCommitSealProofTypesV8[t+abi.RegisteredSealProof_StackedDrg2KiBV1_1] = struct{}{}
 	}
 }
 
@@ -122,6 +136,7 @@ func SetPreCommitChallengeDelay(delay abi.ChainEpoch) {
 	miner4.PreCommitChallengeDelay = delay
 	miner5.PreCommitChallengeDelay = delay
 	miner6.PreCommitChallengeDelay = delay
+	miner7.PreCommitChallengeDelay = delay
 }
 
 // TODO: this function shouldn't really exist. Instead, the API should expose the precommit delay.
@@ -151,6 +166,9 @@ func SetConsensusMinerMinPower(p abi.StoragePower) {
 	for _, policy := range builtin6.PoStProofPolicies {
 		policy.ConsensusMinerMinPower = p
 	}
+	for _, policy := range builtin7.PoStProofPolicies {
+		policy.ConsensusMinerMinPower = p
+	}
 }
 
 // SetMinVerifiedDealSize sets the minimum size of a verified deal. This should
@@ -162,6 +180,7 @@ func SetMinVerifiedDealSize(size abi.StoragePower) {
 	verifreg4.MinVerifiedDealSize = size
 	verifreg5.MinVerifiedDealSize = size
 	verifreg6.MinVerifiedDealSize = size
+	verifreg7.MinVerifiedDealSize = size
 }
 
 func GetMaxProveCommitDuration(ver actors.Version, t abi.RegisteredSealProof) abi.ChainEpoch {
@@ -178,6 +197,8 @@ func GetMaxProveCommitDuration(ver actors.Version, t abi.RegisteredSealProof) ab
 		return miner5.MaxProveCommitDuration[t]
 	case actors.Version6:
 		return miner6.MaxProveCommitDuration[t]
+	case actors.Version7:
+		return miner7.MaxProveCommitDuration[t]
 	default:
 		panic("unsupported actors version")
 	}
@@ -201,6 +222,8 @@ func DealProviderCollateralBounds(
 		return market5.DealProviderCollateralBounds(size, verified, rawBytePower, qaPower, baselinePower, circulatingFil)
 	case actors.Version6:
 		return market6.DealProviderCollateralBounds(size, verified, rawBytePower, qaPower, baselinePower, circulatingFil)
+	case actors.Version7:
+		return market7.DealProviderCollateralBounds(size, verified, rawBytePower, qaPower, baselinePower, circulatingFil)
 	default:
 		panic("unsupported actors version")
 	}
@@ -236,6 +259,10 @@ func SetWPoStChallengeWindow(period abi.ChainEpoch) {
 	miner6.WPoStChallengeWindow = period
 	miner6.WPoStProvingPeriod = period * abi.ChainEpoch(miner4.WPoStPeriodDeadlines)
 	miner6.WPoStDisputeWindow = period * 30 // see the miner3 comment
+
+	miner7.WPoStChallengeWindow = period
+	miner7.WPoStProvingPeriod = period * abi.ChainEpoch(miner4.WPoStPeriodDeadlines)
+	miner7.WPoStDisputeWindow = period * 30 // see the miner3 comment
 }
 
 func GetWinningPoStSectorSetLookback(nwVer network.Version) abi.ChainEpoch {
@@ -300,6 +327,8 @@ func GetAddressedSectorsMax(nwVer network.Version) int {
 		return miner5.AddressedSectorsMax
 	case actors.Version6:
 		return miner6.AddressedSectorsMax
+	case actors.Version7:
+		return miner7.AddressedSectorsMax
 	default:
 		panic("unsupported network version")
 	}
@@ -320,6 +349,8 @@ func GetDeclarationsMax(nwVer network.Version) int {
 		return miner5.DeclarationsMax
 	case actors.Version6:
 		return miner6.DeclarationsMax
+	case actors.Version7:
+		return miner7.DeclarationsMax
 	default:
 		panic("unsupported network version")
 	}
@@ -15,6 +15,7 @@ const (
 	Version4 Version = 4
 	Version5 Version = 5
 	Version6 Version = 6
+	Version7 Version = 7
 )
 
 // Converts a network version into an actors adt version.
@@ -32,6 +33,8 @@ func VersionForNetwork(version network.Version) Version {
 		return Version5
 	case network.Version14:
 		return Version6
+	case network.Version15:
+		return Version7
 	default:
 		panic(fmt.Sprintf("unsupported network version %d", version))
 	}
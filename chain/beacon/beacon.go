@@ -54,6 +54,11 @@ func ValidateBlockValues(bSchedule Schedule, h *types.BlockHeader, parentEpoch a
 			if len(h.BeaconEntries) != 2 {
 				return xerrors.Errorf("expected two beacon entries at beacon fork, got %d", len(h.BeaconEntries))
 			}
+			// beacons which don't chain entries need the first entry checked on
+			// its own (see group.GroupBeacon)
+			if err := currBeacon.VerifyEntry(h.BeaconEntries[0], types.BeaconEntry{}); err != nil {
+				return xerrors.Errorf("beacon at fork point invalid: (%v): %w", h.BeaconEntries[0], err)
+			}
 			err := currBeacon.VerifyEntry(h.BeaconEntries[1], h.BeaconEntries[0])
 			if err != nil {
 				return xerrors.Errorf("beacon at fork point invalid: (%v, %v): %w",
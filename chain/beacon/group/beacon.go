@@ -0,0 +1,152 @@
+package group
+
+import (
+	"context"
+	"time"
+
+	"github.com/drand/kyber"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/beacon"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+// GroupBeacon provides randomness produced by a beacon group run by the
+// network operators, as an alternative to a drand network. Entries are BLS
+// threshold signatures of the round number by the group, so they can be
+// verified with the group public key alone.
+type GroupBeacon struct {
+	info   *Info
+	pubkey kyber.Point
+	source Source
+
+	filGenTime   uint64
+	filRoundTime uint64
+
+	localCache *lru.Cache
+}
+
+// NewGroupBeacon creates a beacon for the group described by
+// config.ChainInfoJSON, fetching rounds from config.Servers, or from the group
+// members if no servers are set.
+func NewGroupBeacon(genesisTs, interval uint64, config dtypes.DrandConfig) (*GroupBeacon, error) {
+	info, err := InfoFromJSON([]byte(config.ChainInfoJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	servers := config.Servers
+	if len(servers) == 0 {
+		servers = info.Members
+	}
+
+	return NewGroupBeaconWithSource(genesisTs, interval, info, NewHTTPSource(servers))
+}
+
+// NewGroupBeaconWithSource creates a beacon fetching rounds from the given
+// source, e.g. a LocalGroup.
+func NewGroupBeaconWithSource(genesisTs, interval uint64, info *Info, source Source) (*GroupBeacon, error) {
+	if genesisTs == 0 {
+		return nil, xerrors.Errorf("genesis timestamp can't be zero")
+	}
+
+	pubkey, err := info.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	lc, err := lru.New(1024)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GroupBeacon{
+		info:   info,
+		pubkey: pubkey,
+		source: source,
+
+		filGenTime:   genesisTs,
+		filRoundTime: interval,
+
+		localCache: lc,
+	}, nil
+}
+
+func (gb *GroupBeacon) Entry(ctx context.Context, round uint64) <-chan beacon.Response {
+	out := make(chan beacon.Response, 1)
+	if be := gb.getCachedValue(round); be != nil {
+		out <- beacon.Response{Entry: *be}
+		close(out)
+		return out
+	}
+
+	go func() {
+		start := build.Clock.Now()
+		log.Infow("start fetching randomness", "round", round)
+
+		var br beacon.Response
+		sig, err := gb.source.Get(ctx, round)
+		if err != nil {
+			br.Err = xerrors.Errorf("fetching round %d from beacon group: %w", round, err)
+		} else if err := Verify(gb.pubkey, round, sig); err != nil {
+			br.Err = xerrors.Errorf("beacon group returned invalid signature for round %d: %w", round, err)
+		} else {
+			br.Entry = types.BeaconEntry{Round: round, Data: sig}
+			gb.cacheValue(br.Entry)
+		}
+
+		log.Infow("done fetching randomness", "round", round, "took", build.Clock.Since(start))
+		out <- br
+		close(out)
+	}()
+
+	return out
+}
+
+func (gb *GroupBeacon) cacheValue(e types.BeaconEntry) {
+	gb.localCache.Add(e.Round, e)
+}
+
+func (gb *GroupBeacon) getCachedValue(round uint64) *types.BeaconEntry {
+	v, ok := gb.localCache.Get(round)
+	if !ok {
+		return nil
+	}
+	e := v.(types.BeaconEntry)
+	return &e
+}
+
+// VerifyEntry checks the group signature of curr. Rounds aren't chained by
+// signature, so it also checks that curr directly follows prev, unless prev is
+// the genesis entry.
+func (gb *GroupBeacon) VerifyEntry(curr types.BeaconEntry, prev types.BeaconEntry) error {
+	if prev.Round != 0 && curr.Round != prev.Round+1 {
+		return xerrors.Errorf("beacon entry round %d doesn't follow previous round %d", curr.Round, prev.Round)
+	}
+
+	if be := gb.getCachedValue(curr.Round); be != nil && string(be.Data) == string(curr.Data) {
+		return nil
+	}
+
+	if err := Verify(gb.pubkey, curr.Round, curr.Data); err != nil {
+		return xerrors.Errorf("invalid group signature: %w", err)
+	}
+
+	gb.cacheValue(curr)
+	return nil
+}
+
+// MaxBeaconRoundForEpoch returns the latest round started by the start of the
+// previous epoch, numbering rounds like the group does, from 1 at the group
+// genesis.
+func (gb *GroupBeacon) MaxBeaconRoundForEpoch(filEpoch abi.ChainEpoch) uint64 {
+	latestTs := ((uint64(filEpoch) * gb.filRoundTime) + gb.filGenTime) - gb.filRoundTime
+	return gb.info.RoundAt(time.Unix(int64(latestTs), 0))
+}
+
+var _ beacon.RandomBeacon = (*GroupBeacon)(nil)
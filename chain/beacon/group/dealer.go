@@ -0,0 +1,82 @@
+package group
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/drand/drand/key"
+	"github.com/drand/kyber/share"
+	"github.com/drand/kyber/util/random"
+	"golang.org/x/xerrors"
+)
+
+// Share is the secret key share of a group member.
+type Share struct {
+	Index int
+	// Scalar is the hex encoded secret share
+	Scalar string
+}
+
+// ShareFromJSON parses a member key share.
+func ShareFromJSON(b []byte) (*share.PriShare, error) {
+	var s Share
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, xerrors.Errorf("unmarshaling share: %w", err)
+	}
+
+	v, err := hex.DecodeString(s.Scalar)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding share: %w", err)
+	}
+
+	ps := &share.PriShare{I: s.Index, V: key.KeyGroup.Scalar()}
+	if err := ps.V.UnmarshalBinary(v); err != nil {
+		return nil, xerrors.Errorf("unmarshaling share: %w", err)
+	}
+
+	return ps, nil
+}
+
+// ShareJSON encodes a member key share in the format read by ShareFromJSON.
+func ShareJSON(ps *share.PriShare) ([]byte, error) {
+	v, err := ps.V.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(Share{Index: ps.I, Scalar: hex.EncodeToString(v)}, "", "  ")
+}
+
+// Deal generates the keys of a group with a trusted dealer: the group secret
+// is split into one share per member, any threshold of which can sign rounds.
+// The dealer knows the group secret, so dealing should happen offline, and the
+// secret is discarded once the shares are handed to the members.
+func Deal(members []string, threshold int, period, genesisTime uint64) (*Info, []*share.PriShare, error) {
+	if threshold < 1 || threshold > len(members) {
+		return nil, nil, xerrors.Errorf("threshold must be between 1 and the number of members (%d), was %d", len(members), threshold)
+	}
+
+	pri := share.NewPriPoly(key.KeyGroup, threshold, nil, random.New())
+	_, commits := pri.Commit(key.KeyGroup.Point().Base()).Info()
+
+	info := &Info{
+		Period:       period,
+		GenesisTime:  genesisTime,
+		Threshold:    threshold,
+		Coefficients: make([]string, len(commits)),
+		Members:      members,
+	}
+	for i, c := range commits {
+		b, err := c.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+		info.Coefficients[i] = hex.EncodeToString(b)
+	}
+
+	if err := info.check(); err != nil {
+		return nil, nil, err
+	}
+
+	return info, pri.Shares(len(members)), nil
+}
@@ -0,0 +1,158 @@
+package group
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drand/drand/key"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestDealShares(t *testing.T) {
+	info, shares, err := Deal([]string{"a", "b", "c"}, 2, 30, 1000)
+	require.NoError(t, err)
+	require.Len(t, shares, 3)
+
+	ib, err := info.JSON()
+	require.NoError(t, err)
+	info2, err := InfoFromJSON(ib)
+	require.NoError(t, err)
+	require.Equal(t, info, info2)
+
+	for _, ps := range shares {
+		sb, err := ShareJSON(ps)
+		require.NoError(t, err)
+		ps2, err := ShareFromJSON(sb)
+		require.NoError(t, err)
+		require.Equal(t, ps.I, ps2.I)
+		require.True(t, ps.V.Equal(ps2.V))
+
+		_, err = NewMember(info2, ps2, nil)
+		require.NoError(t, err)
+	}
+
+	// shares of another group are rejected
+	_, other, err := Deal([]string{"a", "b", "c"}, 2, 30, 1000)
+	require.NoError(t, err)
+	_, err = NewMember(info, other[0], nil)
+	require.Error(t, err)
+
+	_, _, err = Deal([]string{"a", "b"}, 3, 30, 1000)
+	require.Error(t, err)
+
+	require.Equal(t, uint64(0), info.RoundAt(time.Unix(999, 0)))
+	require.Equal(t, uint64(1), info.RoundAt(time.Unix(1000, 0)))
+	require.Equal(t, uint64(2), info.RoundAt(time.Unix(1030, 0)))
+	require.Equal(t, time.Unix(1030, 0), info.RoundTime(2))
+}
+
+func TestLocalGroup(t *testing.T) {
+	g, err := NewLocalGroup(5, 3, time.Second)
+	require.NoError(t, err)
+
+	g.Start()
+	defer g.Stop()
+
+	gb, err := NewGroupBeaconWithSource(g.Info.GenesisTime, 1, g.Info, g)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	getEntry := func(round uint64) types.BeaconEntry {
+		res := <-gb.Entry(ctx, round)
+		require.NoError(t, res.Err)
+		require.Equal(t, round, res.Entry.Round)
+		return res.Entry
+	}
+
+	e1 := getEntry(1)
+	e2 := getEntry(2)
+	require.NoError(t, gb.VerifyEntry(e2, e1))
+	require.NoError(t, gb.VerifyEntry(e1, types.BeaconEntry{}))
+
+	// rounds must follow each other
+	require.Error(t, gb.VerifyEntry(e1, e2))
+	// signatures must match the round
+	require.Error(t, gb.VerifyEntry(types.BeaconEntry{Round: 3, Data: e2.Data}, e2))
+
+	// the group tolerates members being offline, up to the threshold
+	g.StopMember(0)
+	g.StopMember(3)
+
+	e3 := getEntry(3)
+	require.NoError(t, gb.VerifyEntry(e3, e2))
+
+	g.StopMember(4)
+
+	sctx, scancel := context.WithTimeout(ctx, 2*time.Second)
+	defer scancel()
+	res := <-gb.Entry(sctx, 4)
+	require.Error(t, res.Err)
+
+	// rounds missed while the group was below the threshold are produced once
+	// enough members are back
+	g.StartMember(4)
+
+	e4 := getEntry(4)
+	require.NoError(t, gb.VerifyEntry(e4, e3))
+
+	// another beacon for the same group verifies the entries without the cache
+	gb2, err := NewGroupBeaconWithSource(g.Info.GenesisTime, 1, g.Info, g)
+	require.NoError(t, err)
+	require.NoError(t, gb2.VerifyEntry(e4, e3))
+}
+
+type nilTransport struct{}
+
+func (nilTransport) Broadcast(context.Context, Partial) {}
+
+func TestMemberGet(t *testing.T) {
+	info, shares, err := Deal([]string{"a", "b", "c"}, 2, 30, uint64(time.Now().Unix()))
+	require.NoError(t, err)
+
+	m, err := NewMember(info, shares[0], nilTransport{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	cur := info.RoundAt(time.Now())
+
+	// rounds too far ahead aren't waited for
+	_, err = m.Get(ctx, cur+maxRoundLead+1)
+	require.Error(t, err)
+
+	// waiters are dropped when their context is done
+	sctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = m.Get(sctx, cur)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Empty(t, m.waiters)
+
+	// the member's own partial signature is kept, and another one completes
+	// the round
+	psig, err := key.Scheme.Sign(shares[1], Message(cur))
+	require.NoError(t, err)
+	require.NoError(t, m.AddPartial(ctx, Partial{Round: cur, Signature: psig}))
+
+	sig, err := m.Get(ctx, cur)
+	require.NoError(t, err)
+	pub, err := info.PublicKey()
+	require.NoError(t, err)
+	require.NoError(t, Verify(pub, cur, sig))
+}
+
+func TestMaxBeaconRoundForEpoch(t *testing.T) {
+	info, _, err := Deal([]string{"a", "b", "c"}, 2, 30, 1000)
+	require.NoError(t, err)
+
+	gb, err := NewGroupBeaconWithSource(1000, 30, info, nil)
+	require.NoError(t, err)
+
+	// epoch 1 starts at the group genesis, when round 1 starts
+	require.Equal(t, info.RoundAt(time.Unix(1000, 0)), gb.MaxBeaconRoundForEpoch(1))
+	require.Equal(t, uint64(1), gb.MaxBeaconRoundForEpoch(1))
+	require.Equal(t, uint64(2), gb.MaxBeaconRoundForEpoch(2))
+}
@@ -0,0 +1,202 @@
+package group
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/xerrors"
+)
+
+// maxRequestWait bounds how long a request for a round waits for it to be
+// produced
+const maxRequestWait = time.Minute
+
+// RoundResponse is the response of members to requests for a round.
+type RoundResponse struct {
+	Round     uint64
+	Signature []byte
+}
+
+// Handler serves the member HTTP API. GET /public/{round} returns the group
+// signature of a round, waiting for it to be produced, with "latest" standing
+// for the current round. POST /partial takes partial signatures from other
+// members.
+func (m *Member) Handler() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/public/{round}", m.handleRound).Methods("GET")
+	r.HandleFunc("/partial", m.handlePartial).Methods("POST")
+	return r
+}
+
+func (m *Member) handleRound(w http.ResponseWriter, r *http.Request) {
+	rs := mux.Vars(r)["round"]
+
+	var round uint64
+	if rs == "latest" {
+		round = m.info.RoundAt(time.Now())
+	} else {
+		var err error
+		round, err = strconv.ParseUint(rs, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid round: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), maxRequestWait)
+	defer cancel()
+
+	sig, err := m.Get(ctx, round)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(RoundResponse{Round: round, Signature: sig}); err != nil {
+		log.Warnw("writing round response", "error", err)
+	}
+}
+
+func (m *Member) handlePartial(w http.ResponseWriter, r *http.Request) {
+	var p Partial
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, fmt.Sprintf("decoding partial signature: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := m.AddPartial(context.TODO(), p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+type httpTransport struct {
+	peers  []string
+	client *http.Client
+}
+
+// NewHTTPTransport returns a transport posting partial signatures to the HTTP
+// API of the other members.
+func NewHTTPTransport(info *Info, self int) Transport {
+	var peers []string
+	for i, addr := range info.Members {
+		if i != self {
+			peers = append(peers, strings.TrimRight(addr, "/"))
+		}
+	}
+
+	return &httpTransport{
+		peers:  peers,
+		client: &http.Client{Timeout: time.Duration(info.Period) * time.Second},
+	}
+}
+
+func (t *httpTransport) Broadcast(ctx context.Context, p Partial) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		log.Errorw("marshaling partial signature", "error", err)
+		return
+	}
+
+	for _, peer := range t.peers {
+		go func(peer string) {
+			ctx, cancel := context.WithTimeout(context.Background(), t.client.Timeout)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, "POST", peer+"/partial", bytes.NewReader(b))
+			if err != nil {
+				log.Errorw("creating partial signature request", "peer", peer, "error", err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := t.client.Do(req)
+			if err != nil {
+				log.Warnw("sending partial signature", "peer", peer, "round", p.Round, "error", err)
+				return
+			}
+			defer resp.Body.Close() //nolint:errcheck
+
+			if resp.StatusCode != http.StatusOK {
+				log.Warnw("peer rejected partial signature", "peer", peer, "round", p.Round, "status", resp.Status)
+			}
+		}(peer)
+	}
+}
+
+type httpSource struct {
+	servers []string
+	client  *http.Client
+}
+
+// NewHTTPSource returns a source fetching rounds from the HTTP API of group
+// members, trying them in random order.
+func NewHTTPSource(servers []string) Source {
+	s := &httpSource{
+		client: &http.Client{Timeout: maxRequestWait},
+	}
+	for _, addr := range servers {
+		s.servers = append(s.servers, strings.TrimRight(addr, "/"))
+	}
+	return s
+}
+
+func (s *httpSource) Get(ctx context.Context, round uint64) ([]byte, error) {
+	if len(s.servers) == 0 {
+		return nil, xerrors.Errorf("no group members to fetch round %d from", round)
+	}
+
+	var lastErr error
+	for _, i := range rand.Perm(len(s.servers)) {
+		sig, err := s.get(ctx, s.servers[i], round)
+		if err == nil {
+			return sig, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		log.Warnw("fetching round from group member", "member", s.servers[i], "round", round, "error", err)
+		lastErr = err
+	}
+
+	return nil, xerrors.Errorf("no group member served round %d: %w", round, lastErr)
+}
+
+func (s *httpSource) get(ctx context.Context, server string, round uint64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/public/%d", server, round), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("non-200 response: %s", resp.Status)
+	}
+
+	var rr RoundResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+		return nil, xerrors.Errorf("decoding response: %w", err)
+	}
+	if rr.Round != round {
+		return nil, xerrors.Errorf("requested round %d, got %d", round, rr.Round)
+	}
+
+	return rr.Signature, nil
+}
+
+var _ Source = (*httpSource)(nil)
@@ -0,0 +1,128 @@
+package group
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/drand/drand/key"
+	"github.com/drand/kyber"
+	"github.com/drand/kyber/share"
+	"golang.org/x/xerrors"
+)
+
+// Info describes a beacon group. It is public, and is all that's needed to
+// verify the group's randomness.
+type Info struct {
+	// Period is the time between rounds, in seconds
+	Period uint64
+	// GenesisTime is the unix time of the first round
+	GenesisTime uint64
+	// Threshold is the number of members needed to produce a round
+	Threshold int
+	// Coefficients are the commitments to the group's secret sharing
+	// polynomial, hex encoded. The first one is the group public key.
+	Coefficients []string
+	// Members are the HTTP endpoints of the members, the member with share
+	// index i being Members[i]
+	Members []string
+}
+
+// InfoFromJSON parses and checks group info.
+func InfoFromJSON(b []byte) (*Info, error) {
+	var info Info
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, xerrors.Errorf("unmarshaling group info: %w", err)
+	}
+
+	if err := info.check(); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (i *Info) check() error {
+	if i.Period == 0 {
+		return xerrors.Errorf("group period can't be zero")
+	}
+	if i.Threshold < 1 {
+		return xerrors.Errorf("group threshold must be at least 1")
+	}
+	if len(i.Coefficients) != i.Threshold {
+		return xerrors.Errorf("expected %d polynomial coefficients, got %d", i.Threshold, len(i.Coefficients))
+	}
+	if len(i.Members) != 0 && len(i.Members) < i.Threshold {
+		return xerrors.Errorf("group has %d members, less than the threshold %d", len(i.Members), i.Threshold)
+	}
+
+	_, err := i.PubPoly()
+	return err
+}
+
+// JSON encodes the info in the format read by InfoFromJSON.
+func (i *Info) JSON() ([]byte, error) {
+	return json.MarshalIndent(i, "", "  ")
+}
+
+// PubPoly returns the public sharing polynomial, used to verify partial
+// signatures of members.
+func (i *Info) PubPoly() (*share.PubPoly, error) {
+	commits := make([]kyber.Point, len(i.Coefficients))
+	for n, c := range i.Coefficients {
+		b, err := hex.DecodeString(c)
+		if err != nil {
+			return nil, xerrors.Errorf("decoding coefficient %d: %w", n, err)
+		}
+
+		commits[n] = key.KeyGroup.Point()
+		if err := commits[n].UnmarshalBinary(b); err != nil {
+			return nil, xerrors.Errorf("unmarshaling coefficient %d: %w", n, err)
+		}
+	}
+
+	return share.NewPubPoly(key.KeyGroup, nil, commits), nil
+}
+
+// PublicKey returns the group public key, which verifies round signatures.
+func (i *Info) PublicKey() (kyber.Point, error) {
+	pp, err := i.PubPoly()
+	if err != nil {
+		return nil, err
+	}
+	return pp.Commit(), nil
+}
+
+// RoundAt returns the latest round at the given time, or 0 before genesis.
+func (i *Info) RoundAt(t time.Time) uint64 {
+	now := uint64(t.Unix())
+	if now < i.GenesisTime {
+		return 0
+	}
+	return (now-i.GenesisTime)/i.Period + 1
+}
+
+// RoundTime returns the time at which the round starts.
+func (i *Info) RoundTime(round uint64) time.Time {
+	if round == 0 {
+		return time.Unix(int64(i.GenesisTime), 0)
+	}
+	return time.Unix(int64(i.GenesisTime+(round-1)*i.Period), 0)
+}
+
+// Message returns the message signed by the group for a round. Rounds aren't
+// chained to the previous signature, so members which missed rounds can sign
+// later rounds without catching up, and any round can be verified on its own.
+func Message(round uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], round)
+	h := sha256.Sum256(buf[:])
+	return h[:]
+}
+
+// Verify checks the group signature of a round.
+func Verify(pub kyber.Point, round uint64, sig []byte) error {
+	return key.Scheme.VerifyRecovered(pub, Message(round), sig)
+}
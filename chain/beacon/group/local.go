@@ -0,0 +1,133 @@
+package group
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// LocalGroup is a beacon group running in-process, for tests and local
+// devnets. Members can be taken offline to exercise the threshold.
+type LocalGroup struct {
+	Info    *Info
+	Members []*Member
+
+	lk     sync.Mutex
+	online []bool
+	cancel []context.CancelFunc
+}
+
+// NewLocalGroup deals the keys of a group of n members with the given
+// threshold, with the first round starting now.
+func NewLocalGroup(n, threshold int, period time.Duration) (*LocalGroup, error) {
+	if period < time.Second {
+		return nil, xerrors.Errorf("group period must be at least one second")
+	}
+
+	info, shares, err := Deal(make([]string, n), threshold, uint64(period/time.Second), uint64(time.Now().Unix()))
+	if err != nil {
+		return nil, err
+	}
+
+	g := &LocalGroup{
+		Info:   info,
+		online: make([]bool, n),
+		cancel: make([]context.CancelFunc, n),
+	}
+	for _, ps := range shares {
+		m, err := NewMember(info, ps, &localTransport{group: g, self: ps.I})
+		if err != nil {
+			return nil, err
+		}
+		g.Members = append(g.Members, m)
+	}
+
+	return g, nil
+}
+
+// Start brings all members online.
+func (g *LocalGroup) Start() {
+	for i := range g.Members {
+		g.StartMember(i)
+	}
+}
+
+// Stop takes all members offline.
+func (g *LocalGroup) Stop() {
+	for i := range g.Members {
+		g.StopMember(i)
+	}
+}
+
+// StartMember brings a member online.
+func (g *LocalGroup) StartMember(i int) {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+
+	if g.online[i] {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.online[i] = true
+	g.cancel[i] = cancel
+	go g.Members[i].Run(ctx)
+}
+
+// StopMember takes a member offline; it stops signing rounds and doesn't
+// receive partial signatures.
+func (g *LocalGroup) StopMember(i int) {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+
+	if !g.online[i] {
+		return
+	}
+
+	g.online[i] = false
+	g.cancel[i]()
+}
+
+func (g *LocalGroup) isOnline(i int) bool {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	return g.online[i]
+}
+
+// Get fetches the round from an online member.
+func (g *LocalGroup) Get(ctx context.Context, round uint64) ([]byte, error) {
+	for i, m := range g.Members {
+		if g.isOnline(i) {
+			return m.Get(ctx, round)
+		}
+	}
+
+	return nil, xerrors.Errorf("no group member online")
+}
+
+type localTransport struct {
+	group *LocalGroup
+	self  int
+}
+
+func (t *localTransport) Broadcast(ctx context.Context, p Partial) {
+	if !t.group.isOnline(t.self) {
+		return
+	}
+
+	for i, m := range t.group.Members {
+		if i == t.self || !t.group.isOnline(i) {
+			continue
+		}
+
+		go func(m *Member) {
+			if err := m.AddPartial(context.Background(), p); err != nil {
+				log.Warnw("delivering partial signature", "member", m.Index(), "error", err)
+			}
+		}(m)
+	}
+}
+
+var _ Source = (*LocalGroup)(nil)
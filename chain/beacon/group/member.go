@@ -0,0 +1,291 @@
+package group
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/drand/drand/key"
+	"github.com/drand/kyber/share"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("beacon-group")
+
+// maxRoundAge is the number of past rounds members keep signatures for, and
+// accept partial signatures for
+const maxRoundAge = 1024
+
+// maxRoundLead is the number of rounds ahead of the current one members
+// accept partial signatures for and wait for, to tolerate clock drift between
+// members
+const maxRoundLead = 1
+
+// Partial is the signature share of a member for a round. The member index is
+// encoded in the signature.
+type Partial struct {
+	Round     uint64
+	Signature []byte
+}
+
+// Transport delivers partial signatures to the other members of the group.
+// Broadcast must not block on the other members.
+type Transport interface {
+	Broadcast(ctx context.Context, p Partial)
+}
+
+// Source provides group signatures for rounds, waiting for them to be produced
+// if needed.
+type Source interface {
+	Get(ctx context.Context, round uint64) ([]byte, error)
+}
+
+// Member is a member of a beacon group. At the start of each round it signs
+// the round with its key share and broadcasts the partial signature; once it
+// has a threshold of partial signatures it recovers the group signature.
+type Member struct {
+	info      *Info
+	pub       *share.PubPoly
+	share     *share.PriShare
+	transport Transport
+
+	lk       sync.Mutex
+	partials map[uint64]map[int][]byte
+	sigs     map[uint64][]byte
+	waiters  map[uint64][]chan struct{}
+}
+
+func NewMember(info *Info, ps *share.PriShare, transport Transport) (*Member, error) {
+	pub, err := info.PubPoly()
+	if err != nil {
+		return nil, err
+	}
+
+	if ps.I < 0 || ps.I >= len(info.Members) {
+		return nil, xerrors.Errorf("key share index %d out of range for a group of %d members", ps.I, len(info.Members))
+	}
+	if !pub.Eval(ps.I).V.Equal(key.KeyGroup.Point().Mul(ps.V, nil)) {
+		return nil, xerrors.Errorf("key share %d doesn't belong to the group", ps.I)
+	}
+
+	return &Member{
+		info:      info,
+		pub:       pub,
+		share:     ps,
+		transport: transport,
+
+		partials: map[uint64]map[int][]byte{},
+		sigs:     map[uint64][]byte{},
+		waiters:  map[uint64][]chan struct{}{},
+	}, nil
+}
+
+// Index returns the share index of the member.
+func (m *Member) Index() int {
+	return m.share.I
+}
+
+// Run signs each round as it starts, until the context is canceled.
+func (m *Member) Run(ctx context.Context) {
+	for {
+		round := m.info.RoundAt(time.Now())
+		if round > 0 {
+			m.sign(ctx, round)
+			m.prune(round)
+		}
+
+		select {
+		case <-time.After(time.Until(m.info.RoundTime(round + 1))):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// AddPartial adds a partial signature received from another member. Members
+// sign rounds they get partial signatures for if they haven't yet, so that
+// rounds missed by some members can still be produced later.
+func (m *Member) AddPartial(ctx context.Context, p Partial) error {
+	cur := m.info.RoundAt(time.Now())
+	if p.Round > cur+maxRoundLead {
+		return xerrors.Errorf("partial signature for future round %d (current %d)", p.Round, cur)
+	}
+	if p.Round+maxRoundAge < cur {
+		return xerrors.Errorf("partial signature for round %d is too old (current %d)", p.Round, cur)
+	}
+
+	if err := key.Scheme.VerifyPartial(m.pub, Message(p.Round), p.Signature); err != nil {
+		return xerrors.Errorf("invalid partial signature for round %d: %w", p.Round, err)
+	}
+
+	m.lk.Lock()
+	_, done := m.sigs[p.Round]
+	_, signed := m.partials[p.Round][m.share.I]
+	if !done {
+		m.addPartialLocked(p)
+	}
+	m.lk.Unlock()
+
+	if !done && !signed && p.Round <= cur {
+		m.sign(ctx, p.Round)
+	}
+
+	return nil
+}
+
+// Get returns the group signature for a round, waiting for it to be produced.
+func (m *Member) Get(ctx context.Context, round uint64) ([]byte, error) {
+	cur := m.info.RoundAt(time.Now())
+
+	m.lk.Lock()
+	if sig, ok := m.sigs[round]; ok {
+		m.lk.Unlock()
+		return sig, nil
+	}
+	if round+maxRoundAge < cur {
+		m.lk.Unlock()
+		return nil, xerrors.Errorf("round %d is too old (current %d)", round, cur)
+	}
+	if round > cur+maxRoundLead {
+		m.lk.Unlock()
+		return nil, xerrors.Errorf("round %d is in the future (current %d)", round, cur)
+	}
+
+	wait := make(chan struct{})
+	m.waiters[round] = append(m.waiters[round], wait)
+	m.lk.Unlock()
+
+	if round <= cur {
+		m.sign(ctx, round)
+	}
+
+	select {
+	case <-wait:
+	case <-ctx.Done():
+		m.removeWaiter(round, wait)
+		return nil, ctx.Err()
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.sigs[round], nil
+}
+
+func (m *Member) removeWaiter(round uint64, wait chan struct{}) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	ws := m.waiters[round]
+	for i, w := range ws {
+		if w == wait {
+			ws = append(ws[:i], ws[i+1:]...)
+			break
+		}
+	}
+	if len(ws) == 0 {
+		delete(m.waiters, round)
+		return
+	}
+	m.waiters[round] = ws
+}
+
+// sign broadcasts the partial signature of the member for the round, unless
+// the group signature is already known. The partial signature is broadcast
+// again if the member already signed the round, in case other members missed
+// it.
+func (m *Member) sign(ctx context.Context, round uint64) {
+	m.lk.Lock()
+	if _, ok := m.sigs[round]; ok {
+		m.lk.Unlock()
+		return
+	}
+	psig, ok := m.partials[round][m.share.I]
+	m.lk.Unlock()
+
+	if !ok {
+		var err error
+		psig, err = key.Scheme.Sign(m.share, Message(round))
+		if err != nil {
+			log.Errorw("signing round", "round", round, "error", err)
+			return
+		}
+
+		m.lk.Lock()
+		if _, ok := m.sigs[round]; !ok {
+			m.addPartialLocked(Partial{Round: round, Signature: psig})
+		}
+		m.lk.Unlock()
+	}
+
+	m.transport.Broadcast(ctx, Partial{Round: round, Signature: psig})
+}
+
+func (m *Member) addPartialLocked(p Partial) {
+	idx, err := key.Scheme.IndexOf(p.Signature)
+	if err != nil {
+		return // checked by VerifyPartial
+	}
+
+	if m.partials[p.Round] == nil {
+		m.partials[p.Round] = map[int][]byte{}
+	}
+	m.partials[p.Round][idx] = p.Signature
+
+	if len(m.partials[p.Round]) < m.info.Threshold {
+		return
+	}
+
+	sigs := make([][]byte, 0, len(m.partials[p.Round]))
+	for _, s := range m.partials[p.Round] {
+		sigs = append(sigs, s)
+	}
+
+	sig, err := key.Scheme.Recover(m.pub, Message(p.Round), sigs, m.info.Threshold, len(m.info.Members))
+	if err != nil {
+		log.Errorw("recovering group signature", "round", p.Round, "error", err)
+		return
+	}
+	if err := key.Scheme.VerifyRecovered(m.pub.Commit(), Message(p.Round), sig); err != nil {
+		log.Errorw("recovered invalid group signature", "round", p.Round, "error", err)
+		return
+	}
+
+	log.Debugw("produced round", "round", p.Round)
+
+	m.sigs[p.Round] = sig
+	delete(m.partials, p.Round)
+
+	for _, w := range m.waiters[p.Round] {
+		close(w)
+	}
+	delete(m.waiters, p.Round)
+}
+
+// prune drops the state of rounds older than maxRoundAge.
+func (m *Member) prune(cur uint64) {
+	if cur <= maxRoundAge {
+		return
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	for r := range m.partials {
+		if r+maxRoundAge < cur {
+			delete(m.partials, r)
+		}
+	}
+	for r := range m.sigs {
+		if r+maxRoundAge < cur {
+			delete(m.sigs, r)
+		}
+	}
+	for r := range m.waiters {
+		if r+maxRoundAge < cur {
+			delete(m.waiters, r)
+		}
+	}
+}
+
+var _ Source = (*Member)(nil)
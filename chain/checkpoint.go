@@ -3,15 +3,96 @@ package chain
 import (
 	"encoding/json"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
 
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"golang.org/x/xerrors"
 )
 
 var CheckpointKey = datastore.NewKey("/chain/checks")
 
+// Checkpoint identifies a tipset trusted to be part of the canonical chain,
+// which new nodes can start syncing from instead of genesis.
+type Checkpoint struct {
+	// Genesis is the CID of the genesis block of the network
+	Genesis cid.Cid
+
+	Height abi.ChainEpoch
+	TipSet types.TipSetKey
+	// StateRoot is the parent state of the tipset
+	StateRoot cid.Cid
+}
+
+// SigningBytes returns the bytes checkpoint signers sign.
+func (c *Checkpoint) SigningBytes() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// SignedCheckpoint is the checkpoint file format, a checkpoint along with
+// signatures of the keys vouching for it.
+type SignedCheckpoint struct {
+	Checkpoint Checkpoint
+	Signatures []CheckpointSignature
+}
+
+type CheckpointSignature struct {
+	Signer    address.Address
+	Signature crypto.Signature
+}
+
+// Verify checks that the checkpoint carries valid signatures from at least
+// threshold of the trusted signers.
+func (sc *SignedCheckpoint) Verify(trusted []address.Address, threshold int) error {
+	if len(trusted) == 0 {
+		return xerrors.Errorf("no trusted checkpoint signers configured")
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	if threshold > len(trusted) {
+		return xerrors.Errorf("signature threshold %d is higher than the number of trusted signers (%d)", threshold, len(trusted))
+	}
+
+	msg, err := sc.Checkpoint.SigningBytes()
+	if err != nil {
+		return xerrors.Errorf("serializing checkpoint: %w", err)
+	}
+
+	valid := map[address.Address]struct{}{}
+	for _, s := range sc.Signatures {
+		var isTrusted bool
+		for _, t := range trusted {
+			if t == s.Signer {
+				isTrusted = true
+				break
+			}
+		}
+		if !isTrusted {
+			log.Warnw("ignoring checkpoint signature from untrusted signer", "signer", s.Signer)
+			continue
+		}
+
+		s := s
+		if err := sigs.Verify(&s.Signature, s.Signer, msg); err != nil {
+			return xerrors.Errorf("invalid checkpoint signature from %s: %w", s.Signer, err)
+		}
+		valid[s.Signer] = struct{}{}
+	}
+
+	if len(valid) < threshold {
+		return xerrors.Errorf("checkpoint has %d valid signatures from trusted signers, need %d", len(valid), threshold)
+	}
+
+	return nil
+}
+
 func loadCheckpoint(ds dtypes.MetadataDS) (types.TipSetKey, error) {
 	haveChks, err := ds.Has(CheckpointKey)
 	if err != nil {
@@ -0,0 +1,315 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	blocks "github.com/ipfs/go-block-format"
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-graphsync"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/libp2p/go-libp2p-core/peer"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	bstore "github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/chain/exchange"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+// BootstrapCheckpointKey holds the checkpoint imported with
+// `lotus daemon --import-checkpoint`, until the node has bootstrapped from it
+var BootstrapCheckpointKey = datastore.NewKey("/chain/bootstrap-checkpoint")
+
+// checkpointHeaderWindow is the number of tipsets fetched up to the
+// checkpoint, covering the lookbacks needed for fork choice, beacon entries
+// and ticket randomness when syncing forward from it
+var checkpointHeaderWindow = int(build.Finality)
+
+// chainsyncExtension marks graphsync requests which should be served from,
+// and stored into, the chain blockstore (see modules.Graphsync)
+const chainsyncExtension = graphsync.ExtensionName("chainsync")
+
+// LoadBootstrapCheckpoint returns the checkpoint the node should bootstrap
+// from, or nil if there is none.
+func LoadBootstrapCheckpoint(ds dtypes.MetadataDS) (*Checkpoint, error) {
+	b, err := ds.Get(BootstrapCheckpointKey)
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting bootstrap checkpoint: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, xerrors.Errorf("unmarshaling bootstrap checkpoint: %w", err)
+	}
+
+	return &cp, nil
+}
+
+// SetBootstrapCheckpoint makes the node bootstrap from the checkpoint the
+// next time it starts. The checkpoint must have been verified.
+func SetBootstrapCheckpoint(ds datastore.Datastore, cp *Checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	return ds.Put(BootstrapCheckpointKey, b)
+}
+
+// BootstrapFromCheckpoint makes the checkpoint tipset the head of a node with
+// no chain history. It fetches the headers leading up to the checkpoint and
+// the messages of the checkpoint tipset over chain exchange, the checkpoint
+// state over graphsync, and the few state trees needed for lookbacks as
+// deltas over bitswap. The checkpoint is then pinned, and sync continues
+// forward from it.
+func (syncer *Syncer) BootstrapFromCheckpoint(ctx context.Context, cp *Checkpoint, gs graphsync.GraphExchange, bs bserv.BlockService, peers func() []peer.ID) error {
+	if gen := syncer.Genesis.Blocks()[0].Cid(); cp.Genesis != gen {
+		return xerrors.Errorf("checkpoint is for a different network (genesis %s, ours %s)", cp.Genesis, gen)
+	}
+
+	if hts := syncer.store.GetHeaviestTipSet(); hts.Height() >= cp.Height {
+		log.Infow("chain is already past the bootstrap checkpoint", "head", hts.Height(), "checkpoint", cp.Height)
+		return syncer.ds.Delete(BootstrapCheckpointKey)
+	}
+
+	log.Infow("bootstrapping from checkpoint", "height", cp.Height, "tipset", cp.TipSet)
+
+	headers, err := syncer.fetchCheckpointHeaders(ctx, cp)
+	if err != nil {
+		return err
+	}
+	cpts := headers[0]
+
+	if err := syncer.fetchCheckpointMessages(ctx, cpts); err != nil {
+		return err
+	}
+
+	log.Infow("fetching checkpoint state", "root", cp.StateRoot)
+	if err := fetchStateGraphsync(ctx, gs, peers, cp.StateRoot); err != nil {
+		return xerrors.Errorf("fetching checkpoint state: %w", err)
+	}
+
+	// blocks following the checkpoint look up the state of the tipsets up to
+	// the winning post lookback before them
+	lookback := int(policy.GetWinningPoStSectorSetLookback(syncer.sm.GetNtwkVersion(ctx, cp.Height)))
+	for i := 1; i <= lookback && i < len(headers); i++ {
+		root := headers[i].ParentState()
+		log.Infow("fetching lookback state", "height", headers[i].Height(), "root", root)
+
+		if err := fetchStateDelta(ctx, bs, syncer.store.ChainBlockstore(), root); err != nil {
+			return xerrors.Errorf("fetching state at height %d: %w", headers[i].Height(), err)
+		}
+	}
+
+	if err := syncer.store.ForceHeadSilent(ctx, cpts); err != nil {
+		return xerrors.Errorf("setting checkpoint as head: %w", err)
+	}
+
+	if err := syncer.SetCheckpoint(cpts.Key()); err != nil {
+		return xerrors.Errorf("pinning checkpoint: %w", err)
+	}
+
+	log.Infow("bootstrapped from checkpoint", "height", cp.Height)
+
+	return syncer.ds.Delete(BootstrapCheckpointKey)
+}
+
+// fetchCheckpointHeaders fetches the checkpoint tipset and the tipsets before
+// it, newest first.
+func (syncer *Syncer) fetchCheckpointHeaders(ctx context.Context, cp *Checkpoint) ([]*types.TipSet, error) {
+	var headers []*types.TipSet
+
+	cur := cp.TipSet
+	for len(headers) < checkpointHeaderWindow {
+		count := checkpointHeaderWindow - len(headers)
+		if count > int(exchange.MaxRequestLength) {
+			count = int(exchange.MaxRequestLength)
+		}
+
+		tss, err := syncer.Exchange.GetBlocks(ctx, cur, count)
+		if err != nil {
+			return nil, xerrors.Errorf("fetching headers from %s: %w", cur, err)
+		}
+		if len(tss) == 0 {
+			return nil, xerrors.Errorf("no headers returned for %s", cur)
+		}
+
+		if len(headers) == 0 {
+			if tss[0].Height() != cp.Height {
+				return nil, xerrors.Errorf("checkpoint tipset height %d doesn't match checkpoint height %d", tss[0].Height(), cp.Height)
+			}
+			if tss[0].ParentState() != cp.StateRoot {
+				return nil, xerrors.Errorf("checkpoint tipset state root %s doesn't match checkpoint state root %s", tss[0].ParentState(), cp.StateRoot)
+			}
+		}
+
+		for _, ts := range tss {
+			if err := syncer.store.PersistBlockHeaders(ts.Blocks()...); err != nil {
+				return nil, xerrors.Errorf("persisting headers: %w", err)
+			}
+		}
+
+		headers = append(headers, tss...)
+
+		last := tss[len(tss)-1]
+		if last.Height() == 0 {
+			break
+		}
+		cur = last.Parents()
+	}
+
+	log.Infow("fetched checkpoint headers", "count", len(headers), "from", headers[len(headers)-1].Height())
+
+	return headers, nil
+}
+
+// fetchCheckpointMessages fetches the messages of the checkpoint tipset, which
+// get executed when validating the blocks following it.
+func (syncer *Syncer) fetchCheckpointMessages(ctx context.Context, ts *types.TipSet) error {
+	msgs, err := syncer.Exchange.GetChainMessages(ctx, []*types.TipSet{ts})
+	if err != nil {
+		return xerrors.Errorf("fetching checkpoint messages: %w", err)
+	}
+	if len(msgs) != 1 {
+		return xerrors.Errorf("expected messages for one tipset, got %d", len(msgs))
+	}
+
+	// temp storage so we don't persist data we dont want to
+	bs := bstore.NewMemory()
+	if _, err := zipTipSetAndMessages(cbor.NewCborStore(bs), ts, msgs[0].Bls, msgs[0].Secpk, msgs[0].BlsIncludes, msgs[0].SecpkIncludes); err != nil {
+		return xerrors.Errorf("checkpoint messages don't match the tipset: %w", err)
+	}
+
+	if err := persistMessages(ctx, bs, msgs[0]); err != nil {
+		return err
+	}
+
+	return copyBlockstore(ctx, bs, syncer.store.ChainBlockstore())
+}
+
+// fetchStateGraphsync fetches the whole DAG under root with graphsync, trying
+// the peers in turn until one serves all of it.
+func fetchStateGraphsync(ctx context.Context, gs graphsync.GraphExchange, peers func() []peer.ID, root cid.Cid) error {
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	all := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+
+	ps := peers()
+	if len(ps) == 0 {
+		return xerrors.Errorf("no peers to fetch state from")
+	}
+
+	var lastErr error
+	for _, p := range ps {
+		progress, errs := gs.Request(ctx, p, cidlink.Link{Cid: root}, all, graphsync.ExtensionData{
+			Name: chainsyncExtension,
+			Data: nil,
+		})
+
+		var n int
+		var reqErr error
+		for progress != nil || errs != nil {
+			select {
+			case _, ok := <-progress:
+				if !ok {
+					progress = nil
+					continue
+				}
+				n++
+				if n%100000 == 0 {
+					log.Infow("fetching state", "root", root, "peer", p, "blocks", n)
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				reqErr = err
+			}
+		}
+
+		if reqErr == nil {
+			log.Infow("fetched state", "root", root, "peer", p, "blocks", n)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Warnw("fetching state from peer failed", "peer", p, "error", reqErr)
+		lastErr = reqErr
+	}
+
+	return xerrors.Errorf("no peer could serve the state: %w", lastErr)
+}
+
+// fetchStateDelta fetches the blocks under root missing from the blockstore,
+// assuming that present blocks have their whole DAG present. Blocks are only
+// written once the DAG is complete so that this holds when interrupted.
+func fetchStateDelta(ctx context.Context, bs bserv.BlockGetter, to bstore.Blockstore, root cid.Cid) error {
+	fetched := map[cid.Cid]blocks.Block{}
+	seen := cid.NewSet()
+
+	queue := []cid.Cid{root}
+	for len(queue) > 0 {
+		var want []cid.Cid
+		for _, c := range queue {
+			if !seen.Visit(c) {
+				continue
+			}
+
+			has, err := to.Has(c)
+			if err != nil {
+				return xerrors.Errorf("checking blockstore: %w", err)
+			}
+			if !has {
+				want = append(want, c)
+			}
+		}
+		queue = nil
+
+		for blk := range bs.GetBlocks(ctx, want) {
+			fetched[blk.Cid()] = blk
+
+			if blk.Cid().Prefix().Codec != cid.DagCBOR {
+				continue
+			}
+			if err := cbg.ScanForLinks(bytes.NewReader(blk.RawData()), func(c cid.Cid) {
+				queue = append(queue, c)
+			}); err != nil {
+				return xerrors.Errorf("scanning %s for links: %w", blk.Cid(), err)
+			}
+		}
+
+		for _, c := range want {
+			if _, ok := fetched[c]; !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return xerrors.Errorf("failed to fetch block %s", c)
+			}
+		}
+	}
+
+	blks := make([]blocks.Block, 0, len(fetched))
+	for _, blk := range fetched {
+		blks = append(blks, blk)
+	}
+
+	log.Debugw("fetched state delta", "root", root, "blocks", len(blks))
+
+	return to.PutMany(blks)
+}
@@ -0,0 +1,66 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+)
+
+func TestSignedCheckpointVerify(t *testing.T) {
+	c, err := cid.Decode("bafy2bzacea3wsdh6y3a36tb3skempjoxqpuyompjbmfeyf34fi3uy6uue42v4")
+	require.NoError(t, err)
+
+	cp := Checkpoint{
+		Genesis:   c,
+		Height:    1000,
+		TipSet:    types.NewTipSetKey(c),
+		StateRoot: c,
+	}
+	msg, err := cp.SigningBytes()
+	require.NoError(t, err)
+
+	newSigner := func() (address.Address, CheckpointSignature) {
+		pk, err := sigs.Generate(crypto.SigTypeSecp256k1)
+		require.NoError(t, err)
+		pub, err := sigs.ToPublic(crypto.SigTypeSecp256k1, pk)
+		require.NoError(t, err)
+		addr, err := address.NewSecp256k1Address(pub)
+		require.NoError(t, err)
+		sig, err := sigs.Sign(crypto.SigTypeSecp256k1, pk, msg)
+		require.NoError(t, err)
+		return addr, CheckpointSignature{Signer: addr, Signature: *sig}
+	}
+
+	a1, s1 := newSigner()
+	a2, s2 := newSigner()
+	a3, s3 := newSigner()
+
+	sc := SignedCheckpoint{Checkpoint: cp, Signatures: []CheckpointSignature{s1, s2, s3}}
+
+	require.NoError(t, sc.Verify([]address.Address{a1}, 0))
+	require.NoError(t, sc.Verify([]address.Address{a1, a2}, 2))
+	require.Error(t, sc.Verify(nil, 1))
+	require.Error(t, sc.Verify([]address.Address{a1}, 2))
+
+	// signatures from untrusted signers don't count
+	sc.Signatures = []CheckpointSignature{s1, s3}
+	require.Error(t, sc.Verify([]address.Address{a1, a2}, 2))
+	require.NoError(t, sc.Verify([]address.Address{a1, a3}, 2))
+
+	// duplicate signatures count once
+	sc.Signatures = []CheckpointSignature{s1, s1}
+	require.Error(t, sc.Verify([]address.Address{a1, a2}, 2))
+
+	// signatures over a different checkpoint are invalid
+	sc.Signatures = []CheckpointSignature{s1}
+	sc.Checkpoint.Height++
+	require.Error(t, sc.Verify([]address.Address{a1}, 1))
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p-core/host"
@@ -34,6 +35,10 @@ type client struct {
 	host host.Host
 
 	peerTracker *bsPeerTracker
+
+	// sendRequest sends a request to a peer and reads the response back,
+	// `sendRequestToPeer` outside of tests.
+	sendRequest func(ctx context.Context, peer peer.ID, req *Request) (*Response, error)
 }
 
 var _ Client = (*client)(nil)
@@ -41,10 +46,12 @@ var _ Client = (*client)(nil)
 // NewClient creates a new libp2p-based exchange.Client that uses the libp2p
 // ChainExhange protocol as the fetching mechanism.
 func NewClient(lc fx.Lifecycle, host host.Host, pmgr peermgr.MaybePeerMgr) Client {
-	return &client{
+	c := &client{
 		host:        host,
 		peerTracker: newPeerTracker(lc, host, pmgr.Mgr),
 	}
+	c.sendRequest = c.sendRequestToPeer
+	return c
 }
 
 // Main logic of the client request service. The provided `Request`
@@ -112,26 +119,12 @@ func (c *client) doRequest(
 		default:
 		}
 
-		// Send request, read response.
-		res, err := c.sendRequestToPeer(ctx, peer, req)
-		if err != nil {
-			if !xerrors.Is(err, network.ErrNoConn) {
-				log.Warnf("could not send request to peer %s: %s",
-					peer.String(), err)
-			}
-			continue
-		}
-
-		// Process and validate response.
-		validRes, err := c.processResponse(req, res, tipsets)
+		validRes, err := c.requestPeer(ctx, peer, req, tipsets)
 		if err != nil {
-			log.Warnf("processing peer %s response failed: %s",
-				peer.String(), err)
 			continue
 		}
 
 		c.peerTracker.logGlobalSuccess(build.Clock.Since(globalTime))
-		c.host.ConnManager().TagPeer(peer, "bsync", SuccessPeerTagValue)
 		return validRes, nil
 	}
 
@@ -142,15 +135,42 @@ func (c *client) doRequest(
 	return nil, xerrors.Errorf(errString)
 }
 
+// requestPeer sends the request to the peer and validates the response,
+// recording the outcome in the peer tracker.
+func (c *client) requestPeer(ctx context.Context, peer peer.ID, req *Request, tipsets []*types.TipSet) (*validatedResponse, error) {
+	start := build.Clock.Now()
+
+	// Send request, read response.
+	res, err := c.sendRequest(ctx, peer, req)
+	if err != nil {
+		if !xerrors.Is(err, network.ErrNoConn) {
+			log.Warnf("could not send request to peer %s: %s",
+				peer.String(), err)
+		}
+		return nil, err
+	}
+
+	// Process and validate response.
+	validRes, err := c.processResponse(req, res, tipsets)
+	if err != nil {
+		log.Warnf("processing peer %s response failed: %s",
+			peer.String(), err)
+		c.peerTracker.logInvalid(peer)
+		return nil, err
+	}
+
+	c.peerTracker.logSuccess(peer, build.Clock.Since(start), uint64(len(res.Chain)))
+	c.host.ConnManager().TagPeer(peer, "bsync", SuccessPeerTagValue)
+	return validRes, nil
+}
+
 // Process and validate response. Check the status, the integrity of the
 // information returned, and that it matches the request. Extract the information
 // into a `validatedResponse` for the external-facing APIs to select what they
 // need.
 //
-// We are conflating in the single error returned both status and validation
-// errors. Peer penalization should happen here then, before returning, so
-// we can apply the correct penalties depending on the cause of the error.
-// FIXME: Add the `peer` as argument once we implement penalties.
+// Both status and validation errors are penalized as invalid responses by
+// `requestPeer`.
 func (c *client) processResponse(req *Request, res *Response, tipsets []*types.TipSet) (*validatedResponse, error) {
 	err := res.statusToError()
 	if err != nil {
@@ -339,6 +359,10 @@ func (c *client) GetFullTipSet(ctx context.Context, peer peer.ID, tsk types.TipS
 
 // GetChainMessages implements Client.GetChainMessages(). Refer to the godocs there.
 func (c *client) GetChainMessages(ctx context.Context, tipsets []*types.TipSet) ([]*CompactedMessages, error) {
+	if len(tipsets) > ParallelSegmentLength {
+		return c.getChainMessagesParallel(ctx, tipsets)
+	}
+
 	head := tipsets[0]
 	length := uint64(len(tipsets))
 
@@ -365,6 +389,123 @@ func (c *client) GetChainMessages(ctx context.Context, tipsets []*types.TipSet)
 	return validRes.messages, nil
 }
 
+// messageSegment is a range of tipsets to fetch the messages of, as indexes in
+// the tipsets passed to `getChainMessagesParallel`.
+type messageSegment struct {
+	start, end int
+	attempts   int
+}
+
+// getChainMessagesParallel splits the tipsets into segments and fetches their
+// messages from the best peers in parallel, each peer taking the next segment
+// once it's done with one, so faster peers serve more of the range. A segment
+// failed by a peer is handed to another peer, and the failing peer drops out
+// of this fetch.
+func (c *client) getChainMessagesParallel(ctx context.Context, tipsets []*types.TipSet) ([]*CompactedMessages, error) {
+	ctx, span := trace.StartSpan(ctx, "GetChainMessagesParallel")
+	defer span.End()
+	if span.IsRecordingEvents() {
+		span.AddAttributes(
+			trace.StringAttribute("tipset", fmt.Sprint(tipsets[0].Cids())),
+			trace.Int64Attribute("count", int64(len(tipsets))),
+		)
+	}
+
+	peers := c.getShuffledPeers()
+	if len(peers) == 0 {
+		return nil, xerrors.Errorf("no peers available")
+	}
+	if len(peers) > MaxParallelPeers {
+		peers = peers[:MaxParallelPeers]
+	}
+
+	nsegs := (len(tipsets) + ParallelSegmentLength - 1) / ParallelSegmentLength
+	queue := make(chan messageSegment, nsegs)
+	for start := 0; start < len(tipsets); start += ParallelSegmentLength {
+		end := start + ParallelSegmentLength
+		if end > len(tipsets) {
+			end = len(tipsets)
+		}
+		queue <- messageSegment{start: start, end: end}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	out := make([]*CompactedMessages, len(tipsets))
+
+	var lk sync.Mutex
+	remaining := len(tipsets)
+	var fetchErr error
+
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+
+			for {
+				var seg messageSegment
+				select {
+				case seg = <-queue:
+				case <-ctx.Done():
+					return
+				}
+
+				req := &Request{
+					Head:    tipsets[seg.start].Cids(),
+					Length:  uint64(seg.end - seg.start),
+					Options: Messages,
+				}
+
+				res, err := c.requestPeer(ctx, p, req, tipsets[seg.start:seg.end])
+				if err != nil {
+					seg.attempts++
+
+					lk.Lock()
+					if seg.attempts >= MaxSegmentAttempts && fetchErr == nil {
+						fetchErr = xerrors.Errorf("fetching messages at %d-%d failed %d times, last error: %w", seg.start, seg.end, seg.attempts, err)
+						cancel()
+					}
+					lk.Unlock()
+
+					if seg.attempts < MaxSegmentAttempts {
+						queue <- seg
+					}
+					return
+				}
+
+				n := copy(out[seg.start:seg.end], res.messages)
+
+				lk.Lock()
+				remaining -= n
+				if remaining == 0 {
+					cancel()
+				}
+				lk.Unlock()
+
+				if seg.start+n < seg.end {
+					// partial response, the rest goes back to the queue
+					queue <- messageSegment{start: seg.start + n, end: seg.end}
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if remaining > 0 {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("context cancelled: %w", err)
+		}
+		return nil, xerrors.Errorf("all peers failed fetching messages, %d of %d tipsets missing", remaining, len(tipsets))
+	}
+
+	return out, nil
+}
+
 // Send a request to a peer. Write request in the stream and read the
 // response back. We do not do any processing of the request/response
 // here.
@@ -444,9 +585,7 @@ func (c *client) sendRequestToPeer(ctx context.Context, peer peer.ID, req *Reque
 		)
 	}
 
-	c.peerTracker.logSuccess(peer, build.Clock.Since(connectionStart), uint64(len(res.Chain)))
-	// FIXME: We should really log a success only after we validate the response.
-	//  It might be a bit hard to do.
+	// The success is logged by `requestPeer` once the response is validated.
 	return &res, nil
 }
 
@@ -460,6 +599,11 @@ func (c *client) RemovePeer(p peer.ID) {
 	c.peerTracker.removePeer(p)
 }
 
+// PeerStats implements Client.PeerStats(). Refer to the godocs there.
+func (c *client) PeerStats() []PeerStats {
+	return c.peerTracker.stats()
+}
+
 // getShuffledPeers returns a preference-sorted set of peers (by latency
 // and failure counting), shuffling the first few peers so we don't always
 // pick the same peer.
@@ -0,0 +1,215 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+type fakePeer func(idx int, length uint64) (*Response, error)
+
+// fakeChain serves the messages of the tipsets, tagging each tipset's
+// messages with its index so the merged result can be checked
+type fakeChain struct {
+	tipsets []*types.TipSet
+	index   map[types.TipSetKey]int
+
+	lk       sync.Mutex
+	requests map[peer.ID]int
+}
+
+func newFakeChain(n int) *fakeChain {
+	fc := &fakeChain{
+		index:    map[types.TipSetKey]int{},
+		requests: map[peer.ID]int{},
+	}
+
+	var parent *types.TipSet
+	var chain []*types.TipSet
+	for i := 0; i < n; i++ {
+		ts := mock.TipSet(mock.MkBlock(parent, 1, uint64(i)))
+		chain = append(chain, ts)
+		parent = ts
+	}
+
+	// the head goes first, as when syncing
+	for i := len(chain) - 1; i >= 0; i-- {
+		fc.index[chain[i].Key()] = len(fc.tipsets)
+		fc.tipsets = append(fc.tipsets, chain[i])
+	}
+
+	return fc
+}
+
+func (fc *fakeChain) messages(idx int) *CompactedMessages {
+	return &CompactedMessages{
+		Bls:           []*types.Message{{Nonce: uint64(idx)}},
+		BlsIncludes:   [][]uint64{{0}},
+		SecpkIncludes: [][]uint64{{}},
+	}
+}
+
+func (fc *fakeChain) good(idx int, length uint64) (*Response, error) {
+	res := &Response{Status: Ok}
+	for i := idx; i < idx+int(length) && i < len(fc.tipsets); i++ {
+		res.Chain = append(res.Chain, &BSTipSet{Messages: fc.messages(i)})
+	}
+	return res, nil
+}
+
+// short serves at most 3 tipsets
+func (fc *fakeChain) short(idx int, length uint64) (*Response, error) {
+	if length > 3 {
+		res, err := fc.good(idx, 3)
+		res.Status = Partial
+		return res, err
+	}
+	return fc.good(idx, length)
+}
+
+// bad serves messages which don't match the blocks
+func (fc *fakeChain) bad(idx int, length uint64) (*Response, error) {
+	res, err := fc.good(idx, length)
+	for _, bs := range res.Chain {
+		bs.Messages.BlsIncludes = [][]uint64{{5}}
+	}
+	return res, err
+}
+
+func (fc *fakeChain) slow(idx int, length uint64) (*Response, error) {
+	time.Sleep(20 * time.Millisecond)
+	return fc.good(idx, length)
+}
+
+func (fc *fakeChain) failing(int, uint64) (*Response, error) {
+	return nil, xerrors.New("stream reset")
+}
+
+func newTestClient(t *testing.T, fc *fakeChain, peers map[peer.ID]fakePeer) *client {
+	mn := mocknet.New(context.Background())
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	c := &client{
+		host:        h,
+		peerTracker: &bsPeerTracker{peers: map[peer.ID]*peerStats{}},
+	}
+	c.sendRequest = func(ctx context.Context, p peer.ID, req *Request) (*Response, error) {
+		fc.lk.Lock()
+		fc.requests[p]++
+		fc.lk.Unlock()
+
+		idx, ok := fc.index[types.NewTipSetKey(req.Head...)]
+		if !ok {
+			return &Response{Status: NotFound}, nil
+		}
+		return peers[p](idx, req.Length)
+	}
+
+	for p := range peers {
+		c.AddPeer(p)
+	}
+
+	return c
+}
+
+func requireMessages(t *testing.T, msgs []*CompactedMessages, n int) {
+	require.Len(t, msgs, n)
+	for i, m := range msgs {
+		require.NotNil(t, m, "tipset %d", i)
+		require.Equal(t, uint64(i), m.Bls[0].Nonce)
+	}
+}
+
+func TestGetChainMessagesParallel(t *testing.T) {
+	ctx := context.Background()
+	fc := newFakeChain(4*ParallelSegmentLength + 3)
+
+	c := newTestClient(t, fc, map[peer.ID]fakePeer{
+		"good":  fc.good,
+		"short": fc.short,
+		"bad":   fc.bad,
+		"slow":  fc.slow,
+		"fail":  fc.failing,
+	})
+
+	msgs, err := c.GetChainMessages(ctx, fc.tipsets)
+	require.NoError(t, err)
+	requireMessages(t, msgs, len(fc.tipsets))
+
+	// peers sending invalid responses are penalized
+	for _, st := range c.PeerStats() {
+		if st.Peer == "bad" && st.Invalid > 0 {
+			require.Less(t, st.Score, 0.0)
+		}
+		if st.Peer == "good" {
+			require.Zero(t, st.Invalid)
+		}
+	}
+}
+
+func TestGetChainMessagesParallelPartial(t *testing.T) {
+	ctx := context.Background()
+	fc := newFakeChain(3*ParallelSegmentLength + 1)
+
+	// every response is partial, the rest of each segment is requeued
+	c := newTestClient(t, fc, map[peer.ID]fakePeer{
+		"short1": fc.short,
+		"short2": fc.short,
+	})
+
+	msgs, err := c.GetChainMessages(ctx, fc.tipsets)
+	require.NoError(t, err)
+	requireMessages(t, msgs, len(fc.tipsets))
+}
+
+func TestGetChainMessagesParallelFails(t *testing.T) {
+	ctx := context.Background()
+	fc := newFakeChain(2*ParallelSegmentLength + 1)
+
+	c := newTestClient(t, fc, map[peer.ID]fakePeer{
+		"bad":  fc.bad,
+		"fail": fc.failing,
+	})
+
+	_, err := c.GetChainMessages(ctx, fc.tipsets)
+	require.Error(t, err)
+
+	// each failing peer drops out of the fetch after its first failure
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+	require.Equal(t, 1, fc.requests["bad"])
+	require.Equal(t, 1, fc.requests["fail"])
+}
+
+func TestGetChainMessagesParallelSegmentAttempts(t *testing.T) {
+	ctx := context.Background()
+	fc := newFakeChain(ParallelSegmentLength + 1)
+
+	peers := map[peer.ID]fakePeer{}
+	for _, p := range []peer.ID{"a", "b", "c", "d", "e", "f", "g"} {
+		peers[p] = fc.failing
+	}
+	c := newTestClient(t, fc, peers)
+
+	_, err := c.GetChainMessages(ctx, fc.tipsets)
+	require.Error(t, err)
+
+	// a segment isn't requested more than MaxSegmentAttempts times
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+	var total int
+	for _, n := range fc.requests {
+		total += n
+	}
+	require.LessOrEqual(t, total, 2*MaxSegmentAttempts)
+}
@@ -2,6 +2,7 @@ package exchange
 
 import (
 	"context"
+	"time"
 
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -33,7 +34,9 @@ type Client interface {
 	GetBlocks(ctx context.Context, tsk types.TipSetKey, count int) ([]*types.TipSet, error)
 
 	// GetChainMessages fetches messages from the network, starting from the first provided tipset
-	// and returning messages from as many tipsets as requested or less.
+	// and returning messages from as many tipsets as requested or less. Long
+	// ranges are split into segments fetched in parallel from several peers,
+	// in which case messages for all the tipsets are returned.
 	GetChainMessages(ctx context.Context, tipsets []*types.TipSet) ([]*CompactedMessages, error)
 
 	// GetFullTipSet fetches a full tipset from a given peer. If successful,
@@ -47,4 +50,26 @@ type Client interface {
 	// RemovePeer removes a peer from the pool of peers that the Client
 	// requests data from.
 	RemovePeer(peer peer.ID)
+
+	// PeerStats returns the request statistics and score of the peers in the
+	// pool, best scored first.
+	PeerStats() []PeerStats
+}
+
+// PeerStats are the request statistics of a peer, as tracked by the Client.
+type PeerStats struct {
+	Peer peer.ID
+
+	// Score goes up with good responses, and down with failed, invalid or slow
+	// ones. Peers with a low score are only asked when better peers fail.
+	Score float64
+
+	Successes int
+	Failures  int
+	Invalid   int
+	Slow      int
+
+	// AvgTipSetTime is the moving average of the time taken per tipset served
+	AvgTipSetTime time.Duration
+	FirstSeen     time.Time
 }
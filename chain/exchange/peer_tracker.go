@@ -19,8 +19,14 @@ import (
 type peerStats struct {
 	successes   int
 	failures    int
+	invalid     int
+	slow        int
 	firstSeen   time.Time
 	averageTime time.Duration
+
+	// score goes up with good responses and down with failed, invalid or
+	// slow ones, see the penalties below
+	score float64
 }
 
 type bsPeerTracker struct {
@@ -82,6 +88,21 @@ const (
 	newPeerMul = 0.9
 )
 
+const (
+	maxPeerScore = 100
+	minPeerScore = -100
+
+	// badPeerScore is the score below which peers are only asked when all
+	// better peers failed
+	badPeerScore = -50
+
+	successReward   = 1
+	failurePenalty  = 5
+	invalidPenalty  = 25
+	slowPenalty     = 2
+	slowResponseMul = 4 // times the average time per tipset of all peers
+)
+
 func (bpt *bsPeerTracker) prefSortedPeers() []peer.ID {
 	// TODO: this could probably be cached, but as long as its not too many peers, fine for now
 	bpt.lk.Lock()
@@ -117,6 +138,11 @@ func (bpt *bsPeerTracker) prefSortedPeers() []peer.ID {
 			costJ = getPeerInitLat(out[j])
 		}
 
+		badI, badJ := pi.score < badPeerScore, pj.score < badPeerScore
+		if badI != badJ {
+			return badJ
+		}
+
 		return costI < costJ
 	})
 
@@ -167,7 +193,16 @@ func (bpt *bsPeerTracker) logSuccess(p peer.ID, dur time.Duration, reqSize uint6
 	if reqSize == 0 {
 		reqSize = 1
 	}
-	logTime(pi, dur/time.Duration(reqSize))
+
+	perTipSet := dur / time.Duration(reqSize)
+	if avg := bpt.avgTipSetTime(); avg > 0 && perTipSet > slowResponseMul*avg {
+		pi.slow++
+		bpt.adjustScore(p, pi, -slowPenalty)
+	} else {
+		bpt.adjustScore(p, pi, successReward)
+	}
+
+	logTime(pi, perTipSet)
 }
 
 func (bpt *bsPeerTracker) logFailure(p peer.ID, dur time.Duration, reqSize uint64) {
@@ -182,12 +217,86 @@ func (bpt *bsPeerTracker) logFailure(p peer.ID, dur time.Duration, reqSize uint6
 	}
 
 	pi.failures++
+	bpt.adjustScore(p, pi, -failurePenalty)
 	if reqSize == 0 {
 		reqSize = 1
 	}
 	logTime(pi, dur/time.Duration(reqSize))
 }
 
+// logInvalid records a response which failed validation.
+func (bpt *bsPeerTracker) logInvalid(p peer.ID) {
+	bpt.lk.Lock()
+	defer bpt.lk.Unlock()
+
+	pi, ok := bpt.peers[p]
+	if !ok {
+		log.Warnw("log invalid called on peer not in tracker", "peerid", p.String())
+		return
+	}
+
+	pi.invalid++
+	bpt.adjustScore(p, pi, -invalidPenalty)
+}
+
+// avgTipSetTime returns the average time per tipset over the peers which
+// served requests. Must be called with the lock held.
+func (bpt *bsPeerTracker) avgTipSetTime() time.Duration {
+	var sum time.Duration
+	var n int
+	for _, pi := range bpt.peers {
+		if pi.successes > 0 {
+			sum += pi.averageTime
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / time.Duration(n)
+}
+
+// adjustScore changes the score of the peer and reports it to the peer
+// manager. Must be called with the lock held.
+func (bpt *bsPeerTracker) adjustScore(p peer.ID, pi *peerStats, delta float64) {
+	pi.score += delta
+	if pi.score > maxPeerScore {
+		pi.score = maxPeerScore
+	}
+	if pi.score < minPeerScore {
+		pi.score = minPeerScore
+	}
+
+	if bpt.pmgr != nil {
+		bpt.pmgr.SetPeerScore(p, pi.score)
+	}
+}
+
+func (bpt *bsPeerTracker) stats() []PeerStats {
+	bpt.lk.Lock()
+	defer bpt.lk.Unlock()
+
+	out := make([]PeerStats, 0, len(bpt.peers))
+	for p, pi := range bpt.peers {
+		out = append(out, PeerStats{
+			Peer:          p,
+			Score:         pi.score,
+			Successes:     pi.successes,
+			Failures:      pi.failures,
+			Invalid:       pi.invalid,
+			Slow:          pi.slow,
+			AvgTipSetTime: pi.averageTime,
+			FirstSeen:     pi.firstSeen,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+
+	return out
+}
+
 func (bpt *bsPeerTracker) removePeer(p peer.ID) {
 	bpt.lk.Lock()
 	defer bpt.lk.Unlock()
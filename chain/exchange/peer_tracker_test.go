@@ -0,0 +1,71 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPeerTracker(peers ...peer.ID) *bsPeerTracker {
+	bpt := &bsPeerTracker{peers: map[peer.ID]*peerStats{}}
+	for _, p := range peers {
+		bpt.addPeer(p)
+	}
+	return bpt
+}
+
+func TestPeerTrackerScore(t *testing.T) {
+	bpt := newTestPeerTracker("a", "b")
+
+	bpt.logSuccess("a", 10*time.Millisecond, 10)
+	require.Equal(t, float64(successReward), bpt.peers["a"].score)
+
+	// more than slowResponseMul times slower than the average
+	bpt.logSuccess("b", time.Second, 10)
+	require.Equal(t, -float64(slowPenalty), bpt.peers["b"].score)
+	require.Equal(t, 1, bpt.peers["b"].slow)
+
+	bpt.logFailure("a", time.Millisecond, 1)
+	require.Equal(t, float64(successReward-failurePenalty), bpt.peers["a"].score)
+
+	bpt.logInvalid("b")
+	require.Equal(t, -float64(slowPenalty+invalidPenalty), bpt.peers["b"].score)
+	require.Equal(t, 1, bpt.peers["b"].invalid)
+
+	// scores are bounded
+	for i := 0; i < 10; i++ {
+		bpt.logInvalid("b")
+	}
+	require.Equal(t, float64(minPeerScore), bpt.peers["b"].score)
+
+	for i := 0; i < 2*maxPeerScore; i++ {
+		bpt.logSuccess("a", 10*time.Millisecond, 10)
+	}
+	require.Equal(t, float64(maxPeerScore), bpt.peers["a"].score)
+
+	// unknown peers are ignored
+	bpt.logInvalid("c")
+	require.NotContains(t, bpt.peers, peer.ID("c"))
+}
+
+func TestPeerTrackerBadPeersLast(t *testing.T) {
+	bpt := newTestPeerTracker("fast", "slow", "bad")
+
+	bpt.logSuccess("fast", time.Millisecond, 1)
+	bpt.logSuccess("slow", 2*time.Millisecond, 1)
+	// the bad peer is the fastest, but sent invalid responses
+	bpt.logSuccess("bad", time.Microsecond, 1)
+	for i := 0; i < 3; i++ {
+		bpt.logInvalid("bad")
+	}
+	require.Less(t, bpt.peers["bad"].score, float64(badPeerScore))
+
+	require.Equal(t, []peer.ID{"fast", "slow", "bad"}, bpt.prefSortedPeers())
+
+	stats := bpt.stats()
+	require.Len(t, stats, 3)
+	require.Equal(t, peer.ID("bad"), stats[2].Peer)
+	require.Equal(t, 3, stats[2].Invalid)
+}
@@ -44,6 +44,17 @@ const (
 	WriteResDeadline    = 60 * time.Second
 )
 
+const (
+	// ParallelSegmentLength is the number of tipsets per request when
+	// fetching the messages of a range of tipsets from several peers.
+	ParallelSegmentLength = 8
+	// MaxParallelPeers is the number of peers a range is fetched from.
+	MaxParallelPeers = ShufflePeersPrefix
+	// MaxSegmentAttempts is the number of peers a segment is requested from
+	// before the whole fetch fails.
+	MaxSegmentAttempts = 5
+)
+
 // FIXME: Rename. Make private.
 type Request struct {
 	// List of ordered CIDs comprising a `TipSetKey` from where to start
@@ -0,0 +1,492 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// Transfer is a message sent or received by an address, either a top-level
+// message or a value transfer made by an actor while executing one.
+type Transfer struct {
+	// Message is the top-level message which caused the transfer
+	Message cid.Cid
+	// TipSet is the tipset in which the message was executed
+	TipSet types.TipSetKey
+	Epoch  abi.ChainEpoch
+
+	From   address.Address
+	To     address.Address
+	Value  abi.TokenAmount
+	Method abi.MethodNum
+	// ExitCode is non-zero if the transfer, or a call it was made from,
+	// failed, in which case the value wasn't transferred
+	ExitCode exitcode.ExitCode
+	// GasCost is the total cost paid by the sender of a top-level message
+	GasCost abi.TokenAmount
+
+	// Internal is set for transfers made by actors, found in execution traces
+	Internal bool
+	// Implicit is set for transfers made while executing messages which aren't
+	// on chain, such as block rewards and cron
+	Implicit bool
+}
+
+// AddrIndex maps addresses to the transfers they were part of on the current
+// chain.
+type AddrIndex interface {
+	// History returns up to limit transfers of the address, from the most
+	// recent epoch, starting after the cursor returned by a previous call. The
+	// returned cursor is empty once there are no more transfers.
+	History(ctx context.Context, addr address.Address, cursor string, limit int) ([]Transfer, string, error)
+
+	// Backfill indexes the transfers executed in the given number of epochs
+	// up to and including from, returning the number of transfers indexed.
+	Backfill(ctx context.Context, from *types.TipSet, epochs abi.ChainEpoch) (int, error)
+}
+
+// StateTracer computes the execution traces and states the address index is
+// built from, implemented by the state manager.
+type StateTracer interface {
+	ExecutionTrace(ctx context.Context, ts *types.TipSet) (cid.Cid, []*api.InvocResult, error)
+	ParentState(ts *types.TipSet) (*state.StateTree, error)
+}
+
+type addrIndex struct {
+	ds datastore.Batching
+	cs *store.ChainStore
+	st StateTracer
+
+	// newHead is signaled when the chain head changes
+	newHead chan struct{}
+	// head is the tipset the index is caught up with, only used by run
+	head *types.TipSet
+}
+
+// headKey stores the key of the tipset the index is caught up with, so that
+// the tipsets applied while the node was stopped are indexed when it starts.
+var headKey = datastore.NewKey("/head")
+
+// indexedTipSet records the keys written for the transfers executed in a
+// tipset, so that they can be removed when it's reverted.
+type indexedTipSet struct {
+	TipSet types.TipSetKey
+	Keys   []string
+}
+
+// NewAddrIndex creates an index kept in the datastore, which is updated in the
+// background as the chain head changes, until ctx is canceled, starting from
+// the head it was caught up with when the node stopped. Computing the
+// execution traces takes as long as executing the messages again, which is why
+// it isn't done in the head change notification, which only signals the
+// indexer to catch up with the new head.
+func NewAddrIndex(ctx context.Context, ds datastore.Batching, cs *store.ChainStore, st StateTracer) AddrIndex {
+	ai := &addrIndex{
+		ds: ds,
+		cs: cs,
+		st: st,
+
+		newHead: make(chan struct{}, 1),
+	}
+	ai.head = ai.lastHead()
+
+	// catch up with the tipsets applied since the last indexed head
+	ai.newHead <- struct{}{}
+
+	cs.SubscribeHeadChanges(func(rev, app []*types.TipSet) error {
+		select {
+		case ai.newHead <- struct{}{}:
+		default:
+			// the indexer hasn't caught up with the last signal yet
+		}
+		return nil
+	})
+
+	go ai.run(ctx)
+
+	return ai
+}
+
+func (ai *addrIndex) run(ctx context.Context) {
+	for {
+		select {
+		case <-ai.newHead:
+			if err := ai.catchUp(ctx, ai.cs.GetHeaviestTipSet()); err != nil {
+				log.Errorw("updating address index", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lastHead returns the tipset the index was caught up with when the node
+// stopped, or the current head if it isn't known.
+func (ai *addrIndex) lastHead() *types.TipSet {
+	b, err := ai.ds.Get(headKey)
+	if err == datastore.ErrNotFound {
+		return ai.cs.GetHeaviestTipSet()
+	}
+	if err != nil {
+		log.Errorw("getting last indexed head, indexing from the current head", "error", err)
+		return ai.cs.GetHeaviestTipSet()
+	}
+
+	var tsk types.TipSetKey
+	if err := json.Unmarshal(b, &tsk); err != nil {
+		log.Errorw("unmarshaling last indexed head, indexing from the current head", "error", err)
+		return ai.cs.GetHeaviestTipSet()
+	}
+
+	ts, err := ai.cs.LoadTipSet(tsk)
+	if err != nil {
+		log.Errorw("loading last indexed head, indexing from the current head", "tipset", tsk, "error", err)
+		return ai.cs.GetHeaviestTipSet()
+	}
+
+	return ts
+}
+
+func (ai *addrIndex) setHead(ts *types.TipSet) error {
+	ai.head = ts
+
+	b, err := json.Marshal(ts.Key())
+	if err != nil {
+		return err
+	}
+	if err := ai.ds.Put(headKey, b); err != nil {
+		return xerrors.Errorf("saving indexed head: %w", err)
+	}
+	return nil
+}
+
+// catchUp reverts the tipsets indexed since the common ancestor of the last
+// indexed head and the new head, and indexes the tipsets up to the new head.
+func (ai *addrIndex) catchUp(ctx context.Context, head *types.TipSet) error {
+	if head == nil {
+		return nil
+	}
+	if ai.head == nil {
+		if _, err := ai.indexTipSet(ctx, head); err != nil {
+			log.Errorw("indexing tipset transfers", "height", head.Height(), "error", err)
+		}
+		return ai.setHead(head)
+	}
+
+	rev, app, err := ai.cs.ReorgOps(ai.head, head)
+	if err != nil {
+		return xerrors.Errorf("computing reorg from %d to %d: %w", ai.head.Height(), head.Height(), err)
+	}
+
+	for _, ts := range rev {
+		if err := ai.revertTipSet(ts); err != nil {
+			log.Errorw("removing reverted tipset from address index", "height", ts.Height(), "error", err)
+		}
+	}
+
+	// apply is ordered from the new head down
+	for i := len(app) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		ts := app[i]
+		if _, err := ai.indexTipSet(ctx, ts); err != nil {
+			log.Errorw("indexing tipset transfers", "height", ts.Height(), "error", err)
+		}
+
+		// saved as it goes, so that a restart resumes from here
+		if err := ai.setHead(ts); err != nil {
+			return err
+		}
+	}
+
+	return ai.setHead(head)
+}
+
+func addrPrefix(addr address.Address) string {
+	return "/a/" + addr.String() + "/"
+}
+
+// transferKey orders the transfers of an address from the most recent epoch,
+// then in execution order within an epoch.
+func transferKey(addr address.Address, epoch abi.ChainEpoch, msgIdx, seq int) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("%s%016x/%06d/%06d", addrPrefix(addr), uint64(math.MaxInt64-int64(epoch)), msgIdx, seq))
+}
+
+func tipSetKey(epoch abi.ChainEpoch) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("/t/%d", epoch))
+}
+
+func (ai *addrIndex) History(ctx context.Context, addr address.Address, cursor string, limit int) ([]Transfer, string, error) {
+	prefix := addrPrefix(addr)
+	if cursor != "" && !strings.HasPrefix(cursor, prefix) {
+		return nil, "", xerrors.Errorf("cursor doesn't belong to address %s", addr)
+	}
+
+	q := query.Query{
+		Prefix: prefix,
+		Orders: []query.Order{query.OrderByKey{}},
+		Limit:  limit,
+	}
+	if cursor != "" {
+		q.Filters = []query.Filter{query.FilterKeyCompare{Op: query.GreaterThan, Key: cursor}}
+	}
+
+	res, err := ai.ds.Query(q)
+	if err != nil {
+		return nil, "", xerrors.Errorf("querying address index: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []Transfer
+	var last string
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, "", xerrors.Errorf("reading address index: %w", r.Error)
+		}
+
+		var t Transfer
+		if err := json.Unmarshal(r.Value, &t); err != nil {
+			return nil, "", xerrors.Errorf("unmarshaling transfer: %w", err)
+		}
+
+		out = append(out, t)
+		last = r.Key
+	}
+
+	if limit <= 0 || len(out) < limit {
+		last = ""
+	}
+
+	return out, last, nil
+}
+
+// executionTraces returns the traces of the messages executed in the tipset,
+// which are the messages included in its parent, including implicit messages.
+func (ai *addrIndex) executionTraces(ctx context.Context, ts *types.TipSet) ([]*api.InvocResult, error) {
+	if ts.Height() == 0 {
+		return nil, nil
+	}
+
+	pts, err := ai.cs.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, xerrors.Errorf("loading parent tipset: %w", err)
+	}
+
+	_, trace, err := ai.st.ExecutionTrace(ctx, pts)
+	if err != nil {
+		return nil, xerrors.Errorf("computing execution trace: %w", err)
+	}
+
+	return trace, nil
+}
+
+func (ai *addrIndex) indexTipSet(ctx context.Context, ts *types.TipSet) (int, error) {
+	trace, err := ai.executionTraces(ctx, ts)
+	if err != nil {
+		return 0, err
+	}
+
+	// resolve addresses in the state after execution, so that actors created
+	// by the messages can be resolved
+	st, err := ai.st.ParentState(ts)
+	if err != nil {
+		return 0, xerrors.Errorf("loading state: %w", err)
+	}
+	resolve := func(addr address.Address) address.Address {
+		id, err := st.LookupID(addr)
+		if err != nil {
+			return addr
+		}
+		return id
+	}
+
+	batch, err := ai.ds.Batch()
+	if err != nil {
+		return 0, err
+	}
+
+	// remove transfers of another tipset at the same height, whose revert
+	// could have been missed, e.g. while the node was stopped
+	if err := ai.removeTipSet(batch, ts.Height(), nil); err != nil {
+		return 0, err
+	}
+
+	var n int
+	its := indexedTipSet{TipSet: ts.Key()}
+	put := func(key datastore.Key, b []byte) error {
+		its.Keys = append(its.Keys, key.String())
+		return batch.Put(key, b)
+	}
+
+	for i, ir := range trace {
+		implicit := ir.Msg.From == builtin.SystemActorAddr
+
+		var seq int
+		var add func(et types.ExecutionTrace, code exitcode.ExitCode, internal bool) error
+		add = func(et types.ExecutionTrace, code exitcode.ExitCode, internal bool) error {
+			if et.Msg == nil {
+				return nil
+			}
+
+			// a transfer only happens if the calls it was made from succeeded
+			if code.IsSuccess() && et.MsgRct != nil {
+				code = et.MsgRct.ExitCode
+			}
+
+			if !internal || !et.Msg.Value.IsZero() {
+				t := Transfer{
+					Message:  ir.MsgCid,
+					TipSet:   ts.Key(),
+					Epoch:    ts.Height(),
+					From:     resolve(et.Msg.From),
+					To:       resolve(et.Msg.To),
+					Value:    et.Msg.Value,
+					Method:   et.Msg.Method,
+					ExitCode: code,
+					GasCost:  big.Zero(),
+					Internal: internal,
+					Implicit: implicit,
+				}
+				if !internal && !implicit {
+					t.GasCost = ir.GasCost.TotalCost
+				}
+
+				b, err := json.Marshal(t)
+				if err != nil {
+					return err
+				}
+
+				if err := put(transferKey(t.From, ts.Height(), i, seq), b); err != nil {
+					return err
+				}
+				if t.To != t.From {
+					if err := put(transferKey(t.To, ts.Height(), i, seq), b); err != nil {
+						return err
+					}
+				}
+				seq++
+				n++
+			}
+
+			for _, sub := range et.Subcalls {
+				if err := add(sub, code, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		et := ir.ExecutionTrace
+		if et.Msg == nil {
+			et.Msg = ir.Msg
+		}
+		if et.MsgRct == nil {
+			et.MsgRct = ir.MsgRct
+		}
+		if err := add(et, exitcode.Ok, false); err != nil {
+			return 0, xerrors.Errorf("indexing transfers of message %s: %w", ir.MsgCid, err)
+		}
+	}
+
+	b, err := json.Marshal(its)
+	if err != nil {
+		return 0, err
+	}
+	if err := batch.Put(tipSetKey(ts.Height()), b); err != nil {
+		return 0, err
+	}
+
+	if err := batch.Commit(); err != nil {
+		return 0, xerrors.Errorf("writing address index: %w", err)
+	}
+
+	return n, nil
+}
+
+// removeTipSet adds the removal of the transfers indexed at the given height
+// to the batch, if they were executed in tsk, or in any tipset if tsk is nil.
+func (ai *addrIndex) removeTipSet(batch datastore.Batch, epoch abi.ChainEpoch, tsk *types.TipSetKey) error {
+	b, err := ai.ds.Get(tipSetKey(epoch))
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return xerrors.Errorf("getting indexed tipset: %w", err)
+	}
+
+	var its indexedTipSet
+	if err := json.Unmarshal(b, &its); err != nil {
+		return xerrors.Errorf("unmarshaling indexed tipset: %w", err)
+	}
+	if tsk != nil && its.TipSet != *tsk {
+		return nil
+	}
+
+	for _, k := range its.Keys {
+		if err := batch.Delete(datastore.NewKey(k)); err != nil {
+			return err
+		}
+	}
+
+	return batch.Delete(tipSetKey(epoch))
+}
+
+func (ai *addrIndex) revertTipSet(ts *types.TipSet) error {
+	batch, err := ai.ds.Batch()
+	if err != nil {
+		return err
+	}
+
+	tsk := ts.Key()
+	if err := ai.removeTipSet(batch, ts.Height(), &tsk); err != nil {
+		return err
+	}
+
+	return batch.Commit()
+}
+
+func (ai *addrIndex) Backfill(ctx context.Context, from *types.TipSet, epochs abi.ChainEpoch) (int, error) {
+	stop := from.Height() - epochs
+
+	var n int
+	for ts := from; ts.Height() > stop && ts.Height() > 0; {
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+
+		c, err := ai.indexTipSet(ctx, ts)
+		if err != nil {
+			return n, xerrors.Errorf("indexing tipset at %d: %w", ts.Height(), err)
+		}
+		n += c
+
+		ts, err = ai.cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return n, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+	}
+
+	return n, nil
+}
+
+var _ AddrIndex = (*addrIndex)(nil)
@@ -0,0 +1,375 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/exitcode"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+// fakeTracer returns the traces set for the messages included in a tipset
+type fakeTracer struct {
+	traces map[types.TipSetKey][]*api.InvocResult
+	st     *state.StateTree
+}
+
+func newFakeTracer(t *testing.T) *fakeTracer {
+	st, err := state.NewStateTree(cbor.NewCborStore(blockstore.NewMemory()), types.StateTreeVersion0)
+	require.NoError(t, err)
+
+	return &fakeTracer{
+		traces: map[types.TipSetKey][]*api.InvocResult{},
+		st:     st,
+	}
+}
+
+func (ft *fakeTracer) ExecutionTrace(ctx context.Context, ts *types.TipSet) (cid.Cid, []*api.InvocResult, error) {
+	return cid.Undef, ft.traces[ts.Key()], nil
+}
+
+func (ft *fakeTracer) ParentState(*types.TipSet) (*state.StateTree, error) {
+	return ft.st, nil
+}
+
+func newTestAddrIndex(tc *testChain, ft *fakeTracer) *addrIndex {
+	return &addrIndex{
+		ds: syncds.MutexWrap(datastore.NewMapDatastore()),
+		cs: tc.cs,
+		st: ft,
+	}
+}
+
+func call(from, to uint64, value uint64, code exitcode.ExitCode, subcalls ...types.ExecutionTrace) types.ExecutionTrace {
+	return types.ExecutionTrace{
+		Msg: &types.Message{
+			From:  mock.Address(from),
+			To:    mock.Address(to),
+			Value: types.NewInt(value),
+		},
+		MsgRct:   &types.MessageReceipt{ExitCode: code},
+		Subcalls: subcalls,
+	}
+}
+
+// invoc creates the result of a top-level message, with the message and
+// receipt only set on the result, as returned for messages which failed
+// before being applied
+func invoc(top types.ExecutionTrace, gas uint64) *api.InvocResult {
+	return &api.InvocResult{
+		MsgCid:         top.Msg.Cid(),
+		Msg:            top.Msg,
+		MsgRct:         top.MsgRct,
+		GasCost:        api.MsgGasCost{TotalCost: types.NewInt(gas)},
+		ExecutionTrace: types.ExecutionTrace{Subcalls: top.Subcalls},
+	}
+}
+
+type transferSummary struct {
+	From, To uint64
+	Value    uint64
+	ExitCode exitcode.ExitCode
+	Internal bool
+	Implicit bool
+	GasCost  uint64
+}
+
+func summarize(t *testing.T, ts []Transfer) []transferSummary {
+	id := func(a address.Address) uint64 {
+		i, err := address.IDFromAddress(a)
+		require.NoError(t, err)
+		return i
+	}
+
+	var out []transferSummary
+	for _, tr := range ts {
+		out = append(out, transferSummary{
+			From:     id(tr.From),
+			To:       id(tr.To),
+			Value:    tr.Value.Uint64(),
+			ExitCode: tr.ExitCode,
+			Internal: tr.Internal,
+			Implicit: tr.Implicit,
+			GasCost:  tr.GasCost.Uint64(),
+		})
+	}
+	return out
+}
+
+func history(t *testing.T, ai *addrIndex, addr uint64) []Transfer {
+	ts, cursor, err := ai.History(context.Background(), mock.Address(addr), "", 0)
+	require.NoError(t, err)
+	require.Empty(t, cursor)
+	return ts
+}
+
+func TestAddrIndexTransfers(t *testing.T) {
+	tc := newTestChain(t)
+	ft := newFakeTracer(t)
+	ai := newTestAddrIndex(tc, ft)
+
+	ts1 := tc.mkTipSet(tc.gen, 1, nil, nil)
+	ts2 := tc.mkTipSet(ts1, 1, nil, nil)
+
+	ft.traces[ts1.Key()] = []*api.InvocResult{
+		// internal transfers, skipping calls without value
+		invoc(call(101, 102, 5, exitcode.Ok,
+			call(102, 103, 2, exitcode.Ok),
+			call(102, 104, 0, exitcode.Ok,
+				call(104, 105, 1, exitcode.Ok)),
+		), 7),
+		// failed message, its subcalls didn't transfer anything
+		invoc(call(101, 106, 3, exitcode.ErrInsufficientFunds,
+			call(106, 107, 1, exitcode.Ok),
+		), 8),
+		// failed subcall
+		invoc(call(101, 108, 0, exitcode.Ok,
+			call(108, 109, 4, exitcode.ErrForbidden,
+				call(109, 110, 1, exitcode.Ok)),
+		), 9),
+		// block reward, from the system actor
+		invoc(call(0, 111, 10, exitcode.Ok), 0),
+	}
+
+	n, err := ai.indexTipSet(tc.ctx, ts2)
+	require.NoError(t, err)
+	require.Equal(t, 9, n)
+
+	require.Equal(t, []transferSummary{
+		{From: 101, To: 102, Value: 5, GasCost: 7},
+		{From: 101, To: 106, Value: 3, ExitCode: exitcode.ErrInsufficientFunds, GasCost: 8},
+		{From: 101, To: 108, GasCost: 9},
+	}, summarize(t, history(t, ai, 101)))
+
+	require.Equal(t, []transferSummary{
+		{From: 101, To: 102, Value: 5, GasCost: 7},
+		{From: 102, To: 103, Value: 2, Internal: true},
+	}, summarize(t, history(t, ai, 102)))
+
+	// the call without value isn't indexed, the transfer it made is
+	require.Equal(t, []transferSummary{
+		{From: 104, To: 105, Value: 1, Internal: true},
+	}, summarize(t, history(t, ai, 104)))
+
+	require.Equal(t, []transferSummary{
+		{From: 106, To: 107, Value: 1, ExitCode: exitcode.ErrInsufficientFunds, Internal: true},
+	}, summarize(t, history(t, ai, 107)))
+
+	require.Equal(t, []transferSummary{
+		{From: 108, To: 109, Value: 4, ExitCode: exitcode.ErrForbidden, Internal: true},
+		{From: 109, To: 110, Value: 1, ExitCode: exitcode.ErrForbidden, Internal: true},
+	}, summarize(t, history(t, ai, 109)))
+
+	require.Equal(t, []transferSummary{
+		{From: 0, To: 111, Value: 10, Implicit: true},
+	}, summarize(t, history(t, ai, 111)))
+
+	for _, tr := range history(t, ai, 101) {
+		require.Equal(t, ts2.Key(), tr.TipSet)
+		require.Equal(t, ts2.Height(), tr.Epoch)
+	}
+}
+
+func TestAddrIndexHistoryPaging(t *testing.T) {
+	tc := newTestChain(t)
+	ft := newFakeTracer(t)
+	ai := newTestAddrIndex(tc, ft)
+
+	// three epochs with two messages each, of increasing value
+	parent := tc.gen
+	var value uint64
+	for i := 0; i < 3; i++ {
+		ft.traces[parent.Key()] = []*api.InvocResult{
+			invoc(call(101, 102, value, exitcode.Ok), 0),
+			invoc(call(101, 103, value+1, exitcode.Ok), 0),
+		}
+		value += 2
+
+		ts := tc.mkTipSet(parent, 1, nil, nil)
+		_, err := ai.indexTipSet(tc.ctx, ts)
+		require.NoError(t, err)
+		parent = ts
+	}
+
+	values := func(ts []Transfer) []uint64 {
+		var out []uint64
+		for _, s := range summarize(t, ts) {
+			out = append(out, s.Value)
+		}
+		return out
+	}
+
+	// the most recent epoch first, in execution order within an epoch
+	page, cursor, err := ai.History(tc.ctx, mock.Address(101), "", 4)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4, 5, 2, 3}, values(page))
+	require.NotEmpty(t, cursor)
+
+	page, cursor, err = ai.History(tc.ctx, mock.Address(101), cursor, 4)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1}, values(page))
+	require.Empty(t, cursor)
+
+	// a full last page returns a cursor to an empty page
+	page, cursor, err = ai.History(tc.ctx, mock.Address(102), "", 3)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4, 2, 0}, values(page))
+	require.NotEmpty(t, cursor)
+
+	page, cursor, err = ai.History(tc.ctx, mock.Address(102), cursor, 3)
+	require.NoError(t, err)
+	require.Empty(t, page)
+	require.Empty(t, cursor)
+
+	// cursors can't be used for other addresses
+	_, cursor, err = ai.History(tc.ctx, mock.Address(101), "", 1)
+	require.NoError(t, err)
+	_, _, err = ai.History(tc.ctx, mock.Address(102), cursor, 1)
+	require.Error(t, err)
+}
+
+func TestAddrIndexRevert(t *testing.T) {
+	tc := newTestChain(t)
+	ft := newFakeTracer(t)
+	ai := newTestAddrIndex(tc, ft)
+
+	ts1 := tc.mkTipSet(tc.gen, 1, nil, nil)
+	ts2 := tc.mkTipSet(ts1, 1, nil, nil)
+	ts2b := tc.mkTipSet(ts1, 2, nil, nil)
+	ft.traces[ts1.Key()] = []*api.InvocResult{
+		invoc(call(101, 102, 1, exitcode.Ok), 0),
+	}
+
+	_, err := ai.indexTipSet(tc.ctx, ts2)
+	require.NoError(t, err)
+	require.Len(t, history(t, ai, 101), 1)
+
+	// reverting another tipset at the same height keeps the transfers
+	require.NoError(t, ai.revertTipSet(ts2b))
+	require.Len(t, history(t, ai, 102), 1)
+
+	require.NoError(t, ai.revertTipSet(ts2))
+	require.Empty(t, history(t, ai, 101))
+	require.Empty(t, history(t, ai, 102))
+
+	_, err = ai.ds.Get(tipSetKey(ts2.Height()))
+	require.Equal(t, datastore.ErrNotFound, err)
+}
+
+func TestAddrIndexSameHeightCleanup(t *testing.T) {
+	tc := newTestChain(t)
+	ft := newFakeTracer(t)
+	ai := newTestAddrIndex(tc, ft)
+
+	ts1 := tc.mkTipSet(tc.gen, 1, nil, nil)
+	ts1b := tc.mkTipSet(tc.gen, 2, nil, nil)
+	ts2 := tc.mkTipSet(ts1, 1, nil, nil)
+	ts2b := tc.mkTipSet(ts1b, 2, nil, nil)
+	ft.traces[ts1.Key()] = []*api.InvocResult{
+		invoc(call(101, 102, 1, exitcode.Ok), 0),
+	}
+	ft.traces[ts1b.Key()] = []*api.InvocResult{
+		invoc(call(101, 103, 2, exitcode.Ok), 0),
+	}
+
+	_, err := ai.indexTipSet(tc.ctx, ts2)
+	require.NoError(t, err)
+
+	// ts2 wasn't reverted, e.g. because the node was stopped during the reorg
+	_, err = ai.indexTipSet(tc.ctx, ts2b)
+	require.NoError(t, err)
+
+	require.Empty(t, history(t, ai, 102))
+	require.Equal(t, []transferSummary{
+		{From: 101, To: 103, Value: 2},
+	}, summarize(t, history(t, ai, 101)))
+	require.Equal(t, ts2b.Key(), history(t, ai, 103)[0].TipSet)
+}
+
+func TestAddrIndexCatchUp(t *testing.T) {
+	tc := newTestChain(t)
+	ft := newFakeTracer(t)
+	ai := newTestAddrIndex(tc, ft)
+
+	ts1 := tc.mkTipSet(tc.gen, 1, nil, nil)
+	ts2 := tc.mkTipSet(ts1, 1, nil, nil)
+	ts3 := tc.mkTipSet(ts2, 1, nil, nil)
+	ts2b := tc.mkTipSet(ts1, 2, nil, nil)
+	ts3b := tc.mkTipSet(ts2b, 2, nil, nil)
+	ts4b := tc.mkTipSet(ts3b, 2, nil, nil)
+
+	ft.traces[ts1.Key()] = []*api.InvocResult{invoc(call(101, 102, 1, exitcode.Ok), 0)}
+	ft.traces[ts2.Key()] = []*api.InvocResult{invoc(call(101, 102, 2, exitcode.Ok), 0)}
+	ft.traces[ts2b.Key()] = []*api.InvocResult{invoc(call(101, 102, 3, exitcode.Ok), 0)}
+	ft.traces[ts3b.Key()] = []*api.InvocResult{invoc(call(101, 102, 4, exitcode.Ok), 0)}
+
+	values := func() []uint64 {
+		var out []uint64
+		for _, s := range summarize(t, history(t, ai, 101)) {
+			out = append(out, s.Value)
+		}
+		return out
+	}
+
+	// the first head is indexed on its own
+	require.NoError(t, ai.catchUp(tc.ctx, ts1))
+	require.Empty(t, values())
+
+	// several head changes are caught up with at once
+	require.NoError(t, ai.catchUp(tc.ctx, ts3))
+	require.Equal(t, []uint64{2, 1}, values())
+	require.Equal(t, ts3, ai.head)
+
+	// reorg, ts2 and ts3 are reverted, ts2b executes the messages of ts1 again
+	require.NoError(t, ai.catchUp(tc.ctx, ts4b))
+	require.Equal(t, []uint64{4, 3, 1}, values())
+	require.Equal(t, ts4b, ai.head)
+}
+
+func TestAddrIndexResume(t *testing.T) {
+	tc := newTestChain(t)
+	ft := newFakeTracer(t)
+	ai := newTestAddrIndex(tc, ft)
+
+	ts1 := tc.mkTipSet(tc.gen, 1, nil, nil)
+	ts2 := tc.mkTipSet(ts1, 1, nil, nil)
+	ts3 := tc.mkTipSet(ts2, 1, nil, nil)
+	ts4 := tc.mkTipSet(ts3, 1, nil, nil)
+
+	ft.traces[ts2.Key()] = []*api.InvocResult{invoc(call(101, 102, 1, exitcode.Ok), 0)}
+	ft.traces[ts3.Key()] = []*api.InvocResult{invoc(call(101, 102, 2, exitcode.Ok), 0)}
+	ft.traces[ts4.Key()] = []*api.InvocResult{invoc(call(101, 102, 3, exitcode.Ok), 0)}
+
+	require.NoError(t, ai.catchUp(tc.ctx, ts1))
+	require.NoError(t, ai.catchUp(tc.ctx, ts2))
+
+	// the node restarts once the chain is at ts4, the index resumes from ts2
+	ai2 := newTestAddrIndex(tc, ft)
+	ai2.ds = ai.ds
+	ai2.head = ai2.lastHead()
+	require.Equal(t, ts2, ai2.head)
+
+	require.NoError(t, ai2.catchUp(tc.ctx, ts4))
+
+	var values []uint64
+	for _, s := range summarize(t, history(t, ai2, 101)) {
+		values = append(values, s.Value)
+	}
+	require.Equal(t, []uint64{3, 2, 1}, values)
+
+	// without a saved head, the index starts from the current head
+	ai3 := newTestAddrIndex(tc, ft)
+	require.Equal(t, tc.cs.GetHeaviestTipSet(), ai3.lastHead())
+}
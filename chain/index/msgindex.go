@@ -0,0 +1,236 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	blockadt "github.com/filecoin-project/specs-actors/actors/util/adt"
+
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var log = logging.Logger("chainindex")
+
+// ErrNotFound is returned for messages which aren't in the index, either
+// because they weren't executed on the current chain, or because they were
+// executed before the index was enabled and weren't backfilled.
+var ErrNotFound = errors.New("message not found in index")
+
+// MsgInfo locates the execution of a message.
+type MsgInfo struct {
+	Message cid.Cid
+	// TipSet is the tipset in which the message was executed, holding its
+	// receipt; the message was included in its parent.
+	TipSet  types.TipSetKey
+	Epoch   abi.ChainEpoch
+	Receipt types.MessageReceipt
+}
+
+// MsgIndex maps messages to the tipset they were executed in on the current
+// chain.
+type MsgIndex interface {
+	// GetMsgInfo returns ErrNotFound for messages which aren't indexed.
+	GetMsgInfo(ctx context.Context, m cid.Cid) (MsgInfo, error)
+
+	// Backfill indexes the messages executed in the given number of epochs
+	// up to and including from, returning the number of messages indexed.
+	Backfill(ctx context.Context, from *types.TipSet, epochs abi.ChainEpoch) (int, error)
+}
+
+type msgIndex struct {
+	ds datastore.Batching
+	cs *store.ChainStore
+}
+
+// NewMsgIndex creates an index kept in the datastore, which is updated as the
+// chain head changes.
+func NewMsgIndex(ds datastore.Batching, cs *store.ChainStore) MsgIndex {
+	mi := &msgIndex{
+		ds: ds,
+		cs: cs,
+	}
+
+	cs.SubscribeHeadChanges(mi.onHeadChange)
+
+	return mi
+}
+
+func msgKey(m cid.Cid) datastore.Key {
+	return datastore.NewKey("/m/" + m.String())
+}
+
+func (mi *msgIndex) GetMsgInfo(ctx context.Context, m cid.Cid) (MsgInfo, error) {
+	b, err := mi.ds.Get(msgKey(m))
+	if err == datastore.ErrNotFound {
+		return MsgInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return MsgInfo{}, xerrors.Errorf("getting message info: %w", err)
+	}
+
+	var info MsgInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return MsgInfo{}, xerrors.Errorf("unmarshaling message info: %w", err)
+	}
+
+	return info, nil
+}
+
+func (mi *msgIndex) onHeadChange(rev, app []*types.TipSet) error {
+	for _, ts := range rev {
+		if err := mi.revertTipSet(ts); err != nil {
+			log.Errorw("removing reverted tipset from message index", "height", ts.Height(), "error", err)
+		}
+	}
+
+	for _, ts := range app {
+		if _, err := mi.indexTipSet(ts); err != nil {
+			log.Errorw("indexing tipset messages", "height", ts.Height(), "error", err)
+		}
+	}
+
+	return nil
+}
+
+// executedMessages returns the messages executed in the tipset, which are the
+// messages included in its parent, with their receipts.
+func (mi *msgIndex) executedMessages(ts *types.TipSet) ([]types.ChainMsg, []types.MessageReceipt, error) {
+	if ts.Height() == 0 {
+		return nil, nil, nil
+	}
+
+	pts, err := mi.cs.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading parent tipset: %w", err)
+	}
+
+	msgs, err := mi.cs.MessagesForTipset(pts)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading parent messages: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil, nil, nil
+	}
+
+	a, err := blockadt.AsArray(mi.cs.ActorStore(context.TODO()), ts.Blocks()[0].ParentMessageReceipts)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading receipts: %w", err)
+	}
+
+	rcpts := make([]types.MessageReceipt, len(msgs))
+	for i := range msgs {
+		found, err := a.Get(uint64(i), &rcpts[i])
+		if err != nil {
+			return nil, nil, xerrors.Errorf("loading receipt %d: %w", i, err)
+		}
+		if !found {
+			return nil, nil, xerrors.Errorf("no receipt for message %d", i)
+		}
+	}
+
+	return msgs, rcpts, nil
+}
+
+func (mi *msgIndex) indexTipSet(ts *types.TipSet) (int, error) {
+	msgs, rcpts, err := mi.executedMessages(ts)
+	if err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, m := range msgs {
+		b, err := json.Marshal(MsgInfo{
+			Message: m.Cid(),
+			TipSet:  ts.Key(),
+			Epoch:   ts.Height(),
+			Receipt: rcpts[i],
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if err := batch.Put(msgKey(m.Cid()), b); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		return 0, xerrors.Errorf("writing message index: %w", err)
+	}
+
+	return len(msgs), nil
+}
+
+// revertTipSet removes the messages executed in a reverted tipset, unless they
+// were already indexed as executed in another tipset.
+func (mi *msgIndex) revertTipSet(ts *types.TipSet) error {
+	msgs, _, err := mi.executedMessages(ts)
+	if err != nil {
+		return err
+	}
+
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range msgs {
+		info, err := mi.GetMsgInfo(context.TODO(), m.Cid())
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.TipSet != ts.Key() {
+			continue
+		}
+
+		if err := batch.Delete(msgKey(m.Cid())); err != nil {
+			return err
+		}
+	}
+
+	return batch.Commit()
+}
+
+func (mi *msgIndex) Backfill(ctx context.Context, from *types.TipSet, epochs abi.ChainEpoch) (int, error) {
+	stop := from.Height() - epochs
+
+	var n int
+	for ts := from; ts.Height() > stop && ts.Height() > 0; {
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+
+		c, err := mi.indexTipSet(ts)
+		if err != nil {
+			return n, xerrors.Errorf("indexing tipset at %d: %w", ts.Height(), err)
+		}
+		n += c
+
+		ts, err = mi.cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return n, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+	}
+
+	return n, nil
+}
+
+var _ MsgIndex = (*msgIndex)(nil)
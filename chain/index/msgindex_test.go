@@ -0,0 +1,174 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	blockadt "github.com/filecoin-project/specs-actors/actors/util/adt"
+
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+// testChain builds tipsets with real message and receipt arrays in a
+// chain store
+type testChain struct {
+	t   *testing.T
+	ctx context.Context
+	cs  *store.ChainStore
+	gen *types.TipSet
+}
+
+func newTestChain(t *testing.T) *testChain {
+	bs := blockstore.NewMemorySync()
+	cs := store.NewChainStore(bs, bs, syncds.MutexWrap(datastore.NewMapDatastore()), nil, nil)
+	t.Cleanup(func() {
+		_ = cs.Close()
+	})
+
+	tc := &testChain{
+		t:   t,
+		ctx: context.Background(),
+		cs:  cs,
+	}
+	tc.gen = tc.mkTipSet(nil, 0, nil, nil)
+
+	return tc
+}
+
+func testMsg(nonce uint64) *types.Message {
+	return &types.Message{
+		To:    mock.Address(100),
+		From:  mock.Address(101),
+		Nonce: nonce,
+		Value: types.NewInt(nonce),
+	}
+}
+
+// mkTipSet creates a tipset including msgs, with the receipts of the messages
+// included in its parent
+func (tc *testChain) mkTipSet(parent *types.TipSet, ticket uint64, msgs []*types.Message, parentRcpts []types.MessageReceipt) *types.TipSet {
+	st := tc.cs.ActorStore(tc.ctx)
+
+	bmArr := blockadt.MakeEmptyArray(st)
+	for i, m := range msgs {
+		c, err := tc.cs.PutMessage(m)
+		require.NoError(tc.t, err)
+
+		cc := cbg.CborCid(c)
+		require.NoError(tc.t, bmArr.Set(uint64(i), &cc))
+	}
+	bmRoot, err := bmArr.Root()
+	require.NoError(tc.t, err)
+	smRoot, err := blockadt.MakeEmptyArray(st).Root()
+	require.NoError(tc.t, err)
+
+	meta, err := st.Put(tc.ctx, &types.MsgMeta{
+		BlsMessages:   bmRoot,
+		SecpkMessages: smRoot,
+	})
+	require.NoError(tc.t, err)
+
+	rArr := blockadt.MakeEmptyArray(st)
+	for i := range parentRcpts {
+		require.NoError(tc.t, rArr.Set(uint64(i), &parentRcpts[i]))
+	}
+	rRoot, err := rArr.Root()
+	require.NoError(tc.t, err)
+
+	blk := mock.MkBlock(parent, 1, ticket)
+	blk.Messages = meta
+	blk.ParentMessageReceipts = rRoot
+	require.NoError(tc.t, tc.cs.PersistBlockHeaders(blk))
+
+	return mock.TipSet(blk)
+}
+
+func rcpt(gas int64) types.MessageReceipt {
+	return types.MessageReceipt{GasUsed: gas}
+}
+
+func requireMsgInfo(t *testing.T, mi MsgIndex, m *types.Message, ts *types.TipSet, gas int64) {
+	info, err := mi.GetMsgInfo(context.Background(), m.Cid())
+	require.NoError(t, err)
+	require.Equal(t, m.Cid(), info.Message)
+	require.Equal(t, ts.Key(), info.TipSet)
+	require.Equal(t, ts.Height(), info.Epoch)
+	require.Equal(t, gas, info.Receipt.GasUsed)
+}
+
+func requireNotIndexed(t *testing.T, mi MsgIndex, m cid.Cid) {
+	_, err := mi.GetMsgInfo(context.Background(), m)
+	require.Equal(t, ErrNotFound, err)
+}
+
+func TestMsgIndex(t *testing.T) {
+	tc := newTestChain(t)
+
+	m1, m2, m3, m4 := testMsg(1), testMsg(2), testMsg(3), testMsg(4)
+
+	ts1 := tc.mkTipSet(tc.gen, 1, []*types.Message{m1, m2}, nil)
+	ts2 := tc.mkTipSet(ts1, 1, []*types.Message{m3}, []types.MessageReceipt{rcpt(10), rcpt(20)})
+	ts3 := tc.mkTipSet(ts2, 1, nil, []types.MessageReceipt{rcpt(30)})
+
+	// a fork of ts3, in which m3 got a different receipt
+	ts3b := tc.mkTipSet(ts2, 2, []*types.Message{m4}, []types.MessageReceipt{rcpt(31)})
+	ts4b := tc.mkTipSet(ts3b, 2, nil, []types.MessageReceipt{rcpt(40)})
+
+	mi := NewMsgIndex(syncds.MutexWrap(datastore.NewMapDatastore()), tc.cs).(*msgIndex)
+
+	require.NoError(t, mi.onHeadChange(nil, []*types.TipSet{ts1, ts2, ts3}))
+
+	t.Run("hits", func(t *testing.T) {
+		requireMsgInfo(t, mi, m1, ts2, 10)
+		requireMsgInfo(t, mi, m2, ts2, 20)
+		requireMsgInfo(t, mi, m3, ts3, 30)
+	})
+
+	t.Run("misses", func(t *testing.T) {
+		requireNotIndexed(t, mi, m4.Cid())
+		requireNotIndexed(t, mi, testMsg(5).Cid())
+	})
+
+	t.Run("reorg", func(t *testing.T) {
+		require.NoError(t, mi.onHeadChange([]*types.TipSet{ts3}, []*types.TipSet{ts3b, ts4b}))
+
+		requireMsgInfo(t, mi, m1, ts2, 10)
+		requireMsgInfo(t, mi, m3, ts3b, 31)
+		requireMsgInfo(t, mi, m4, ts4b, 40)
+
+		// reverting a tipset doesn't remove messages indexed in another one
+		require.NoError(t, mi.revertTipSet(ts3))
+		requireMsgInfo(t, mi, m3, ts3b, 31)
+
+		// back to the original chain
+		require.NoError(t, mi.onHeadChange([]*types.TipSet{ts4b, ts3b}, []*types.TipSet{ts3}))
+
+		requireMsgInfo(t, mi, m3, ts3, 30)
+		requireNotIndexed(t, mi, m4.Cid())
+	})
+
+	t.Run("backfill", func(t *testing.T) {
+		bmi := NewMsgIndex(syncds.MutexWrap(datastore.NewMapDatastore()), tc.cs)
+
+		n, err := bmi.Backfill(tc.ctx, ts3, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		requireMsgInfo(t, bmi, m3, ts3, 30)
+		requireNotIndexed(t, bmi, m1.Cid())
+
+		// backfilling stops at genesis
+		n, err = bmi.Backfill(tc.ctx, ts3, 100)
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+		requireMsgInfo(t, bmi, m1, ts2, 10)
+	})
+}
@@ -0,0 +1,260 @@
+package autoreplace
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+var log = logging.Logger("mpool-replace")
+
+type ReplacerAPI interface {
+	ChainHead(context.Context) (*types.TipSet, error)
+	MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error)
+	MpoolGetConfig(context.Context) (*types.MpoolConfig, error)
+	MpoolPush(context.Context, *types.SignedMessage) (cid.Cid, error)
+	WalletSignMessage(context.Context, address.Address, *types.Message) (*types.SignedMessage, error)
+	StateGetActor(context.Context, address.Address, types.TipSetKey) (*types.Actor, error)
+}
+
+// AddrsFunc returns the addresses whose messages are watched
+type AddrsFunc func(ctx context.Context) ([]address.Address, error)
+
+// ReplaceEvt is the journal event recorded when a stuck message is replaced,
+// or can't be replaced because the replacement would exceed the fee cap of
+// its method
+type ReplaceEvt struct {
+	From          address.Address
+	Nonce         uint64
+	Method        string
+	PendingEpochs abi.ChainEpoch
+
+	OldCid        cid.Cid
+	OldGasFeeCap  abi.TokenAmount
+	OldGasPremium abi.TokenAmount
+
+	NewCid        cid.Cid
+	NewGasFeeCap  abi.TokenAmount
+	NewGasPremium abi.TokenAmount
+
+	Capped bool
+	Error  string `json:",omitempty"`
+}
+
+type pendingMsg struct {
+	cid   cid.Cid
+	since abi.ChainEpoch
+}
+
+type msgKey struct {
+	from  address.Address
+	nonce uint64
+}
+
+// Replacer watches the mpool for our own messages which stay pending for
+// longer than the configured number of epochs, and replaces them with the
+// gas premium and fee cap raised by the mpool ReplaceByFeeRatio, up to the
+// max fee configured for the method.
+//
+// Messages are considered pending from the epoch the Replacer first saw them
+// in the mpool, and from the epoch of their last replacement.
+type Replacer struct {
+	api   ReplacerAPI
+	addrs AddrsFunc
+	cfg   config.StuckMessageConfig
+
+	journal    journal.Journal
+	evtReplace journal.EventType
+
+	pending map[msgKey]pendingMsg
+}
+
+func NewReplacer(rapi ReplacerAPI, addrs AddrsFunc, cfg config.StuckMessageConfig, j journal.Journal) *Replacer {
+	return &Replacer{
+		api:   rapi,
+		addrs: addrs,
+		cfg:   cfg,
+
+		journal:    j,
+		evtReplace: j.RegisterEventType("mpool", "replace_stuck"),
+
+		pending: map[msgKey]pendingMsg{},
+	}
+}
+
+func (r *Replacer) Run(ctx context.Context) {
+	ticker := build.Clock.Ticker(time.Duration(build.BlockDelaySecs) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := r.check(ctx); err != nil {
+			log.Errorf("checking for stuck messages: %+v", err)
+		}
+	}
+}
+
+func (r *Replacer) check(ctx context.Context) error {
+	ts, err := r.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	addrs, err := r.addrs(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting watched addresses: %w", err)
+	}
+	ours := map[address.Address]struct{}{}
+	for _, a := range addrs {
+		ours[a] = struct{}{}
+	}
+
+	msgs, err := r.api.MpoolPending(ctx, ts.Key())
+	if err != nil {
+		return xerrors.Errorf("getting pending messages: %w", err)
+	}
+
+	mpcfg, err := r.api.MpoolGetConfig(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting mpool config: %w", err)
+	}
+
+	seen := map[msgKey]struct{}{}
+	for _, sm := range msgs {
+		if _, ok := ours[sm.Message.From]; !ok {
+			continue
+		}
+
+		k := msgKey{from: sm.Message.From, nonce: sm.Message.Nonce}
+		seen[k] = struct{}{}
+
+		p, ok := r.pending[k]
+		if !ok || p.cid != sm.Cid() {
+			// new message, or replaced by someone else
+			r.pending[k] = pendingMsg{cid: sm.Cid(), since: ts.Height()}
+			continue
+		}
+
+		if ts.Height()-p.since < abi.ChainEpoch(r.cfg.PendingEpochs) {
+			continue
+		}
+
+		evt := r.replace(ctx, ts, sm, mpcfg.ReplaceByFeeRatio)
+		evt.PendingEpochs = ts.Height() - p.since
+		r.journal.RecordEvent(r.evtReplace, func() interface{} {
+			return evt
+		})
+
+		switch {
+		case evt.Error != "":
+			log.Errorw("replacing stuck message", "from", evt.From, "nonce", evt.Nonce, "cid", evt.OldCid, "error", evt.Error)
+		case evt.Capped:
+			log.Warnw("stuck message replacement would exceed max fee", "from", evt.From, "nonce", evt.Nonce, "cid", evt.OldCid, "method", evt.Method)
+			// don't retry before the message is stuck for another PendingEpochs
+			r.pending[k] = pendingMsg{cid: sm.Cid(), since: ts.Height()}
+		default:
+			log.Infow("replaced stuck message", "from", evt.From, "nonce", evt.Nonce, "old", evt.OldCid, "new", evt.NewCid, "method", evt.Method)
+			r.pending[k] = pendingMsg{cid: evt.NewCid, since: ts.Height()}
+		}
+	}
+
+	for k := range r.pending {
+		if _, ok := seen[k]; !ok {
+			delete(r.pending, k)
+		}
+	}
+
+	return nil
+}
+
+func (r *Replacer) replace(ctx context.Context, ts *types.TipSet, sm *types.SignedMessage, ratio float64) *ReplaceEvt {
+	msg := sm.Message
+	evt := &ReplaceEvt{
+		From:   msg.From,
+		Nonce:  msg.Nonce,
+		Method: r.methodName(ctx, ts, &msg),
+
+		OldCid:        sm.Cid(),
+		OldGasFeeCap:  msg.GasFeeCap,
+		OldGasPremium: msg.GasPremium,
+	}
+
+	minRBF := messagepool.ComputeMinRBF(msg.GasPremium)
+	msg.GasPremium = big.Max(scaleFee(msg.GasPremium, ratio), minRBF)
+	msg.GasFeeCap = big.Max(scaleFee(msg.GasFeeCap, ratio), msg.GasPremium)
+
+	maxFee := abi.TokenAmount(r.cfg.DefaultMaxFee)
+	for _, mf := range r.cfg.MaxFee {
+		if mf.Method == evt.Method {
+			maxFee = abi.TokenAmount(mf.MaxFee)
+			break
+		}
+	}
+	messagepool.CapGasFee(func() (abi.TokenAmount, error) {
+		return maxFee, nil
+	}, &msg, &api.MessageSendSpec{MaxFee: maxFee})
+
+	evt.NewGasFeeCap = msg.GasFeeCap
+	evt.NewGasPremium = msg.GasPremium
+
+	if msg.GasPremium.LessThan(minRBF) {
+		evt.Capped = true
+		return evt
+	}
+
+	nsm, err := r.api.WalletSignMessage(ctx, msg.From, &msg)
+	if err != nil {
+		evt.Error = xerrors.Errorf("signing replacement: %w", err).Error()
+		return evt
+	}
+
+	c, err := r.api.MpoolPush(ctx, nsm)
+	if err != nil {
+		evt.Error = xerrors.Errorf("pushing replacement: %w", err).Error()
+		return evt
+	}
+
+	evt.NewCid = c
+	return evt
+}
+
+func (r *Replacer) methodName(ctx context.Context, ts *types.TipSet, msg *types.Message) string {
+	if msg.Method == 0 {
+		return "Send"
+	}
+
+	act, err := r.api.StateGetActor(ctx, msg.To, ts.Key())
+	if err == nil {
+		if m, ok := stmgr.MethodsMap[act.Code][msg.Method]; ok {
+			return m.Name
+		}
+	}
+
+	return fmt.Sprint(msg.Method)
+}
+
+func scaleFee(v abi.TokenAmount, ratio float64) abi.TokenAmount {
+	num := types.NewInt(uint64(ratio * messagepool.RbfDenom))
+	return types.BigDiv(types.BigMul(v, num), types.NewInt(messagepool.RbfDenom))
+}
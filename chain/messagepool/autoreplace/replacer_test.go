@@ -0,0 +1,129 @@
+package autoreplace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+type fakeAPI struct {
+	height  abi.ChainEpoch
+	pending map[cid.Cid]*types.SignedMessage
+	pushed  []*types.SignedMessage
+}
+
+func (f *fakeAPI) ChainHead(context.Context) (*types.TipSet, error) {
+	blk := mock.MkBlock(nil, 1, 1)
+	blk.Height = f.height
+	return mock.TipSet(blk), nil
+}
+
+func (f *fakeAPI) MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error) {
+	var out []*types.SignedMessage
+	for _, sm := range f.pending {
+		out = append(out, sm)
+	}
+	return out, nil
+}
+
+func (f *fakeAPI) MpoolGetConfig(context.Context) (*types.MpoolConfig, error) {
+	return messagepool.DefaultConfig(), nil
+}
+
+func (f *fakeAPI) MpoolPush(_ context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+	for c, p := range f.pending {
+		if p.Message.From == sm.Message.From && p.Message.Nonce == sm.Message.Nonce {
+			delete(f.pending, c)
+		}
+	}
+	f.pending[sm.Cid()] = sm
+	f.pushed = append(f.pushed, sm)
+	return sm.Cid(), nil
+}
+
+func (f *fakeAPI) WalletSignMessage(_ context.Context, _ address.Address, msg *types.Message) (*types.SignedMessage, error) {
+	return &types.SignedMessage{
+		Message:   *msg,
+		Signature: crypto.Signature{Type: crypto.SigTypeSecp256k1},
+	}, nil
+}
+
+func (f *fakeAPI) StateGetActor(context.Context, address.Address, types.TipSetKey) (*types.Actor, error) {
+	return &types.Actor{}, nil
+}
+
+func TestReplaceStuckMessage(t *testing.T) {
+	ctx := context.Background()
+	from := mock.Address(1000)
+
+	sm := &types.SignedMessage{
+		Message: types.Message{
+			From:       from,
+			To:         mock.Address(1001),
+			Nonce:      3,
+			Value:      types.NewInt(1),
+			GasLimit:   1000000,
+			GasFeeCap:  types.NewInt(1000),
+			GasPremium: types.NewInt(100),
+		},
+		Signature: crypto.Signature{Type: crypto.SigTypeSecp256k1},
+	}
+
+	fapi := &fakeAPI{
+		height:  100,
+		pending: map[cid.Cid]*types.SignedMessage{sm.Cid(): sm},
+	}
+	addrs := func(context.Context) ([]address.Address, error) {
+		return []address.Address{from}, nil
+	}
+	cfg := config.StuckMessageConfig{
+		EnableReplace: true,
+		PendingEpochs: 10,
+		MaxFee: []config.MethodMaxFee{
+			{Method: "Send", MaxFee: types.FIL(types.NewInt(150 * 1000000))},
+		},
+		DefaultMaxFee: types.FIL(types.NewInt(0)),
+	}
+
+	r := NewReplacer(fapi, addrs, cfg, journal.NilJournal())
+
+	// not pending for long enough
+	require.NoError(t, r.check(ctx))
+	fapi.height = 105
+	require.NoError(t, r.check(ctx))
+	require.Len(t, fapi.pushed, 0)
+
+	fapi.height = 110
+	require.NoError(t, r.check(ctx))
+	require.Len(t, fapi.pushed, 1)
+
+	repl := fapi.pushed[0].Message
+	require.Equal(t, sm.Message.Nonce, repl.Nonce)
+	require.True(t, repl.GasPremium.GreaterThanEqual(messagepool.ComputeMinRBF(sm.Message.GasPremium)))
+	// capped at the max fee of the method
+	require.True(t, repl.GasFeeCap.Equals(types.NewInt(150)))
+
+	// the replacement is only replaced once it's stuck for PendingEpochs
+	fapi.height = 115
+	require.NoError(t, r.check(ctx))
+	require.Len(t, fapi.pushed, 1)
+
+	// raising the premium again would exceed the max fee of the method
+	fapi.height = 120
+	require.NoError(t, r.check(ctx))
+	fapi.height = 130
+	require.NoError(t, r.check(ctx))
+	require.Len(t, fapi.pushed, 1)
+}
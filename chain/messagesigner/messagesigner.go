@@ -1,21 +1,19 @@
 package messagesigner
 
 import (
-	"bytes"
 	"context"
 	"sync"
 
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
 	logging "github.com/ipfs/go-log/v2"
-	cbg "github.com/whyrusleeping/cbor-gen"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/types"
-	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
 
 const dsKeyActorNonce = "ActorNextNonce"
@@ -24,6 +22,7 @@ var log = logging.Logger("messagesigner")
 
 type MpoolNonceAPI interface {
 	GetNonce(context.Context, address.Address, types.TipSetKey) (uint64, error)
+	PendingFor(address.Address) ([]*types.SignedMessage, *types.TipSet)
 }
 
 // MessageSigner keeps track of nonces per address, and increments the nonce
@@ -32,15 +31,14 @@ type MessageSigner struct {
 	wallet api.Wallet
 	lk     sync.Mutex
 	mpool  MpoolNonceAPI
-	ds     datastore.Batching
+	nonces NonceProvider
 }
 
-func NewMessageSigner(wallet api.Wallet, mpool MpoolNonceAPI, ds dtypes.MetadataDS) *MessageSigner {
-	ds = namespace.Wrap(ds, datastore.NewKey("/message-signer/"))
+func NewMessageSigner(wallet api.Wallet, mpool MpoolNonceAPI, nonces NonceProvider) *MessageSigner {
 	return &MessageSigner{
 		wallet: wallet,
 		mpool:  mpool,
-		ds:     ds,
+		nonces: nonces,
 	}
 }
 
@@ -56,6 +54,23 @@ func (ms *MessageSigner) SignMessage(ctx context.Context, msg *types.Message, cb
 		return nil, xerrors.Errorf("failed to create nonce: %w", err)
 	}
 
+	smsg, err := ms.signMessage(ctx, msg, nonce, cb)
+	if err != nil {
+		if rerr := ms.nonces.ReleaseNonce(ctx, msg.From, nonce); rerr != nil {
+			log.Errorf("failed to release nonce %d of %s: %s", nonce, msg.From, rerr)
+		}
+		return nil, err
+	}
+
+	// If the callback executed successfully, commit the nonce
+	if err := ms.nonces.CommitNonce(ctx, msg.From, nonce, smsg.Cid()); err != nil {
+		return nil, xerrors.Errorf("failed to save nonce: %w", err)
+	}
+
+	return smsg, nil
+}
+
+func (ms *MessageSigner) signMessage(ctx context.Context, msg *types.Message, nonce uint64, cb func(*types.SignedMessage) error) (*types.SignedMessage, error) {
 	// Sign the message with the nonce
 	msg.Nonce = nonce
 
@@ -82,16 +97,12 @@ func (ms *MessageSigner) SignMessage(ctx context.Context, msg *types.Message, cb
 		return nil, err
 	}
 
-	// If the callback executed successfully, write the nonce to the datastore
-	if err := ms.saveNonce(msg.From, nonce); err != nil {
-		return nil, xerrors.Errorf("failed to save nonce: %w", err)
-	}
-
 	return smsg, nil
 }
 
-// nextNonce gets the next nonce for the given address.
-// If there is no nonce in the datastore, gets the nonce from the message pool.
+// nextNonce reserves the next nonce for the given address, no lower than the
+// message pool nonce. Nonces of messages still pending in the message pool
+// aren't used again, even when the provider considers them a gap.
 func (ms *MessageSigner) nextNonce(ctx context.Context, addr address.Address) (uint64, error) {
 	// Nonces used to be created by the mempool and we need to support nodes
 	// that have mempool nonces, so first check the mempool for a nonce for
@@ -102,60 +113,45 @@ func (ms *MessageSigner) nextNonce(ctx context.Context, addr address.Address) (u
 		return 0, xerrors.Errorf("failed to get nonce from mempool: %w", err)
 	}
 
-	// Get the next nonce for this address from the datastore
-	addrNonceKey := ms.dstoreKey(addr)
-	dsNonceBytes, err := ms.ds.Get(addrNonceKey)
-
-	switch {
-	case xerrors.Is(err, datastore.ErrNotFound):
-		// If a nonce for this address hasn't yet been created in the
-		// datastore, just use the nonce from the mempool
-		return nonce, nil
-
-	case err != nil:
-		return 0, xerrors.Errorf("failed to get nonce from datastore: %w", err)
-
-	default:
-		// There is a nonce in the datastore, so unmarshall it
-		maj, dsNonce, err := cbg.CborReadHeader(bytes.NewReader(dsNonceBytes))
+	held := map[uint64]struct{}{}
+	for {
+		res, err := ms.nonces.ReserveNonce(ctx, addr, nonce)
 		if err != nil {
-			return 0, xerrors.Errorf("failed to parse nonce from datastore: %w", err)
+			return 0, err
 		}
-		if maj != cbg.MajUnsignedInt {
-			return 0, xerrors.Errorf("bad cbor type parsing nonce from datastore")
+		if res.Replaces == nil || !ms.pending(addr, *res.Replaces) {
+			return res.Nonce, nil
 		}
-
-		// The message pool nonce should be <= than the datastore nonce
-		if nonce <= dsNonce {
-			nonce = dsNonce
-		} else {
-			log.Warnf("mempool nonce was larger than datastore nonce (%d > %d)", nonce, dsNonce)
+		if _, ok := held[res.Nonce]; ok {
+			return 0, xerrors.Errorf("nonce %d was handed out again while its message is pending", res.Nonce)
+		}
+		held[res.Nonce] = struct{}{}
+
+		// The message the nonce was committed for is in our mpool, behind a
+		// gap, so the nonce is still taken. Commit it again, which makes the
+		// provider hand out the next one.
+		log.Infow("nonce gap message is pending", "addr", addr, "nonce", res.Nonce, "msg", *res.Replaces)
+		if err := ms.nonces.CommitNonce(ctx, addr, res.Nonce, *res.Replaces); err != nil {
+			return 0, xerrors.Errorf("failed to save nonce: %w", err)
 		}
-
-		return nonce, nil
 	}
 }
 
-// saveNonce increments the nonce for this address and writes it to the
-// datastore
-func (ms *MessageSigner) saveNonce(addr address.Address, nonce uint64) error {
-	// Increment the nonce
-	nonce++
-
-	// Write the nonce to the datastore
-	addrNonceKey := ms.dstoreKey(addr)
-	buf := bytes.Buffer{}
-	_, err := buf.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, nonce))
-	if err != nil {
-		return xerrors.Errorf("failed to marshall nonce: %w", err)
-	}
-	err = ms.ds.Put(addrNonceKey, buf.Bytes())
-	if err != nil {
-		return xerrors.Errorf("failed to write nonce to datastore: %w", err)
+// pending checks whether the message is pending in the mpool
+func (ms *MessageSigner) pending(addr address.Address, msg cid.Cid) bool {
+	pending, _ := ms.mpool.PendingFor(addr)
+	for _, sm := range pending {
+		if sm.Cid() == msg {
+			return true
+		}
 	}
-	return nil
+	return false
+}
+
+func namespaceDs(ds datastore.Batching) datastore.Batching {
+	return namespace.Wrap(ds, datastore.NewKey("/message-signer/"))
 }
 
-func (ms *MessageSigner) dstoreKey(addr address.Address) datastore.Key {
+func dstoreKey(addr address.Address) datastore.Key {
 	return datastore.KeyWithNamespaces([]string{dsKeyActorNonce, addr.String()})
 }
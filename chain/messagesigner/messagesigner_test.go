@@ -2,30 +2,56 @@ package messagesigner
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/wallet"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/ipfs/go-cid"
 	ds_sync "github.com/ipfs/go-datastore/sync"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/filecoin-project/go-state-types/crypto"
 
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/ipfs/go-datastore"
 )
 
 type mockMpool struct {
-	lk     sync.RWMutex
-	nonces map[address.Address]uint64
+	lk      sync.RWMutex
+	nonces  map[address.Address]uint64
+	pending map[address.Address][]*types.SignedMessage
 }
 
 func newMockMpool() *mockMpool {
-	return &mockMpool{nonces: make(map[address.Address]uint64)}
+	return &mockMpool{
+		nonces:  make(map[address.Address]uint64),
+		pending: make(map[address.Address][]*types.SignedMessage),
+	}
+}
+
+func (mp *mockMpool) add(sm *types.SignedMessage) {
+	mp.lk.Lock()
+	defer mp.lk.Unlock()
+
+	mp.pending[sm.Message.From] = append(mp.pending[sm.Message.From], sm)
+}
+
+func (mp *mockMpool) PendingFor(addr address.Address) ([]*types.SignedMessage, *types.TipSet) {
+	mp.lk.RLock()
+	defer mp.lk.RUnlock()
+
+	return mp.pending[addr], nil
 }
 
 func (mp *mockMpool) setNonce(addr address.Address, nonce uint64) {
@@ -176,7 +202,7 @@ func TestMessageSignerSignMessage(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mpool := newMockMpool()
 			ds := ds_sync.MutexWrap(datastore.NewMapDatastore())
-			ms := NewMessageSigner(w, mpool, ds)
+			ms := NewMessageSigner(w, mpool, NewNonceTracker(ds))
 
 			for _, m := range tt.msgs {
 				if len(m.mpoolNonce) == 1 {
@@ -198,3 +224,135 @@ func TestMessageSignerSignMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestNonceTrackerRefillsGaps(t *testing.T) {
+	ctx := context.Background()
+
+	w, _ := wallet.NewWallet(wallet.NewMemKeyStore())
+	addr, err := w.WalletNew(ctx, types.KTSecp256k1)
+	require.NoError(t, err)
+
+	nt := NewNonceTracker(ds_sync.MutexWrap(datastore.NewMapDatastore()))
+
+	for i := uint64(0); i < 3; i++ {
+		res, err := nt.ReserveNonce(ctx, addr, 0)
+		require.NoError(t, err)
+		require.Equal(t, i, res.Nonce)
+	}
+
+	msg1, msg3 := testMsgCid(t, addr, 1), testMsgCid(t, addr, 3)
+
+	// a released nonce is handed out again before a new one
+	require.NoError(t, nt.CommitNonce(ctx, addr, 0, testMsgCid(t, addr, 0)))
+	require.NoError(t, nt.ReleaseNonce(ctx, addr, 1))
+	require.NoError(t, nt.CommitNonce(ctx, addr, 2, testMsgCid(t, addr, 2)))
+
+	res, err := nt.ReserveNonce(ctx, addr, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, res.Nonce)
+	require.Nil(t, res.Replaces)
+
+	res, err = nt.ReserveNonce(ctx, addr, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, res.Nonce)
+
+	// a committed nonce which never reached the mpool is refilled after the
+	// grace period
+	gp := GapGracePeriod
+	GapGracePeriod = 0
+	defer func() {
+		GapGracePeriod = gp
+	}()
+
+	require.NoError(t, nt.CommitNonce(ctx, addr, 1, msg1))
+	require.NoError(t, nt.CommitNonce(ctx, addr, 3, msg3))
+
+	// the caller is told which message it replaces
+	res, err = nt.ReserveNonce(ctx, addr, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, res.Nonce)
+	require.NotNil(t, res.Replaces)
+	require.Equal(t, msg1, *res.Replaces)
+}
+
+func testMsgCid(t *testing.T, from address.Address, nonce uint64) cid.Cid {
+	msg := &types.Message{From: from, To: from, Nonce: nonce}
+	sm := &types.SignedMessage{Message: *msg, Signature: crypto.Signature{Type: crypto.SigTypeSecp256k1}}
+	return sm.Cid()
+}
+
+// Two nodes share the nonces of an address through a served tracker. One of
+// them reserved a nonce and didn't send its message yet, so the mpools see a
+// gap, with the messages after it waiting for it.
+func TestServedNonceTrackerClients(t *testing.T) {
+	ctx := context.Background()
+
+	gp := GapGracePeriod
+	GapGracePeriod = 500 * time.Millisecond
+	defer func() {
+		GapGracePeriod = gp
+	}()
+
+	w, _ := wallet.NewWallet(wallet.NewMemKeyStore())
+	from, err := w.WalletNew(ctx, types.KTSecp256k1)
+	require.NoError(t, err)
+	to, err := w.WalletNew(ctx, types.KTSecp256k1)
+	require.NoError(t, err)
+
+	rpcServer := jsonrpc.NewServer()
+	rpcServer.Register(NonceNamespace, PermissionedNonceProvider(NewNonceTracker(ds_sync.MutexWrap(datastore.NewMapDatastore()))))
+	srv := httptest.NewServer(&auth.Handler{
+		Verify: func(ctx context.Context, token string) ([]auth.Permission, error) {
+			return []auth.Permission{api.PermSign}, nil
+		},
+		Next: rpcServer.ServeHTTP,
+	})
+	defer srv.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+	url := "ws://" + srv.Listener.Addr().String()
+
+	npA, closeA, err := NewRemoteNonceProvider(ctx, url, header)
+	require.NoError(t, err)
+	defer closeA()
+	npB, closeB, err := NewRemoteNonceProvider(ctx, url, header)
+	require.NoError(t, err)
+	defer closeB()
+
+	mpoolA, mpoolB := newMockMpool(), newMockMpool()
+	msA := NewMessageSigner(w, mpoolA, npA)
+	msB := NewMessageSigner(w, mpoolB, npB)
+
+	everywhere := func(sm *types.SignedMessage) error {
+		mpoolA.add(sm)
+		mpoolB.add(sm)
+		return nil
+	}
+
+	res, err := npA.ReserveNonce(ctx, from, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, res.Nonce)
+
+	sm, err := msB.SignMessage(ctx, &types.Message{From: from, To: to}, everywhere)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, sm.Message.Nonce)
+
+	// the message of nonce 2 never reaches any mpool
+	res, err = npA.ReserveNonce(ctx, from, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, res.Nonce)
+	require.NoError(t, npA.CommitNonce(ctx, from, 2, testMsgCid(t, from, 2)))
+
+	time.Sleep(GapGracePeriod + 100*time.Millisecond)
+
+	// both committed nonces are past the grace period, but the message of
+	// nonce 1 is pending, so only nonce 2 is handed out again
+	sm, err = msB.SignMessage(ctx, &types.Message{From: from, To: to}, everywhere)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, sm.Message.Nonce)
+
+	sm, err = msA.SignMessage(ctx, &types.Message{From: from, To: to}, everywhere)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, sm.Message.Nonce)
+}
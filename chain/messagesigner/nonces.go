@@ -0,0 +1,232 @@
+package messagesigner
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+// NonceProvider hands out the nonces of the messages signed by the
+// MessageSigner. A reserved nonce must be committed with the CID of its
+// message once it's in the mpool, or released if the message couldn't be
+// sent, so that it can be handed out again.
+type NonceProvider interface {
+	// ReserveNonce returns the nonce of the next message from the address,
+	// no lower than minNonce, the next nonce known to the caller's mpool.
+	ReserveNonce(ctx context.Context, addr address.Address, minNonce uint64) (NonceReservation, error)
+	CommitNonce(ctx context.Context, addr address.Address, nonce uint64, msg cid.Cid) error
+	ReleaseNonce(ctx context.Context, addr address.Address, nonce uint64) error
+}
+
+// NonceReservation is a nonce handed out by a NonceProvider
+type NonceReservation struct {
+	Nonce uint64
+	// Replaces is set when the nonce was committed for a message which
+	// didn't show up in the mpool in time. The message may still be pending
+	// somewhere, so before using the nonce the caller must check it doesn't
+	// know the message, or else commit the nonce again.
+	Replaces *cid.Cid
+}
+
+var (
+	// ReservationTimeout is how long a reserved nonce is held for its message
+	// to be signed and pushed, after which it is handed out again
+	ReservationTimeout = 2 * time.Minute
+	// GapGracePeriod is how long a committed nonce may stay missing from the
+	// mpool, while its message propagates, before it's considered a gap and
+	// handed out again
+	GapGracePeriod = time.Duration(4*build.BlockDelaySecs) * time.Second
+)
+
+// NonceTracker is a NonceProvider keeping the next nonce of each address in a
+// datastore. Nonces which were skipped, because they were released, their
+// reservation expired, or their message never reached the mpool, are handed
+// out again before new ones, filling the gap.
+type NonceTracker struct {
+	lk      sync.Mutex
+	ds      datastore.Batching
+	started time.Time
+	addrs   map[address.Address]*addrNonces
+}
+
+type addrNonces struct {
+	reserved  map[uint64]time.Time
+	committed map[uint64]committedNonce
+	released  map[uint64]struct{}
+}
+
+type committedNonce struct {
+	at  time.Time
+	msg cid.Cid
+}
+
+func NewNonceTracker(ds dtypes.MetadataDS) *NonceTracker {
+	return &NonceTracker{
+		ds:      namespaceDs(ds),
+		started: time.Now(),
+		addrs:   map[address.Address]*addrNonces{},
+	}
+}
+
+var _ NonceProvider = (*NonceTracker)(nil)
+
+func (nt *NonceTracker) ReserveNonce(ctx context.Context, addr address.Address, minNonce uint64) (NonceReservation, error) {
+	nt.lk.Lock()
+	defer nt.lk.Unlock()
+
+	next, found, err := nt.loadNonce(addr)
+	if err != nil {
+		return NonceReservation{}, err
+	}
+	// The message pool nonce should be <= than the datastore nonce
+	if !found || next < minNonce {
+		if found {
+			log.Warnf("mempool nonce was larger than datastore nonce (%d > %d)", minNonce, next)
+		}
+		next = minNonce
+	}
+
+	an := nt.addrNonces(addr, minNonce)
+	now := time.Now()
+
+	for n, at := range an.reserved {
+		if now.Sub(at) > ReservationTimeout {
+			log.Warnw("nonce reservation expired", "addr", addr, "nonce", n)
+			delete(an.reserved, n)
+		}
+	}
+
+	// look for a gap below the next nonce
+	for n := minNonce; n < next; n++ {
+		if _, ok := an.reserved[n]; ok {
+			continue
+		}
+
+		res := NonceReservation{Nonce: n}
+		if _, ok := an.released[n]; !ok {
+			cn, ok := an.committed[n]
+			if !ok {
+				// nonces handed out before a restart
+				cn.at = nt.started
+			}
+			if now.Sub(cn.at) < GapGracePeriod {
+				continue
+			}
+			if cn.msg.Defined() {
+				c := cn.msg
+				res.Replaces = &c
+			}
+			log.Warnw("refilling nonce gap", "addr", addr, "nonce", n, "replaces", cn.msg)
+		}
+
+		delete(an.released, n)
+		delete(an.committed, n)
+		an.reserved[n] = now
+		return res, nil
+	}
+
+	if err := nt.saveNonce(addr, next+1); err != nil {
+		return NonceReservation{}, err
+	}
+	an.reserved[next] = now
+	return NonceReservation{Nonce: next}, nil
+}
+
+func (nt *NonceTracker) CommitNonce(ctx context.Context, addr address.Address, nonce uint64, msg cid.Cid) error {
+	nt.lk.Lock()
+	defer nt.lk.Unlock()
+
+	an := nt.addrNonces(addr, 0)
+	delete(an.reserved, nonce)
+	an.committed[nonce] = committedNonce{at: time.Now(), msg: msg}
+	return nil
+}
+
+func (nt *NonceTracker) ReleaseNonce(ctx context.Context, addr address.Address, nonce uint64) error {
+	nt.lk.Lock()
+	defer nt.lk.Unlock()
+
+	an := nt.addrNonces(addr, 0)
+	delete(an.reserved, nonce)
+	an.released[nonce] = struct{}{}
+	return nil
+}
+
+// addrNonces returns the nonces handed out for the address, forgetting the
+// ones below minNonce, which made it to the mpool.
+func (nt *NonceTracker) addrNonces(addr address.Address, minNonce uint64) *addrNonces {
+	an, ok := nt.addrs[addr]
+	if !ok {
+		an = &addrNonces{
+			reserved:  map[uint64]time.Time{},
+			committed: map[uint64]committedNonce{},
+			released:  map[uint64]struct{}{},
+		}
+		nt.addrs[addr] = an
+	}
+
+	for n := range an.reserved {
+		if n < minNonce {
+			delete(an.reserved, n)
+		}
+	}
+	for n := range an.committed {
+		if n < minNonce {
+			delete(an.committed, n)
+		}
+	}
+	for n := range an.released {
+		if n < minNonce {
+			delete(an.released, n)
+		}
+	}
+
+	return an
+}
+
+func (nt *NonceTracker) loadNonce(addr address.Address) (uint64, bool, error) {
+	dsNonceBytes, err := nt.ds.Get(dstoreKey(addr))
+	switch {
+	case xerrors.Is(err, datastore.ErrNotFound):
+		return 0, false, nil
+
+	case err != nil:
+		return 0, false, xerrors.Errorf("failed to get nonce from datastore: %w", err)
+
+	default:
+		// There is a nonce in the datastore, so unmarshall it
+		maj, dsNonce, err := cbg.CborReadHeader(bytes.NewReader(dsNonceBytes))
+		if err != nil {
+			return 0, false, xerrors.Errorf("failed to parse nonce from datastore: %w", err)
+		}
+		if maj != cbg.MajUnsignedInt {
+			return 0, false, xerrors.Errorf("bad cbor type parsing nonce from datastore")
+		}
+		return dsNonce, true, nil
+	}
+}
+
+// saveNonce writes the next nonce of the address to the datastore
+func (nt *NonceTracker) saveNonce(addr address.Address, nonce uint64) error {
+	buf := bytes.Buffer{}
+	_, err := buf.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, nonce))
+	if err != nil {
+		return xerrors.Errorf("failed to marshall nonce: %w", err)
+	}
+	err = nt.ds.Put(dstoreKey(addr), buf.Bytes())
+	if err != nil {
+		return xerrors.Errorf("failed to write nonce to datastore: %w", err)
+	}
+	return nil
+}
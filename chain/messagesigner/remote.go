@@ -0,0 +1,63 @@
+package messagesigner
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// NonceNamespace is the JSON-RPC namespace nonce servers serve a
+// NonceProvider under
+const NonceNamespace = "Nonce"
+
+// RemoteNonceProvider is a NonceProvider served by another process, such as
+// 'lotus-wallet run --serve-nonces', so that the nodes sending messages from
+// the same addresses don't use the same nonces.
+type RemoteNonceProvider struct {
+	Internal struct {
+		ReserveNonce func(ctx context.Context, addr address.Address, minNonce uint64) (NonceReservation, error) `perm:"sign"`
+		CommitNonce  func(ctx context.Context, addr address.Address, nonce uint64, msg cid.Cid) error           `perm:"sign"`
+		ReleaseNonce func(ctx context.Context, addr address.Address, nonce uint64) error                        `perm:"sign"`
+	}
+}
+
+func NewRemoteNonceProvider(ctx context.Context, addr string, requestHeader http.Header) (*RemoteNonceProvider, jsonrpc.ClientCloser, error) {
+	var res RemoteNonceProvider
+	closer, err := jsonrpc.NewMergeClient(ctx, addr, NonceNamespace,
+		[]interface{}{
+			&res.Internal,
+		},
+		requestHeader,
+	)
+
+	return &res, closer, err
+}
+
+// PermissionedNonceProvider wraps the NonceProvider served to other processes,
+// so that only callers with the sign permission can use it.
+func PermissionedNonceProvider(np NonceProvider) NonceProvider {
+	var out RemoteNonceProvider
+	auth.PermissionedProxy(api.AllPermissions, api.DefaultPerms, np, &out.Internal)
+	return &out
+}
+
+func (p *RemoteNonceProvider) ReserveNonce(ctx context.Context, addr address.Address, minNonce uint64) (NonceReservation, error) {
+	return p.Internal.ReserveNonce(ctx, addr, minNonce)
+}
+
+func (p *RemoteNonceProvider) CommitNonce(ctx context.Context, addr address.Address, nonce uint64, msg cid.Cid) error {
+	return p.Internal.CommitNonce(ctx, addr, nonce, msg)
+}
+
+func (p *RemoteNonceProvider) ReleaseNonce(ctx context.Context, addr address.Address, nonce uint64) error {
+	return p.Internal.ReleaseNonce(ctx, addr, nonce)
+}
+
+var _ NonceProvider = (*RemoteNonceProvider)(nil)
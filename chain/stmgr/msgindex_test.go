@@ -0,0 +1,142 @@
+package stmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/index"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+type fakeMsgIndex map[cid.Cid]index.MsgInfo
+
+func (fi fakeMsgIndex) GetMsgInfo(ctx context.Context, m cid.Cid) (index.MsgInfo, error) {
+	info, ok := fi[m]
+	if !ok {
+		return index.MsgInfo{}, index.ErrNotFound
+	}
+	return info, nil
+}
+
+func (fi fakeMsgIndex) Backfill(context.Context, *types.TipSet, abi.ChainEpoch) (int, error) {
+	return 0, nil
+}
+
+// mkChain persists a chain of n tipsets on top of parent, returning them by
+// height above parent
+func mkChain(t *testing.T, cs *store.ChainStore, parent *types.TipSet, n int, ticket uint64) []*types.TipSet {
+	var out []*types.TipSet
+	for i := 0; i < n; i++ {
+		ts := mock.TipSet(mock.MkBlock(parent, 1, ticket))
+		require.NoError(t, cs.PersistBlockHeaders(ts.Blocks()...))
+		out = append(out, ts)
+		parent = ts
+	}
+	return out
+}
+
+func TestSearchForIndexedMsg(t *testing.T) {
+	ctx := context.Background()
+
+	bs := blockstore.NewMemorySync()
+	cs := store.NewChainStore(bs, bs, syncds.MutexWrap(datastore.NewMapDatastore()), nil, nil)
+	defer cs.Close() //nolint:errcheck
+
+	gen := mock.TipSet(mock.MkBlock(nil, 0, 0))
+	require.NoError(t, cs.PersistBlockHeaders(gen.Blocks()...))
+
+	chain := append([]*types.TipSet{gen}, mkChain(t, cs, gen, 10, 1)...)
+	head := chain[10]
+
+	// a fork off height 4, which the index still points into
+	fork := mkChain(t, cs, chain[4], 2, 2)
+	require.NotEqual(t, chain[5].Key(), fork[0].Key())
+
+	msg := func(nonce uint64) *types.Message {
+		return &types.Message{
+			To:    mock.Address(100),
+			From:  mock.Address(101),
+			Nonce: nonce,
+		}
+	}
+	info := func(m *types.Message, ts *types.TipSet) index.MsgInfo {
+		return index.MsgInfo{
+			Message: m.Cid(),
+			TipSet:  ts.Key(),
+			Epoch:   ts.Height(),
+			Receipt: types.MessageReceipt{GasUsed: int64(m.Nonce)},
+		}
+	}
+
+	executed := msg(1)
+	reorged := msg(2)
+	missing := msg(3)
+
+	fi := fakeMsgIndex{
+		executed.Cid(): info(executed, chain[7]),
+		reorged.Cid():  info(reorged, fork[0]),
+	}
+
+	us := UpgradeSchedule{}
+
+	t.Run("disabled", func(t *testing.T) {
+		sm, err := NewStateManagerWithUpgradeSchedule(cs, us)
+		require.NoError(t, err)
+
+		_, _, _, err = sm.searchForIndexedMsg(ctx, head, executed, LookbackNoLimit)
+		require.True(t, xerrors.Is(err, index.ErrNotFound))
+	})
+
+	sm, err := NewStateManagerWithMsgIndex(cs, us, fi)
+	require.NoError(t, err)
+
+	t.Run("hit", func(t *testing.T) {
+		ts, r, c, err := sm.searchForIndexedMsg(ctx, head, executed, LookbackNoLimit)
+		require.NoError(t, err)
+		require.Equal(t, chain[7].Key(), ts.Key())
+		require.Equal(t, int64(1), r.GasUsed)
+		require.Equal(t, executed.Cid(), c)
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		_, _, _, err := sm.searchForIndexedMsg(ctx, head, missing, LookbackNoLimit)
+		require.True(t, xerrors.Is(err, index.ErrNotFound))
+	})
+
+	t.Run("reorg", func(t *testing.T) {
+		_, _, _, err := sm.searchForIndexedMsg(ctx, head, reorged, LookbackNoLimit)
+		require.True(t, xerrors.Is(err, index.ErrNotFound))
+
+		// found when searching from the fork
+		ts, _, _, err := sm.searchForIndexedMsg(ctx, fork[1], reorged, LookbackNoLimit)
+		require.NoError(t, err)
+		require.Equal(t, fork[0].Key(), ts.Key())
+	})
+
+	t.Run("after from", func(t *testing.T) {
+		_, _, _, err := sm.searchForIndexedMsg(ctx, chain[5], executed, LookbackNoLimit)
+		require.True(t, xerrors.Is(err, index.ErrNotFound))
+	})
+
+	t.Run("beyond limit", func(t *testing.T) {
+		ts, r, _, err := sm.searchForIndexedMsg(ctx, head, executed, 3)
+		require.NoError(t, err)
+		require.Nil(t, ts)
+		require.Nil(t, r)
+
+		ts, _, _, err = sm.searchForIndexedMsg(ctx, head, executed, 4)
+		require.NoError(t, err)
+		require.Equal(t, chain[7].Key(), ts.Key())
+	})
+}
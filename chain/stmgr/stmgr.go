@@ -41,6 +41,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors/builtin/power"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/reward"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/verifreg"
+	"github.com/filecoin-project/lotus/chain/index"
 	"github.com/filecoin-project/lotus/chain/state"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -75,6 +76,9 @@ type migration struct {
 type StateManager struct {
 	cs *store.ChainStore
 
+	// msgIndex speeds up message searches, if enabled
+	msgIndex index.MsgIndex
+
 	cancel   context.CancelFunc
 	shutdown chan struct{}
 
@@ -111,6 +115,12 @@ func NewStateManager(cs *store.ChainStore) *StateManager {
 }
 
 func NewStateManagerWithUpgradeSchedule(cs *store.ChainStore, us UpgradeSchedule) (*StateManager, error) {
+	return NewStateManagerWithMsgIndex(cs, us, nil)
+}
+
+// NewStateManagerWithMsgIndex creates a state manager which looks messages up
+// in the given message index before searching the chain. The index may be nil.
+func NewStateManagerWithMsgIndex(cs *store.ChainStore, us UpgradeSchedule, mi index.MsgIndex) (*StateManager, error) {
 	// If we have upgrades, make sure they're in-order and make sense.
 	if err := us.Validate(); err != nil {
 		return nil, err
@@ -153,6 +163,7 @@ func NewStateManagerWithUpgradeSchedule(cs *store.ChainStore, us UpgradeSchedule
 		expensiveUpgrades: expensiveUpgrades,
 		newVM:             vm.NewVM,
 		cs:                cs,
+		msgIndex:          mi,
 		stCache:           make(map[string][]cid.Cid),
 		compWait:          make(map[string]chan struct{}),
 	}, nil
@@ -626,7 +637,7 @@ func (sm *StateManager) WaitForMessage(ctx context.Context, mcid cid.Cid, confid
 	var backFm cid.Cid
 	backSearchWait := make(chan struct{})
 	go func() {
-		fts, r, foundMsg, err := sm.searchBackForMsg(ctx, head[0].Val, msg, lookbackLimit, allowReplaced)
+		fts, r, foundMsg, err := sm.searchForMsg(ctx, head[0].Val, msg, lookbackLimit, allowReplaced)
 		if err != nil {
 			log.Warnf("failed to look back through chain for message: %v", err)
 			return
@@ -716,7 +727,7 @@ func (sm *StateManager) SearchForMessage(ctx context.Context, head *types.TipSet
 		return head, r, foundMsg, nil
 	}
 
-	fts, r, foundMsg, err := sm.searchBackForMsg(ctx, head, msg, lookbackLimit, allowReplaced)
+	fts, r, foundMsg, err := sm.searchForMsg(ctx, head, msg, lookbackLimit, allowReplaced)
 
 	if err != nil {
 		log.Warnf("failed to look back through chain for message %s", mcid)
@@ -730,6 +741,55 @@ func (sm *StateManager) SearchForMessage(ctx context.Context, head *types.TipSet
 	return fts, r, foundMsg, nil
 }
 
+// searchForMsg looks for the message in the message index if enabled, falling
+// back to searching the chain backwards from the given tipset.
+func (sm *StateManager) searchForMsg(ctx context.Context, from *types.TipSet, m types.ChainMsg, limit abi.ChainEpoch, allowReplaced bool) (*types.TipSet, *types.MessageReceipt, cid.Cid, error) {
+	ts, r, foundMsg, err := sm.searchForIndexedMsg(ctx, from, m, limit)
+	switch {
+	case err == nil:
+		return ts, r, foundMsg, nil
+	case xerrors.Is(err, index.ErrNotFound):
+	default:
+		log.Warnw("message index lookup failed, searching the chain", "message", m.Cid(), "error", err)
+	}
+
+	return sm.searchBackForMsg(ctx, from, m, limit, allowReplaced)
+}
+
+// searchForIndexedMsg looks the message up in the message index, checking
+// that the tipset it was executed in is on the chain of the given tipset. It
+// returns index.ErrNotFound when the chain has to be searched instead, and
+// no tipset when the message was executed beyond the limit.
+func (sm *StateManager) searchForIndexedMsg(ctx context.Context, from *types.TipSet, m types.ChainMsg, limit abi.ChainEpoch) (*types.TipSet, *types.MessageReceipt, cid.Cid, error) {
+	if sm.msgIndex == nil {
+		return nil, nil, cid.Undef, index.ErrNotFound
+	}
+
+	info, err := sm.msgIndex.GetMsgInfo(ctx, m.Cid())
+	if err != nil {
+		return nil, nil, cid.Undef, err
+	}
+
+	if info.Epoch > from.Height() {
+		// executed after the tipset we search from
+		return nil, nil, cid.Undef, index.ErrNotFound
+	}
+	if limit != LookbackNoLimit && info.Epoch <= from.Height()-limit {
+		return nil, nil, cid.Undef, nil
+	}
+
+	ts, err := sm.cs.GetTipsetByHeight(ctx, info.Epoch, from, false)
+	if err != nil {
+		return nil, nil, cid.Undef, xerrors.Errorf("loading tipset at %d: %w", info.Epoch, err)
+	}
+	if ts.Key() != info.TipSet {
+		// the index is behind a reorg
+		return nil, nil, cid.Undef, index.ErrNotFound
+	}
+
+	return ts, &info.Receipt, info.Message, nil
+}
+
 // searchBackForMsg searches up to limit tipsets backwards from the given
 // tipset for a message receipt.
 // If limit is
@@ -1207,6 +1267,21 @@ func getFilPowerLocked(ctx context.Context, st *state.StateTree) (abi.TokenAmoun
 	return pst.TotalLocked()
 }
 
+// GetFilPosLocked returns the PoS deposits of all miners
+func GetFilPosLocked(ctx context.Context, st *state.StateTree) (abi.TokenAmount, error) {
+	pactor, err := st.GetActor(power.Address)
+	if err != nil {
+		return big.Zero(), xerrors.Errorf("failed to load power actor: %w", err)
+	}
+
+	pst, err := power.Load(adt.WrapStore(ctx, st.Store), pactor)
+	if err != nil {
+		return big.Zero(), xerrors.Errorf("failed to load power state: %w", err)
+	}
+
+	return pst.TotalPosPower()
+}
+
 func (sm *StateManager) GetFilLocked(ctx context.Context, st *state.StateTree) (abi.TokenAmount, error) {
 
 	filMarketLocked, err := getFilMarketLocked(ctx, st)
@@ -1309,6 +1384,52 @@ func (sm *StateManager) GetVMCirculatingSupplyDetailed(ctx context.Context, heig
 	}, nil
 }
 
+// GetSupplyDetailed breaks down the supply at the given state, with the
+// locked funds split by category. Unlike the VM circulating supply, the
+// circulating supply it returns excludes the PoS deposits.
+func (sm *StateManager) GetSupplyDetailed(ctx context.Context, height abi.ChainEpoch, st *state.StateTree) (*api.NetworkSupply, error) {
+	cs, err := sm.GetVMCirculatingSupplyDetailed(ctx, height, st)
+	if err != nil {
+		return nil, err
+	}
+
+	marketLocked, err := getFilMarketLocked(ctx, st)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get filMarketLocked: %w", err)
+	}
+
+	pledged, err := getFilPowerLocked(ctx, st)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get filPowerLocked: %w", err)
+	}
+
+	// PoS deposits are only loaded for the report, the VM circulating supply
+	// doesn't deduct them
+	posLocked, err := GetFilPosLocked(ctx, st)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get filPosLocked: %w", err)
+	}
+
+	return networkSupply(height, cs, pledged, marketLocked, posLocked), nil
+}
+
+// networkSupply builds the supply breakdown from the VM circulating supply,
+// deducting the PoS deposits from it
+func networkSupply(height abi.ChainEpoch, cs api.CirculatingSupply, pledged, marketLocked, posLocked abi.TokenAmount) *api.NetworkSupply {
+	return &api.NetworkSupply{
+		Epoch:            height,
+		Minted:           cs.FilMined,
+		Vested:           cs.FilVested,
+		ReserveDisbursed: cs.FilReserveDisbursed,
+		Burnt:            cs.FilBurnt,
+		Pledged:          pledged,
+		MarketLocked:     marketLocked,
+		PosLocked:        posLocked,
+		Circulating:      big.Max(big.Sub(cs.FilCirculating, posLocked), big.Zero()),
+		VMCirculating:    cs.FilCirculating,
+	}
+}
+
 func (sm *StateManager) GetCirculatingSupply(ctx context.Context, height abi.ChainEpoch, st *state.StateTree) (abi.TokenAmount, error) {
 	circ := big.Zero()
 	unCirc := big.Zero()
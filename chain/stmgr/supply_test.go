@@ -0,0 +1,42 @@
+package stmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestNetworkSupply(t *testing.T) {
+	cs := api.CirculatingSupply{
+		FilVested:           abi.NewTokenAmount(100),
+		FilMined:            abi.NewTokenAmount(50),
+		FilBurnt:            abi.NewTokenAmount(10),
+		FilLocked:           abi.NewTokenAmount(40),
+		FilCirculating:      abi.NewTokenAmount(105),
+		FilReserveDisbursed: abi.NewTokenAmount(5),
+	}
+
+	sup := networkSupply(10, cs, abi.NewTokenAmount(30), abi.NewTokenAmount(10), abi.NewTokenAmount(25))
+	require.Equal(t, abi.ChainEpoch(10), sup.Epoch)
+	require.True(t, sup.Minted.Equals(cs.FilMined))
+	require.True(t, sup.Vested.Equals(cs.FilVested))
+	require.True(t, sup.ReserveDisbursed.Equals(cs.FilReserveDisbursed))
+	require.True(t, sup.Burnt.Equals(cs.FilBurnt))
+	require.True(t, sup.Pledged.Equals(abi.NewTokenAmount(30)))
+	require.True(t, sup.MarketLocked.Equals(abi.NewTokenAmount(10)))
+	require.True(t, sup.PosLocked.Equals(abi.NewTokenAmount(25)))
+
+	// the PoS deposits are only deducted from the reported circulating supply
+	require.True(t, sup.VMCirculating.Equals(abi.NewTokenAmount(105)))
+	require.True(t, sup.Circulating.Equals(abi.NewTokenAmount(80)), sup.Circulating.String())
+
+	// and never below zero
+	sup = networkSupply(10, cs, big.Zero(), big.Zero(), abi.NewTokenAmount(200))
+	require.True(t, sup.VMCirculating.Equals(abi.NewTokenAmount(105)))
+	require.True(t, sup.Circulating.IsZero(), sup.Circulating.String())
+}
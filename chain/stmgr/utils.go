@@ -42,6 +42,7 @@ import (
 	exported4 "github.com/filecoin-project/specs-actors/v4/actors/builtin/exported"
 	exported5 "github.com/filecoin-project/specs-actors/v5/actors/builtin/exported"
 	exported6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/exported"
+	exported7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/exported"
 )
 
 func GetNetworkName(ctx context.Context, sm *StateManager, st cid.Cid) (dtypes.NetworkName, error) {
@@ -586,6 +587,7 @@ func init() {
 	actors = append(actors, exported4.BuiltinActors()...)
 	actors = append(actors, exported5.BuiltinActors()...)
 	actors = append(actors, exported6.BuiltinActors()...)
+	actors = append(actors, exported7.BuiltinActors()...)
 
 	for _, actor := range actors {
 		exports := actor.Exports()
@@ -67,8 +67,8 @@ var (
 
 	log = logging.Logger("chain")
 
-	concurrentSyncRequests = exchange.ShufflePeersPrefix
-	syncRequestBatchSize   = 8
+	concurrentSyncRequests = exchange.MaxParallelPeers
+	syncRequestBatchSize   = exchange.ParallelSegmentLength
 	syncRequestRetries     = 5
 )
 
@@ -210,7 +210,9 @@ func (syncer *Syncer) runMetricsTricker(tickerCtx context.Context) {
 
 func (syncer *Syncer) Stop() {
 	syncer.syncmgr.Stop()
-	syncer.tickerCtxCancel()
+	if syncer.tickerCtxCancel != nil {
+		syncer.tickerCtxCancel()
+	}
 }
 
 // InformNewHead informs the syncer about a new potential tipset
@@ -1589,68 +1591,47 @@ func (syncer *Syncer) iterFullTipsets(ctx context.Context, headers []*types.TipS
 	return nil
 }
 
+// fetchMessages fetches the messages of the headers, which the exchange client
+// splits into segments fetched in parallel from several peers.
 func (syncer *Syncer) fetchMessages(ctx context.Context, headers []*types.TipSet, startOffset int) ([]*exchange.CompactedMessages, error) {
-	batchSize := len(headers)
-	batch := make([]*exchange.CompactedMessages, batchSize)
-
-	var wg sync.WaitGroup
-	var mx sync.Mutex
-	var batchErr error
-
 	start := build.Clock.Now()
 
-	for j := 0; j < batchSize; j += syncRequestBatchSize {
-		wg.Add(1)
-		go func(j int) {
-			defer wg.Done()
-
-			nreq := syncRequestBatchSize
-			if j+nreq > batchSize {
-				nreq = batchSize - j
+	log.Infof("fetching messages for %d tipsets at %d", len(headers), startOffset)
+
+	batch := make([]*exchange.CompactedMessages, 0, len(headers))
+	for len(batch) < len(headers) {
+		// short ranges are fetched from a single peer, which may serve only
+		// part of it
+		var requestErr error
+		var requestResult []*exchange.CompactedMessages
+		for retry := 0; requestResult == nil && retry < syncRequestRetries; retry++ {
+			if retry > 0 {
+				log.Infof("fetching messages at %d (retry %d)", startOffset+len(batch), retry)
 			}
 
-			failed := false
-			for offset := 0; !failed && offset < nreq; {
-				nextI := j + offset
-				lastI := j + nreq
-
-				var requestErr error
-				var requestResult []*exchange.CompactedMessages
-				for retry := 0; requestResult == nil && retry < syncRequestRetries; retry++ {
-					if retry > 0 {
-						log.Infof("fetching messages at %d (retry %d)", startOffset+nextI, retry)
-					} else {
-						log.Infof("fetching messages at %d", startOffset+nextI)
-					}
-
-					result, err := syncer.Exchange.GetChainMessages(ctx, headers[nextI:lastI])
-					if err != nil {
-						requestErr = multierror.Append(requestErr, err)
-					} else {
-						requestResult = result
-					}
-				}
+			result, err := syncer.Exchange.GetChainMessages(ctx, headers[len(batch):])
+			switch {
+			case err != nil:
+				requestErr = multierror.Append(requestErr, err)
+			case len(result) == 0:
+				requestErr = multierror.Append(requestErr, xerrors.Errorf("no messages returned"))
+			default:
+				requestResult = result
+			}
 
-				mx.Lock()
-				if requestResult != nil {
-					copy(batch[j+offset:], requestResult)
-					offset += len(requestResult)
-				} else {
-					log.Errorf("error fetching messages at %d: %s", nextI, requestErr)
-					batchErr = multierror.Append(batchErr, requestErr)
-					failed = true
-				}
-				mx.Unlock()
+			if ctx.Err() != nil {
+				break
 			}
-		}(j)
-	}
-	wg.Wait()
+		}
 
-	if batchErr != nil {
-		return nil, batchErr
+		if requestResult == nil {
+			log.Errorf("error fetching messages at %d: %s", startOffset+len(batch), requestErr)
+			return nil, requestErr
+		}
+		batch = append(batch, requestResult...)
 	}
 
-	log.Infof("fetching messages for %d tipsets at %d done; took %s", batchSize, startOffset, build.Clock.Since(start))
+	log.Infof("fetching messages for %d tipsets at %d done; took %s", len(headers), startOffset, build.Clock.Since(start))
 
 	return batch, nil
 }
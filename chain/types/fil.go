@@ -59,13 +59,15 @@ func (f FIL) MarshalText() (text []byte, err error) {
 	return []byte(f.String()), nil
 }
 
-func (f FIL) UnmarshalText(text []byte) error {
+func (f *FIL) UnmarshalText(text []byte) error {
 	p, err := ParseFIL(string(text))
 	if err != nil {
 		return err
 	}
 
-	f.Int.Set(p.Int)
+	// replace rather than set the value, the default configs share their FIL
+	// values, so setting one would change all of them
+	f.Int = p.Int
 	return nil
 }
 
@@ -112,3 +112,13 @@ func TestFilShort(t *testing.T) {
 		})
 	}
 }
+
+func TestFilUnmarshalTextShared(t *testing.T) {
+	def := MustParseFIL("0.07")
+	a, b := def, def
+
+	require.NoError(t, a.UnmarshalText([]byte("5")))
+	require.Equal(t, "5 KAKH", a.String())
+	require.Equal(t, "0.07 KAKH", b.String())
+	require.Equal(t, "0.07 KAKH", def.String())
+}
@@ -10,6 +10,8 @@ import (
 var (
 	ErrKeyInfoNotFound = fmt.Errorf("key info not found")
 	ErrKeyExists       = fmt.Errorf("key already exists")
+	ErrKeyStoreLocked  = fmt.Errorf("keystore is locked")
+	ErrBadPassphrase   = fmt.Errorf("wrong keystore passphrase")
 )
 
 // KeyType defines a type of a key
@@ -70,3 +72,17 @@ type KeyStore interface {
 	// Delete removes a key from keystore
 	Delete(string) error
 }
+
+// LockableKeyStore is a KeyStore whose keys can be encrypted with a
+// passphrase, in which case it has to be unlocked to get or put keys
+type LockableKeyStore interface {
+	KeyStore
+	// Encrypted returns whether the keys are encrypted
+	Encrypted() bool
+	// Unlock derives the encryption key from the passphrase
+	Unlock(passphrase []byte) error
+	// Lock forgets the encryption key
+	Lock() error
+	// Locked returns whether the keystore is encrypted and locked
+	Locked() bool
+}
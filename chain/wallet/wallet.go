@@ -329,6 +329,42 @@ func (w *LocalWallet) WalletDelete(ctx context.Context, addr address.Address) er
 	return nil
 }
 
+// Lock locks the encrypted keystore of the wallet, and forgets the keys read
+// from it, so that they can't be used until the keystore is unlocked.
+func (w *LocalWallet) Lock() error {
+	lks, ok := w.keystore.(types.LockableKeyStore)
+	if !ok || !lks.Encrypted() {
+		return xerrors.Errorf("wallet keystore isn't encrypted")
+	}
+
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if err := lks.Lock(); err != nil {
+		return xerrors.Errorf("locking keystore: %w", err)
+	}
+	w.keys = make(map[address.Address]*Key)
+
+	return nil
+}
+
+// Unlock unlocks the encrypted keystore of the wallet with its passphrase.
+func (w *LocalWallet) Unlock(passphrase []byte) error {
+	lks, ok := w.keystore.(types.LockableKeyStore)
+	if !ok || !lks.Encrypted() {
+		return xerrors.Errorf("wallet keystore isn't encrypted")
+	}
+
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if err := lks.Unlock(passphrase); err != nil {
+		return xerrors.Errorf("unlocking keystore: %w", err)
+	}
+
+	return nil
+}
+
 func (w *LocalWallet) Get() api.Wallet {
 	if w == nil {
 		return nil
@@ -54,6 +54,7 @@ var ChainCmd = &cli.Command{
 		ChainGetCmd,
 		ChainBisectCmd,
 		ChainExportCmd,
+		ChainIndexCmd,
 		SlashConsensusFault,
 		ChainGasPriceCmd,
 		ChainInspectUsage,
@@ -1107,6 +1108,89 @@ var ChainExportCmd = &cli.Command{
 	},
 }
 
+var ChainIndexCmd = &cli.Command{
+	Name:  "index",
+	Usage: "Manage the chain indexes of the node",
+	Subcommands: []*cli.Command{
+		chainIndexBackfillCmd,
+	},
+}
+
+var chainIndexBackfillCmd = &cli.Command{
+	Name:  "backfill",
+	Usage: "Add messages executed before the chain indexes were enabled to the indexes",
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "from",
+			Usage: "epoch to start backfilling from, defaults to the chain head",
+		},
+		&cli.Int64Flag{
+			Name:  "epochs",
+			Usage: "number of epochs to backfill",
+			Value: int64(build.Finality),
+		},
+		&cli.Int64Flag{
+			Name:  "batch",
+			Usage: "number of epochs to backfill per request",
+			Value: 1000,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPIV1(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		ts, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+		if cctx.IsSet("from") {
+			ts, err = api.ChainGetTipSetByHeight(ctx, abi.ChainEpoch(cctx.Int64("from")), ts.Key())
+			if err != nil {
+				return xerrors.Errorf("loading tipset at %d: %w", cctx.Int64("from"), err)
+			}
+		}
+
+		batch := abi.ChainEpoch(cctx.Int64("batch"))
+		if batch <= 0 {
+			return xerrors.Errorf("batch must be positive")
+		}
+
+		stop := ts.Height() - abi.ChainEpoch(cctx.Int64("epochs"))
+		if stop < 0 {
+			stop = 0
+		}
+
+		var total int
+		for ts.Height() > stop {
+			epochs := batch
+			if ts.Height()-epochs < stop {
+				epochs = ts.Height() - stop
+			}
+
+			n, err := api.ChainIndexBackfill(ctx, ts.Key(), epochs)
+			if err != nil {
+				return xerrors.Errorf("backfilling from %d: %w", ts.Height(), err)
+			}
+			total += n
+
+			fmt.Printf("Indexed epochs %d-%d: %d entries (%d total)\n", ts.Height()-epochs+1, ts.Height(), n, total)
+
+			next := ts.Height() - epochs
+			ts, err = api.ChainGetTipSetByHeight(ctx, next, ts.Key())
+			if err != nil {
+				return xerrors.Errorf("loading tipset at %d: %w", next, err)
+			}
+		}
+
+		fmt.Printf("Done, added %d index entries\n", total)
+		return nil
+	},
+}
+
 var SlashConsensusFault = &cli.Command{
 	Name:      "slash-consensus",
 	Usage:     "Report consensus fault",
@@ -1,10 +1,15 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/journal"
 )
 
 var LogCmd = &cli.Command{
@@ -13,6 +18,7 @@ var LogCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		LogList,
 		LogSetLevel,
+		LogJournal,
 	},
 }
 
@@ -100,3 +106,153 @@ var LogSetLevel = &cli.Command{
 		return nil
 	},
 }
+
+var LogJournal = &cli.Command{
+	Name:  "journal",
+	Usage: "Query the event journal",
+	Description: `Print journal events, oldest first, one JSON-encoded event per line.
+
+   The --since and --until flags accept either a duration before now (e.g. 2h)
+   or an RFC3339 timestamp.
+
+   eg) log journal --system wdpost --since 3h
+       log journal --system mpool --event "*" --follow
+`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "system",
+			Usage: "only show events of this system, e.g. wdpost",
+		},
+		&cli.StringFlag{
+			Name:  "event",
+			Usage: "only show events with this name",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "only show events recorded after this time",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "only show events recorded before this time",
+		},
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f"},
+			Usage:   "keep printing new events as they get recorded",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		since, err := parseJournalTime(cctx.String("since"))
+		if err != nil {
+			return xerrors.Errorf("parsing --since: %w", err)
+		}
+		until, err := parseJournalTime(cctx.String("until"))
+		if err != nil {
+			return xerrors.Errorf("parsing --until: %w", err)
+		}
+
+		system, event := cctx.String("system"), cctx.String("event")
+
+		var sub <-chan lapi.JournalEvent
+		if cctx.Bool("follow") {
+			if !until.IsZero() {
+				return xerrors.Errorf("--follow can't be used with --until")
+			}
+
+			// subscribe first so that no events are missed between the
+			// query and the subscription
+			sub, err = api.JournalSubscribe(ctx, system, event)
+			if err != nil {
+				return xerrors.Errorf("subscribing to journal: %w", err)
+			}
+		}
+
+		evts, err := api.JournalQuery(ctx, system, event, since, until)
+		if err != nil {
+			return xerrors.Errorf("querying journal: %w", err)
+		}
+
+		if len(evts) == journal.MaxQueryEvents {
+			_, _ = fmt.Fprintf(cctx.App.ErrWriter, "only the last %d events are shown, use --since and --until to see earlier ones\n", journal.MaxQueryEvents)
+		}
+
+		// events recorded between subscribing and querying are returned by
+		// both; the subscription skips the ones the query returned, counting
+		// identical events, until it gets past the last of them
+		var last time.Time
+		printed := map[journalEventKey]int{}
+		for _, e := range evts {
+			if err := printJournalEvent(cctx, e); err != nil {
+				return err
+			}
+			last = e.Timestamp
+			if sub != nil {
+				printed[journalKey(e)]++
+			}
+		}
+
+		if sub == nil {
+			return nil
+		}
+
+		for e := range sub {
+			if printed != nil {
+				if e.Timestamp.After(last) {
+					printed = nil
+				} else if k := journalKey(e); printed[k] > 0 {
+					printed[k]--
+					continue // already printed by the query
+				}
+			}
+			if err := printJournalEvent(cctx, e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+type journalEventKey struct {
+	system, event string
+	timestamp     int64
+	data          string
+}
+
+func journalKey(e lapi.JournalEvent) journalEventKey {
+	return journalEventKey{
+		system:    e.System,
+		event:     e.Event,
+		timestamp: e.Timestamp.UnixNano(),
+		data:      string(e.Data),
+	}
+}
+
+func parseJournalTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func printJournalEvent(cctx *cli.Context, e lapi.JournalEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return xerrors.Errorf("marshaling event: %w", err)
+	}
+
+	_, err = fmt.Fprintln(cctx.App.Writer, string(b))
+	return err
+}
@@ -3,7 +3,9 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	stdbig "math/big"
+	"os"
 	"sort"
 	"strconv"
 
@@ -34,6 +36,8 @@ var MpoolCmd = &cli.Command{
 		MpoolFindCmd,
 		MpoolConfig,
 		MpoolGasPerfCmd,
+		MpoolPushSignedCmd,
+		MpoolFixGapsCmd,
 	},
 }
 
@@ -722,3 +726,170 @@ var MpoolGasPerfCmd = &cli.Command{
 		return nil
 	},
 }
+
+var MpoolPushSignedCmd = &cli.Command{
+	Name:      "push-signed",
+	Usage:     "Push a message signed with 'lotus wallet sign-message'",
+	ArgsUsage: "[signed message file (default: stdin)]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var b []byte
+		if cctx.Args().Present() {
+			b, err = ioutil.ReadFile(cctx.Args().First())
+		} else {
+			b, err = ioutil.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			return xerrors.Errorf("reading signed message: %w", err)
+		}
+
+		var sm types.SignedMessage
+		if err := json.Unmarshal(b, &sm); err != nil {
+			return xerrors.Errorf("decoding signed message: %w", err)
+		}
+
+		c, err := api.MpoolPush(ctx, &sm)
+		if err != nil {
+			return xerrors.Errorf("pushing message: %w", err)
+		}
+
+		afmt := NewAppFmt(cctx.App)
+		afmt.Println(c)
+		return nil
+	},
+}
+
+var MpoolFixGapsCmd = &cli.Command{
+	Name:  "fix-gaps",
+	Usage: "Fill nonce gaps holding back pending messages with zero-value self-sends",
+	Description: `Messages in the mpool can't be included on chain while a message with a lower
+   nonce from the same address is missing. This command finds every nonce
+   between the on-chain nonce and the highest pending nonce of an address which
+   has no pending message, and fills it with a zero-value message to self.
+
+   Without --from, all wallet addresses with pending messages are checked.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "only fill nonce gaps of the given address",
+		},
+		&cli.Int64Flag{
+			Name:  "gas-fee-cap",
+			Usage: "specify gas fee cap for nonce filling messages (default: 2 * parent base fee)",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only print the nonce gaps",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+		afmt := NewAppFmt(cctx.App)
+
+		msgs, err := api.MpoolPending(ctx, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("getting pending messages: %w", err)
+		}
+
+		pending := map[address.Address]map[uint64]struct{}{}
+		for _, msg := range msgs {
+			if pending[msg.Message.From] == nil {
+				pending[msg.Message.From] = map[uint64]struct{}{}
+			}
+			pending[msg.Message.From][msg.Message.Nonce] = struct{}{}
+		}
+
+		var addrs []address.Address
+		if cctx.IsSet("from") {
+			a, err := address.NewFromString(cctx.String("from"))
+			if err != nil {
+				return xerrors.Errorf("parsing from address: %w", err)
+			}
+			addrs = append(addrs, a)
+		} else {
+			local, err := api.WalletList(ctx)
+			if err != nil {
+				return xerrors.Errorf("listing wallet addresses: %w", err)
+			}
+			for _, a := range local {
+				if _, ok := pending[a]; ok {
+					addrs = append(addrs, a)
+				}
+			}
+		}
+
+		ts, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		feeCap := big.Mul(ts.Blocks()[0].ParentBaseFee, big.NewInt(2))
+		if fcf := cctx.Int64("gas-fee-cap"); fcf != 0 {
+			feeCap = abi.NewTokenAmount(fcf)
+		}
+
+		for _, addr := range addrs {
+			act, err := api.StateGetActor(ctx, addr, ts.Key())
+			if err != nil {
+				return xerrors.Errorf("getting actor %s: %w", addr, err)
+			}
+
+			var maxPending uint64
+			for n := range pending[addr] {
+				if n > maxPending {
+					maxPending = n
+				}
+			}
+
+			var gaps []uint64
+			for n := act.Nonce; n < maxPending; n++ {
+				if _, ok := pending[addr][n]; !ok {
+					gaps = append(gaps, n)
+				}
+			}
+			if len(gaps) == 0 {
+				afmt.Printf("%s: no nonce gaps\n", addr)
+				continue
+			}
+			afmt.Printf("%s: %d nonce gaps: %v\n", addr, len(gaps), gaps)
+			if cctx.Bool("dry-run") {
+				continue
+			}
+
+			for _, n := range gaps {
+				msg := &types.Message{
+					From:       addr,
+					To:         addr,
+					Value:      types.NewInt(0),
+					Nonce:      n,
+					GasLimit:   1000000,
+					GasFeeCap:  feeCap,
+					GasPremium: abi.NewTokenAmount(5),
+				}
+				smsg, err := api.WalletSignMessage(ctx, addr, msg)
+				if err != nil {
+					return xerrors.Errorf("signing filler message %d: %w", n, err)
+				}
+
+				c, err := api.MpoolPush(ctx, smsg)
+				if err != nil {
+					return xerrors.Errorf("pushing filler message %d: %w", n, err)
+				}
+				afmt.Printf("  nonce %d: %s\n", n, c)
+			}
+		}
+
+		return nil
+	},
+}
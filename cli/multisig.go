@@ -29,6 +29,7 @@ import (
 	init2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/init"
 	msig2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/multisig"
 
+	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/blockstore"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors/adt"
@@ -513,6 +514,10 @@ var msigApproveCmd = &cli.Command{
 			from = defaddr
 		}
 
+		if err := printMsigTxn(cctx, api, msig, int64(txid)); err != nil {
+			return err
+		}
+
 		var msgCid cid.Cid
 		if cctx.Args().Len() == 2 {
 			msgCid, err = api.MsigApprove(ctx, msig, txid, from)
@@ -579,6 +584,59 @@ var msigApproveCmd = &cli.Command{
 	},
 }
 
+// printMsigTxn prints the pending multisig transaction, with its parameters
+// decoded, so that signers can see what they approve.
+func printMsigTxn(cctx *cli.Context, api v0api.FullNode, msig address.Address, txid int64) error {
+	ctx := ReqContext(cctx)
+	store := adt.WrapStore(ctx, cbor.NewCborStore(blockstore.NewAPIBlockstore(api)))
+
+	act, err := api.StateGetActor(ctx, msig, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("looking up multisig %s: %w", msig, err)
+	}
+
+	mstate, err := multisig.Load(store, act)
+	if err != nil {
+		return err
+	}
+
+	var tx *multisig.Transaction
+	if err := mstate.ForEachPendingTxn(func(id int64, txn multisig.Transaction) error {
+		if id == txid {
+			tx = &txn
+		}
+		return nil
+	}); err != nil {
+		return xerrors.Errorf("reading pending transactions: %w", err)
+	}
+	if tx == nil {
+		return xerrors.Errorf("multisig %s has no pending transaction %d", msig, txid)
+	}
+
+	method := "Send"
+	params := fmt.Sprintf("%x", tx.Params)
+	if tx.Method != 0 {
+		method = "unknown method"
+		if targAct, err := api.StateGetActor(ctx, tx.To, types.EmptyTSK); err == nil {
+			if m, ok := stmgr.MethodsMap[targAct.Code][tx.Method]; ok {
+				method = m.Name
+			}
+			if p, err := JsonParams(targAct.Code, tx.Method, tx.Params); err == nil {
+				params = p
+			}
+		}
+	}
+
+	w := cctx.App.Writer
+	_, _ = fmt.Fprintf(w, "Transaction %d:\n", txid)
+	_, _ = fmt.Fprintf(w, "To: %s\n", tx.To)
+	_, _ = fmt.Fprintf(w, "Value: %s\n", types.FIL(tx.Value))
+	_, _ = fmt.Fprintf(w, "Method: %s (%d)\n", method, tx.Method)
+	_, _ = fmt.Fprintf(w, "Params: %s\n", params)
+	_, _ = fmt.Fprintf(w, "Approvals: %d\n", len(tx.Approved))
+	return nil
+}
+
 var msigRemoveProposeCmd = &cli.Command{
 	Name:      "propose-remove",
 	Usage:     "Propose to remove a signer",
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/client"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+// UnsignedOutFlag makes commands which send a message write it to a file
+// instead, for it to be signed on another host with 'lotus wallet
+// sign-message', and sent with 'lotus mpool push-signed'.
+var UnsignedOutFlag = &cli.StringFlag{
+	Name:  "unsigned-out",
+	Usage: "write the message to this file for signing offline, instead of sending it; the next nonce of the sender is used, so push it before preparing the next message",
+}
+
+// PrepareUnsignedMessage sets the nonce and gas of a message to be signed
+// offline, and changes its sender to the key address, which the offline
+// wallet can sign with without the chain state.
+func PrepareUnsignedMessage(ctx context.Context, full v0api.FullNode, msg *types.Message, spec *lapi.MessageSendSpec) (*types.Message, error) {
+	from, err := full.StateAccountKey(ctx, msg.From, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("looking up key address of %s: %w", msg.From, err)
+	}
+	msg.From = from
+
+	msg, err = full.GasEstimateMessageGas(ctx, msg, spec, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("estimating gas: %w", err)
+	}
+
+	msg.Nonce, err = full.MpoolGetNonce(ctx, msg.From)
+	if err != nil {
+		return nil, xerrors.Errorf("getting nonce: %w", err)
+	}
+
+	return msg, nil
+}
+
+// WriteUnsignedMessage writes a message prepared with PrepareUnsignedMessage
+// to the file.
+func WriteUnsignedMessage(w io.Writer, path string, msg *types.Message) error {
+	b, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return xerrors.Errorf("writing unsigned message: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "Unsigned message %s written to %s\n", msg.Cid(), path)
+	_, _ = fmt.Fprintf(w, "Sign it with 'lotus wallet sign-message %s', and send it with 'lotus mpool push-signed'\n", path)
+	return nil
+}
+
+// PushMessage pushes the message to the mpool, or writes it to the file given
+// with UnsignedOutFlag for signing offline, in which case it returns nil.
+func PushMessage(cctx *cli.Context, full v0api.FullNode, msg *types.Message, spec *lapi.MessageSendSpec) (*types.SignedMessage, error) {
+	ctx := ReqContext(cctx)
+
+	if out := cctx.String(UnsignedOutFlag.Name); out != "" {
+		msg, err := PrepareUnsignedMessage(ctx, full, msg, spec)
+		if err != nil {
+			return nil, err
+		}
+		return nil, WriteUnsignedMessage(cctx.App.Writer, out, msg)
+	}
+
+	return full.MpoolPushMessage(ctx, msg, spec)
+}
+
+// WalletKeystoreFlag and WalletAPIFlag make 'lotus wallet sign-message' sign
+// without a full node, with the keystore of a repo on the host, or with a
+// lotus-wallet.
+var WalletKeystoreFlag = &cli.StringFlag{
+	Name:  "keystore",
+	Usage: "sign with the keys in the keystore of this lotus or lotus-wallet repo, without a running node; the repo must not be in use",
+}
+
+var WalletAPIFlag = &cli.StringFlag{
+	Name:  "wallet-api",
+	Usage: "sign with the lotus-wallet at this API info (token:multiaddr), without a full node",
+}
+
+// OfflineWallet returns the wallet given with WalletKeystoreFlag or
+// WalletAPIFlag, or nil if neither is set.
+func OfflineWallet(cctx *cli.Context) (lapi.Wallet, func(), error) {
+	if path := cctx.String(WalletKeystoreFlag.Name); path != "" {
+		r, err := repo.NewFS(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ok, err := r.Exists()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, xerrors.Errorf("repo at '%s' is not initialized", path)
+		}
+
+		if err := cliutil.UnlockKeyStore(r); err != nil {
+			return nil, nil, err
+		}
+
+		lr, err := r.LockRO(repo.Wallet)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("locking repo: %w", err)
+		}
+
+		ks, err := lr.KeyStore()
+		if err != nil {
+			_ = lr.Close()
+			return nil, nil, err
+		}
+
+		w, err := wallet.NewWallet(ks)
+		if err != nil {
+			_ = lr.Close()
+			return nil, nil, err
+		}
+
+		return w, func() { _ = lr.Close() }, nil
+	}
+
+	if info := cctx.String(WalletAPIFlag.Name); info != "" {
+		ai := cliutil.ParseApiInfo(info)
+
+		addr, err := ai.DialArgs("v0")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		w, closer, err := client.NewWalletRPCV0(ReqContext(cctx), addr, ai.AuthHeader())
+		if err != nil {
+			return nil, nil, xerrors.Errorf("connecting to lotus-wallet: %w", err)
+		}
+
+		return w, closer, nil
+	}
+
+	return nil, nil, nil
+}
+
+// SignMessage signs the message with the wallet, as the full node does. The
+// sender must be a key address, which messages prepared with
+// PrepareUnsignedMessage are sent from.
+func SignMessage(ctx context.Context, w lapi.Wallet, msg *types.Message) (*types.SignedMessage, error) {
+	mb, err := msg.ToStorageBlock()
+	if err != nil {
+		return nil, xerrors.Errorf("serializing message: %w", err)
+	}
+
+	sig, err := w.WalletSign(ctx, msg.From, mb.Cid().Bytes(), lapi.MsgMeta{
+		Type:  lapi.MTChainMsg,
+		Extra: mb.RawData(),
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to sign message: %w", err)
+	}
+
+	return &types.SignedMessage{
+		Message:   *msg,
+		Signature: *sig,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	ucli "github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+func TestWalletSignMessageKeystore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// a repo with a key, as set up by lotus-wallet on the signing host
+	r, err := repo.NewFS(filepath.Join(dir, "repo"))
+	require.NoError(t, err)
+	require.NoError(t, r.Init(repo.Wallet))
+
+	lr, err := r.Lock(repo.Wallet)
+	require.NoError(t, err)
+	ks, err := lr.KeyStore()
+	require.NoError(t, err)
+	w, err := wallet.NewWallet(ks)
+	require.NoError(t, err)
+	from, err := w.WalletNew(ctx, types.KTSecp256k1)
+	require.NoError(t, err)
+	require.NoError(t, lr.Close())
+
+	msg := &types.Message{
+		From:       from,
+		To:         mustAddr(address.NewIDAddress(1000)),
+		Value:      types.NewInt(1000),
+		Nonce:      3,
+		GasLimit:   1000000,
+		GasFeeCap:  types.NewInt(100),
+		GasPremium: types.NewInt(10),
+	}
+	unsigned := filepath.Join(dir, "msg.json")
+	require.NoError(t, WriteUnsignedMessage(ioutil.Discard, unsigned, msg))
+
+	app := ucli.NewApp()
+	app.Commands = ucli.Commands{walletSignMessage}
+	app.Setup()
+	buf := new(bytes.Buffer)
+	app.Writer = buf
+	app.ErrWriter = ioutil.Discard
+
+	// no full node is needed
+	err = app.Run([]string{"lotus", "sign-message", "--keystore=" + filepath.Join(dir, "repo"), unsigned})
+	require.NoError(t, err)
+
+	var sm types.SignedMessage
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &sm))
+	require.Equal(t, msg.Cid(), sm.Message.Cid())
+	require.NoError(t, sigs.Verify(&sm.Signature, from, sm.Message.Cid().Bytes()))
+
+	// the keystore can't be combined with a lotus-wallet
+	err = app.Run([]string{"lotus", "sign-message", "--keystore=" + filepath.Join(dir, "repo"), "--wallet-api=token:/ip4/127.0.0.1/tcp/1234/http", unsigned})
+	require.Error(t, err)
+}
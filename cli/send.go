@@ -61,6 +61,7 @@ var sendCmd = &cli.Command{
 			Name:  "force",
 			Usage: "must be specified for the action to take effect if maybe SysErrInsufficientFunds etc",
 		},
+		UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 2 {
@@ -144,6 +145,18 @@ var sendCmd = &cli.Command{
 			params.Nonce = &n
 		}
 
+		if out := cctx.String(UnsignedOutFlag.Name); out != "" {
+			msg, err := srv.PrepareUnsigned(ctx, params)
+			if err != nil {
+				if errors.Is(err, ErrSendBalanceTooLow) {
+					return fmt.Errorf("--force must be specified for this action to have an effect; you have been warned: %w", err)
+				}
+				return xerrors.Errorf("preparing message: %w", err)
+			}
+
+			return WriteUnsignedMessage(cctx.App.Writer, out, msg)
+		}
+
 		msgCid, err := srv.Send(ctx, params)
 
 		if err != nil {
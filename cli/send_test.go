@@ -2,7 +2,10 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/filecoin-project/go-address"
@@ -125,4 +128,36 @@ func TestSendCLI(t *testing.T) {
 		assert.EqualValues(t, arbtCid.String()+"\n", buf.String())
 	})
 
+	t.Run("unsigned-out", func(t *testing.T) {
+		app, mockSrvcs, buf, done := newMockApp(t, sendCmd)
+		defer done()
+
+		msg := &types.Message{
+			To:       mustAddr(address.NewIDAddress(1)),
+			From:     mustAddr(address.NewIDAddress(2)),
+			Value:    oneFil,
+			Nonce:    3,
+			GasLimit: 1000,
+		}
+		out := filepath.Join(t.TempDir(), "msg.json")
+
+		gomock.InOrder(
+			mockSrvcs.EXPECT().PrepareUnsigned(gomock.Any(), SendParams{
+				To:  mustAddr(address.NewIDAddress(1)),
+				Val: oneFil,
+			}).Return(msg, nil),
+			// nothing is sent
+			mockSrvcs.EXPECT().Close(),
+		)
+		err := app.Run([]string{"lotus", "send", "--unsigned-out=" + out, "t01", "1"})
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), msg.Cid().String())
+
+		b, err := ioutil.ReadFile(out)
+		assert.NoError(t, err)
+		var written types.Message
+		assert.NoError(t, json.Unmarshal(b, &written))
+		assert.Equal(t, msg.Cid(), written.Cid())
+	})
+
 }
@@ -24,6 +24,9 @@ import (
 type ServicesAPI interface {
 	// Sends executes a send given SendParams
 	Send(ctx context.Context, params SendParams) (cid.Cid, error)
+	// PrepareUnsigned builds the message Send would send, with its nonce and
+	// gas set, to be signed offline
+	PrepareUnsigned(ctx context.Context, params SendParams) (*types.Message, error)
 	// DecodeTypedParamsFromJSON takes in information needed to identify a method and converts JSON
 	// parameters to bytes of their CBOR encoding
 	DecodeTypedParamsFromJSON(ctx context.Context, to address.Address, method abi.MethodNum, paramstr string) ([]byte, error)
@@ -94,11 +97,11 @@ type SendParams struct {
 
 var ErrSendBalanceTooLow = errors.New("balance too low")
 
-func (s *ServicesImpl) Send(ctx context.Context, params SendParams) (cid.Cid, error) {
+func (s *ServicesImpl) messageForSend(ctx context.Context, params SendParams) (*types.Message, error) {
 	if params.From == address.Undef {
 		defaddr, err := s.api.WalletDefaultAddress(ctx)
 		if err != nil {
-			return cid.Undef, err
+			return nil, err
 		}
 		params.From = defaddr
 	}
@@ -132,19 +135,28 @@ func (s *ServicesImpl) Send(ctx context.Context, params SendParams) (cid.Cid, er
 		// Funds insufficient check
 		fromBalance, err := s.api.WalletBalance(ctx, msg.From)
 		if err != nil {
-			return cid.Undef, err
+			return nil, err
 		}
 		totalCost := types.BigAdd(types.BigMul(msg.GasFeeCap, types.NewInt(uint64(msg.GasLimit))), msg.Value)
 
 		if fromBalance.LessThan(totalCost) {
-			return cid.Undef, xerrors.Errorf("From balance %s less than total cost %s: %w", types.FIL(fromBalance), types.FIL(totalCost), ErrSendBalanceTooLow)
+			return nil, xerrors.Errorf("From balance %s less than total cost %s: %w", types.FIL(fromBalance), types.FIL(totalCost), ErrSendBalanceTooLow)
 
 		}
 	}
 
+	return msg, nil
+}
+
+func (s *ServicesImpl) Send(ctx context.Context, params SendParams) (cid.Cid, error) {
+	msg, err := s.messageForSend(ctx, params)
+	if err != nil {
+		return cid.Undef, err
+	}
+
 	if params.Nonce != nil {
 		msg.Nonce = *params.Nonce
-		sm, err := s.api.WalletSignMessage(ctx, params.From, msg)
+		sm, err := s.api.WalletSignMessage(ctx, msg.From, msg)
 		if err != nil {
 			return cid.Undef, err
 		}
@@ -164,3 +176,21 @@ func (s *ServicesImpl) Send(ctx context.Context, params SendParams) (cid.Cid, er
 
 	return sm.Cid(), nil
 }
+
+func (s *ServicesImpl) PrepareUnsigned(ctx context.Context, params SendParams) (*types.Message, error) {
+	msg, err := s.messageForSend(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err = PrepareUnsignedMessage(ctx, s.api, msg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Nonce != nil {
+		msg.Nonce = *params.Nonce
+	}
+
+	return msg, nil
+}
@@ -263,4 +263,27 @@ func TestSendService(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, *msgCid, c)
 	})
+
+	t.Run("prepare-unsigned", func(t *testing.T) {
+		params := params
+		srvcs, mockApi := setupMockSrvcs(t)
+		defer srvcs.Close() //nolint:errcheck
+
+		gomock.InOrder(
+			mockApi.EXPECT().WalletBalance(ctxM, a1).Return(types.NewInt(balance), nil),
+			mockApi.EXPECT().StateAccountKey(ctxM, a1, types.EmptyTSK).Return(a1, nil),
+			mockApi.EXPECT().GasEstimateMessageGas(ctxM, MessageMatcher(params), nil, types.EmptyTSK).DoAndReturn(
+				func(_ context.Context, msg *types.Message, _ *api.MessageSendSpec, _ types.TipSetKey) (*types.Message, error) {
+					msg.GasLimit = 1000
+					return msg, nil
+				}),
+			mockApi.EXPECT().MpoolGetNonce(ctxM, a1).Return(uint64(7), nil),
+			// nothing is signed or pushed
+		)
+
+		msg, err := srvcs.PrepareUnsigned(ctx, params)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(7), msg.Nonce)
+		assert.Equal(t, int64(1000), msg.GasLimit)
+	})
 }
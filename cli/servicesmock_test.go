@@ -8,6 +8,7 @@ import (
 	context "context"
 	go_address "github.com/filecoin-project/go-address"
 	abi "github.com/filecoin-project/go-state-types/abi"
+	types "github.com/filecoin-project/lotus/chain/types"
 	gomock "github.com/golang/mock/gomock"
 	go_cid "github.com/ipfs/go-cid"
 	reflect "reflect"
@@ -65,6 +66,21 @@ func (mr *MockServicesAPIMockRecorder) DecodeTypedParamsFromJSON(arg0, arg1, arg
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecodeTypedParamsFromJSON", reflect.TypeOf((*MockServicesAPI)(nil).DecodeTypedParamsFromJSON), arg0, arg1, arg2, arg3)
 }
 
+// PrepareUnsigned mocks base method
+func (m *MockServicesAPI) PrepareUnsigned(arg0 context.Context, arg1 SendParams) (*types.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrepareUnsigned", arg0, arg1)
+	ret0, _ := ret[0].(*types.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrepareUnsigned indicates an expected call of PrepareUnsigned
+func (mr *MockServicesAPIMockRecorder) PrepareUnsigned(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrepareUnsigned", reflect.TypeOf((*MockServicesAPI)(nil).PrepareUnsigned), arg0, arg1)
+}
+
 // Send mocks base method
 func (m *MockServicesAPI) Send(arg0 context.Context, arg1 SendParams) (go_cid.Cid, error) {
 	m.ctrl.T.Helper()
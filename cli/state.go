@@ -59,6 +59,7 @@ var StateCmd = &cli.Command{
 		StateListActorsCmd,
 		StateListMinersCmd,
 		StateCircSupplyCmd,
+		StateSupplyCmd,
 		StateSectorCmd,
 		StateGetActorCmd,
 		StateLookupIDCmd,
@@ -67,6 +68,8 @@ var StateCmd = &cli.Command{
 		StateReadStateCmd,
 		StateListMessagesCmd,
 		StateComputeStateCmd,
+		StateComputeDiffCmd,
+		StateDiffRootsCmd,
 		StateCallCmd,
 		StateGetDealSetCmd,
 		StateWaitMsgCmd,
@@ -116,6 +119,13 @@ var StateMinerInfo = &cli.Command{
 		}
 		fmt.Printf("Available Balance: %s\n", types.FIL(availableBalance))
 		fmt.Printf("Owner:\t%s\n", mi.Owner)
+		if mi.Beneficiary != mi.Owner {
+			fmt.Printf("Beneficiary:\t%s (quota %s, used %s, expires at %d)\n", mi.Beneficiary,
+				types.FIL(mi.BeneficiaryTerm.Quota), types.FIL(mi.BeneficiaryTerm.UsedQuota), mi.BeneficiaryTerm.Expiration)
+		}
+		if p := mi.PendingBeneficiaryTerm; p != nil {
+			fmt.Printf("Pending Beneficiary:\t%s (quota %s, expires at %d)\n", p.NewBeneficiary, types.FIL(p.NewQuota), p.NewExpiration)
+		}
 		fmt.Printf("Worker:\t%s\n", mi.Worker)
 		for i, controlAddress := range mi.ControlAddresses {
 			fmt.Printf("Control %d: \t%s\n", i, controlAddress)
@@ -426,6 +436,7 @@ var StateReplayCmd = &cli.Command{
 			Name:  "detailed-gas",
 			Usage: "print out detailed gas costs for given message",
 		},
+		traceTreeFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 1 {
@@ -474,6 +485,14 @@ var StateReplayCmd = &cli.Command{
 			printInternalExecutions("\t", res.ExecutionTrace.Subcalls)
 		}
 
+		if cctx.Bool("tree") {
+			head, err := fapi.ChainHead(ctx)
+			if err != nil {
+				return err
+			}
+			printTraceTree(os.Stdout, res, traceCodeGetter(fapi, head.ParentState()), true)
+		}
+
 		return nil
 	},
 }
@@ -941,6 +960,7 @@ var StateComputeStateCmd = &cli.Command{
 			Name:  "no-timing",
 			Usage: "don't show timing information in html traces",
 		},
+		traceTreeFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
@@ -1038,6 +1058,13 @@ var StateComputeStateCmd = &cli.Command{
 		}
 
 		fmt.Println("computed state cid: ", stout.Root)
+		if cctx.Bool("tree") {
+			getCode := traceCodeGetter(api, stout.Root)
+			for _, ir := range stout.Trace {
+				printTraceTree(os.Stdout, ir, getCode, !cctx.Bool("no-timing"))
+			}
+			return nil
+		}
 		if cctx.Bool("show-trace") {
 			for _, ir := range stout.Trace {
 				fmt.Printf("%s\t%s\t%s\t%d\t%x\t%d\t%x\n", ir.Msg.From, ir.Msg.To, ir.Msg.Value, ir.Msg.Method, ir.Msg.Params, ir.MsgRct.ExitCode, ir.MsgRct.Return)
@@ -1743,6 +1770,58 @@ var StateCircSupplyCmd = &cli.Command{
 	},
 }
 
+var StateSupplyCmd = &cli.Command{
+	Name:  "supply",
+	Usage: "Break down the supply into minted, vested, burnt and locked funds",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the breakdown as json",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPIV1(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		ts, err := LoadTipSet(ctx, cctx, &v0api.WrapperV1Full{FullNode: api})
+		if err != nil {
+			return err
+		}
+
+		sup, err := api.StateSupply(ctx, ts.Key())
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			out, err := json.MarshalIndent(sup, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
+		fmt.Printf("Epoch:              %d\n", sup.Epoch)
+		fmt.Printf("Minted:             %s\n", types.FIL(sup.Minted))
+		fmt.Printf("Vested:             %s\n", types.FIL(sup.Vested))
+		fmt.Printf("Reserve disbursed:  %s\n", types.FIL(sup.ReserveDisbursed))
+		fmt.Printf("Burnt:              %s\n", types.FIL(sup.Burnt))
+		fmt.Printf("Pledged:            %s\n", types.FIL(sup.Pledged))
+		fmt.Printf("Market locked:      %s\n", types.FIL(sup.MarketLocked))
+		fmt.Printf("PoS locked:         %s\n", types.FIL(sup.PosLocked))
+		fmt.Printf("Circulating:        %s\n", types.FIL(sup.Circulating))
+		fmt.Printf("VM circulating:     %s (doesn't deduct PoS locked)\n", types.FIL(sup.VMCirculating))
+
+		return nil
+	},
+}
+
 var StateSectorCmd = &cli.Command{
 	Name:      "sector",
 	Usage:     "Get miner sector info",
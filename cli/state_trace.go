@@ -0,0 +1,605 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	init_ "github.com/filecoin-project/lotus/chain/actors/builtin/init"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/market"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/power"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var StateComputeDiffCmd = &cli.Command{
+	Name:  "compute-diff",
+	Usage: "Compare the outputs of two state computations",
+	Description: `Compares two execution traces, as written by 'lotus state compute-state --json',
+e.g. computed by two builds which disagree on a state root, and prints where
+the messages, receipts, subcalls and gas charges diverge.
+
+With --state, the actors which differ between the two resulting state roots
+are also listed, which requires both state trees to be available to the node.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "a",
+			Usage:    "first compute-state json output",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "b",
+			Usage:    "second compute-state json output",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "state",
+			Usage: "also diff the actor states of the two state roots",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		a, err := readComputeStateOutput(cctx.String("a"))
+		if err != nil {
+			return err
+		}
+		b, err := readComputeStateOutput(cctx.String("b"))
+		if err != nil {
+			return err
+		}
+
+		if a.Root == b.Root {
+			fmt.Printf("State roots match: %s\n", a.Root)
+		} else {
+			fmt.Printf("State roots differ: %s != %s\n", a.Root, b.Root)
+		}
+
+		diffs := diffTraces(a.Trace, b.Trace)
+		if len(diffs) == 0 {
+			fmt.Println("Execution traces match")
+		} else {
+			fmt.Printf("Execution traces differ in %d places:\n", len(diffs))
+			for _, d := range diffs {
+				fmt.Printf("%s: %s\n\ta: %s\n\tb: %s\n", d.Path, d.What, d.A, d.B)
+			}
+		}
+
+		if !cctx.Bool("state") || a.Root == b.Root {
+			return nil
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		fmt.Println()
+		return printStateDiff(ReqContext(cctx), os.Stdout, api, a.Root, b.Root)
+	},
+}
+
+var StateDiffRootsCmd = &cli.Command{
+	Name:      "diff-roots",
+	Usage:     "List the actors which differ between two state roots",
+	ArgsUsage: "<stateRootA> <stateRootB>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 2 {
+			return ShowHelp(cctx, fmt.Errorf("must pass two state roots"))
+		}
+
+		ra, err := cid.Decode(cctx.Args().Get(0))
+		if err != nil {
+			return xerrors.Errorf("parsing first state root: %w", err)
+		}
+		rb, err := cid.Decode(cctx.Args().Get(1))
+		if err != nil {
+			return xerrors.Errorf("parsing second state root: %w", err)
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		return printStateDiff(ReqContext(cctx), os.Stdout, api, ra, rb)
+	},
+}
+
+func readComputeStateOutput(path string) (*lapi.ComputeStateOutput, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var o lapi.ComputeStateOutput
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, xerrors.Errorf("parsing %s: %w", path, err)
+	}
+
+	return &o, nil
+}
+
+type traceDiff struct {
+	// Path locates the call, as the message index followed by the subcall
+	// index at each level
+	Path string
+	What string
+	A, B string
+}
+
+// diffTraces compares the execution traces of two computations of the same
+// tipset, message by message.
+func diffTraces(a, b []*lapi.InvocResult) []traceDiff {
+	var out []traceDiff
+
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+
+	for i := 0; i < n; i++ {
+		path := fmt.Sprintf("msg %d", i)
+		if i >= len(a) {
+			out = append(out, traceDiff{Path: path, What: "message", A: "missing", B: b[i].MsgCid.String()})
+			continue
+		}
+		if i >= len(b) {
+			out = append(out, traceDiff{Path: path, What: "message", A: a[i].MsgCid.String(), B: "missing"})
+			continue
+		}
+
+		ia, ib := a[i], b[i]
+		if ia.MsgCid != ib.MsgCid {
+			// the computations didn't apply the same messages, the rest of the
+			// traces can't be compared
+			out = append(out, traceDiff{Path: path, What: "message", A: ia.MsgCid.String(), B: ib.MsgCid.String()})
+			break
+		}
+
+		path = fmt.Sprintf("msg %d (%s)", i, ia.MsgCid)
+		cmp := func(what, x, y string) {
+			if x != y {
+				out = append(out, traceDiff{Path: path, What: what, A: x, B: y})
+			}
+		}
+
+		cmp("error", ia.Error, ib.Error)
+		cmp("total cost", ia.GasCost.TotalCost.String(), ib.GasCost.TotalCost.String())
+		cmp("base fee burn", ia.GasCost.BaseFeeBurn.String(), ib.GasCost.BaseFeeBurn.String())
+		cmp("over estimation burn", ia.GasCost.OverEstimationBurn.String(), ib.GasCost.OverEstimationBurn.String())
+		cmp("miner penalty", ia.GasCost.MinerPenalty.String(), ib.GasCost.MinerPenalty.String())
+		cmp("miner tip", ia.GasCost.MinerTip.String(), ib.GasCost.MinerTip.String())
+		cmp("refund", ia.GasCost.Refund.String(), ib.GasCost.Refund.String())
+
+		out = append(out, diffExecution(path, ia.ExecutionTrace, ib.ExecutionTrace)...)
+	}
+
+	return out
+}
+
+func diffExecution(path string, a, b types.ExecutionTrace) []traceDiff {
+	var out []traceDiff
+	cmp := func(what, x, y string) {
+		if x != y {
+			out = append(out, traceDiff{Path: path, What: what, A: x, B: y})
+		}
+	}
+
+	if a.Msg != nil && b.Msg != nil {
+		cmp("from", a.Msg.From.String(), b.Msg.From.String())
+		cmp("to", a.Msg.To.String(), b.Msg.To.String())
+		cmp("method", a.Msg.Method.String(), b.Msg.Method.String())
+		cmp("value", a.Msg.Value.String(), b.Msg.Value.String())
+		cmp("params", fmt.Sprintf("%x", a.Msg.Params), fmt.Sprintf("%x", b.Msg.Params))
+	}
+	if a.MsgRct != nil && b.MsgRct != nil {
+		cmp("exit code", a.MsgRct.ExitCode.String(), b.MsgRct.ExitCode.String())
+		cmp("return", fmt.Sprintf("%x", a.MsgRct.Return), fmt.Sprintf("%x", b.MsgRct.Return))
+		cmp("gas used", fmt.Sprint(a.MsgRct.GasUsed), fmt.Sprint(b.MsgRct.GasUsed))
+	}
+	cmp("error", a.Error, b.Error)
+
+	if ga, gb := ownGas(a), ownGas(b); ga != gb {
+		cmp("own gas", fmt.Sprint(ga), fmt.Sprint(gb))
+
+		// point at the first charge which differs
+		for i := 0; i < len(a.GasCharges) || i < len(b.GasCharges); i++ {
+			ca, cb := "missing", "missing"
+			if i < len(a.GasCharges) {
+				ca = fmt.Sprintf("%s %d", a.GasCharges[i].Name, a.GasCharges[i].TotalGas)
+			}
+			if i < len(b.GasCharges) {
+				cb = fmt.Sprintf("%s %d", b.GasCharges[i].Name, b.GasCharges[i].TotalGas)
+			}
+			if ca != cb {
+				cmp(fmt.Sprintf("gas charge %d", i), ca, cb)
+				break
+			}
+		}
+	}
+
+	cmp("subcalls", fmt.Sprint(len(a.Subcalls)), fmt.Sprint(len(b.Subcalls)))
+	for i := 0; i < len(a.Subcalls) && i < len(b.Subcalls); i++ {
+		out = append(out, diffExecution(fmt.Sprintf("%s/%d", path, i), a.Subcalls[i], b.Subcalls[i])...)
+	}
+
+	return out
+}
+
+// ownGas is the gas charged to a call itself, excluding its subcalls
+func ownGas(et types.ExecutionTrace) int64 {
+	var gas int64
+	for _, gc := range et.GasCharges {
+		gas += gc.TotalGas
+	}
+	return gas
+}
+
+// totalGas is the gas charged to a call and its subcalls
+func totalGas(et types.ExecutionTrace) int64 {
+	gas := ownGas(et)
+	for _, sc := range et.Subcalls {
+		gas += totalGas(sc)
+	}
+	return gas
+}
+
+// printTraceTree writes the calls made by a message as a tree, with the gas
+// charged to each call, including and excluding its subcalls.
+func printTraceTree(w io.Writer, ir *lapi.InvocResult, getCode func(addr address.Address) (cid.Cid, error), timing bool) {
+	fmt.Fprintf(w, "%s", ir.MsgCid)
+	if ir.MsgRct != nil {
+		fmt.Fprintf(w, ": exit %d, gas used %d", ir.MsgRct.ExitCode, ir.MsgRct.GasUsed)
+	}
+	if !ir.GasCost.TotalCost.Nil() {
+		fmt.Fprintf(w, ", cost %s", types.FIL(ir.GasCost.TotalCost).Short())
+	}
+	fmt.Fprintln(w)
+
+	et := ir.ExecutionTrace
+	if et.Msg == nil {
+		et.Msg = ir.Msg
+	}
+	if et.MsgRct == nil {
+		et.MsgRct = ir.MsgRct
+	}
+	printCallTree(w, "", true, et, getCode, timing)
+}
+
+func printCallTree(w io.Writer, prefix string, last bool, et types.ExecutionTrace, getCode func(addr address.Address) (cid.Cid, error), timing bool) {
+	branch, indent := "├─ ", "│  "
+	if last {
+		branch, indent = "└─ ", "   "
+	}
+
+	line := prefix + branch
+	if et.Msg != nil {
+		method := fmt.Sprintf("method %d", et.Msg.Method)
+		if getCode != nil {
+			if code, err := getCode(et.Msg.To); err == nil {
+				if name := getMethod(code, et.Msg.Method); name != "" {
+					method = name
+				}
+			}
+		}
+		line += fmt.Sprintf("%s → %s %s", et.Msg.From, et.Msg.To, method)
+		if !et.Msg.Value.IsZero() {
+			line += fmt.Sprintf(" (%s)", types.FIL(et.Msg.Value).Short())
+		}
+	}
+	if et.MsgRct != nil {
+		line += fmt.Sprintf(": exit %d", et.MsgRct.ExitCode)
+	}
+	line += fmt.Sprintf(", gas %d (own %d)", totalGas(et), ownGas(et))
+	if timing {
+		line += fmt.Sprintf(", %s", et.Duration)
+	}
+	if et.Error != "" {
+		line += fmt.Sprintf(", error: %s", et.Error)
+	}
+	fmt.Fprintln(w, line)
+
+	for i, sc := range et.Subcalls {
+		printCallTree(w, prefix+indent, i == len(et.Subcalls)-1, sc, getCode, timing)
+	}
+}
+
+// printStateDiff lists the actors which were added, removed or changed
+// between two state roots, with the changes to builtin actor states found by
+// the actor diff helpers.
+func printStateDiff(ctx context.Context, w io.Writer, api v0api.FullNode, ra, rb cid.Cid) error {
+	cst := cbor.NewCborStore(blockstore.NewAPIBlockstore(api))
+	store := adt.WrapStore(ctx, cst)
+
+	sta, err := state.LoadStateTree(cst, ra)
+	if err != nil {
+		return xerrors.Errorf("loading state tree %s: %w", ra, err)
+	}
+	stb, err := state.LoadStateTree(cst, rb)
+	if err != nil {
+		return xerrors.Errorf("loading state tree %s: %w", rb, err)
+	}
+
+	changed, err := state.Diff(sta, stb)
+	if err != nil {
+		return xerrors.Errorf("diffing state trees: %w", err)
+	}
+	// actors only in a
+	removed, err := state.Diff(stb, sta)
+	if err != nil {
+		return xerrors.Errorf("diffing state trees: %w", err)
+	}
+
+	addrs := make([]string, 0, len(changed)+len(removed))
+	for k := range changed {
+		addrs = append(addrs, k)
+	}
+	for k := range removed {
+		if _, ok := changed[k]; !ok {
+			addrs = append(addrs, k)
+		}
+	}
+	sort.Strings(addrs)
+
+	fmt.Fprintf(w, "%d actors differ between %s and %s\n", len(addrs), ra, rb)
+
+	for _, k := range addrs {
+		addr, err := address.NewFromString(k)
+		if err != nil {
+			return err
+		}
+
+		actA, errA := sta.GetActor(addr)
+		actB, errB := stb.GetActor(addr)
+		switch {
+		case errA != nil && errB != nil:
+			return xerrors.Errorf("loading actor %s: %w", addr, errA)
+		case errA != nil:
+			fmt.Fprintf(w, "%s: only in b (%s, balance %s)\n", addr, builtin.ActorNameByCode(actB.Code), types.FIL(actB.Balance))
+			continue
+		case errB != nil:
+			fmt.Fprintf(w, "%s: only in a (%s, balance %s)\n", addr, builtin.ActorNameByCode(actA.Code), types.FIL(actA.Balance))
+			continue
+		}
+
+		fmt.Fprintf(w, "%s (%s):\n", addr, builtin.ActorNameByCode(actB.Code))
+		if actA.Code != actB.Code {
+			fmt.Fprintf(w, "\tcode: %s -> %s\n", builtin.ActorNameByCode(actA.Code), builtin.ActorNameByCode(actB.Code))
+		}
+		if !actA.Balance.Equals(actB.Balance) {
+			fmt.Fprintf(w, "\tbalance: %s -> %s (%s)\n", types.FIL(actA.Balance), types.FIL(actB.Balance), types.FIL(types.BigSub(actB.Balance, actA.Balance)))
+		}
+		if actA.Nonce != actB.Nonce {
+			fmt.Fprintf(w, "\tnonce: %d -> %d\n", actA.Nonce, actB.Nonce)
+		}
+		if actA.Head != actB.Head {
+			fmt.Fprintf(w, "\thead: %s -> %s\n", actA.Head, actB.Head)
+
+			changes, err := diffActorState(store, addr, actA, actB)
+			if err != nil {
+				fmt.Fprintf(w, "\tcan't diff actor state: %s\n", err)
+			}
+			for _, c := range changes {
+				fmt.Fprintf(w, "\t%s\n", c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// diffActorState describes the changes between two states of a builtin actor,
+// for the actors which have diff helpers.
+func diffActorState(store adt.Store, addr address.Address, a, b *types.Actor) ([]string, error) {
+	var out []string
+	add := func(format string, args ...interface{}) {
+		out = append(out, fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case builtin.IsStorageMinerActor(b.Code):
+		sa, err := miner.Load(store, a)
+		if err != nil {
+			return nil, err
+		}
+		sb, err := miner.Load(store, b)
+		if err != nil {
+			return nil, err
+		}
+
+		sc, err := miner.DiffSectors(sa, sb)
+		if err != nil {
+			return nil, xerrors.Errorf("diffing sectors: %w", err)
+		}
+		for _, s := range sc.Added {
+			add("sector %d added", s.SectorNumber)
+		}
+		for _, s := range sc.Extended {
+			add("sector %d extended: expiration %d -> %d", s.To.SectorNumber, s.From.Expiration, s.To.Expiration)
+		}
+		for _, s := range sc.Removed {
+			add("sector %d removed", s.SectorNumber)
+		}
+
+		pc, err := miner.DiffPreCommits(sa, sb)
+		if err != nil {
+			return nil, xerrors.Errorf("diffing precommits: %w", err)
+		}
+		for _, p := range pc.Added {
+			add("precommit %d added", p.Info.SectorNumber)
+		}
+		for _, p := range pc.Removed {
+			add("precommit %d removed", p.Info.SectorNumber)
+		}
+
+	case builtin.IsMultisigActor(b.Code):
+		sa, err := multisig.Load(store, a)
+		if err != nil {
+			return nil, err
+		}
+		sb, err := multisig.Load(store, b)
+		if err != nil {
+			return nil, err
+		}
+
+		tc, err := multisig.DiffPendingTransactions(sa, sb)
+		if err != nil {
+			return nil, xerrors.Errorf("diffing pending transactions: %w", err)
+		}
+		for _, t := range tc.Added {
+			add("transaction %d added: to %s, value %s, method %d", t.TxID, t.Tx.To, types.FIL(t.Tx.Value), t.Tx.Method)
+		}
+		for _, t := range tc.Modified {
+			add("transaction %d approvals: %v -> %v", t.TxID, t.From.Approved, t.To.Approved)
+		}
+		for _, t := range tc.Removed {
+			add("transaction %d removed", t.TxID)
+		}
+
+	case addr == power.Address:
+		sa, err := power.Load(store, a)
+		if err != nil {
+			return nil, err
+		}
+		sb, err := power.Load(store, b)
+		if err != nil {
+			return nil, err
+		}
+
+		cc, err := power.DiffClaims(sa, sb)
+		if err != nil {
+			return nil, xerrors.Errorf("diffing claims: %w", err)
+		}
+		for _, c := range cc.Added {
+			add("claim of %s added: %s QA power", c.Miner, types.SizeStr(c.Claim.QualityAdjPower))
+		}
+		for _, c := range cc.Modified {
+			add("claim of %s: %s -> %s QA power", c.Miner, types.SizeStr(c.From.QualityAdjPower), types.SizeStr(c.To.QualityAdjPower))
+		}
+		for _, c := range cc.Removed {
+			add("claim of %s removed", c.Miner)
+		}
+
+	case addr == market.Address:
+		sa, err := market.Load(store, a)
+		if err != nil {
+			return nil, err
+		}
+		sb, err := market.Load(store, b)
+		if err != nil {
+			return nil, err
+		}
+
+		pa, err := sa.Proposals()
+		if err != nil {
+			return nil, err
+		}
+		pb, err := sb.Proposals()
+		if err != nil {
+			return nil, err
+		}
+		pc, err := market.DiffDealProposals(pa, pb)
+		if err != nil {
+			return nil, xerrors.Errorf("diffing deal proposals: %w", err)
+		}
+		for _, p := range pc.Added {
+			add("deal %d proposed: %s -> %s", p.ID, p.Proposal.Client, p.Proposal.Provider)
+		}
+		for _, p := range pc.Removed {
+			add("deal %d proposal removed", p.ID)
+		}
+
+		da, err := sa.States()
+		if err != nil {
+			return nil, err
+		}
+		db, err := sb.States()
+		if err != nil {
+			return nil, err
+		}
+		dc, err := market.DiffDealStates(da, db)
+		if err != nil {
+			return nil, xerrors.Errorf("diffing deal states: %w", err)
+		}
+		for _, d := range dc.Added {
+			add("deal %d activated at %d", d.ID, d.Deal.SectorStartEpoch)
+		}
+		for _, d := range dc.Modified {
+			add("deal %d state changed: %+v -> %+v", d.ID, *d.From, *d.To)
+		}
+		for _, d := range dc.Removed {
+			add("deal %d state removed", d.ID)
+		}
+
+	case addr == init_.Address:
+		sa, err := init_.Load(store, a)
+		if err != nil {
+			return nil, err
+		}
+		sb, err := init_.Load(store, b)
+		if err != nil {
+			return nil, err
+		}
+
+		ac, err := init_.DiffAddressMap(sa, sb)
+		if err != nil {
+			return nil, xerrors.Errorf("diffing address map: %w", err)
+		}
+		for _, p := range ac.Added {
+			add("address %s assigned %s", p.PK, p.ID)
+		}
+		for _, p := range ac.Modified {
+			add("address %s: %s -> %s", p.To.PK, p.From.ID, p.To.ID)
+		}
+		for _, p := range ac.Removed {
+			add("address %s (%s) removed", p.PK, p.ID)
+		}
+	}
+
+	return out, nil
+}
+
+// traceTreeFlag adds a readable call tree to the commands printing traces
+var traceTreeFlag = &cli.BoolFlag{
+	Name:  "tree",
+	Usage: "print traces as a tree of calls, with the gas used by each call",
+}
+
+func traceCodeGetter(api v0api.FullNode, root cid.Cid) func(addr address.Address) (cid.Cid, error) {
+	st, err := state.LoadStateTree(cbor.NewCborStore(blockstore.NewAPIBlockstore(api)), root)
+	if err != nil {
+		return nil
+	}
+
+	codes := map[address.Address]cid.Cid{}
+	return func(addr address.Address) (cid.Cid, error) {
+		if c, ok := codes[addr]; ok {
+			return c, nil
+		}
+		act, err := st.GetActor(addr)
+		if err != nil {
+			return cid.Undef, err
+		}
+		codes[addr] = act.Code
+		return act.Code, nil
+	}
+}
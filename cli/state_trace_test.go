@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/stretchr/testify/require"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	types "github.com/filecoin-project/lotus/chain/types"
+)
+
+func testTrace(value int64, gas int64, code exitcode.ExitCode) []*lapi.InvocResult {
+	msg := &types.Message{
+		From:   mustAddr(address.NewIDAddress(100)),
+		To:     mustAddr(address.NewIDAddress(1000)),
+		Value:  types.NewInt(0),
+		Method: 2,
+	}
+	sub := &types.Message{
+		From:   msg.To,
+		To:     mustAddr(address.NewIDAddress(101)),
+		Value:  types.NewInt(uint64(value)),
+		Method: 0,
+	}
+
+	return []*lapi.InvocResult{{
+		MsgCid: msg.Cid(),
+		Msg:    msg,
+		MsgRct: &types.MessageReceipt{GasUsed: 10 + gas},
+		GasCost: lapi.MsgGasCost{
+			TotalCost: types.NewInt(100),
+		},
+		ExecutionTrace: types.ExecutionTrace{
+			Msg:        msg,
+			MsgRct:     &types.MessageReceipt{GasUsed: 10 + gas},
+			GasCharges: []*types.GasTrace{{Name: "OnMethodInvocation", TotalGas: 10}},
+			Subcalls: []types.ExecutionTrace{{
+				Msg:        sub,
+				MsgRct:     &types.MessageReceipt{ExitCode: code, GasUsed: gas},
+				GasCharges: []*types.GasTrace{{Name: "OnMethodInvocation", TotalGas: gas}},
+			}},
+		},
+	}}
+}
+
+func TestDiffTraces(t *testing.T) {
+	a := testTrace(5, 20, exitcode.Ok)
+
+	require.Empty(t, diffTraces(a, testTrace(5, 20, exitcode.Ok)))
+
+	diffs := diffTraces(a, testTrace(6, 25, exitcode.ErrForbidden))
+	var what []string
+	for _, d := range diffs {
+		what = append(what, d.Path[strings.Index(d.Path, ")"):]+" "+d.What)
+	}
+	require.Equal(t, []string{
+		") gas used",
+		")/0 value",
+		")/0 exit code",
+		")/0 gas used",
+		")/0 own gas",
+		")/0 gas charge 0",
+	}, what)
+
+	// traces of different messages aren't compared further
+	b := testTrace(5, 20, exitcode.Ok)
+	b[0].MsgCid = arbtCid
+	diffs = diffTraces(a, b)
+	require.Len(t, diffs, 1)
+	require.Equal(t, "message", diffs[0].What)
+
+	diffs = diffTraces(a, nil)
+	require.Len(t, diffs, 1)
+	require.Equal(t, "missing", diffs[0].B)
+}
+
+func TestPrintTraceTree(t *testing.T) {
+	var buf bytes.Buffer
+	tr := testTrace(5, 20, exitcode.Ok)[0]
+	printTraceTree(&buf, tr, nil, false)
+	sub := tr.ExecutionTrace.Subcalls[0].Msg
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[0], "exit 0, gas used 30")
+	require.Equal(t, "└─ "+tr.Msg.From.String()+" → "+tr.Msg.To.String()+" method 2: exit 0, gas 30 (own 10)", lines[1])
+	require.True(t, strings.HasPrefix(lines[2], "   └─ "+sub.From.String()+" → "+sub.To.String()+" method 0 ("))
+	require.True(t, strings.HasSuffix(lines[2], "): exit 0, gas 20 (own 20)"))
+}
@@ -2,18 +2,25 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
 	"time"
 
 	"github.com/filecoin-project/lotus/chain/types"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	cid "github.com/ipfs/go-cid"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain"
 )
 
 var SyncCmd = &cli.Command{
@@ -26,6 +33,7 @@ var SyncCmd = &cli.Command{
 		SyncUnmarkBadCmd,
 		SyncCheckBadCmd,
 		SyncCheckpointCmd,
+		SyncSignCheckpointCmd,
 	},
 }
 
@@ -78,6 +86,20 @@ var SyncStatusCmd = &cli.Command{
 				fmt.Printf("\tError: %s\n", ss.Message)
 			}
 		}
+
+		if len(state.Peers) > 0 {
+			fmt.Println("peers:")
+			tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintf(tw, "\tPeer\tScore\tSuccesses\tFailures\tInvalid\tSlow\tTime/Tipset\n")
+			for _, ps := range state.Peers {
+				_, _ = fmt.Fprintf(tw, "\t%s\t%.0f\t%d\t%d\t%d\t%d\t%s\n",
+					ps.Peer, ps.Score, ps.Successes, ps.Failures, ps.Invalid, ps.Slow, ps.AvgTipSetTime.Round(time.Millisecond))
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	},
 }
@@ -241,6 +263,113 @@ var SyncCheckpointCmd = &cli.Command{
 	},
 }
 
+var SyncSignCheckpointCmd = &cli.Command{
+	Name:      "sign-checkpoint",
+	Usage:     "sign a checkpoint which new nodes can bootstrap from with 'lotus daemon --import-checkpoint'",
+	ArgsUsage: "[tipsetKey]",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "epoch",
+			Usage: "checkpoint the tipset at the given epoch",
+		},
+		&cli.StringFlag{
+			Name:     "signer",
+			Usage:    "wallet address to sign the checkpoint with",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "add-to",
+			Usage: "add the signature to an existing checkpoint file, which must be for the same tipset",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		signer, err := address.NewFromString(cctx.String("signer"))
+		if err != nil {
+			return xerrors.Errorf("parsing signer address: %w", err)
+		}
+
+		var ts *types.TipSet
+
+		if cctx.IsSet("epoch") {
+			ts, err = napi.ChainGetTipSetByHeight(ctx, abi.ChainEpoch(cctx.Uint64("epoch")), types.EmptyTSK)
+		}
+		if ts == nil {
+			ts, err = parseTipSet(ctx, napi, cctx.Args().Slice())
+		}
+		if err != nil {
+			return err
+		}
+
+		if ts == nil {
+			return fmt.Errorf("must pass cids for tipset to checkpoint, or specify epoch flag")
+		}
+
+		gen, err := napi.ChainGetGenesis(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting genesis: %w", err)
+		}
+
+		var sc chain.SignedCheckpoint
+		if f := cctx.String("add-to"); f != "" {
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				return xerrors.Errorf("reading checkpoint: %w", err)
+			}
+			if err := json.Unmarshal(b, &sc); err != nil {
+				return xerrors.Errorf("parsing checkpoint: %w", err)
+			}
+
+			if sc.Checkpoint.TipSet != ts.Key() {
+				return xerrors.Errorf("checkpoint file is for tipset %s, not %s", sc.Checkpoint.TipSet, ts.Key())
+			}
+		}
+
+		sc.Checkpoint = chain.Checkpoint{
+			Genesis:   gen.Blocks()[0].Cid(),
+			Height:    ts.Height(),
+			TipSet:    ts.Key(),
+			StateRoot: ts.ParentState(),
+		}
+
+		msg, err := sc.Checkpoint.SigningBytes()
+		if err != nil {
+			return err
+		}
+
+		sig, err := napi.WalletSign(ctx, signer, msg)
+		if err != nil {
+			return xerrors.Errorf("signing checkpoint: %w", err)
+		}
+
+		sigs := []chain.CheckpointSignature{{Signer: signer, Signature: *sig}}
+		for _, s := range sc.Signatures {
+			if s.Signer != signer {
+				sigs = append(sigs, s)
+			}
+		}
+		sc.Signatures = sigs
+
+		out, err := json.MarshalIndent(sc, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if f := cctx.String("add-to"); f != "" {
+			return ioutil.WriteFile(f, out, 0644)
+		}
+
+		_, err = fmt.Fprintln(cctx.App.Writer, string(out))
+		return err
+	},
+}
+
 func SyncWait(ctx context.Context, napi v0api.FullNode, watch bool) error {
 	tick := time.Second / 4
 
@@ -0,0 +1,82 @@
+package cliutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+const (
+	// EnvKeyStorePassphrase holds the passphrase of encrypted keystores
+	EnvKeyStorePassphrase = "LOTUS_KEYSTORE_PASSPHRASE"
+	// EnvKeyStorePassphraseFD holds the number of a file descriptor to read
+	// the passphrase of encrypted keystores from
+	EnvKeyStorePassphraseFD = "LOTUS_KEYSTORE_PASSPHRASE_FD"
+)
+
+// ReadPassphrase reads a keystore passphrase from the file descriptor in
+// LOTUS_KEYSTORE_PASSPHRASE_FD, from LOTUS_KEYSTORE_PASSPHRASE, or else by
+// prompting on the terminal.
+func ReadPassphrase(prompt string) ([]byte, error) {
+	if fds, ok := os.LookupEnv(EnvKeyStorePassphraseFD); ok {
+		fd, err := strconv.Atoi(fds)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing %s: %w", EnvKeyStorePassphraseFD, err)
+		}
+
+		f := os.NewFile(uintptr(fd), "passphrase")
+		defer f.Close() //nolint:errcheck
+
+		line, err := bufio.NewReader(f).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, xerrors.Errorf("reading passphrase from fd %d: %w", fd, err)
+		}
+		return []byte(strings.TrimRight(line, "\r\n")), nil
+	}
+
+	if pass, ok := os.LookupEnv(EnvKeyStorePassphrase); ok {
+		return []byte(pass), nil
+	}
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, xerrors.Errorf("stdin isn't a terminal, set %s or %s", EnvKeyStorePassphrase, EnvKeyStorePassphraseFD)
+	}
+
+	fmt.Fprint(os.Stderr, prompt) //nolint:errcheck
+	pass, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr) //nolint:errcheck
+	if err != nil {
+		return nil, xerrors.Errorf("reading passphrase: %w", err)
+	}
+	return pass, nil
+}
+
+// UnlockKeyStore unlocks the keystore of the repo, if it's encrypted, with a
+// passphrase read with ReadPassphrase.
+func UnlockKeyStore(r *repo.FsRepo) error {
+	encrypted, err := r.KeyStoreEncrypted()
+	if err != nil {
+		return err
+	}
+	if !encrypted {
+		return nil
+	}
+
+	pass, err := ReadPassphrase("Keystore passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	if err := r.UnlockKeyStore(pass); err != nil {
+		return xerrors.Errorf("unlocking keystore: %w", err)
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/filecoin-project/go-state-types/crypto"
 
 	"github.com/filecoin-project/lotus/chain/types"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
 	"github.com/filecoin-project/lotus/lib/tablewriter"
 )
 
@@ -28,13 +29,17 @@ var walletCmd = &cli.Command{
 		walletNew,
 		walletList,
 		walletBalance,
+		walletHistory,
 		walletExport,
 		walletImport,
 		walletGetDefault,
 		walletSetDefault,
 		walletSign,
+		walletSignMessage,
 		walletVerify,
 		walletDelete,
+		walletLock,
+		walletUnlock,
 		walletMarket,
 	},
 }
@@ -253,6 +258,130 @@ var walletSetDefault = &cli.Command{
 	},
 }
 
+var walletHistory = &cli.Command{
+	Name:      "history",
+	Usage:     "List the messages and value transfers of an address",
+	ArgsUsage: "[address]",
+	Description: `Lists the messages sent or received by the address, and the value transfers
+made to or from it by actors, e.g. withdrawals and block rewards, from the most
+recent. Requires Index.EnableAddrIndex to be set in the node config.`,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "number of entries to list",
+			Value: 50,
+		},
+		&cli.StringFlag{
+			Name:  "cursor",
+			Usage: "list entries following the cursor printed by a previous call",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "list all entries",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "output entries as json lines",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPIV1(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var addr address.Address
+		if cctx.Args().First() != "" {
+			addr, err = address.NewFromString(cctx.Args().First())
+		} else {
+			addr, err = api.WalletDefaultAddress(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		id, err := api.StateLookupID(ctx, addr, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("looking up address: %w", err)
+		}
+
+		limit := cctx.Int("limit")
+		if cctx.Bool("all") {
+			limit = 0
+		}
+
+		hist, err := api.StateAddressHistory(ctx, addr, cctx.String("cursor"), limit)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			for _, t := range hist.Transfers {
+				b, err := json.Marshal(t)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+			}
+		} else {
+			tw := tablewriter.New(
+				tablewriter.Col("Epoch"),
+				tablewriter.Col("Message"),
+				tablewriter.Col("Type"),
+				tablewriter.Col("From"),
+				tablewriter.Col("To"),
+				tablewriter.Col("Value"),
+				tablewriter.Col("Method"),
+				tablewriter.Col("Exit"),
+				tablewriter.Col("Fee"))
+
+			for _, t := range hist.Transfers {
+				typ := "message"
+				switch {
+				case t.Implicit:
+					typ = "implicit"
+				case t.Internal:
+					typ = "internal"
+				}
+
+				value := types.FIL(t.Value).Short()
+				if t.From == id && t.To != id {
+					value = "-" + value
+				}
+
+				fee := ""
+				if t.From == id && !t.GasCost.IsZero() {
+					fee = types.FIL(t.GasCost).Short()
+				}
+
+				tw.Write(map[string]interface{}{
+					"Epoch":   t.Epoch,
+					"Message": t.Message,
+					"Type":    typ,
+					"From":    t.From,
+					"To":      t.To,
+					"Value":   value,
+					"Method":  t.Method,
+					"Exit":    t.ExitCode,
+					"Fee":     fee,
+				})
+			}
+
+			if err := tw.Flush(os.Stdout); err != nil {
+				return err
+			}
+		}
+
+		if hist.Cursor != "" {
+			fmt.Fprintf(os.Stderr, "More entries available, continue with --cursor=%s\n", hist.Cursor)
+		}
+
+		return nil
+	},
+}
+
 var walletExport = &cli.Command{
 	Name:      "export",
 	Usage:     "export keys",
@@ -427,6 +556,87 @@ var walletSign = &cli.Command{
 	},
 }
 
+var walletSignMessage = &cli.Command{
+	Name:      "sign-message",
+	Usage:     "Sign a message written with --unsigned-out, for sending with 'lotus mpool push-signed'",
+	ArgsUsage: "<unsigned message file>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "write the signed message to this file instead of stdout",
+		},
+		WalletKeystoreFlag,
+		WalletAPIFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify the unsigned message file"))
+		}
+		if cctx.IsSet(WalletKeystoreFlag.Name) && cctx.IsSet(WalletAPIFlag.Name) {
+			return ShowHelp(cctx, fmt.Errorf("--%s and --%s can't be used together", WalletKeystoreFlag.Name, WalletAPIFlag.Name))
+		}
+
+		ctx := ReqContext(cctx)
+
+		// sign with the keys given, or with the full node wallet
+		sign := func(msg *types.Message) (*types.SignedMessage, error) {
+			w, closer, err := OfflineWallet(cctx)
+			if err != nil {
+				return nil, err
+			}
+			if w != nil {
+				defer closer()
+				return SignMessage(ctx, w, msg)
+			}
+
+			api, closer, err := GetFullNodeAPI(cctx)
+			if err != nil {
+				return nil, err
+			}
+			defer closer()
+			return api.WalletSignMessage(ctx, msg.From, msg)
+		}
+
+		b, err := ioutil.ReadFile(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		var msg types.Message
+		if err := json.Unmarshal(b, &msg); err != nil {
+			return xerrors.Errorf("decoding unsigned message: %w", err)
+		}
+
+		// show what is being signed, the message was built on another host
+		w := cctx.App.ErrWriter
+		_, _ = fmt.Fprintf(w, "Signing message %s\n", msg.Cid())
+		_, _ = fmt.Fprintf(w, "From:     %s\n", msg.From)
+		_, _ = fmt.Fprintf(w, "To:       %s\n", msg.To)
+		_, _ = fmt.Fprintf(w, "Value:    %s\n", types.FIL(msg.Value))
+		_, _ = fmt.Fprintf(w, "Method:   %d\n", msg.Method)
+		_, _ = fmt.Fprintf(w, "Params:   %x\n", msg.Params)
+		_, _ = fmt.Fprintf(w, "Nonce:    %d\n", msg.Nonce)
+		_, _ = fmt.Fprintf(w, "Max fee:  %s\n", types.FIL(msg.RequiredFunds()))
+
+		sm, err := sign(&msg)
+		if err != nil {
+			return xerrors.Errorf("signing message: %w", err)
+		}
+
+		out, err := json.MarshalIndent(sm, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if path := cctx.String("out"); path != "" {
+			return ioutil.WriteFile(path, out, 0644)
+		}
+
+		_, _ = fmt.Fprintln(cctx.App.Writer, string(out))
+		return nil
+	},
+}
+
 var walletVerify = &cli.Command{
 	Name:      "verify",
 	Usage:     "verify the signature of a message",
@@ -504,6 +714,43 @@ var walletDelete = &cli.Command{
 	},
 }
 
+var walletLock = &cli.Command{
+	Name:  "lock",
+	Usage: "Lock the encrypted keystore of the wallet, until it's unlocked again",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPIV1(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		return api.WalletLock(ctx)
+	},
+}
+
+var walletUnlock = &cli.Command{
+	Name:  "unlock",
+	Usage: "Unlock the encrypted keystore of the wallet",
+	Description: `The passphrase is read from the file descriptor in ` + cliutil.EnvKeyStorePassphraseFD + `,
+from ` + cliutil.EnvKeyStorePassphrase + `, or else prompted for.`,
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPIV1(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		pass, err := cliutil.ReadPassphrase("Keystore passphrase: ")
+		if err != nil {
+			return err
+		}
+
+		return api.WalletUnlock(ctx, string(pass))
+	},
+}
+
 var walletMarket = &cli.Command{
 	Name:  "market",
 	Usage: "Interact with market balances",
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/beacon/group"
+)
+
+var log = logging.Logger("lotus-beacon")
+
+func main() {
+	logging.SetLogLevel("*", "INFO")
+
+	app := &cli.App{
+		Name:    "lotus-beacon",
+		Usage:   "Run a member of a randomness beacon group",
+		Version: build.UserVersion(),
+		Commands: []*cli.Command{
+			dealCmd,
+			runCmd,
+			getCmd,
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+}
+
+var dealCmd = &cli.Command{
+	Name:  "deal",
+	Usage: "Generate the keys of a new beacon group",
+	Description: `Generates the group info and one key share per member, in the output directory.
+
+The group secret is known to the machine running this command, and discarded
+once the shares are written; it should be run offline, and the shares handed
+to the members over secure channels.`,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "member",
+			Usage:    "http endpoint of a member, in share order (repeatable)",
+			Required: true,
+		},
+		&cli.IntFlag{
+			Name:     "threshold",
+			Usage:    "number of members needed to produce a round",
+			Required: true,
+		},
+		&cli.Uint64Flag{
+			Name:  "period",
+			Usage: "seconds between rounds",
+			Value: build.BlockDelaySecs,
+		},
+		&cli.StringFlag{
+			Name:  "genesis-time",
+			Usage: "time of the first round (RFC3339), defaults to now",
+		},
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "output directory",
+			Value: ".",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		genesis := time.Now()
+		if cctx.IsSet("genesis-time") {
+			var err error
+			genesis, err = time.Parse(time.RFC3339, cctx.String("genesis-time"))
+			if err != nil {
+				return xerrors.Errorf("parsing genesis time: %w", err)
+			}
+		}
+
+		info, shares, err := group.Deal(cctx.StringSlice("member"), cctx.Int("threshold"), cctx.Uint64("period"), uint64(genesis.Unix()))
+		if err != nil {
+			return err
+		}
+
+		out := cctx.String("out")
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+
+		ib, err := info.JSON()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(out, "group.json"), ib, 0644); err != nil {
+			return xerrors.Errorf("writing group info: %w", err)
+		}
+
+		for _, ps := range shares {
+			sb, err := group.ShareJSON(ps)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filepath.Join(out, fmt.Sprintf("share-%d.json", ps.I)), sb, 0600); err != nil {
+				return xerrors.Errorf("writing share %d: %w", ps.I, err)
+			}
+		}
+
+		fmt.Printf("Wrote group info and %d shares to %s\n", len(shares), out)
+		return nil
+	},
+}
+
+var runCmd = &cli.Command{
+	Name:  "run",
+	Usage: "Run a group member",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "group",
+			Usage:    "group info file",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "share",
+			Usage:    "key share file of this member",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "address to serve the member http api on",
+			Value: "0.0.0.0:2345",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		info, err := readGroupInfo(cctx.String("group"))
+		if err != nil {
+			return err
+		}
+
+		sb, err := ioutil.ReadFile(cctx.String("share"))
+		if err != nil {
+			return xerrors.Errorf("reading share: %w", err)
+		}
+		ps, err := group.ShareFromJSON(sb)
+		if err != nil {
+			return err
+		}
+
+		m, err := group.NewMember(info, ps, group.NewHTTPTransport(info, ps.I))
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(cctx.Context)
+		defer cancel()
+
+		srv := &http.Server{
+			Addr:    cctx.String("listen"),
+			Handler: m.Handler(),
+		}
+
+		sigCh := make(chan os.Signal, 2)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		go func() {
+			<-sigCh
+			log.Warn("shutting down")
+			cancel()
+			if err := srv.Shutdown(context.TODO()); err != nil {
+				log.Errorf("shutting down http server: %s", err)
+			}
+		}()
+
+		go m.Run(ctx)
+
+		log.Infow("running beacon group member", "index", ps.I, "threshold", info.Threshold, "members", len(info.Members), "listen", srv.Addr)
+
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	},
+}
+
+var getCmd = &cli.Command{
+	Name:      "get",
+	Usage:     "Fetch and verify a round from the group",
+	ArgsUsage: "[round]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "group",
+			Usage:    "group info file",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		info, err := readGroupInfo(cctx.String("group"))
+		if err != nil {
+			return err
+		}
+
+		round := info.RoundAt(time.Now())
+		if cctx.Args().Present() {
+			if _, err := fmt.Sscan(cctx.Args().First(), &round); err != nil {
+				return xerrors.Errorf("parsing round: %w", err)
+			}
+		}
+
+		pub, err := info.PublicKey()
+		if err != nil {
+			return err
+		}
+
+		sig, err := group.NewHTTPSource(info.Members).Get(cctx.Context, round)
+		if err != nil {
+			return err
+		}
+		if err := group.Verify(pub, round, sig); err != nil {
+			return xerrors.Errorf("group returned invalid signature: %w", err)
+		}
+
+		fmt.Printf("Round %d: %s\n", round, hex.EncodeToString(sig))
+		return nil
+	},
+}
+
+func readGroupInfo(path string) (*group.Info, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("reading group info: %w", err)
+	}
+	return group.InfoFromJSON(b)
+}
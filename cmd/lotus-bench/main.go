@@ -108,6 +108,8 @@ func main() {
 			proveCmd,
 			sealBenchCmd,
 			importBenchCmd,
+			pipelineCmd,
+			vmBenchCmd,
 		},
 	}
 
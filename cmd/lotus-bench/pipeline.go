@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+var pipelineCmd = &cli.Command{
+	Name:  "pipeline",
+	Usage: "Simulate the sealing pipeline on a miner's worker topology",
+	Description: `Runs a discrete-event simulation of the sealing scheduler, using the workers
+listed by 'lotus-miner sealing workers --json' and task timings recorded with
+'lotus-bench sealing --json-out'. The result is the projected sealing rate,
+queue depths and the bottleneck of the pipeline.
+
+Proposed hardware changes can be evaluated by adding workers (in the same
+format as the workers file), removing workers, or scaling task durations on
+some workers; the current and proposed topologies are then compared.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "workers",
+			Usage:    "path to the output of 'lotus-miner sealing workers --json'",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "timings",
+			Usage:    "path to the output of 'lotus-bench sealing --json-out'",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "sector-size",
+			Usage: "size of the sectors, defaults to the sector size in the timings file",
+		},
+		&cli.DurationFlag{
+			Name:  "finalize-time",
+			Usage: "duration of the finalize task",
+			Value: time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:  "fetch-time",
+			Usage: "time to move sealing data between workers",
+			Value: 5 * time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:  "seed-wait",
+			Usage: "time between PreCommit2 and Commit1, defaults to the chain seed delay",
+		},
+		&cli.IntFlag{
+			Name:  "max-sealing",
+			Usage: "maximum number of sectors sealed at once, 0 for no limit",
+		},
+		&cli.DurationFlag{
+			Name:  "duration",
+			Usage: "simulated time",
+			Value: 30 * 24 * time.Hour,
+		},
+		&cli.StringSliceFlag{
+			Name:  "add-workers",
+			Usage: "proposed change: path to a file with workers to add, in the same format as --workers",
+		},
+		&cli.StringSliceFlag{
+			Name:  "remove-worker",
+			Usage: "proposed change: remove workers by hostname or worker ID",
+		},
+		&cli.StringSliceFlag{
+			Name:  "speedup",
+			Usage: "proposed change: divide task durations on a worker, as hostname=factor (e.g. pc1-1=1.5)",
+		},
+		&cli.BoolFlag{
+			Name:  "json-out",
+			Usage: "output results in json format",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		current, err := readPipelineWorkers(c.String("workers"))
+		if err != nil {
+			return err
+		}
+
+		tb, err := ioutil.ReadFile(c.String("timings"))
+		if err != nil {
+			return xerrors.Errorf("reading timings: %w", err)
+		}
+
+		var bo BenchResults
+		if err := json.Unmarshal(tb, &bo); err != nil {
+			return xerrors.Errorf("parsing timings: %w", err)
+		}
+
+		if len(bo.SealingResults) == 0 {
+			return xerrors.Errorf("timings file doesn't contain sealing results")
+		}
+
+		sectorSize := bo.SectorSize
+		if c.IsSet("sector-size") {
+			ss, err := units.RAMInBytes(c.String("sector-size"))
+			if err != nil {
+				return xerrors.Errorf("parsing sector size: %w", err)
+			}
+			sectorSize = abi.SectorSize(ss)
+		}
+
+		var avg SealingResult
+		for _, r := range bo.SealingResults {
+			avg.AddPiece += r.AddPiece
+			avg.PreCommit1 += r.PreCommit1
+			avg.PreCommit2 += r.PreCommit2
+			avg.Commit1 += r.Commit1
+			avg.Commit2 += r.Commit2
+		}
+		n := time.Duration(len(bo.SealingResults))
+
+		seedWait := c.Duration("seed-wait")
+		if !c.IsSet("seed-wait") {
+			seedWait = time.Duration(policy.GetPreCommitChallengeDelay()+sealing.InteractivePoRepConfidence) * time.Duration(build.BlockDelaySecs) * time.Second
+		}
+
+		cfg := sectorstorage.SimConfig{
+			ProofType: spt(sectorSize),
+			Durations: map[sealtasks.TaskType]time.Duration{
+				sealtasks.TTAddPiece:   avg.AddPiece / n,
+				sealtasks.TTPreCommit1: avg.PreCommit1 / n,
+				sealtasks.TTPreCommit2: avg.PreCommit2 / n,
+				sealtasks.TTCommit1:    avg.Commit1 / n,
+				sealtasks.TTCommit2:    avg.Commit2 / n,
+				sealtasks.TTFinalize:   c.Duration("finalize-time"),
+			},
+			FetchDuration: c.Duration("fetch-time"),
+			SeedWait:      seedWait,
+			MaxSealing:    c.Int("max-sealing"),
+			Duration:      c.Duration("duration"),
+		}
+
+		cfg.Workers = simWorkers(current)
+		res, err := sectorstorage.Simulate(cfg)
+		if err != nil {
+			return xerrors.Errorf("simulating current topology: %w", err)
+		}
+
+		results := map[string]*sectorstorage.SimResult{"current": res}
+
+		proposed, changed, err := proposedWorkers(c, current, cfg.Durations)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			cfg.Workers = simWorkers(proposed)
+			res, err := sectorstorage.Simulate(cfg)
+			if err != nil {
+				return xerrors.Errorf("simulating proposed topology: %w", err)
+			}
+			results["proposed"] = res
+		}
+
+		if c.Bool("json-out") {
+			data, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(data))
+			return nil
+		}
+
+		fmt.Printf("simulated %s of sealing %s sectors\n", cfg.Duration, units.BytesSize(float64(sectorSize)))
+		for _, name := range []string{"current", "proposed"} {
+			res, ok := results[name]
+			if !ok {
+				continue
+			}
+
+			fmt.Printf("\n%s topology: %d sectors sealed, %.2f sectors/day\n", name, res.Sealed, res.SectorsPerDay)
+			if res.BottleneckTask != "" {
+				fmt.Printf("bottleneck: %s on %s\n", res.BottleneckTask.Short(), res.BottleneckWorker)
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintf(tw, "Task\tAvg Queue\tMax Queue\tAvg Wait\n")
+			for _, task := range sectorstorage.SimPipeline {
+				qs := res.Queues[task]
+				_, _ = fmt.Fprintf(tw, "%s\t%.1f\t%d\t%s\n", task.Short(), qs.AvgDepth, qs.MaxDepth, qs.AvgWait.Truncate(time.Second))
+			}
+			_ = tw.Flush()
+
+			fmt.Println()
+
+			tw = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintf(tw, "Worker\tBusy\tUtilization\tTasks\n")
+			for _, w := range res.Workers {
+				var tasks []string
+				for _, task := range sectorstorage.SimPipeline {
+					if cnt := w.Tasks[task]; cnt > 0 {
+						tasks = append(tasks, fmt.Sprintf("%s:%d", task.Short(), cnt))
+					}
+				}
+
+				_, _ = fmt.Fprintf(tw, "%s\t%d%%\t%d%%\t%s\n", w.Name,
+					int64(w.Busy*100/cfg.Duration), int64(w.Utilization*100), strings.Join(tasks, " "))
+			}
+			_ = tw.Flush()
+		}
+
+		return nil
+	},
+}
+
+type pipelineWorker struct {
+	id uuid.UUID
+	sectorstorage.SimWorker
+}
+
+func simWorkers(ws []pipelineWorker) []sectorstorage.SimWorker {
+	out := make([]sectorstorage.SimWorker, len(ws))
+	for i, w := range ws {
+		out[i] = w.SimWorker
+	}
+	return out
+}
+
+func readPipelineWorkers(path string) ([]pipelineWorker, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("reading workers: %w", err)
+	}
+
+	var stats map[uuid.UUID]storiface.WorkerStats
+	if err := json.Unmarshal(b, &stats); err != nil {
+		return nil, xerrors.Errorf("parsing workers (%s): %w", path, err)
+	}
+
+	ids := make([]uuid.UUID, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	out := make([]pipelineWorker, 0, len(stats))
+	for _, id := range ids {
+		st := stats[id]
+		if !st.Enabled {
+			continue
+		}
+		if len(st.Tasks) == 0 {
+			return nil, xerrors.Errorf("worker %s (%s) has no task types; use the output of a miner recent enough to report them", id, st.Info.Hostname)
+		}
+
+		out = append(out, pipelineWorker{
+			id: id,
+			SimWorker: sectorstorage.SimWorker{
+				Name:  fmt.Sprintf("%s (%s)", st.Info.Hostname, id.String()[:8]),
+				Info:  st.Info,
+				Tasks: st.Tasks,
+			},
+		})
+	}
+
+	return out, nil
+}
+
+func proposedWorkers(c *cli.Context, current []pipelineWorker, durations map[sealtasks.TaskType]time.Duration) ([]pipelineWorker, bool, error) {
+	var changed bool
+
+	remove := map[string]struct{}{}
+	for _, r := range c.StringSlice("remove-worker") {
+		remove[r] = struct{}{}
+	}
+
+	var out []pipelineWorker
+	for _, w := range current {
+		_, byHost := remove[w.Info.Hostname]
+		_, byID := remove[w.id.String()]
+		if byHost || byID {
+			changed = true
+			continue
+		}
+
+		out = append(out, w)
+	}
+
+	for _, path := range c.StringSlice("add-workers") {
+		added, err := readPipelineWorkers(path)
+		if err != nil {
+			return nil, false, err
+		}
+
+		out = append(out, added...)
+		changed = true
+	}
+
+	for _, s := range c.StringSlice("speedup") {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return nil, false, xerrors.Errorf("invalid speedup '%s', expected hostname=factor", s)
+		}
+
+		factor, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || factor <= 0 {
+			return nil, false, xerrors.Errorf("invalid speedup factor '%s'", parts[1])
+		}
+
+		var found bool
+		for i := range out {
+			if out[i].Info.Hostname != parts[0] {
+				continue
+			}
+			found = true
+
+			out[i].Durations = map[sealtasks.TaskType]time.Duration{}
+			for task, d := range durations {
+				out[i].Durations[task] = time.Duration(float64(d) / factor)
+			}
+		}
+		if !found {
+			return nil, false, xerrors.Errorf("no worker with hostname '%s'", parts[0])
+		}
+
+		changed = true
+	}
+
+	return out, changed, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var vmBenchCmd = &cli.Command{
+	Name:  "vm",
+	Usage: "Benchmark VM execution of the messages of a local chain, per actor method",
+	Description: `Replays the messages executed in a range of epochs of the chain of a (stopped)
+lotus node through the VM, and compares the gas charged by every actor method
+to the time it takes to run.
+
+The time and gas of a call don't include its subcalls. The ratio column is
+the time taken relative to the compute gas charged, at ` + fmt.Sprint(GasPerNs) + ` gas per ns, so
+methods with a ratio well above 1 are underpriced, and methods with a ratio
+well below 1 are overpriced; these are flagged, as well as the number of calls
+of a method which were much slower than its average.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "repo",
+			EnvVars: []string{"LOTUS_PATH"},
+			Value:   "~/.lotus",
+		},
+		&cli.Int64Flag{
+			Name:  "height",
+			Usage: "last epoch to replay, defaults to the chain head",
+		},
+		&cli.Int64Flag{
+			Name:  "epochs",
+			Usage: "number of epochs to replay",
+			Value: 100,
+		},
+		&cli.Float64Flag{
+			Name:  "threshold",
+			Usage: "flag methods whose ratio is this many times above or below 1",
+			Value: 2,
+		},
+		&cli.IntFlag{
+			Name:  "min-calls",
+			Usage: "only flag methods with at least this many calls",
+			Value: 10,
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "output the statistics as json",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := context.TODO()
+
+		fsrepo, err := repo.NewFS(cctx.String("repo"))
+		if err != nil {
+			return err
+		}
+
+		lkrepo, err := fsrepo.Lock(repo.FullNode)
+		if err != nil {
+			return err
+		}
+		defer lkrepo.Close() //nolint:errcheck
+
+		bs, err := lkrepo.Blockstore(ctx, repo.UniversalBlockstore)
+		if err != nil {
+			return xerrors.Errorf("failed to open blockstore: %w", err)
+		}
+		defer func() {
+			if c, ok := bs.(io.Closer); ok {
+				if err := c.Close(); err != nil {
+					log.Warnf("failed to close blockstore: %s", err)
+				}
+			}
+		}()
+
+		mds, err := lkrepo.Datastore(ctx, "/metadata")
+		if err != nil {
+			return err
+		}
+
+		cs := store.NewChainStore(bs, bs, mds, vm.Syscalls(ffiwrapper.ProofVerifier), nil)
+		defer cs.Close() //nolint:errcheck
+
+		if err := cs.Load(); err != nil {
+			return xerrors.Errorf("loading chain: %w", err)
+		}
+
+		sm := stmgr.NewStateManager(cs)
+		cst := cbor.NewCborStore(bs)
+
+		end := cs.GetHeaviestTipSet()
+		if cctx.IsSet("height") {
+			end, err = cs.GetTipsetByHeight(ctx, abi.ChainEpoch(cctx.Int64("height")), end, true)
+			if err != nil {
+				return xerrors.Errorf("loading tipset at height %d: %w", cctx.Int64("height"), err)
+			}
+		}
+
+		// the messages executed in the range are the ones included in the
+		// tipsets before it
+		var tipsets []*types.TipSet
+		stop := end.Height() - abi.ChainEpoch(cctx.Int64("epochs"))
+		for ts := end; ts.Height() > stop && ts.Height() > 0; {
+			pts, err := cs.LoadTipSet(ts.Parents())
+			if err != nil {
+				return xerrors.Errorf("loading parent tipset: %w", err)
+			}
+			tipsets = append(tipsets, pts)
+			ts = pts
+		}
+
+		vs := newVMStats()
+		for i := len(tipsets) - 1; i >= 0; i-- {
+			ts := tipsets[i]
+
+			start := time.Now()
+			root, trace, err := sm.ExecutionTrace(ctx, ts)
+			if err != nil {
+				return xerrors.Errorf("executing tipset at %d: %w", ts.Height(), err)
+			}
+			log.Infow("executed tipset", "height", ts.Height(), "messages", len(trace), "took", time.Since(start))
+
+			// actors created while executing the tipset are only in the state
+			// after it
+			pre, err := state.LoadStateTree(cst, ts.ParentState())
+			if err != nil {
+				return xerrors.Errorf("loading state tree: %w", err)
+			}
+			post, err := state.LoadStateTree(cst, root)
+			if err != nil {
+				return xerrors.Errorf("loading state tree: %w", err)
+			}
+			getCode := func(addr address.Address) (cid.Cid, error) {
+				act, err := post.GetActor(addr)
+				if err != nil {
+					act, err = pre.GetActor(addr)
+				}
+				if err != nil {
+					return cid.Undef, err
+				}
+				return act.Code, nil
+			}
+
+			for _, ir := range trace {
+				vs.addCall(ir.ExecutionTrace, getCode)
+			}
+		}
+
+		methods := vs.stats(cctx.Float64("threshold"), cctx.Int("min-calls"))
+
+		if cctx.Bool("json") {
+			return json.NewEncoder(os.Stdout).Encode(methods)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Actor\tMethod\tCalls\tAvg Gas\tAvg Compute Gas\tAvg Time\tRatio\tSlow Calls\tFlag")
+		for _, m := range methods {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%.0f\t%.0f\t%s\t%.2f ± %.2f\t%d\t%s\n",
+				m.Actor, m.Method, m.Calls, m.AvgGas, m.AvgComputeGas, time.Duration(m.AvgTime), m.Ratio, m.RatioStddev, m.SlowCalls, m.Flag)
+		}
+		return tw.Flush()
+	},
+}
+
+// slowCallStddevs is the number of standard deviations above the average
+// ratio of a method at which a call is counted as slow
+const slowCallStddevs = 3
+
+type methodKey struct {
+	actor  string
+	method string
+}
+
+type methodStats struct {
+	gas        meanVar
+	computeGas meanVar
+	timeTaken  meanVar
+	ratio      meanVar
+
+	ratios []float64
+}
+
+type vmStats struct {
+	methods map[methodKey]*methodStats
+}
+
+func newVMStats() *vmStats {
+	return &vmStats{
+		methods: map[methodKey]*methodStats{},
+	}
+}
+
+// addCall adds a call and its subcalls to the statistics, identifying the
+// actors called by the code returned by getCode.
+func (vs *vmStats) addCall(et types.ExecutionTrace, getCode func(addr address.Address) (cid.Cid, error)) {
+	if et.Msg == nil {
+		return
+	}
+
+	key := methodKey{actor: "unknown", method: fmt.Sprintf("%d", et.Msg.Method)}
+	if code, err := getCode(et.Msg.To); err == nil {
+		key.actor = builtin.ActorNameByCode(code)
+		if meta, ok := stmgr.MethodsMap[code][et.Msg.Method]; ok {
+			key.method = meta.Name
+		}
+	}
+	if et.Msg.Method == 0 {
+		key.method = "Send"
+	}
+
+	// only count what the call itself did
+	var gas, computeGas int64
+	for _, gc := range et.GasCharges {
+		gas += gc.TotalGas
+		computeGas += gc.ComputeGas
+	}
+	took := et.Duration
+	for _, sc := range et.Subcalls {
+		took -= sc.Duration
+	}
+
+	ms := vs.methods[key]
+	if ms == nil {
+		ms = &methodStats{}
+		vs.methods[key] = ms
+	}
+
+	ms.gas.AddPoint(float64(gas))
+	ms.computeGas.AddPoint(float64(computeGas))
+	ms.timeTaken.AddPoint(float64(took.Nanoseconds()))
+	if computeGas > 0 {
+		r := float64(took.Nanoseconds()) / float64(computeGas) * GasPerNs
+		ms.ratio.AddPoint(r)
+		ms.ratios = append(ms.ratios, r)
+	}
+
+	for _, sc := range et.Subcalls {
+		vs.addCall(sc, getCode)
+	}
+}
+
+type MethodStats struct {
+	Actor  string
+	Method string
+	Calls  int
+
+	AvgGas        float64
+	AvgComputeGas float64
+	// AvgTime is in nanoseconds
+	AvgTime float64
+
+	// Ratio is the average time taken relative to the compute gas charged,
+	// 1 meaning that the gas charged matches the time taken
+	Ratio       float64
+	RatioStddev float64
+	// SlowCalls is the number of calls with a ratio far above the average
+	SlowCalls int
+
+	// Flag is "underpriced" or "overpriced" for methods whose ratio is off by
+	// more than the threshold
+	Flag string
+}
+
+// stats returns the statistics of each method, by total time taken.
+func (vs *vmStats) stats(threshold float64, minCalls int) []MethodStats {
+	out := make([]MethodStats, 0, len(vs.methods))
+	for k, ms := range vs.methods {
+		s := MethodStats{
+			Actor:         k.actor,
+			Method:        k.method,
+			Calls:         int(ms.gas.n),
+			AvgGas:        ms.gas.Mean(),
+			AvgComputeGas: ms.computeGas.Mean(),
+			AvgTime:       ms.timeTaken.Mean(),
+			Ratio:         ms.ratio.Mean(),
+		}
+		if ms.ratio.n > 1 {
+			s.RatioStddev = ms.ratio.Stddev()
+		}
+
+		for _, r := range ms.ratios {
+			if r > s.Ratio+slowCallStddevs*s.RatioStddev && s.RatioStddev > 0 {
+				s.SlowCalls++
+			}
+		}
+
+		if s.Calls >= minCalls && ms.ratio.n > 0 {
+			switch {
+			case s.Ratio > threshold:
+				s.Flag = "underpriced"
+			case s.Ratio < 1/threshold:
+				s.Flag = "overpriced"
+			}
+		}
+
+		out = append(out, s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].AvgTime*float64(out[i].Calls) > out[j].AvgTime*float64(out[j].Calls)
+	})
+
+	return out
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestVMStats(t *testing.T) {
+	a1, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	a2, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	noCode := func(address.Address) (cid.Cid, error) {
+		return cid.Undef, types.ErrActorNotFound
+	}
+
+	call := func(method uint64, gas int64, took time.Duration, subcalls ...types.ExecutionTrace) types.ExecutionTrace {
+		var d time.Duration
+		for _, sc := range subcalls {
+			d += sc.Duration
+		}
+		return types.ExecutionTrace{
+			Msg:        &types.Message{From: a1, To: a2, Method: abi.MethodNum(method)},
+			GasCharges: []*types.GasTrace{{TotalGas: gas, ComputeGas: gas}},
+			Duration:   took + d,
+			Subcalls:   subcalls,
+		}
+	}
+
+	vs := newVMStats()
+	for i := 0; i < 10; i++ {
+		// method 2 takes its gas' worth of time, method 3 takes 4 times more,
+		// and its subcalls aren't counted in it
+		vs.addCall(call(2, 1000, 100*time.Nanosecond), noCode)
+		vs.addCall(call(3, 1000, 400*time.Nanosecond, call(4, 1000, 10*time.Nanosecond)), noCode)
+	}
+
+	stats := vs.stats(2, 10)
+	require.Len(t, stats, 3)
+
+	byMethod := map[string]MethodStats{}
+	for _, s := range stats {
+		byMethod[s.Method] = s
+	}
+
+	require.Equal(t, 10, byMethod["2"].Calls)
+	require.InDelta(t, 1, byMethod["2"].Ratio, 0.01)
+	require.Equal(t, "", byMethod["2"].Flag)
+
+	require.InDelta(t, 4, byMethod["3"].Ratio, 0.01)
+	require.InDelta(t, 400, byMethod["3"].AvgTime, 0.01)
+	require.Equal(t, "underpriced", byMethod["3"].Flag)
+
+	require.InDelta(t, 0.1, byMethod["4"].Ratio, 0.01)
+	require.Equal(t, "overpriced", byMethod["4"].Flag)
+
+	// ordered by total time
+	require.Equal(t, "3", stats[0].Method)
+
+	// methods with few calls aren't flagged
+	for _, s := range vs.stats(2, 11) {
+		require.Equal(t, "", s.Flag)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/ipfs/go-datastore/namespace"
@@ -34,6 +35,7 @@ import (
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	"github.com/filecoin-project/lotus/lib/lotuslog"
 	"github.com/filecoin-project/lotus/lib/rpcenc"
 	"github.com/filecoin-project/lotus/metrics"
@@ -102,6 +104,14 @@ func main() {
 var runCmd = &cli.Command{
 	Name:  "run",
 	Usage: "Start lotus worker",
+	Description: `The default resource requirements of tasks can be overridden by placing a
+resources.json file in the worker repo. It maps task types to the fields to
+override, and optionally limits the number of tasks of a type running at once:
+
+  {
+    "seal/v0/precommit/1": {"MinMemory": 8589934592, "MaxMemory": 10737418240, "MaxConcurrent": 3},
+    "seal/v0/commit/2": {"CanGPU": false}
+  }`,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "listen",
@@ -146,11 +156,21 @@ var runCmd = &cli.Command{
 			Usage: "enable commit (32G sectors: all cores or GPUs, 128GiB Memory + 64GiB swap)",
 			Value: true,
 		},
+		&cli.BoolFlag{
+			Name:  "windowpost",
+			Usage: "enable window post (requires local access to the sealed sectors being proven)",
+			Value: false,
+		},
 		&cli.IntFlag{
 			Name:  "parallel-fetch-limit",
 			Usage: "maximum fetch operations to run in parallel",
 			Value: 5,
 		},
+		&cli.StringFlag{
+			Name:  "fetch-bandwidth",
+			Usage: "maximum total sector fetch rate per second, e.g. 100MiB (0 for unlimited)",
+			Value: "0",
+		},
 		&cli.StringFlag{
 			Name:  "timeout",
 			Usage: "used when 'listen' is unspecified. must be a valid duration recognized by golang's time.ParseDuration function",
@@ -226,7 +246,7 @@ var runCmd = &cli.Command{
 			return err
 		}
 
-		if cctx.Bool("commit") {
+		if cctx.Bool("commit") || cctx.Bool("windowpost") {
 			if err := paramfetch.GetParams(ctx, build.ParametersJSON(), uint64(ssize)); err != nil {
 				return xerrors.Errorf("get params: %w", err)
 			}
@@ -251,6 +271,9 @@ var runCmd = &cli.Command{
 		if cctx.Bool("commit") {
 			taskTypes = append(taskTypes, sealtasks.TTCommit2)
 		}
+		if cctx.Bool("windowpost") {
+			taskTypes = append(taskTypes, sealtasks.TTGenerateWindowPoSt)
+		}
 
 		if len(taskTypes) == 0 {
 			return xerrors.Errorf("no task types specified")
@@ -332,6 +355,11 @@ var runCmd = &cli.Command{
 			return err
 		}
 
+		taskRes, err := loadTaskResources(filepath.Join(lr.Path(), "resources.json"))
+		if err != nil {
+			return err
+		}
+
 		log.Info("Opening local storage; connecting to master")
 		const unspecifiedAddress = "0.0.0.0"
 		address := cctx.String("listen")
@@ -361,7 +389,12 @@ var runCmd = &cli.Command{
 			return xerrors.Errorf("could not get api info: %w", err)
 		}
 
-		remote := stores.NewRemote(localStore, nodeApi, sminfo.AuthHeader(), cctx.Int("parallel-fetch-limit"))
+		fetchBandwidth, err := units.RAMInBytes(cctx.String("fetch-bandwidth"))
+		if err != nil {
+			return xerrors.Errorf("parsing fetch-bandwidth: %w", err)
+		}
+
+		remote := stores.NewRemote(localStore, nodeApi, sminfo.AuthHeader(), cctx.Int("parallel-fetch-limit"), uint64(fetchBandwidth))
 
 		fh := &stores.FetchHandler{Local: localStore}
 		remoteHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -380,8 +413,9 @@ var runCmd = &cli.Command{
 
 		workerApi := &worker{
 			LocalWorker: sectorstorage.NewLocalWorker(sectorstorage.WorkerConfig{
-				TaskTypes: taskTypes,
-				NoSwap:    cctx.Bool("no-swap"),
+				TaskTypes:     taskTypes,
+				NoSwap:        cctx.Bool("no-swap"),
+				TaskResources: taskRes,
 			}, remote, localStore, nodeApi, nodeApi, wsts),
 			localStore: localStore,
 			ls:         lr,
@@ -557,3 +591,37 @@ func extractRoutableIP(timeout time.Duration) (string, error) {
 
 	return strings.Split(localAddr.IP.String(), ":")[0], nil
 }
+
+// loadTaskResources reads the optional task resource overrides from the worker
+// repo. The file maps task types to storiface.TaskResources, e.g.
+//
+//	{"seal/v0/precommit/1": {"MinMemory": 8589934592, "MaxConcurrent": 3}}
+func loadTaskResources(path string) (map[sealtasks.TaskType]storiface.TaskResources, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("reading task resources: %w", err)
+	}
+
+	var out map[sealtasks.TaskType]storiface.TaskResources
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, xerrors.Errorf("parsing task resources (%s): %w", path, err)
+	}
+
+	for tt, res := range out {
+		if _, ok := sectorstorage.ResourceTable[tt]; !ok && tt != sealtasks.TTFinalize && tt != sealtasks.TTFetch {
+			log.Warnf("%s: resource overrides for unknown task type %s", path, tt)
+		}
+		if res.MaxConcurrent < 0 {
+			return nil, xerrors.Errorf("%s: negative MaxConcurrent for %s", path, tt)
+		}
+	}
+
+	if len(out) > 0 {
+		log.Infof("loaded task resource overrides from %s", path)
+	}
+
+	return out, nil
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/wallet"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
 	"github.com/filecoin-project/lotus/node/modules"
 	"github.com/filecoin-project/lotus/node/modules/lp2p"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -183,6 +184,10 @@ var keyinfoImportCmd = &cli.Command{
 			return err
 		}
 
+		if err := cliutil.UnlockKeyStore(fsrepo); err != nil {
+			return err
+		}
+
 		lkrepo, err := fsrepo.Lock(repo.FullNode)
 		if err != nil {
 			return err
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var keystoreCmd = &cli.Command{
+	Name:  "keystore",
+	Usage: "Manage the keystore of a lotus or lotus-miner repo",
+	Subcommands: []*cli.Command{
+		keystoreEncryptCmd,
+	},
+}
+
+var keystoreEncryptCmd = &cli.Command{
+	Name:  "encrypt",
+	Usage: "Encrypt the keys of an existing repo with a passphrase",
+	Description: `Encrypts the wallet keys, JWT secret and libp2p key stored in the keystore of
+a (stopped) lotus or lotus-miner repo. The node then needs the passphrase to
+start, read from the file descriptor in ` + cliutil.EnvKeyStorePassphraseFD + `, from
+` + cliutil.EnvKeyStorePassphrase + `, or else prompted for.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "repo",
+			EnvVars: []string{"LOTUS_PATH"},
+			Value:   "~/.lotus",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		r, err := repo.NewFS(cctx.String("repo"))
+		if err != nil {
+			return err
+		}
+
+		ok, err := r.Exists()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return xerrors.Errorf("repo at '%s' is not initialized", cctx.String("repo"))
+		}
+
+		pass, err := cliutil.ReadPassphrase("New keystore passphrase: ")
+		if err != nil {
+			return err
+		}
+		if len(pass) == 0 {
+			return xerrors.Errorf("passphrase can't be empty")
+		}
+
+		_, fromFD := os.LookupEnv(cliutil.EnvKeyStorePassphraseFD)
+		_, fromEnv := os.LookupEnv(cliutil.EnvKeyStorePassphrase)
+		if !fromFD && !fromEnv {
+			again, err := cliutil.ReadPassphrase("Repeat passphrase: ")
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(pass, again) {
+				return xerrors.Errorf("passphrases don't match")
+			}
+		}
+
+		if err := r.EncryptKeyStore(pass); err != nil {
+			return xerrors.Errorf("encrypting keystore: %w", err)
+		}
+
+		fmt.Println("Keystore encrypted")
+		return nil
+	},
+}
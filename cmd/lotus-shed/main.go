@@ -54,6 +54,8 @@ func main() {
 		cidCmd,
 		blockmsgidCmd,
 		signaturesCmd,
+		migrateCmd,
+		keystoreCmd,
 	}
 
 	app := &cli.App{
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
+
+	states2 "github.com/filecoin-project/specs-actors/v2/actors/states"
+	"github.com/filecoin-project/specs-actors/v3/actors/migration/nv10"
+	states3 "github.com/filecoin-project/specs-actors/v3/actors/states"
+	states4 "github.com/filecoin-project/specs-actors/v4/actors/states"
+	states5 "github.com/filecoin-project/specs-actors/v5/actors/states"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/power"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var migrateCmd = &cli.Command{
+	Name:  "migrate",
+	Usage: "Rehearse a network upgrade migration against the chain of a local node",
+	Description: `Runs the state migration of a network upgrade on the state of the chain of a
+(stopped) lotus node at the given height, as if the upgrade happened there, and
+reports how long it took, how much memory it used, and how it changed the state.
+
+The actor invariants are checked before and after the migration. The migrated
+state is only kept in memory, the repo isn't modified.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "repo",
+			EnvVars: []string{"LOTUS_PATH"},
+			Value:   "~/.lotus",
+		},
+		&cli.Int64Flag{
+			Name:  "from-height",
+			Usage: "height of the tipset to migrate the parent state of, defaults to the chain head",
+		},
+		&cli.UintFlag{
+			Name:     "to-version",
+			Usage:    "network version of the upgrade to run",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "pre-migrate",
+			Usage: "run the pre-migrations of the upgrade first, as the node would before the upgrade",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-invariants",
+			Usage: "don't check the actor invariants",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := context.TODO()
+
+		nv := network.Version(cctx.Uint("to-version"))
+		var upgrade *stmgr.Upgrade
+		for _, u := range stmgr.DefaultUpgradeSchedule() {
+			if u.Network == nv && u.Migration != nil {
+				u := u
+				upgrade = &u
+			}
+		}
+		if upgrade == nil {
+			return xerrors.Errorf("no migration for network version %d", nv)
+		}
+
+		fsrepo, err := repo.NewFS(cctx.String("repo"))
+		if err != nil {
+			return err
+		}
+
+		lkrepo, err := fsrepo.Lock(repo.FullNode)
+		if err != nil {
+			return err
+		}
+		defer lkrepo.Close() //nolint:errcheck
+
+		bs, err := lkrepo.Blockstore(ctx, repo.UniversalBlockstore)
+		if err != nil {
+			return xerrors.Errorf("failed to open blockstore: %w", err)
+		}
+		defer func() {
+			if c, ok := bs.(io.Closer); ok {
+				if err := c.Close(); err != nil {
+					log.Warnf("failed to close blockstore: %s", err)
+				}
+			}
+		}()
+
+		mds, err := lkrepo.Datastore(ctx, "/metadata")
+		if err != nil {
+			return err
+		}
+
+		// keep everything written by the migration in memory
+		wbs := blockstore.NewTieredBstore(bs, blockstore.NewMemorySync())
+
+		cs := store.NewChainStore(wbs, wbs, mds, vm.Syscalls(ffiwrapper.ProofVerifier), nil)
+		defer cs.Close() //nolint:errcheck
+
+		if err := cs.Load(); err != nil {
+			return xerrors.Errorf("loading chain: %w", err)
+		}
+
+		sm := stmgr.NewStateManager(cs)
+		cst := cbor.NewCborStore(wbs)
+		adtStore := adt.WrapStore(ctx, cst)
+
+		ts := cs.GetHeaviestTipSet()
+		if cctx.IsSet("from-height") {
+			ts, err = cs.GetTipsetByHeight(ctx, abi.ChainEpoch(cctx.Int64("from-height")), ts, true)
+			if err != nil {
+				return xerrors.Errorf("loading tipset at height %d: %w", cctx.Int64("from-height"), err)
+			}
+		}
+
+		// the parent state was computed by executing the parent tipset, as the
+		// migration is when the upgrade happens at the height after it
+		pts, err := cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return xerrors.Errorf("loading parent tipset: %w", err)
+		}
+		height := ts.Height() - 1
+		oldRoot := ts.ParentState()
+
+		curNv := sm.GetNtwkVersion(ctx, height)
+		if curNv >= nv {
+			return xerrors.Errorf("state at height %d is already at network version %d", height, curNv)
+		}
+		fromActors := actors.VersionForNetwork(curNv)
+		toActors := actors.VersionForNetwork(nv)
+
+		w := cctx.App.Writer
+		fmt.Fprintf(w, "Migrating state %s at height %d from network version %d (actors v%d) to %d (actors v%d)\n",
+			oldRoot, height, curNv, fromActors, nv, toActors)
+
+		if !cctx.Bool("skip-invariants") {
+			if err := printInvariants(w, adtStore, fromActors, oldRoot, height); err != nil {
+				return xerrors.Errorf("checking invariants before migration: %w", err)
+			}
+		}
+
+		cache := nv10.NewMemMigrationCache()
+		if cctx.Bool("pre-migrate") {
+			for _, pm := range upgrade.PreMigrations {
+				var err error
+				took, mem := measure(func() {
+					err = pm.PreMigration(ctx, sm, cache, oldRoot, height, pts)
+				})
+				if err != nil {
+					return xerrors.Errorf("running pre-migration: %w", err)
+				}
+				fmt.Fprintf(w, "Pre-migration took %s, peak heap %s\n", took, units.BytesSize(float64(mem)))
+			}
+		}
+
+		var newRoot cid.Cid
+		took, mem := measure(func() {
+			newRoot, err = upgrade.Migration(ctx, sm, cache, nil, oldRoot, height, pts)
+		})
+		if err != nil {
+			return xerrors.Errorf("running migration: %w", err)
+		}
+		fmt.Fprintf(w, "Migration to %s took %s, peak heap %s\n", newRoot, took, units.BytesSize(float64(mem)))
+
+		if !cctx.Bool("skip-invariants") {
+			if err := printInvariants(w, adtStore, toActors, newRoot, height); err != nil {
+				return xerrors.Errorf("checking invariants after migration: %w", err)
+			}
+		}
+
+		oldTree, err := state.LoadStateTree(cst, oldRoot)
+		if err != nil {
+			return xerrors.Errorf("loading state tree: %w", err)
+		}
+		newTree, err := state.LoadStateTree(cst, newRoot)
+		if err != nil {
+			return xerrors.Errorf("loading migrated state tree: %w", err)
+		}
+
+		if err := printBalanceChanges(w, oldTree, newTree); err != nil {
+			return err
+		}
+
+		oldPos, err := posSums(adtStore, oldTree)
+		if err != nil {
+			return xerrors.Errorf("summing pos before migration: %w", err)
+		}
+		newPos, err := posSums(adtStore, newTree)
+		if err != nil {
+			return xerrors.Errorf("summing pos after migration: %w", err)
+		}
+
+		fmt.Fprintf(w, "TotalPos: %s -> %s\n", types.FIL(oldPos.totalPos), types.FIL(newPos.totalPos))
+		fmt.Fprintf(w, "PosDeposits: %s -> %s (%d miners)\n", types.FIL(oldPos.posDeposits), types.FIL(newPos.posDeposits), newPos.miners)
+
+		return nil
+	},
+}
+
+// measure runs fn, returning how long it took and the peak heap size while it
+// ran.
+func measure(fn func()) (time.Duration, uint64) {
+	var peak uint64
+	var lk sync.Mutex
+	sample := func() {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		lk.Lock()
+		if ms.HeapAlloc > peak {
+			peak = ms.HeapAlloc
+		}
+		lk.Unlock()
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-time.After(100 * time.Millisecond):
+				sample()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	fn()
+	took := time.Since(start)
+
+	close(done)
+	<-stopped
+	sample()
+
+	return took, peak
+}
+
+// printInvariants checks the invariants of the state of the given actors
+// version, printing the broken ones to w.
+func printInvariants(w io.Writer, store adt.Store, av actors.Version, root cid.Cid, priorEpoch abi.ChainEpoch) error {
+	expected := types.FromFil(build.FilBase)
+
+	var msgs []string
+	switch av {
+	case actors.Version2:
+		tree, err := states2.LoadTree(store, root)
+		if err != nil {
+			return err
+		}
+		acc, err := states2.CheckStateInvariants(tree, expected, priorEpoch)
+		if err != nil {
+			return err
+		}
+		msgs = acc.Messages()
+	case actors.Version3:
+		tree, err := states3.LoadTree(store, root)
+		if err != nil {
+			return err
+		}
+		acc, err := states3.CheckStateInvariants(tree, expected, priorEpoch)
+		if err != nil {
+			return err
+		}
+		msgs = acc.Messages()
+	case actors.Version4:
+		tree, err := states4.LoadTree(store, root)
+		if err != nil {
+			return err
+		}
+		acc, err := states4.CheckStateInvariants(tree, expected, priorEpoch)
+		if err != nil {
+			return err
+		}
+		msgs = acc.Messages()
+	case actors.Version5:
+		tree, err := states5.LoadTree(store, root)
+		if err != nil {
+			return err
+		}
+		acc, err := states5.CheckStateInvariants(tree, expected, priorEpoch)
+		if err != nil {
+			return err
+		}
+		msgs = acc.Messages()
+	case actors.Version6:
+		tree, err := states6.LoadTree(store, root)
+		if err != nil {
+			return err
+		}
+		acc, err := states6.CheckStateInvariants(tree, expected, priorEpoch)
+		if err != nil {
+			return err
+		}
+		msgs = acc.Messages()
+	case actors.Version7:
+		tree, err := states7.LoadTree(store, root)
+		if err != nil {
+			return err
+		}
+		acc, err := states7.CheckStateInvariants(tree, expected, priorEpoch)
+		if err != nil {
+			return err
+		}
+		msgs = acc.Messages()
+	default:
+		fmt.Fprintf(w, "Can't check the invariants of actors v%d\n", av)
+		return nil
+	}
+
+	if len(msgs) == 0 {
+		fmt.Fprintf(w, "Invariants of %s hold\n", root)
+		return nil
+	}
+
+	fmt.Fprintf(w, "%d invariants of %s are broken:\n", len(msgs), root)
+	for _, m := range msgs {
+		fmt.Fprintf(w, "  %s\n", m)
+	}
+	return nil
+}
+
+// printBalanceChanges prints the actors whose balance changed between the
+// state trees to w.
+func printBalanceChanges(w io.Writer, oldTree, newTree *state.StateTree) error {
+	changed, err := state.Diff(oldTree, newTree)
+	if err != nil {
+		return xerrors.Errorf("diffing state trees: %w", err)
+	}
+
+	type balanceChange struct {
+		addr     string
+		old, new abi.TokenAmount
+	}
+	var changes []balanceChange
+	total := big.Zero()
+	for a, act := range changed {
+		addr, err := address.NewFromString(a)
+		if err != nil {
+			return err
+		}
+
+		old := big.Zero()
+		oact, err := oldTree.GetActor(addr)
+		switch {
+		case err == nil:
+			old = oact.Balance
+		case !xerrors.Is(err, types.ErrActorNotFound):
+			return xerrors.Errorf("getting actor %s: %w", addr, err)
+		}
+
+		if !old.Equals(act.Balance) {
+			changes = append(changes, balanceChange{addr: a, old: old, new: act.Balance})
+			total = big.Add(total, big.Sub(act.Balance, old))
+		}
+	}
+
+	fmt.Fprintf(w, "%d actors changed, %d balances changed by %s in total\n", len(changed), len(changes), types.FIL(total))
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].addr < changes[j].addr
+	})
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s: %s -> %s\n", c.addr, types.FIL(c.old), types.FIL(c.new))
+	}
+
+	return nil
+}
+
+type posSum struct {
+	totalPos    abi.TokenAmount
+	posDeposits abi.TokenAmount
+	miners      int
+}
+
+// posSums returns the TotalPos of the power actor, and the sum of the
+// PosDeposits of all miners.
+func posSums(store adt.Store, tree *state.StateTree) (posSum, error) {
+	out := posSum{posDeposits: big.Zero()}
+
+	pact, err := tree.GetActor(power.Address)
+	if err != nil {
+		return out, xerrors.Errorf("loading power actor: %w", err)
+	}
+	pst, err := power.Load(store, pact)
+	if err != nil {
+		return out, xerrors.Errorf("loading power actor state: %w", err)
+	}
+	out.totalPos, err = pst.TotalPosPower()
+	if err != nil {
+		return out, err
+	}
+
+	err = tree.ForEach(func(addr address.Address, act *types.Actor) error {
+		if !builtin.IsStorageMinerActor(act.Code) {
+			return nil
+		}
+
+		mst, err := miner.Load(store, act)
+		if err != nil {
+			return xerrors.Errorf("loading miner %s state: %w", addr, err)
+		}
+		lf, err := mst.LockedFunds()
+		if err != nil {
+			return err
+		}
+		if lf.PosDeposits.Int != nil {
+			out.posDeposits = big.Add(out.posDeposits, lf.PosDeposits)
+		}
+		out.miners++
+		return nil
+	})
+
+	return out, err
+}
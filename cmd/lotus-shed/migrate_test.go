@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	account6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/account"
+	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
+	power6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/power"
+
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/power"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// fixtureState is a small v6 state with a power actor, two miners and an
+// account
+type fixtureState struct {
+	t     *testing.T
+	ctx   context.Context
+	cst   cbor.IpldStore
+	store adt.Store
+	tree  *state.StateTree
+}
+
+var (
+	fixtureAccount = mustIDAddr(100)
+	fixtureMiner1  = mustIDAddr(1000)
+	fixtureMiner2  = mustIDAddr(1001)
+)
+
+func mustIDAddr(id uint64) address.Address {
+	a, err := address.NewIDAddress(id)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func newFixtureState(t *testing.T) *fixtureState {
+	ctx := context.Background()
+	cst := cbor.NewCborStore(blockstore.NewMemory())
+
+	tree, err := state.NewStateTree(cst, types.StateTreeVersion4)
+	require.NoError(t, err)
+
+	fs := &fixtureState{
+		t:     t,
+		ctx:   ctx,
+		cst:   cst,
+		store: adt.WrapStore(ctx, cst),
+		tree:  tree,
+	}
+
+	pst, err := power6.ConstructState(fs.store)
+	require.NoError(t, err)
+	pst.TotalPos = types.NewInt(100)
+	fs.setActor(power.Address, builtin6.StoragePowerActorCodeID, fs.put(pst), big.Zero())
+
+	fs.setMiner(fixtureMiner1, types.NewInt(30), types.NewInt(50))
+	fs.setMiner(fixtureMiner2, types.NewInt(20), types.NewInt(40))
+
+	fs.setAccount(fixtureAccount, types.NewInt(10))
+	fs.flush()
+
+	return fs
+}
+
+func (fs *fixtureState) put(v interface{}) cid.Cid {
+	c, err := fs.cst.Put(fs.ctx, v)
+	require.NoError(fs.t, err)
+	return c
+}
+
+func (fs *fixtureState) setActor(addr address.Address, code, head cid.Cid, balance abi.TokenAmount) {
+	require.NoError(fs.t, fs.tree.SetActor(addr, &types.Actor{
+		Code:    code,
+		Head:    head,
+		Balance: balance,
+	}))
+}
+
+func (fs *fixtureState) setAccount(addr address.Address, balance abi.TokenAmount) {
+	fs.setActor(addr, builtin6.AccountActorCodeID, fs.put(&account6.State{Address: addr}), balance)
+}
+
+func (fs *fixtureState) setMiner(addr address.Address, posDeposits, balance abi.TokenAmount) {
+	info, err := miner6.ConstructMinerInfo(fixtureAccount, fixtureAccount, nil, []byte("peer"), nil, abi.RegisteredPoStProof_StackedDrgWindow2KiBV1)
+	require.NoError(fs.t, err)
+
+	mst, err := miner6.ConstructState(fs.store, fs.put(info), 0, 0)
+	require.NoError(fs.t, err)
+	mst.PosDeposits = posDeposits
+
+	fs.setActor(addr, builtin6.StorageMinerActorCodeID, fs.put(mst), balance)
+}
+
+// flush writes the changed actors to the state tree, as needed for diffing
+// and iterating over it
+func (fs *fixtureState) flush() cid.Cid {
+	root, err := fs.tree.Flush(fs.ctx)
+	require.NoError(fs.t, err)
+	return root
+}
+
+// fork returns a copy of the state, which can be changed independently
+func (fs *fixtureState) fork() *fixtureState {
+	tree, err := state.LoadStateTree(fs.cst, fs.flush())
+	require.NoError(fs.t, err)
+
+	out := *fs
+	out.tree = tree
+	return &out
+}
+
+func TestMeasure(t *testing.T) {
+	var buf []byte
+	took, peak := measure(func() {
+		buf = make([]byte, 16<<20)
+		time.Sleep(250 * time.Millisecond)
+	})
+
+	require.GreaterOrEqual(t, int64(took), int64(250*time.Millisecond))
+	require.GreaterOrEqual(t, peak, uint64(len(buf)))
+}
+
+func TestPrintBalanceChanges(t *testing.T) {
+	oldState := newFixtureState(t)
+
+	newState := oldState.fork()
+	newState.setAccount(fixtureAccount, types.NewInt(15))
+	// changed state, same balance
+	newState.setMiner(fixtureMiner1, types.NewInt(35), types.NewInt(50))
+	created := mustIDAddr(101)
+	newState.setAccount(created, types.NewInt(5))
+	newState.flush()
+
+	var out bytes.Buffer
+	require.NoError(t, printBalanceChanges(&out, oldState.tree, newState.tree))
+
+	require.Equal(t, fmt.Sprintf(`3 actors changed, 2 balances changed by %s in total
+  %s: %s -> %s
+  %s: %s -> %s
+`,
+		types.FIL(types.NewInt(10)),
+		fixtureAccount, types.FIL(types.NewInt(10)), types.FIL(types.NewInt(15)),
+		created, types.FIL(big.Zero()), types.FIL(types.NewInt(5)),
+	), out.String())
+
+	// no changes
+	out.Reset()
+	require.NoError(t, printBalanceChanges(&out, oldState.tree, oldState.tree))
+	require.Equal(t, fmt.Sprintf("0 actors changed, 0 balances changed by %s in total\n", types.FIL(big.Zero())), out.String())
+}
+
+func TestPosSums(t *testing.T) {
+	fs := newFixtureState(t)
+
+	sums, err := posSums(fs.store, fs.tree)
+	require.NoError(t, err)
+	require.Equal(t, "100", sums.totalPos.String())
+	require.Equal(t, "50", sums.posDeposits.String())
+	require.Equal(t, 2, sums.miners)
+
+	// a state without the power actor can't be summed
+	require.NoError(t, fs.tree.DeleteActor(power.Address))
+	_, err = posSums(fs.store, fs.tree)
+	require.Error(t, err)
+}
+
+func TestPrintInvariantsUnsupportedVersion(t *testing.T) {
+	fs := newFixtureState(t)
+
+	var out bytes.Buffer
+	require.NoError(t, printInvariants(&out, fs.store, actors.Version0, fs.flush(), 0))
+	require.Equal(t, "Can't check the invariants of actors v0\n", out.String())
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	stdbig "math/big"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	cbor "github.com/ipfs/go-ipld-cbor"
 
@@ -16,9 +19,14 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
 
 	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
 
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/blockstore"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
@@ -36,8 +44,11 @@ var actorCmd = &cli.Command{
 		actorSetAddrsCmd,
 		actorWithdrawCmd,
 		actorRepayDebtCmd,
+		actorFundsCmd,
 		actorSetPeeridCmd,
 		actorSetOwnerCmd,
+		actorSetBeneficiaryCmd,
+		actorConfirmBeneficiaryCmd,
 		actorControl,
 		actorProposeChangeWorker,
 		actorConfirmChangeWorker,
@@ -58,6 +69,7 @@ var actorSetAddrsCmd = &cli.Command{
 			Usage: "unset address",
 			Value: false,
 		},
+		lcli.UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		args := cctx.Args().Slice()
@@ -117,7 +129,7 @@ var actorSetAddrsCmd = &cli.Command{
 
 		gasLimit := cctx.Int64("gas-limit")
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			To:       maddr,
 			From:     minfo.Worker,
 			Value:    types.NewInt(0),
@@ -128,6 +140,9 @@ var actorSetAddrsCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Printf("Requested multiaddrs change in message %s\n", smsg.Cid())
 		return nil
@@ -144,6 +159,7 @@ var actorSetPeeridCmd = &cli.Command{
 			Usage: "set gas limit",
 			Value: 0,
 		},
+		lcli.UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		nodeAPI, closer, err := lcli.GetStorageMinerAPI(cctx)
@@ -182,7 +198,7 @@ var actorSetPeeridCmd = &cli.Command{
 
 		gasLimit := cctx.Int64("gas-limit")
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			To:       maddr,
 			From:     minfo.Worker,
 			Value:    types.NewInt(0),
@@ -193,6 +209,9 @@ var actorSetPeeridCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Printf("Requested peerid change in message %s\n", smsg.Cid())
 		return nil
@@ -204,6 +223,13 @@ var actorWithdrawCmd = &cli.Command{
 	Name:      "withdraw",
 	Usage:     "withdraw available balance",
 	ArgsUsage: "[amount (KAKH)]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "beneficiary",
+			Usage: "send the withdrawal from the beneficiary address instead of the owner",
+		},
+		lcli.UnsignedOutFlag,
+	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
 		if err != nil {
@@ -234,6 +260,15 @@ var actorWithdrawCmd = &cli.Command{
 			return err
 		}
 
+		if mi.Beneficiary != mi.Owner {
+			// the beneficiary can only receive up to its quota
+			head, err := api.ChainHead(ctx)
+			if err != nil {
+				return err
+			}
+			available = big.Min(available, mi.BeneficiaryTerm.Available(head.Height()))
+		}
+
 		amount := available
 		if cctx.Args().Present() {
 			f, err := types.ParseFIL(cctx.Args().First())
@@ -255,9 +290,14 @@ var actorWithdrawCmd = &cli.Command{
 			return err
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		from := mi.Owner
+		if cctx.Bool("beneficiary") {
+			from = mi.Beneficiary
+		}
+
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			To:     maddr,
-			From:   mi.Owner,
+			From:   from,
 			Value:  types.NewInt(0),
 			Method: miner.Methods.WithdrawBalance,
 			Params: params,
@@ -265,8 +305,14 @@ var actorWithdrawCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Printf("Requested rewards withdrawal in message %s\n", smsg.Cid())
+		if mi.Beneficiary != mi.Owner {
+			fmt.Printf("Funds will be sent to the beneficiary %s\n", mi.Beneficiary)
+		}
 
 		return nil
 	},
@@ -281,6 +327,7 @@ var actorRepayDebtCmd = &cli.Command{
 			Name:  "from",
 			Usage: "optionally specify the account to send funds from",
 		},
+		lcli.UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
@@ -354,7 +401,7 @@ var actorRepayDebtCmd = &cli.Command{
 			return xerrors.Errorf("sender isn't a controller of miner: %s", fromId)
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			To:     maddr,
 			From:   fromId,
 			Value:  amount,
@@ -364,6 +411,9 @@ var actorRepayDebtCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Printf("Sent repay debt message %s\n", smsg.Cid())
 
@@ -371,12 +421,137 @@ var actorRepayDebtCmd = &cli.Command{
 	},
 }
 
+var actorFundsCmd = &cli.Command{
+	Name:  "funds",
+	Usage: "Show the locked funds of the miner by category, and when they unlock",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "schedule",
+			Usage: "show the funds unlocking each day",
+		},
+		&cli.IntFlag{
+			Name:  "days",
+			Usage: "number of days to show with --schedule",
+			Value: 30,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetFullNodeAPIV1(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := getActorAddress(ctx, cctx)
+		if err != nil {
+			return err
+		}
+
+		vs, err := api.StateMinerVestingSchedule(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Balance:            %s\n", types.FIL(vs.Balance))
+		fmt.Printf("Available:          %s\n", types.FIL(vs.Available))
+		fmt.Printf("Reward vesting:     %s\n", types.FIL(vs.Locked.VestingFunds))
+		fmt.Printf("PoS vesting:        %s\n", types.FIL(vs.Locked.PosDeposits))
+		fmt.Printf("Initial pledge:     %s\n", types.FIL(vs.Locked.InitialPledgeRequirement))
+		fmt.Printf("PreCommit deposits: %s\n", types.FIL(vs.Locked.PreCommitDeposits))
+
+		if !cctx.Bool("schedule") {
+			return nil
+		}
+
+		days := cctx.Int("days")
+		if days <= 0 {
+			return xerrors.Errorf("--days must be positive")
+		}
+
+		buckets := vestingBuckets(vs, days)
+
+		fmt.Println()
+
+		tw := tablewriter.New(
+			tablewriter.Col("day"),
+			tablewriter.Col("date"),
+			tablewriter.Col("reward vesting"),
+			tablewriter.Col("pos vesting"),
+			tablewriter.Col("pledge"),
+			tablewriter.Col("total"),
+		)
+
+		now := time.Now()
+		for i, b := range buckets {
+			day, date := fmt.Sprint(i), now.AddDate(0, 0, i).Format("2006-01-02")
+			if i == days {
+				day, date = "later", ""
+			}
+
+			tw.Write(map[string]interface{}{
+				"day":            day,
+				"date":           date,
+				"reward vesting": types.FIL(b.vesting).Short(),
+				"pos vesting":    types.FIL(b.posVesting).Short(),
+				"pledge":         types.FIL(b.pledge).Short(),
+				"total":          types.FIL(big.Sum(b.vesting, b.posVesting, b.pledge)).Short(),
+			})
+		}
+
+		return tw.Flush(os.Stdout)
+	},
+}
+
+// vestingBucket holds the funds unlocking in a day
+type vestingBucket struct {
+	vesting, posVesting, pledge abi.TokenAmount
+}
+
+// vestingBuckets merges the vesting tables and the pledge schedule into days
+// from the schedule epoch. Funds which already unlocked are in the first day,
+// and funds unlocking after the given days in the last bucket.
+func vestingBuckets(vs *lapi.MinerVestingSchedule, days int) []vestingBucket {
+	buckets := make([]vestingBucket, days+1)
+	for i := range buckets {
+		buckets[i] = vestingBucket{big.Zero(), big.Zero(), big.Zero()}
+	}
+	dayOf := func(epoch abi.ChainEpoch) int {
+		d := int((epoch - vs.Epoch) / builtin6.EpochsInDay)
+		if d < 0 {
+			// already vested, unlocked or withdrawable with the next message
+			return 0
+		}
+		if d > days {
+			return days
+		}
+		return d
+	}
+
+	for _, vf := range vs.Vesting {
+		b := &buckets[dayOf(vf.Epoch)]
+		b.vesting = big.Add(b.vesting, vf.Amount)
+	}
+	for _, vf := range vs.PosVesting {
+		b := &buckets[dayOf(vf.Epoch)]
+		b.posVesting = big.Add(b.posVesting, vf.Amount)
+	}
+	for _, vf := range vs.Pledge {
+		b := &buckets[dayOf(vf.Epoch)]
+		b.pledge = big.Add(b.pledge, vf.Amount)
+	}
+
+	return buckets
+}
+
 var actorControl = &cli.Command{
 	Name:  "control",
 	Usage: "Manage control addresses",
 	Subcommands: []*cli.Command{
 		actorControlList,
 		actorControlSet,
+		actorControlStatus,
 	},
 }
 
@@ -535,6 +710,122 @@ var actorControlList = &cli.Command{
 	},
 }
 
+var actorControlStatus = &cli.Command{
+	Name:  "status",
+	Usage: "Show control address balances, projected burn and automatic top ups",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "color",
+			Value: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		color.NoColor = !cctx.Bool("color")
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := nodeApi.ActorControlStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(st.Addresses) == 0 {
+			fmt.Println("No control addresses with a minimum balance (PreCommitMinBalance, CommitMinBalance, TerminateMinBalance in the Addresses miner config)")
+		} else {
+			pending := map[address.Address]abi.TokenAmount{}
+			for _, tu := range st.TopUps {
+				if tu.Landed || tu.Expired {
+					continue
+				}
+				amt := tu.Amount
+				if cur, ok := pending[tu.To]; ok {
+					amt = big.Add(cur, amt)
+				}
+				pending[tu.To] = amt
+			}
+
+			tw := tablewriter.New(
+				tablewriter.Col("address"),
+				tablewriter.Col("use"),
+				tablewriter.Col("balance"),
+				tablewriter.Col("min"),
+				tablewriter.Col("burn/day"),
+				tablewriter.Col("days left"),
+				tablewriter.Col("pending top up"),
+			)
+
+			for _, as := range st.Addresses {
+				bstr := types.FIL(as.Balance).String()
+				if as.Balance.LessThan(as.MinBalance) {
+					bstr = color.RedString(bstr)
+				} else {
+					bstr = color.GreenString(bstr)
+				}
+
+				burn, days := "-", "-"
+				if as.BurnPerDay != nil {
+					burn = types.FIL(*as.BurnPerDay).Short()
+					days = "inf"
+					if !as.BurnPerDay.IsZero() {
+						left, _ := new(stdbig.Rat).SetFrac(as.Balance.Int, as.BurnPerDay.Int).Float64()
+						days = fmt.Sprintf("%.1f", left)
+						if left < 1 {
+							days = color.RedString(days)
+						} else if left < 7 {
+							days = color.YellowString(days)
+						}
+					}
+				}
+
+				pstr := ""
+				if amt, ok := pending[as.Address]; ok {
+					pstr = types.FIL(amt).Short()
+				}
+
+				tw.Write(map[string]interface{}{
+					"address":        as.Address,
+					"use":            strings.Join(as.Roles, " "),
+					"balance":        bstr,
+					"min":            types.FIL(as.MinBalance).Short(),
+					"burn/day":       burn,
+					"days left":      days,
+					"pending top up": pstr,
+				})
+			}
+
+			if err := tw.Flush(os.Stdout); err != nil {
+				return err
+			}
+		}
+
+		fmt.Println()
+		if st.TopUpFrom == address.Undef {
+			fmt.Println("Automatic top ups: disabled (set TopUpFrom in the Addresses miner config)")
+			return nil
+		}
+		fmt.Printf("Automatic top ups from %s: %s sent in the last 24h, limit %s\n", st.TopUpFrom, types.FIL(st.SentLastDay).Short(), types.FIL(st.MaxDailyTopUp).Short())
+
+		for _, tu := range st.TopUps {
+			state := color.YellowString("pending")
+			switch {
+			case tu.Landed:
+				state = color.GreenString("landed")
+			case tu.Expired:
+				state = color.RedString("expired")
+			}
+			fmt.Printf("  %s  %s -> %s  %s  %s\n", tu.Sent.Format(time.Stamp), types.FIL(tu.Amount).Short(), tu.To, state, tu.Msg)
+		}
+
+		return nil
+	},
+}
+
 var actorControlSet = &cli.Command{
 	Name:      "set",
 	Usage:     "Set control address(-es)",
@@ -545,6 +836,7 @@ var actorControlSet = &cli.Command{
 			Usage: "Actually send transaction performing the action",
 			Value: false,
 		},
+		lcli.UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
@@ -630,7 +922,7 @@ var actorControlSet = &cli.Command{
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			From:   mi.Owner,
 			To:     maddr,
 			Method: miner.Methods.ChangeWorkerAddress,
@@ -641,6 +933,9 @@ var actorControlSet = &cli.Command{
 		if err != nil {
 			return xerrors.Errorf("mpool push: %w", err)
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Println("Message CID:", smsg.Cid())
 
@@ -658,6 +953,7 @@ var actorSetOwnerCmd = &cli.Command{
 			Usage: "Actually send transaction performing the action",
 			Value: false,
 		},
+		lcli.UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		if !cctx.Bool("really-do-it") {
@@ -722,7 +1018,7 @@ var actorSetOwnerCmd = &cli.Command{
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			From:   fromAddrId,
 			To:     maddr,
 			Method: miner.Methods.ChangeOwnerAddress,
@@ -732,6 +1028,9 @@ var actorSetOwnerCmd = &cli.Command{
 		if err != nil {
 			return xerrors.Errorf("mpool push: %w", err)
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Println("Message CID:", smsg.Cid())
 
@@ -753,6 +1052,285 @@ var actorSetOwnerCmd = &cli.Command{
 	},
 }
 
+var actorSetBeneficiaryCmd = &cli.Command{
+	Name:      "set-beneficiary",
+	Usage:     "Propose a beneficiary address, which receives withdrawn funds instead of the owner",
+	ArgsUsage: "[beneficiaryAddress quota (KAKH) expirationEpoch]",
+	Description: `Proposes, from the owner, a beneficiary which can withdraw up to the quota of funds
+from the miner until the expiration epoch. The change applies once confirmed with
+'lotus-miner actor confirm-beneficiary' by the new beneficiary, and by the current
+beneficiary if it still has quota left. Proposing the owner address, with a quota
+and expiration of 0, reverts the beneficiary to the owner.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "Actually send transaction performing the action",
+			Value: false,
+		},
+		&cli.BoolFlag{
+			Name:  "overwrite-pending-change",
+			Usage: "Overwrite the current pending beneficiary change",
+		},
+		lcli.UnsignedOutFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 3 {
+			return fmt.Errorf("must pass beneficiary address, quota and expiration epoch")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		if err := checkBeneficiarySupport(cctx, api); err != nil {
+			return err
+		}
+
+		ba, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return xerrors.Errorf("parsing beneficiary address: %w", err)
+		}
+
+		newAddrId, err := api.StateLookupID(ctx, ba, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("looking up beneficiary address: %w", err)
+		}
+
+		quota, err := types.ParseFIL(cctx.Args().Get(1))
+		if err != nil {
+			return xerrors.Errorf("parsing quota: %w", err)
+		}
+
+		expiration, err := strconv.ParseInt(cctx.Args().Get(2), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("parsing expiration: %w", err)
+		}
+
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return err
+		}
+
+		if mi.Beneficiary == mi.Owner && newAddrId == mi.Owner {
+			return fmt.Errorf("beneficiary %s is already the owner", mi.Beneficiary)
+		}
+
+		if mi.PendingBeneficiaryTerm != nil && !cctx.Bool("overwrite-pending-change") {
+			return fmt.Errorf("beneficiary change to %s already pending, pass --overwrite-pending-change to replace it", mi.PendingBeneficiaryTerm.NewBeneficiary)
+		}
+
+		if !cctx.Bool("really-do-it") {
+			fmt.Printf("Would propose %s as beneficiary with a quota of %s until epoch %d\n", newAddrId, quota, expiration)
+			fmt.Println("Pass --really-do-it to actually execute this action")
+			return nil
+		}
+
+		sp, err := actors.SerializeParams(&miner.ChangeBeneficiaryParams{
+			NewBeneficiary: newAddrId,
+			NewQuota:       abi.TokenAmount(quota),
+			NewExpiration:  abi.ChainEpoch(expiration),
+		})
+		if err != nil {
+			return xerrors.Errorf("serializing params: %w", err)
+		}
+
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
+			From:   mi.Owner,
+			To:     maddr,
+			Method: builtin7.MethodsMiner.ChangeBeneficiary,
+			Value:  big.Zero(),
+			Params: sp,
+		}, nil)
+		if err != nil {
+			return xerrors.Errorf("mpool push: %w", err)
+		}
+		if smsg == nil {
+			return nil
+		}
+
+		fmt.Println("Propose Message CID:", smsg.Cid())
+
+		// wait for it to get mined into a block
+		wait, err := api.StateWaitMsg(ctx, smsg.Cid(), build.MessageConfidence)
+		if err != nil {
+			return err
+		}
+
+		// check it executed successfully
+		if wait.Receipt.ExitCode != 0 {
+			return fmt.Errorf("propose beneficiary change failed with exit code %d", wait.Receipt.ExitCode)
+		}
+
+		return printBeneficiaryChange(cctx, api, maddr)
+	},
+}
+
+var actorConfirmBeneficiaryCmd = &cli.Command{
+	Name:  "confirm-beneficiary",
+	Usage: "Confirm a beneficiary address change",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "Actually send transaction performing the action",
+			Value: false,
+		},
+		&cli.BoolFlag{
+			Name:  "existing-beneficiary",
+			Usage: "send confirmation from the existing beneficiary address",
+		},
+		&cli.BoolFlag{
+			Name:  "new-beneficiary",
+			Usage: "send confirmation from the new beneficiary address",
+		},
+		lcli.UnsignedOutFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Bool("existing-beneficiary") == cctx.Bool("new-beneficiary") {
+			return fmt.Errorf("must pass exactly one of --existing-beneficiary or --new-beneficiary")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		if err := checkBeneficiarySupport(cctx, api); err != nil {
+			return err
+		}
+
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return err
+		}
+
+		pending := mi.PendingBeneficiaryTerm
+		if pending == nil {
+			return fmt.Errorf("no pending beneficiary change")
+		}
+
+		from := pending.NewBeneficiary
+		if cctx.Bool("existing-beneficiary") {
+			if pending.ApprovedByBeneficiary {
+				return fmt.Errorf("beneficiary change already approved by the existing beneficiary")
+			}
+			from = mi.Beneficiary
+		} else if pending.ApprovedByNominee {
+			return fmt.Errorf("beneficiary change already approved by the new beneficiary")
+		}
+
+		if !cctx.Bool("really-do-it") {
+			fmt.Printf("Would confirm %s as beneficiary with a quota of %s until epoch %d, from %s\n",
+				pending.NewBeneficiary, types.FIL(pending.NewQuota), pending.NewExpiration, from)
+			fmt.Println("Pass --really-do-it to actually execute this action")
+			return nil
+		}
+
+		sp, err := actors.SerializeParams(&miner.ChangeBeneficiaryParams{
+			NewBeneficiary: pending.NewBeneficiary,
+			NewQuota:       pending.NewQuota,
+			NewExpiration:  pending.NewExpiration,
+		})
+		if err != nil {
+			return xerrors.Errorf("serializing params: %w", err)
+		}
+
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
+			From:   from,
+			To:     maddr,
+			Method: builtin7.MethodsMiner.ChangeBeneficiary,
+			Value:  big.Zero(),
+			Params: sp,
+		}, nil)
+		if err != nil {
+			return xerrors.Errorf("mpool push: %w", err)
+		}
+		if smsg == nil {
+			return nil
+		}
+
+		fmt.Println("Confirm Message CID:", smsg.Cid())
+
+		// wait for it to get mined into a block
+		wait, err := api.StateWaitMsg(ctx, smsg.Cid(), build.MessageConfidence)
+		if err != nil {
+			return err
+		}
+
+		// check it executed successfully
+		if wait.Receipt.ExitCode != 0 {
+			return fmt.Errorf("confirm beneficiary change failed with exit code %d", wait.Receipt.ExitCode)
+		}
+
+		return printBeneficiaryChange(cctx, api, maddr)
+	},
+}
+
+// checkBeneficiarySupport fails until the network upgraded to the actors with miner beneficiaries.
+func checkBeneficiarySupport(cctx *cli.Context, api v0api.FullNode) error {
+	nv, err := api.StateNetworkVersion(lcli.ReqContext(cctx), types.EmptyTSK)
+	if err != nil {
+		return err
+	}
+	if nv < network.Version15 {
+		return xerrors.Errorf("miner beneficiaries need network version %d, the network is at %d", network.Version15, nv)
+	}
+	return nil
+}
+
+// printBeneficiaryChange prints the beneficiary of the miner, and what its pending change still needs.
+func printBeneficiaryChange(cctx *cli.Context, api v0api.FullNode, maddr address.Address) error {
+	mi, err := api.StateMinerInfo(lcli.ReqContext(cctx), maddr, types.EmptyTSK)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Beneficiary: %s\n", mi.Beneficiary)
+	if mi.Beneficiary != mi.Owner {
+		fmt.Printf("Quota: %s (used %s), expires at epoch %d\n",
+			types.FIL(mi.BeneficiaryTerm.Quota), types.FIL(mi.BeneficiaryTerm.UsedQuota), mi.BeneficiaryTerm.Expiration)
+	}
+
+	if p := mi.PendingBeneficiaryTerm; p != nil {
+		fmt.Printf("Pending change to %s with a quota of %s until epoch %d\n", p.NewBeneficiary, types.FIL(p.NewQuota), p.NewExpiration)
+		if !p.ApprovedByNominee {
+			fmt.Println("Confirm it from the new beneficiary with 'lotus-miner actor confirm-beneficiary --new-beneficiary'")
+		}
+		if !p.ApprovedByBeneficiary {
+			fmt.Println("Confirm it from the existing beneficiary with 'lotus-miner actor confirm-beneficiary --existing-beneficiary'")
+		}
+	}
+	return nil
+}
+
 var actorProposeChangeWorker = &cli.Command{
 	Name:      "propose-change-worker",
 	Usage:     "Propose a worker address change",
@@ -763,6 +1341,7 @@ var actorProposeChangeWorker = &cli.Command{
 			Usage: "Actually send transaction performing the action",
 			Value: false,
 		},
+		lcli.UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		if !cctx.Args().Present() {
@@ -828,7 +1407,7 @@ var actorProposeChangeWorker = &cli.Command{
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			From:   mi.Owner,
 			To:     maddr,
 			Method: miner.Methods.ChangeWorkerAddress,
@@ -838,6 +1417,9 @@ var actorProposeChangeWorker = &cli.Command{
 		if err != nil {
 			return xerrors.Errorf("mpool push: %w", err)
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Fprintln(cctx.App.Writer, "Propose Message CID:", smsg.Cid())
 
@@ -878,6 +1460,7 @@ var actorConfirmChangeWorker = &cli.Command{
 			Usage: "Actually send transaction performing the action",
 			Value: false,
 		},
+		lcli.UnsignedOutFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		if !cctx.Args().Present() {
@@ -935,7 +1518,7 @@ var actorConfirmChangeWorker = &cli.Command{
 			return nil
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		smsg, err := lcli.PushMessage(cctx, api, &types.Message{
 			From:   mi.Owner,
 			To:     maddr,
 			Method: miner.Methods.ConfirmUpdateWorkerKey,
@@ -944,6 +1527,9 @@ var actorConfirmChangeWorker = &cli.Command{
 		if err != nil {
 			return xerrors.Errorf("mpool push: %w", err)
 		}
+		if smsg == nil {
+			return nil
+		}
 
 		fmt.Fprintln(cctx.App.Writer, "Confirm Message CID:", smsg.Cid())
 
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+)
+
+func TestVestingBuckets(t *testing.T) {
+	const day = builtin6.EpochsInDay
+	start := abi.ChainEpoch(1000)
+
+	vf := func(epoch abi.ChainEpoch, amount int64) miner.VestingFund {
+		return miner.VestingFund{Epoch: epoch, Amount: abi.NewTokenAmount(amount)}
+	}
+
+	vs := &api.MinerVestingSchedule{
+		Epoch: start,
+		Vesting: []miner.VestingFund{
+			vf(start-10, 1), // vested, not yet unlocked
+			vf(start+10, 2),
+			vf(start+day+10, 4),
+		},
+		PosVesting: []miner.VestingFund{
+			vf(start+20, 8),
+			vf(start+2*day, 16),
+			vf(start+90*day, 32), // beyond the shown days
+		},
+		Pledge: []miner.VestingFund{
+			vf(start+day, 64),
+			vf(start+5*day, 128),
+		},
+	}
+
+	buckets := vestingBuckets(vs, 3)
+	require.Len(t, buckets, 4)
+
+	expect := []struct {
+		vesting, posVesting, pledge int64
+	}{
+		{3, 8, 0},
+		{4, 0, 64},
+		{0, 16, 0},
+		{0, 32, 128},
+	}
+	for i, e := range expect {
+		require.True(t, buckets[i].vesting.Equals(abi.NewTokenAmount(e.vesting)), "day %d vesting: %s", i, buckets[i].vesting)
+		require.True(t, buckets[i].posVesting.Equals(abi.NewTokenAmount(e.posVesting)), "day %d pos vesting: %s", i, buckets[i].posVesting)
+		require.True(t, buckets[i].pledge.Equals(abi.NewTokenAmount(e.pledge)), "day %d pledge: %s", i, buckets[i].pledge)
+	}
+}
@@ -266,6 +266,10 @@ func infoCmdAct(cctx *cli.Context) error {
 
 	fmt.Println()
 
+	if err := treasuryInfo(ctx, nodeApi); err != nil {
+		return err
+	}
+
 	if !cctx.Bool("hide-sectors-info") {
 		fmt.Println("Sectors:")
 		err = sectorsInfo(ctx, nodeApi)
@@ -280,6 +284,43 @@ func infoCmdAct(cctx *cli.Context) error {
 	return nil
 }
 
+func treasuryInfo(ctx context.Context, napi api.StorageMiner) error {
+	ts, err := napi.ActorTreasuryStatus(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting treasury status: %w", err)
+	}
+	if !ts.Enabled && len(ts.Actions) == 0 {
+		return nil
+	}
+
+	if ts.Enabled {
+		fmt.Printf("Treasury: next withdrawal %s\n", ts.NextRun.Format(time.Stamp))
+	} else {
+		fmt.Println("Treasury: disabled")
+	}
+
+	// only show the most recent actions
+	acts := ts.Actions
+	if len(acts) > 5 {
+		acts = acts[len(acts)-5:]
+	}
+	for _, a := range acts {
+		res := ""
+		switch {
+		case a.Err != "":
+			res = color.RedString("error: %s", a.Err)
+		case a.Msg != nil:
+			res = a.Msg.String()
+		default:
+			res = "kept"
+		}
+		fmt.Printf("\t%s  %-8s %s -> %s  %s\n", a.Time.Format(time.Stamp), a.Action, types.FIL(a.Amount).Short(), a.To, res)
+	}
+	fmt.Println()
+
+	return nil
+}
+
 type stateMeta struct {
 	i     int
 	col   color.Attribute
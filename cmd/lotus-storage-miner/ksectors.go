@@ -16,6 +16,7 @@ import (
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
@@ -76,9 +77,19 @@ var ksectorsPledgeCmd = &cli.Command{
 var ksectorsKPledgeCmd = &cli.Command{
 	Name:  "kpledge",
 	Usage: "store random data in a sector",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "optionally specify the account to send the pledge from, defaults to the wallet default address",
+		},
+		lcli.UnsignedOutFlag,
+	},
 	Action: func(cctx *cli.Context) error {
 		ctx := lcli.ReqContext(cctx)
 		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
 		defer closer()
 
 		maddr, err := getActorAddress(ctx, cctx)
@@ -131,7 +142,12 @@ var ksectorsKPledgeCmd = &cli.Command{
 		f = f * float64(build.FilecoinPrecision)
 		fmt.Printf("Real collateral: %s\n", types.FIL(big.NewInt(int64(f))))
 
-		fromAddr, err := api.WalletDefaultAddress(ctx)
+		var fromAddr address.Address
+		if from := cctx.String("from"); from != "" {
+			fromAddr, err = address.NewFromString(from)
+		} else {
+			fromAddr, err = api.WalletDefaultAddress(ctx)
+		}
 		if err != nil {
 			return err
 		}
@@ -156,10 +172,13 @@ var ksectorsKPledgeCmd = &cli.Command{
 		}
 
 		// send msg
-		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
+		smsg, err := lcli.PushMessage(cctx, api, msg, nil)
 		if err != nil {
 			return err
 		}
+		if smsg == nil {
+			return nil
+		}
 		fmt.Println(smsg.Cid())
 		// send
 		return nil
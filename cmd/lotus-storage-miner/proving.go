@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"text/tabwriter"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
@@ -29,6 +30,7 @@ var provingCmd = &cli.Command{
 		provingDeadlineInfoCmd,
 		provingFaultsCmd,
 		provingCheckProvableCmd,
+		provingScrubCmd,
 	},
 }
 
@@ -451,3 +453,121 @@ var provingCheckProvableCmd = &cli.Command{
 		return tw.Flush()
 	},
 }
+
+var provingScrubCmd = &cli.Command{
+	Name:  "scrub",
+	Usage: "Manage the background sector integrity scrubber",
+	Subcommands: []*cli.Command{
+		provingScrubStatusCmd,
+		provingScrubCheckCmd,
+	},
+}
+
+var provingScrubStatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Show results of background sector integrity checks",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "list all checked sectors, not only the failing ones",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		color.NoColor = !cctx.Bool("color")
+
+		sapi, scloser, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer scloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		results, err := sapi.ProvingScrubStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		var failing int
+		var oldest time.Time
+		for _, r := range results {
+			if r.Err != "" {
+				failing++
+			}
+			if oldest.IsZero() || r.Checked.Before(oldest) {
+				oldest = r.Checked
+			}
+		}
+
+		fmt.Printf("Checked sectors: %d\n", len(results))
+		fmt.Printf("Failing sectors: %s\n", color.New(failingColor(failing)).Sprint(failing))
+		if !oldest.IsZero() {
+			fmt.Printf("Oldest check:    %s\n", oldest.Format(time.Stamp))
+		}
+
+		if failing == 0 && !cctx.Bool("all") {
+			return nil
+		}
+
+		fmt.Println()
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "sector\tdeadline\tpartition\tchecked\tstatus\tfault msg")
+		for _, r := range results {
+			if r.Err == "" && !cctx.Bool("all") {
+				continue
+			}
+
+			status := color.GreenString("good")
+			if r.Err != "" {
+				status = color.RedString("bad") + fmt.Sprintf(" (%dx: %s)", r.Failures, r.Err)
+			}
+
+			msg := ""
+			if r.FaultMsg != nil {
+				msg = r.FaultMsg.String()
+			}
+
+			_, _ = fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%s\t%s\n", r.Sector, r.Deadline, r.Partition, r.Checked.Format(time.Stamp), status, msg)
+		}
+
+		return tw.Flush()
+	},
+}
+
+var provingScrubCheckCmd = &cli.Command{
+	Name:      "check",
+	Usage:     "Check sectors in the next scrubber run",
+	ArgsUsage: "<sectorNum> ...",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() == 0 {
+			return xerrors.Errorf("must pass sector numbers")
+		}
+
+		sapi, scloser, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer scloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		sectors := make([]abi.SectorNumber, cctx.Args().Len())
+		for i, s := range cctx.Args().Slice() {
+			sn, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return xerrors.Errorf("could not parse sector number '%s': %w", s, err)
+			}
+			sectors[i] = abi.SectorNumber(sn)
+		}
+
+		return sapi.ProvingScrubSectors(ctx, sectors)
+	},
+}
+
+func failingColor(n int) color.Attribute {
+	if n > 0 {
+		return color.FgRed
+	}
+	return color.FgGreen
+}
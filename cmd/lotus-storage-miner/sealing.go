@@ -15,6 +15,7 @@ import (
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 
 	"github.com/filecoin-project/lotus/chain/types"
@@ -37,6 +38,10 @@ var sealingWorkersCmd = &cli.Command{
 	Usage: "list workers",
 	Flags: []cli.Flag{
 		&cli.BoolFlag{Name: "color"},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "output worker stats in json format (can be used with lotus-bench pipeline)",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		color.NoColor = !cctx.Bool("color")
@@ -54,6 +59,15 @@ var sealingWorkersCmd = &cli.Command{
 			return err
 		}
 
+		if cctx.Bool("json") {
+			out, err := json.MarshalIndent(stats, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
 		type sortableStat struct {
 			id uuid.UUID
 			storiface.WorkerStats
@@ -117,6 +131,33 @@ var sealingWorkersCmd = &cli.Command{
 			for _, gpu := range stat.Info.Resources.GPUs {
 				fmt.Printf("\tGPU: %s\n", color.New(gpuCol).Sprintf("%s, %sused", gpu, gpuUse))
 			}
+
+			if len(stat.Tasks) > 0 {
+				tasks := make([]string, len(stat.Tasks))
+				for i, tt := range stat.Tasks {
+					tasks[i] = tt.Short()
+				}
+				fmt.Printf("\tTasks: %s\n", strings.Join(tasks, " "))
+			}
+
+			if len(stat.Info.Resources.Tasks) > 0 {
+				tts := make([]sealtasks.TaskType, 0, len(stat.Info.Resources.Tasks))
+				for tt := range stat.Info.Resources.Tasks {
+					tts = append(tts, tt)
+				}
+				sort.Slice(tts, func(i, j int) bool {
+					return tts[i].Less(tts[j])
+				})
+
+				overrides := make([]string, len(tts))
+				for i, tt := range tts {
+					overrides[i] = tt.Short()
+					if limit := stat.Info.Resources.Tasks[tt].MaxConcurrent; limit > 0 {
+						overrides[i] += fmt.Sprintf("(max %d)", limit)
+					}
+				}
+				fmt.Printf("\tResource overrides: %s\n", strings.Join(overrides, " "))
+			}
 		}
 
 		return nil
@@ -205,6 +246,16 @@ var sealingJobsCmd = &cli.Command{
 			case l.RunWait == storiface.RWRetWait:
 				state = "ret-wait"
 			}
+			if l.Fetch != nil {
+				state += fmt.Sprintf(" (fetch %s %s", l.Fetch.FileType, types.SizeStr(types.NewInt(uint64(l.Fetch.Fetched))))
+				if l.Fetch.Size > 0 {
+					state += fmt.Sprintf("/%s", types.SizeStr(types.NewInt(uint64(l.Fetch.Size))))
+				}
+				if l.Fetch.Resumed > 0 {
+					state += fmt.Sprintf(", resumed %d", l.Fetch.Resumed)
+				}
+				state += ")"
+			}
 			dur := "n/a"
 			if !l.Start.IsZero() {
 				dur = time.Now().Sub(l.Start).Truncate(time.Millisecond * 100).String()
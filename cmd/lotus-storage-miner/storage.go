@@ -48,6 +48,7 @@ stored while moving through the sealing pipeline (references as 'seal').`,
 		storageListCmd,
 		storageFindCmd,
 		storageCleanupCmd,
+		storageMigrateCmd,
 	},
 }
 
@@ -72,6 +73,14 @@ Data for the sealing process will be stored here
 Store
 Finalized sectors that will be moved here for long term storage and be proven
 over time
+
+Tier
+Storage tier tag (e.g. 'hot' or 'cold'); the tier mover migrates sectors between
+paths based on the rules in the [Tiering] config section
+
+Groups
+Storage path groups; when migrating, paths in the same group as the sector's
+current path are preferred
    `,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
@@ -95,6 +104,14 @@ over time
 			Name:  "max-storage",
 			Usage: "(for init) limit storage space for sectors (expensive for very large paths!)",
 		},
+		&cli.StringFlag{
+			Name:  "tier",
+			Usage: "(for init) storage tier tag for this path",
+		},
+		&cli.StringSliceFlag{
+			Name:  "groups",
+			Usage: "(for init) storage path groups",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
@@ -142,6 +159,8 @@ over time
 				CanSeal:    cctx.Bool("seal"),
 				CanStore:   cctx.Bool("store"),
 				MaxStorage: uint64(maxStor),
+				Groups:     cctx.StringSlice("groups"),
+				Tier:       cctx.String("tier"),
 			}
 
 			if !(cfg.CanStore || cfg.CanSeal) {
@@ -191,6 +210,11 @@ var storageListCmd = &cli.Command{
 			return err
 		}
 
+		migrations, err := nodeApi.StorageMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
 		type fsInfo struct {
 			stores.ID
 			sectors []stores.Decl
@@ -321,6 +345,25 @@ var storageListCmd = &cli.Command{
 				fmt.Print(color.HiYellowString("Use: ReadOnly"))
 			}
 
+			if si.Tier != "" || len(si.Groups) > 0 {
+				fmt.Printf("\tTier: %s; Groups: %s\n", color.CyanString(si.Tier), strings.Join(si.Groups, ", "))
+			}
+
+			for _, m := range migrations {
+				if m.State != api.MigrationMoving || (m.From != s.ID && m.To != s.ID) {
+					continue
+				}
+
+				dir := "to " + string(m.To)
+				if m.To == s.ID {
+					dir = "from " + string(m.From)
+				}
+
+				fmt.Printf("\tMigrating: sector %d %s %s/%s\n", m.Sector, dir,
+					types.SizeStr(types.NewInt(uint64(m.Moved))),
+					types.SizeStr(types.NewInt(uint64(m.Total))))
+			}
+
 			if localPath, ok := local[s.ID]; ok {
 				fmt.Printf("\tLocal: %s\n", color.GreenString(localPath))
 			}
@@ -732,3 +775,104 @@ func cleanupRemovedSectorData(ctx context.Context, api api.StorageMiner, napi v0
 
 	return nil
 }
+
+var storageMigrateCmd = &cli.Command{
+	Name:      "migrate",
+	Usage:     "list sector migrations between storage tiers, or queue new ones",
+	ArgsUsage: "[sector numbers...]",
+	Description: `Without arguments this lists queued, running and recently finished sector
+migrations. When sector numbers are given together with the '--tier' flag, the
+sectors are queued for migration into a local storage path with that tier tag.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "tier",
+			Usage: "destination storage tier",
+		},
+		&cli.BoolFlag{Name: "color"},
+	},
+	Action: func(cctx *cli.Context) error {
+		color.NoColor = !cctx.Bool("color")
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if cctx.Args().Present() {
+			if cctx.String("tier") == "" {
+				return xerrors.Errorf("must specify destination tier with --tier")
+			}
+
+			for _, arg := range cctx.Args().Slice() {
+				snum, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil {
+					return xerrors.Errorf("parsing sector number %q: %w", arg, err)
+				}
+
+				if err := nodeApi.StorageMigrateSector(ctx, abi.SectorNumber(snum), cctx.String("tier")); err != nil {
+					return xerrors.Errorf("queueing sector %d: %w", snum, err)
+				}
+			}
+
+			return nil
+		}
+
+		migrations, err := nodeApi.StorageMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Sector"),
+			tablewriter.Col("State"),
+			tablewriter.Col("Progress"),
+			tablewriter.Col("From"),
+			tablewriter.Col("To"),
+			tablewriter.Col("Reason"),
+			tablewriter.NewLineCol("Error"),
+		)
+
+		for _, m := range migrations {
+			state := string(m.State)
+			switch m.State {
+			case api.MigrationMoving:
+				state = color.YellowString(state)
+			case api.MigrationDone:
+				state = color.GreenString(state)
+			case api.MigrationFailed:
+				state = color.RedString(state)
+			}
+
+			progress := "-"
+			if m.Total > 0 {
+				progress = fmt.Sprintf("%s/%s (%d%%)",
+					types.SizeStr(types.NewInt(uint64(m.Moved))),
+					types.SizeStr(types.NewInt(uint64(m.Total))),
+					m.Moved*100/m.Total)
+			}
+
+			to := m.ToTier
+			if m.To != "" {
+				to = fmt.Sprintf("%s (%s)", m.To, m.ToTier)
+			}
+
+			row := map[string]interface{}{
+				"Sector":   m.Sector,
+				"State":    state,
+				"Progress": progress,
+				"From":     m.From,
+				"To":       to,
+				"Reason":   m.Reason,
+			}
+			if m.Err != "" {
+				row["Error"] = m.Err
+			}
+
+			tw.Write(row)
+		}
+
+		return tw.Flush(os.Stdout)
+	},
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"fmt"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/blockstore"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/actors/adt"
+	lbuiltin "github.com/filecoin-project/lotus/chain/actors/builtin"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
 	"github.com/filecoin-project/lotus/chain/types"
 	cliutil "github.com/filecoin-project/lotus/cli/util"
 	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
@@ -17,6 +20,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/specs-actors/v4/actors/builtin"
@@ -40,6 +44,14 @@ var voteSendCmd = &cli.Command{
 	Name:      "send",
 	Usage:     "add vote",
 	ArgsUsage: "amount (KAKH)",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "optionally specify the account to vote from (or to propose with, with --via-msig), defaults to the wallet default address",
+		},
+		viaMsigFlag,
+		lcli.UnsignedOutFlag,
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -63,7 +75,7 @@ var voteSendCmd = &cli.Command{
 			return err
 		}
 
-		fromAddr, err := api.WalletDefaultAddress(ctx)
+		fromAddr, err := voteProposer(cctx, api)
 		if err != nil {
 			return err
 		}
@@ -73,30 +85,13 @@ var voteSendCmd = &cli.Command{
 			return err
 		}
 
-		// build msg
-		params := miner4.AddPosParams{
-			Pos: big.Mul(types.NewInt(amount), big.NewInt(int64(build.FilecoinPrecision))),
-		}
-		enc, err := actors.SerializeParams(&params)
+		msg, err := addPosMessage(maddr, fromAddr, amount)
 		if err != nil {
 			return err
 		}
-		msg := &types.Message{
-			To:     maddr,    // miner
-			From:   fromAddr, // wallet
-			Value:  params.Pos,
-			Method: builtin.MethodsMiner.AddPos,
-			Params: enc,
-		}
 
 		// send msg
-		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
-		if err != nil {
-			return err
-		}
-		fmt.Println(smsg.Cid())
-
-		return nil
+		return pushVoteMessage(cctx, api, msg, fromAddr)
 	},
 }
 
@@ -104,7 +99,19 @@ var voteWithdrawCmd = &cli.Command{
 	Name:      "withdraw",
 	Usage:     "withdraw vote",
 	ArgsUsage: "amount (KAKH)",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "account to propose the withdrawal with, only with --via-msig, defaults to the wallet default address",
+		},
+		viaMsigFlag,
+		lcli.UnsignedOutFlag,
+	},
 	Action: func(cctx *cli.Context) error {
+		if cctx.IsSet("from") && !cctx.IsSet(viaMsigFlag.Name) {
+			return xerrors.Errorf("--from can only be used with --via-msig, withdrawals are sent by the miner owner")
+		}
+
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
 			return err
@@ -144,6 +151,15 @@ var voteWithdrawCmd = &cli.Command{
 			return xerrors.Errorf("getting locked funds: %w", err)
 		}
 		available:= lockedFunds.PosDeposits
+		if mi.Beneficiary != mi.Owner {
+			// deposits are withdrawn to the beneficiary, up to its quota
+			head, err := api.ChainHead(ctx)
+			if err != nil {
+				return err
+			}
+			available = big.Min(available, mi.BeneficiaryTerm.Available(head.Height()))
+			fmt.Printf("Funds will be sent to the beneficiary %s\n", mi.Beneficiary)
+		}
 		amount := available
 		if cctx.Args().Present() {
 			f, err := types.ParseFIL(cctx.Args().First())
@@ -158,30 +174,137 @@ var voteWithdrawCmd = &cli.Command{
 			}
 		}
 
-		// build msg
-		params, err := actors.SerializeParams(&miner2.WithdrawBalanceParams{
-			AmountRequested: amount, // Default to attempting to withdraw all the extra funds in the miner actor
-		})
+		msg, err := withdrawPosMessage(maddr, mi.Owner, amount)
 		if err != nil {
 			return err
 		}
-		msg := &types.Message{
-			To:     maddr,    // miner
-			From:   mi.Owner, // owner
-			Value:  types.NewInt(0),
-			Method: builtin.MethodsMiner.WithDrawPos,
-			Params: params,
+
+		// the withdrawn funds go to the sender, so a multisig owner has to
+		// withdraw them itself
+		proposer := mi.Owner
+		if cctx.IsSet(viaMsigFlag.Name) {
+			msig, err := address.NewFromString(cctx.String(viaMsigFlag.Name))
+			if err != nil {
+				return xerrors.Errorf("parsing multisig address: %w", err)
+			}
+			msigID, err := api.StateLookupID(ctx, msig, types.EmptyTSK)
+			if err != nil {
+				return xerrors.Errorf("looking up multisig %s: %w", msig, err)
+			}
+			if msigID != mi.Owner {
+				return xerrors.Errorf("multisig %s is not the owner of the miner (%s)", msig, mi.Owner)
+			}
+
+			proposer, err = voteProposer(cctx, api)
+			if err != nil {
+				return err
+			}
 		}
 
 		// send msg
-		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
+		return pushVoteMessage(cctx, api, msg, proposer)
+	},
+}
+
+// addPosMessage builds the message voting with the given amount of KAKH from
+// the account.
+func addPosMessage(maddr, from address.Address, kakh uint64) (*types.Message, error) {
+	params := miner4.AddPosParams{
+		Pos: big.Mul(types.NewInt(kakh), big.NewInt(int64(build.FilecoinPrecision))),
+	}
+	enc, err := actors.SerializeParams(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Message{
+		To:     maddr,
+		From:   from,
+		Value:  params.Pos,
+		Method: builtin.MethodsMiner.AddPos,
+		Params: enc,
+	}, nil
+}
+
+// withdrawPosMessage builds the message withdrawing the amount of the vote
+// deposits of the miner, which has to be sent by its owner.
+func withdrawPosMessage(maddr, owner address.Address, amount abi.TokenAmount) (*types.Message, error) {
+	params, err := actors.SerializeParams(&miner2.WithdrawBalanceParams{
+		AmountRequested: amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Message{
+		To:     maddr,
+		From:   owner,
+		Value:  types.NewInt(0),
+		Method: builtin.MethodsMiner.WithDrawPos,
+		Params: params,
+	}, nil
+}
+
+var viaMsigFlag = &cli.StringFlag{
+	Name:  "via-msig",
+	Usage: "propose the message to this multisig, which sends it once enough of its signers approve it with 'lotus msig approve'",
+}
+
+// voteProposer returns the account given with --from, or the wallet default
+// address.
+func voteProposer(cctx *cli.Context, api v0api.FullNode) (address.Address, error) {
+	if from := cctx.String("from"); from != "" {
+		return address.NewFromString(from)
+	}
+	return api.WalletDefaultAddress(ReqContext(cctx))
+}
+
+// pushVoteMessage sends the message, or with --via-msig proposes it to the
+// multisig from the proposer account, replacing the sender of the message
+// with the multisig.
+func pushVoteMessage(cctx *cli.Context, api v0api.FullNode, msg *types.Message, proposer address.Address) error {
+	ctx := ReqContext(cctx)
+
+	var msig address.Address
+	if cctx.IsSet(viaMsigFlag.Name) {
+		var err error
+		msig, err = address.NewFromString(cctx.String(viaMsigFlag.Name))
+		if err != nil {
+			return xerrors.Errorf("parsing multisig address: %w", err)
+		}
+
+		act, err := api.StateGetActor(ctx, msig, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("looking up multisig %s: %w", msig, err)
+		}
+		if !lbuiltin.IsMultisigActor(act.Code) {
+			return xerrors.Errorf("actor %s is not a multisig actor", msig)
+		}
+
+		nv, err := api.StateNetworkVersion(ctx, types.EmptyTSK)
 		if err != nil {
 			return err
 		}
-		fmt.Println(smsg.Cid())
 
+		msg, err = multisig.Message(actors.VersionForNetwork(nv), proposer).Propose(msig, msg.To, msg.Value, msg.Method, msg.Params)
+		if err != nil {
+			return xerrors.Errorf("creating proposal: %w", err)
+		}
+	}
+
+	smsg, err := lcli.PushMessage(cctx, api, msg, nil)
+	if err != nil {
+		return err
+	}
+	if smsg == nil {
 		return nil
-	},
+	}
+	fmt.Println(smsg.Cid())
+
+	if msig != address.Undef {
+		fmt.Printf("Proposed to multisig %s, find the transaction ID with 'lotus msig inspect --decode-params %s' and approve it with 'lotus msig approve %s <txid>'\n", msig, msig, msig)
+	}
+	return nil
 }
 
 var voteStatusCmd = &cli.Command{
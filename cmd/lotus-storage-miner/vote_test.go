@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-state-types/big"
+
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v4/actors/builtin"
+	miner4 "github.com/filecoin-project/specs-actors/v4/actors/builtin/miner"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+func TestAddPosMessage(t *testing.T) {
+	maddr, from := mock.Address(1000), mock.Address(100)
+
+	msg, err := addPosMessage(maddr, from, 3)
+	require.NoError(t, err)
+
+	expected := big.Mul(types.NewInt(3), types.NewInt(build.FilecoinPrecision))
+	require.Equal(t, maddr, msg.To)
+	require.Equal(t, from, msg.From)
+	require.Equal(t, builtin.MethodsMiner.AddPos, msg.Method)
+	require.True(t, expected.Equals(msg.Value))
+
+	var params miner4.AddPosParams
+	require.NoError(t, params.UnmarshalCBOR(bytes.NewReader(msg.Params)))
+	require.True(t, expected.Equals(params.Pos))
+}
+
+func TestWithdrawPosMessage(t *testing.T) {
+	maddr, owner := mock.Address(1000), mock.Address(100)
+	amount := types.NewInt(12345)
+
+	msg, err := withdrawPosMessage(maddr, owner, amount)
+	require.NoError(t, err)
+
+	require.Equal(t, maddr, msg.To)
+	require.Equal(t, owner, msg.From)
+	require.Equal(t, builtin.MethodsMiner.WithDrawPos, msg.Method)
+	require.True(t, msg.Value.IsZero())
+
+	var params miner2.WithdrawBalanceParams
+	require.NoError(t, params.UnmarshalCBOR(bytes.NewReader(msg.Params)))
+	require.True(t, amount.Equals(params.AmountRequested))
+}
+
+func TestVoteWithdrawFromRequiresMsig(t *testing.T) {
+	app := cli.NewApp()
+	app.Commands = []*cli.Command{voteWithdrawCmd}
+
+	err := app.Run([]string{"lotus-miner", "withdraw", "--from", "t0100"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--via-msig")
+}
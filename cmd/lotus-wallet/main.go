@@ -8,21 +8,27 @@ import (
 
 	"github.com/filecoin-project/lotus/api/v0api"
 
+	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/gorilla/mux"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/urfave/cli/v2"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-jsonrpc/auth"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/messagesigner"
 	"github.com/filecoin-project/lotus/chain/wallet"
 	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
 	lcli "github.com/filecoin-project/lotus/cli"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
 	"github.com/filecoin-project/lotus/lib/lotuslog"
 	"github.com/filecoin-project/lotus/metrics"
+	"github.com/filecoin-project/lotus/node/modules"
 	"github.com/filecoin-project/lotus/node/repo"
 )
 
@@ -86,6 +92,10 @@ var runCmd = &cli.Command{
 			Name:  "offline",
 			Usage: "don't query chain state in interactive mode",
 		},
+		&cli.BoolFlag{
+			Name:  "serve-nonces",
+			Usage: "hand out message nonces to the nodes using this wallet with Wallet.NonceServer set to the token of this repo and its address, so that several nodes can send messages from the same addresses",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		log.Info("Starting lotus wallet")
@@ -117,6 +127,10 @@ var runCmd = &cli.Command{
 			}
 		}
 
+		if err := cliutil.UnlockKeyStore(r); err != nil {
+			return err
+		}
+
 		lr, err := r.Lock(repo.Wallet)
 		if err != nil {
 			return err
@@ -173,13 +187,41 @@ var runCmd = &cli.Command{
 		mux.Handle("/rpc/v0", rpcServer)
 		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
 
+		var handler http.Handler = mux
+		if cctx.Bool("serve-nonces") {
+			ds, err := lr.Datastore(context.Background(), "/metadata")
+			if err != nil {
+				return err
+			}
+
+			// handing out nonces requires a token with the sign permission,
+			// such as the one written to the token file of the wallet repo
+			secret, err := modules.APISecret(ks, lr)
+			if err != nil {
+				return xerrors.Errorf("getting api secret: %w", err)
+			}
+			handler = &auth.Handler{
+				Verify: func(ctx context.Context, token string) ([]auth.Permission, error) {
+					var payload modules.JwtPayload
+					if _, err := jwt.Verify([]byte(token), (*jwt.HMACSHA)(secret), &payload); err != nil {
+						return nil, xerrors.Errorf("JWT Verification failed: %w", err)
+					}
+					return payload.Allow, nil
+				},
+				Next: mux.ServeHTTP,
+			}
+
+			log.Info("Serving message nonces")
+			rpcServer.Register(messagesigner.NonceNamespace, messagesigner.PermissionedNonceProvider(messagesigner.NewNonceTracker(ds)))
+		}
+
 		/*ah := &auth.Handler{
 			Verify: nodeApi.AuthVerify,
 			Next:   mux.ServeHTTP,
 		}*/
 
 		srv := &http.Server{
-			Handler: mux,
+			Handler: handler,
 			BaseContext: func(listener net.Listener) context.Context {
 				ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.APIInterface, "lotus-wallet"))
 				return ctx
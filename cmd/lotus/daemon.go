@@ -15,6 +15,7 @@ import (
 	"runtime/pprof"
 	"strings"
 
+	"github.com/filecoin-project/go-address"
 	paramfetch "github.com/filecoin-project/go-paramfetch"
 	metricsprom "github.com/ipfs/go-metrics-prometheus"
 	"github.com/mitchellh/go-homedir"
@@ -29,17 +30,20 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
 	lcli "github.com/filecoin-project/lotus/cli"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/lib/peermgr"
 	"github.com/filecoin-project/lotus/lib/ulimit"
 	"github.com/filecoin-project/lotus/metrics"
 	"github.com/filecoin-project/lotus/node"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/testing"
@@ -110,6 +114,10 @@ var DaemonCmd = &cli.Command{
 			Name:  "import-snapshot",
 			Usage: "import chain state from a given chain export file or url",
 		},
+		&cli.StringFlag{
+			Name:  "import-checkpoint",
+			Usage: "bootstrap from a signed checkpoint file or url, fetching only the state at the checkpoint from the network",
+		},
 		&cli.BoolFlag{
 			Name:  "halt-after-import",
 			Usage: "halt the process after importing chain from file",
@@ -230,6 +238,10 @@ var DaemonCmd = &cli.Command{
 		}
 		freshRepo := err != repo.ErrRepoExists
 
+		if err := cliutil.UnlockKeyStore(r); err != nil {
+			return err
+		}
+
 		if !isLite {
 			if err := paramfetch.GetParams(lcli.ReqContext(cctx), build.ParametersJSON(), 0); err != nil {
 				return xerrors.Errorf("fetching proof parameters: %w", err)
@@ -276,6 +288,16 @@ var DaemonCmd = &cli.Command{
 			}
 		}
 
+		if cpfile := cctx.String("import-checkpoint"); cpfile != "" {
+			if chainfile != "" || snapshot != "" {
+				return fmt.Errorf("cannot specify 'import-checkpoint' along with 'import-chain' or 'import-snapshot'")
+			}
+
+			if err := ImportCheckpoint(ctx, r, cpfile); err != nil {
+				return xerrors.Errorf("importing checkpoint: %w", err)
+			}
+		}
+
 		genesis := node.Options()
 		if len(genBytes) > 0 {
 			genesis = node.Override(new(modules.Genesis), modules.LoadGenesis(genBytes))
@@ -503,3 +525,80 @@ func ImportChain(ctx context.Context, r repo.Repo, fname string, snapshot bool)
 
 	return nil
 }
+
+// ImportCheckpoint verifies the signed checkpoint against the trusted signers
+// in the node config, and makes the node bootstrap from it when it starts.
+func ImportCheckpoint(ctx context.Context, r repo.Repo, fname string) error {
+	var b []byte
+	if strings.HasPrefix(fname, "http://") || strings.HasPrefix(fname, "https://") {
+		resp, err := http.Get(fname) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() //nolint:errcheck
+
+		if resp.StatusCode != http.StatusOK {
+			return xerrors.Errorf("fetching checkpoint failed with non-200 response: %d", resp.StatusCode)
+		}
+
+		b, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return xerrors.Errorf("reading checkpoint: %w", err)
+		}
+	} else {
+		fname, err := homedir.Expand(fname)
+		if err != nil {
+			return err
+		}
+
+		b, err = ioutil.ReadFile(fname)
+		if err != nil {
+			return xerrors.Errorf("reading checkpoint: %w", err)
+		}
+	}
+
+	var sc chain.SignedCheckpoint
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return xerrors.Errorf("parsing checkpoint: %w", err)
+	}
+
+	lr, err := r.Lock(repo.FullNode)
+	if err != nil {
+		return err
+	}
+	defer lr.Close() //nolint:errcheck
+
+	c, err := lr.Config()
+	if err != nil {
+		return xerrors.Errorf("loading config: %w", err)
+	}
+	cfg, ok := c.(*config.FullNode)
+	if !ok {
+		return xerrors.Errorf("invalid config type %T", c)
+	}
+
+	var trusted []address.Address
+	for _, s := range cfg.Checkpoint.TrustedSigners {
+		a, err := address.NewFromString(s)
+		if err != nil {
+			return xerrors.Errorf("parsing trusted checkpoint signer %q: %w", s, err)
+		}
+		trusted = append(trusted, a)
+	}
+
+	if err := sc.Verify(trusted, cfg.Checkpoint.MinSignatures); err != nil {
+		return xerrors.Errorf("verifying checkpoint: %w", err)
+	}
+
+	mds, err := lr.Datastore(context.TODO(), "/metadata")
+	if err != nil {
+		return err
+	}
+
+	if err := chain.SetBootstrapCheckpoint(mds, &sc.Checkpoint); err != nil {
+		return xerrors.Errorf("saving checkpoint: %w", err)
+	}
+
+	log.Infow("imported checkpoint, the node will bootstrap from it", "height", sc.Checkpoint.Height, "tipset", sc.Checkpoint.TipSet, "signatures", len(sc.Signatures))
+	return nil
+}
@@ -22,6 +22,7 @@ const (
 	Version12                 // actors v4 (specs-actors v4.0.0)
 	Version13                 // reserved  (specs-actors v5.x)
 	Version14                 // reserved  (specs-actors v6.x)
+	Version15                 // reserved  (specs-actors v7.x)
 
 	// VersionMax is the maximum version number
 	VersionMax = Version(math.MaxUint32)
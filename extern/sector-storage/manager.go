@@ -12,10 +12,12 @@ import (
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/mitchellh/go-homedir"
+	"golang.org/x/time/rate"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-statestore"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
@@ -41,6 +43,13 @@ type Worker interface {
 
 	Info(context.Context) (storiface.WorkerInfo, error)
 
+	// Returns the state of running sector fetches
+	FetchProgress(context.Context) ([]storiface.FetchProgress, error)
+
+	// GenerateWindowPoSt computes a WindowPoSt proof for the sectors, reading
+	// sector data only from storage local to the worker
+	GenerateWindowPoSt(ctx context.Context, mid abi.ActorID, sectors []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.WindowPoStResult, error)
+
 	Session(context.Context) (uuid.UUID, error)
 
 	Close() error // TODO: do we need this?
@@ -91,6 +100,8 @@ type result struct {
 
 type SealerConfig struct {
 	ParallelFetchLimit int
+	// Maximum total fetch rate in bytes per second, 0 means unlimited
+	FetchBandwidthLimit uint64
 
 	// Local worker config
 	AllowAddPiece   bool
@@ -116,7 +127,7 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 		return nil, xerrors.Errorf("creating prover instance: %w", err)
 	}
 
-	stor := stores.NewRemote(lstor, si, http.Header(sa), sc.ParallelFetchLimit)
+	stor := stores.NewRemote(lstor, si, http.Header(sa), sc.ParallelFetchLimit, sc.FetchBandwidthLimit)
 
 	m := &Manager{
 		ls:         ls,
@@ -710,6 +721,12 @@ func (m *Manager) FsStat(ctx context.Context, id stores.ID) (fsutil.FsStat, erro
 	return m.storage.FsStat(ctx, id)
 }
 
+// MigrateSector moves sector files into another storage path local to the
+// miner process
+func (m *Manager) MigrateSector(ctx context.Context, sector storage.SectorRef, types storiface.SectorFileType, dest stores.ID, lim *rate.Limiter, progress stores.MigrateProgress) error {
+	return m.localStore.MigrateSector(ctx, sector, types, dest, lim, progress)
+}
+
 func (m *Manager) SchedDiag(ctx context.Context, doSched bool) (interface{}, error) {
 	if doSched {
 		select {
@@ -0,0 +1,133 @@
+package sectorstorage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// GenerateWindowPoSt computes the proof on a PoSt worker with local access to
+// all the sectors, failing over to other such workers if the worker errors or
+// goes away. The proof is computed in the miner process when no PoSt worker
+// can prove the sectors.
+func (m *Manager) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, []abi.SectorID, error) {
+	if len(sectorInfo) == 0 {
+		return nil, nil, xerrors.New("no sectors to prove")
+	}
+
+	sector := storage.SectorRef{
+		ID:        abi.SectorID{Miner: minerID, Number: sectorInfo[0].SectorNumber},
+		ProofType: sectorInfo[0].SealProof,
+	}
+
+	exclude := map[uuid.UUID]struct{}{}
+	var lastErr error
+
+	for {
+		selector := newPostSelector(m.index, minerID, sectorInfo, exclude)
+
+		ok, err := m.haveWorker(ctx, sealtasks.TTGenerateWindowPoSt, sector.ProofType, selector)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			if lastErr != nil {
+				log.Errorw("all PoSt workers failed, computing window post locally", "lastError", lastErr)
+			}
+
+			return m.Prover.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness)
+		}
+
+		var res storiface.WindowPoStResult
+		var wid WorkerID
+		err = m.sched.Schedule(ctx, sector, sealtasks.TTGenerateWindowPoSt, selector, schedNop, func(ctx context.Context, w Worker) error {
+			if tw, ok := w.(*trackedWorker); ok {
+				wid = tw.wid
+			}
+
+			var err error
+			res, err = w.GenerateWindowPoSt(ctx, minerID, sectorInfo, append(abi.PoStRandomness{}, randomness...))
+			return err
+		})
+		if err == nil {
+			if len(res.Skipped) > 0 {
+				return nil, res.Skipped, xerrors.Errorf("worker skipped some sectors")
+			}
+			return res.PoStProofs, nil, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if wid == WorkerID(ClosedWorkerID) {
+			// the task didn't get to a worker
+			return nil, nil, xerrors.Errorf("scheduling window post: %w", err)
+		}
+
+		log.Warnw("window post on worker failed, trying another worker", "worker", wid, "sectors", len(sectorInfo), "error", err)
+
+		exclude[uuid.UUID(wid)] = struct{}{}
+		lastErr = err
+	}
+}
+
+// WindowPoStParallelism returns the number of PoSt workers, which can each
+// prove a batch of partitions at once
+func (m *Manager) WindowPoStParallelism(ctx context.Context) int {
+	// don't hold the scheduler lock while calling workers
+	m.sched.workersLk.RLock()
+	workers := make([]Worker, 0, len(m.sched.workers))
+	for _, w := range m.sched.workers {
+		if w.enabled {
+			workers = append(workers, w.workerRpc)
+		}
+	}
+	m.sched.workersLk.RUnlock()
+
+	var n int
+	for _, w := range workers {
+		tasks, err := w.TaskTypes(ctx)
+		if err != nil {
+			continue
+		}
+		if _, ok := tasks[sealtasks.TTGenerateWindowPoSt]; ok {
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// haveWorker returns whether any enabled worker is accepted by the selector
+func (m *Manager) haveWorker(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, sel WorkerSelector) (bool, error) {
+	m.sched.workersLk.RLock()
+	defer m.sched.workersLk.RUnlock()
+
+	for wid, w := range m.sched.workers {
+		if !w.enabled {
+			continue
+		}
+
+		ok, err := sel.Ok(ctx, task, spt, w)
+		if err != nil {
+			log.Warnw("checking if worker can run task", "worker", wid, "task", task, "error", err)
+			continue
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
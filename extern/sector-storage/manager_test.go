@@ -18,13 +18,16 @@ import (
 	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-statestore"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/mock"
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
@@ -98,7 +101,7 @@ func newTestMgr(ctx context.Context, t *testing.T, ds datastore.Datastore) (*Man
 	prover, err := ffiwrapper.New(&readonlyProvider{stor: lstor, index: si})
 	require.NoError(t, err)
 
-	stor := stores.NewRemote(lstor, si, nil, 6000)
+	stor := stores.NewRemote(lstor, si, nil, 6000, 0)
 
 	m := &Manager{
 		ls:         st,
@@ -453,3 +456,66 @@ func TestReenableWorker(t *testing.T) {
 	i, _ = m.sched.Info(ctx)
 	require.Len(t, i.(SchedDiagInfo).OpenWindows, 2)
 }
+
+// postFailWorker fails the first window post computed by any of the workers
+// sharing failed
+type postFailWorker struct {
+	*testWorker
+
+	failed *int64
+	calls  int64
+}
+
+func (w *postFailWorker) GenerateWindowPoSt(ctx context.Context, mid abi.ActorID, sectors []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.WindowPoStResult, error) {
+	atomic.AddInt64(&w.calls, 1)
+	if atomic.CompareAndSwapInt64(w.failed, 0, 1) {
+		return storiface.WindowPoStResult{}, xerrors.New("worker failed")
+	}
+
+	return w.testWorker.GenerateWindowPoSt(ctx, mid, sectors, randomness)
+}
+
+func TestWindowPoStFailover(t *testing.T) {
+	ctx := context.Background()
+	m, lstor, _, idx, cleanup := newTestMgr(ctx, t, datastore.NewMapDatastore())
+	defer cleanup()
+
+	sid := abi.SectorID{Miner: 1000, Number: 1}
+
+	paths, err := lstor.Local(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	for _, ft := range []storiface.SectorFileType{storiface.FTSealed, storiface.FTCache} {
+		require.NoError(t, idx.StorageDeclareSector(ctx, paths[0].ID, sid, ft, true))
+	}
+
+	var failed int64
+	var workers []*postFailWorker
+	for i := 0; i < 2; i++ {
+		tw := newTestWorker(WorkerConfig{
+			TaskTypes: []sealtasks.TaskType{sealtasks.TTGenerateWindowPoSt},
+		}, lstor, m)
+		tw.mockSeal = mock.NewMockSectorMgr([]abi.SectorID{sid})
+
+		w := &postFailWorker{testWorker: tw, failed: &failed}
+		require.NoError(t, m.AddWorker(ctx, w))
+		workers = append(workers, w)
+	}
+
+	require.Equal(t, 2, m.WindowPoStParallelism(ctx))
+
+	sectors := []proof2.SectorInfo{{
+		SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1,
+		SectorNumber: sid.Number,
+	}}
+	randomness := make(abi.PoStRandomness, 32)
+
+	proofs, skipped, err := m.GenerateWindowPoSt(ctx, sid.Miner, sectors, randomness)
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+	require.NotEmpty(t, proofs)
+
+	// the first worker failed, and the proof was computed on the other one
+	require.Equal(t, int64(1), atomic.LoadInt64(&workers[0].calls))
+	require.Equal(t, int64(1), atomic.LoadInt64(&workers[1].calls))
+}
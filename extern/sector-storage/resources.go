@@ -4,6 +4,7 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
 type Resources struct {
@@ -44,6 +45,35 @@ func (r Resources) Threads(wcpus uint64) uint64 {
 	return uint64(r.MaxParallelism)
 }
 
+// taskResources returns the resources needed to run a task on a worker, taking
+// the worker's overrides into account
+func taskResources(wr storiface.WorkerResources, tt sealtasks.TaskType, spt abi.RegisteredSealProof) Resources {
+	r := ResourceTable[tt][spt]
+
+	o, ok := wr.Tasks[tt]
+	if !ok {
+		return r
+	}
+
+	if o.MinMemory != nil {
+		r.MinMemory = *o.MinMemory
+	}
+	if o.MaxMemory != nil {
+		r.MaxMemory = *o.MaxMemory
+	}
+	if o.MaxParallelism != nil {
+		r.MaxParallelism = *o.MaxParallelism
+	}
+	if o.CanGPU != nil {
+		r.CanGPU = *o.CanGPU
+	}
+	if o.BaseMinMemory != nil {
+		r.BaseMinMemory = *o.BaseMinMemory
+	}
+
+	return r
+}
+
 var ResourceTable = map[sealtasks.TaskType]map[abi.RegisteredSealProof]Resources{
 	sealtasks.TTAddPiece: {
 		abi.RegisteredSealProof_StackedDrg64GiBV1: Resources{
@@ -365,6 +395,7 @@ var ResourceTable = map[sealtasks.TaskType]map[abi.RegisteredSealProof]Resources
 func init() {
 	ResourceTable[sealtasks.TTUnseal] = ResourceTable[sealtasks.TTPreCommit1] // TODO: measure accurately
 	ResourceTable[sealtasks.TTReadUnsealed] = ResourceTable[sealtasks.TTFetch]
+	ResourceTable[sealtasks.TTGenerateWindowPoSt] = ResourceTable[sealtasks.TTCommit2] // TODO: measure accurately
 
 	// V1_1 is the same as V1
 	for _, m := range ResourceTable {
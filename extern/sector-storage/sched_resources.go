@@ -3,22 +3,23 @@ package sectorstorage
 import (
 	"sync"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
-func (a *activeResources) withResources(id WorkerID, wr storiface.WorkerResources, r Resources, locker sync.Locker, cb func() error) error {
-	for !a.canHandleRequest(r, id, "withResources", wr) {
+func (a *activeResources) withResources(id WorkerID, wr storiface.WorkerResources, tt sealtasks.TaskType, r Resources, locker sync.Locker, cb func() error) error {
+	for !a.canHandleRequest(r, tt, id, "withResources", wr) {
 		if a.cond == nil {
 			a.cond = sync.NewCond(locker)
 		}
 		a.cond.Wait()
 	}
 
-	a.add(wr, r)
+	a.add(wr, tt, r)
 
 	err := cb()
 
-	a.free(wr, r)
+	a.free(wr, tt, r)
 	if a.cond != nil {
 		a.cond.Broadcast()
 	}
@@ -26,7 +27,12 @@ func (a *activeResources) withResources(id WorkerID, wr storiface.WorkerResource
 	return err
 }
 
-func (a *activeResources) add(wr storiface.WorkerResources, r Resources) {
+func (a *activeResources) add(wr storiface.WorkerResources, tt sealtasks.TaskType, r Resources) {
+	if a.taskCounters == nil {
+		a.taskCounters = map[sealtasks.TaskType]int{}
+	}
+	a.taskCounters[tt]++
+
 	if r.CanGPU {
 		a.gpuUsed = true
 	}
@@ -35,7 +41,9 @@ func (a *activeResources) add(wr storiface.WorkerResources, r Resources) {
 	a.memUsedMax += r.MaxMemory
 }
 
-func (a *activeResources) free(wr storiface.WorkerResources, r Resources) {
+func (a *activeResources) free(wr storiface.WorkerResources, tt sealtasks.TaskType, r Resources) {
+	a.taskCounters[tt]--
+
 	if r.CanGPU {
 		a.gpuUsed = false
 	}
@@ -44,7 +52,11 @@ func (a *activeResources) free(wr storiface.WorkerResources, r Resources) {
 	a.memUsedMax -= r.MaxMemory
 }
 
-func (a *activeResources) canHandleRequest(needRes Resources, wid WorkerID, caller string, res storiface.WorkerResources) bool {
+func (a *activeResources) canHandleRequest(needRes Resources, tt sealtasks.TaskType, wid WorkerID, caller string, res storiface.WorkerResources) bool {
+	if limit := res.Tasks[tt].MaxConcurrent; limit > 0 && a.taskCounters[tt] >= limit {
+		log.Debugf("sched: not scheduling on worker %s for %s; %d %s tasks running, limit %d", wid, caller, a.taskCounters[tt], tt.Short(), limit)
+		return false
+	}
 
 	// TODO: dedupe needRes.BaseMinMemory per task type (don't add if that task is already running)
 	minNeedMem := res.MemReserved + a.memUsedMin + needRes.MinMemory + needRes.BaseMinMemory
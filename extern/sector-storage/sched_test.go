@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
@@ -122,6 +123,14 @@ func (s *schedTestWorker) Info(ctx context.Context) (storiface.WorkerInfo, error
 	}, nil
 }
 
+func (s *schedTestWorker) FetchProgress(context.Context) ([]storiface.FetchProgress, error) {
+	return nil, nil
+}
+
+func (s *schedTestWorker) GenerateWindowPoSt(ctx context.Context, mid abi.ActorID, sectors []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.WindowPoStResult, error) {
+	panic("implement me")
+}
+
 func (s *schedTestWorker) Session(context.Context) (uuid.UUID, error) {
 	return s.session, nil
 }
@@ -589,7 +598,7 @@ func TestWindowCompact(t *testing.T) {
 						taskType: task,
 						sector:   storage.SectorRef{ProofType: spt},
 					})
-					window.allocated.add(wh.info.Resources, ResourceTable[task][spt])
+					window.allocated.add(wh.info.Resources, task, ResourceTable[task][spt])
 				}
 
 				wh.activeWindows = append(wh.activeWindows, window)
@@ -608,7 +617,7 @@ func TestWindowCompact(t *testing.T) {
 
 				for ti, task := range tasks {
 					require.Equal(t, task, wh.activeWindows[wi].todo[ti].taskType, "%d, %d", wi, ti)
-					expectRes.add(wh.info.Resources, ResourceTable[task][spt])
+					expectRes.add(wh.info.Resources, task, ResourceTable[task][spt])
 				}
 
 				require.Equal(t, expectRes.cpuUse, wh.activeWindows[wi].allocated.cpuUse, "%d", wi)
@@ -645,3 +654,51 @@ func TestWindowCompact(t *testing.T) {
 		[][]sealtasks.TaskType{{sealtasks.TTPreCommit1, sealtasks.TTPreCommit1, sealtasks.TTAddPiece}, {sealtasks.TTPreCommit1, sealtasks.TTPreCommit2}}),
 	)
 }
+
+func TestTaskResourceOverrides(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg32GiBV1
+
+	minMem := uint64(8 << 30)
+	noGPU := false
+
+	wr := decentWorkerResources
+	wr.Tasks = map[sealtasks.TaskType]storiface.TaskResources{
+		sealtasks.TTPreCommit1: {MinMemory: &minMem, MaxMemory: &minMem, MaxConcurrent: 2},
+		sealtasks.TTCommit2:    {CanGPU: &noGPU},
+	}
+
+	pc1 := taskResources(wr, sealtasks.TTPreCommit1, spt)
+	require.Equal(t, minMem, pc1.MinMemory)
+	require.Equal(t, minMem, pc1.MaxMemory)
+	require.Equal(t, ResourceTable[sealtasks.TTPreCommit1][spt].BaseMinMemory, pc1.BaseMinMemory)
+
+	require.False(t, taskResources(wr, sealtasks.TTCommit2, spt).CanGPU)
+	require.Equal(t, ResourceTable[sealtasks.TTAddPiece][spt], taskResources(wr, sealtasks.TTAddPiece, spt))
+
+	var a activeResources
+	for i := 0; i < 2; i++ {
+		require.True(t, a.canHandleRequest(pc1, sealtasks.TTPreCommit1, WorkerID{}, "test", wr))
+		a.add(wr, sealtasks.TTPreCommit1, pc1)
+	}
+	require.False(t, a.canHandleRequest(pc1, sealtasks.TTPreCommit1, WorkerID{}, "test", wr))
+
+	// the limit only applies to the task type it's set for
+	ap := taskResources(wr, sealtasks.TTAddPiece, spt)
+	require.True(t, a.canHandleRequest(ap, sealtasks.TTAddPiece, WorkerID{}, "test", wr))
+
+	a.free(wr, sealtasks.TTPreCommit1, pc1)
+	require.True(t, a.canHandleRequest(pc1, sealtasks.TTPreCommit1, WorkerID{}, "test", wr))
+}
+
+func TestPostSelectorHasAllSectors(t *testing.T) {
+	where := []map[stores.ID]struct{}{
+		{"a": {}, "b": {}},
+		{"b": {}},
+	}
+
+	require.True(t, hasAllSectors(where, map[stores.ID]struct{}{"b": {}}))
+	require.True(t, hasAllSectors(where, map[stores.ID]struct{}{"a": {}, "b": {}, "c": {}}))
+	require.False(t, hasAllSectors(where, map[stores.ID]struct{}{"a": {}}))
+	require.False(t, hasAllSectors(append(where, map[stores.ID]struct{}{}), map[stores.ID]struct{}{"a": {}, "b": {}}))
+	require.True(t, hasAllSectors(nil, nil))
+}
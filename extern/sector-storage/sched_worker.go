@@ -295,15 +295,15 @@ func (sw *schedWorker) workerCompactWindows() {
 			var moved []int
 
 			for ti, todo := range window.todo {
-				needRes := ResourceTable[todo.taskType][todo.sector.ProofType]
-				if !lower.allocated.canHandleRequest(needRes, sw.wid, "compactWindows", worker.info.Resources) {
+				needRes := taskResources(worker.info.Resources, todo.taskType, todo.sector.ProofType)
+				if !lower.allocated.canHandleRequest(needRes, todo.taskType, sw.wid, "compactWindows", worker.info.Resources) {
 					continue
 				}
 
 				moved = append(moved, ti)
 				lower.todo = append(lower.todo, todo)
-				lower.allocated.add(worker.info.Resources, needRes)
-				window.allocated.free(worker.info.Resources, needRes)
+				lower.allocated.add(worker.info.Resources, todo.taskType, needRes)
+				window.allocated.free(worker.info.Resources, todo.taskType, needRes)
 			}
 
 			if len(moved) > 0 {
@@ -351,8 +351,8 @@ assignLoop:
 
 			worker.lk.Lock()
 			for t, todo := range firstWindow.todo {
-				needRes := ResourceTable[todo.taskType][todo.sector.ProofType]
-				if worker.preparing.canHandleRequest(needRes, sw.wid, "startPreparing", worker.info.Resources) {
+				needRes := taskResources(worker.info.Resources, todo.taskType, todo.sector.ProofType)
+				if worker.preparing.canHandleRequest(needRes, todo.taskType, sw.wid, "startPreparing", worker.info.Resources) {
 					tidx = t
 					break
 				}
@@ -390,10 +390,10 @@ assignLoop:
 func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRequest) error {
 	w, sh := sw.worker, sw.sched
 
-	needRes := ResourceTable[req.taskType][req.sector.ProofType]
+	needRes := taskResources(w.info.Resources, req.taskType, req.sector.ProofType)
 
 	w.lk.Lock()
-	w.preparing.add(w.info.Resources, needRes)
+	w.preparing.add(w.info.Resources, req.taskType, needRes)
 	w.lk.Unlock()
 
 	go func() {
@@ -403,7 +403,7 @@ func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRe
 
 		if err != nil {
 			w.lk.Lock()
-			w.preparing.free(w.info.Resources, needRes)
+			w.preparing.free(w.info.Resources, req.taskType, needRes)
 			w.lk.Unlock()
 			sh.workersLk.Unlock()
 
@@ -424,9 +424,9 @@ func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRe
 		}
 
 		// wait (if needed) for resources in the 'active' window
-		err = w.active.withResources(sw.wid, w.info.Resources, needRes, &sh.workersLk, func() error {
+		err = w.active.withResources(sw.wid, w.info.Resources, req.taskType, needRes, &sh.workersLk, func() error {
 			w.lk.Lock()
-			w.preparing.free(w.info.Resources, needRes)
+			w.preparing.free(w.info.Resources, req.taskType, needRes)
 			w.lk.Unlock()
 			sh.workersLk.Unlock()
 			defer sh.workersLk.Lock() // we MUST return locked from this function
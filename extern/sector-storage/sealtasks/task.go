@@ -14,6 +14,8 @@ const (
 	TTFetch        TaskType = "seal/v0/fetch"
 	TTUnseal       TaskType = "seal/v0/unseal"
 	TTReadUnsealed TaskType = "seal/v0/unsealread"
+
+	TTGenerateWindowPoSt TaskType = "post/v0/windowproof"
 )
 
 var order = map[TaskType]int{
@@ -25,7 +27,9 @@ var order = map[TaskType]int{
 	TTUnseal:       1,
 	TTFetch:        -1,
 	TTReadUnsealed: -1,
-	TTFinalize:     -2, // most priority
+	TTFinalize:     -2,
+
+	TTGenerateWindowPoSt: -3, // most priority
 }
 
 var shortNames = map[TaskType]string{
@@ -41,6 +45,8 @@ var shortNames = map[TaskType]string{
 	TTFetch:        "GET",
 	TTUnseal:       "UNS",
 	TTReadUnsealed: "RD",
+
+	TTGenerateWindowPoSt: "WDP",
 }
 
 func (a TaskType) MuchLess(b TaskType) (bool, bool) {
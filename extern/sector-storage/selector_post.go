@@ -0,0 +1,138 @@
+package sectorstorage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// postSelector accepts PoSt workers which have local access to the sealed and
+// cache files of all the sectors being proven, so that no sector data has to
+// be moved to compute the proof
+type postSelector struct {
+	index   stores.SectorIndex
+	mid     abi.ActorID
+	sectors []proof2.SectorInfo
+
+	exclude map[uuid.UUID]struct{}
+
+	once  sync.Once
+	where []map[stores.ID]struct{} // per sector, storage holding both files
+	err   error
+}
+
+func newPostSelector(index stores.SectorIndex, mid abi.ActorID, sectors []proof2.SectorInfo, exclude map[uuid.UUID]struct{}) *postSelector {
+	return &postSelector{
+		index:   index,
+		mid:     mid,
+		sectors: sectors,
+		exclude: exclude,
+	}
+}
+
+func (s *postSelector) findSectors(ctx context.Context) ([]map[stores.ID]struct{}, error) {
+	s.once.Do(func() {
+		s.where = make([]map[stores.ID]struct{}, len(s.sectors))
+		for i, si := range s.sectors {
+			ssize, err := si.SealProof.SectorSize()
+			if err != nil {
+				s.err = xerrors.Errorf("getting sector size: %w", err)
+				return
+			}
+
+			sid := abi.SectorID{Miner: s.mid, Number: si.SectorNumber}
+
+			count := map[stores.ID]int{}
+			for _, ft := range []storiface.SectorFileType{storiface.FTSealed, storiface.FTCache} {
+				found, err := s.index.StorageFindSector(ctx, sid, ft, ssize, false)
+				if err != nil {
+					s.err = xerrors.Errorf("finding sector %d: %w", si.SectorNumber, err)
+					return
+				}
+				for _, info := range found {
+					count[info.ID]++
+				}
+			}
+
+			s.where[i] = map[stores.ID]struct{}{}
+			for id, n := range count {
+				if n == 2 {
+					s.where[i][id] = struct{}{}
+				}
+			}
+		}
+	})
+
+	return s.where, s.err
+}
+
+func (s *postSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, whnd *workerHandle) (bool, error) {
+	tasks, err := whnd.workerRpc.TaskTypes(ctx)
+	if err != nil {
+		return false, xerrors.Errorf("getting supported worker task types: %w", err)
+	}
+	if _, supported := tasks[task]; !supported {
+		return false, nil
+	}
+
+	if len(s.exclude) > 0 {
+		sess, err := whnd.workerRpc.Session(ctx)
+		if err != nil {
+			return false, xerrors.Errorf("getting worker session: %w", err)
+		}
+		if _, excluded := s.exclude[sess]; excluded {
+			return false, nil
+		}
+	}
+
+	where, err := s.findSectors(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	paths, err := whnd.workerRpc.Paths(ctx)
+	if err != nil {
+		return false, xerrors.Errorf("getting worker paths: %w", err)
+	}
+
+	have := map[stores.ID]struct{}{}
+	for _, path := range paths {
+		have[path.ID] = struct{}{}
+	}
+
+	return hasAllSectors(where, have), nil
+}
+
+func (s *postSelector) Cmp(ctx context.Context, task sealtasks.TaskType, a, b *workerHandle) (bool, error) {
+	return a.utilization() < b.utilization(), nil
+}
+
+// hasAllSectors returns whether, for every sector, one of the paths holding it
+// is in have
+func hasAllSectors(where []map[stores.ID]struct{}, have map[stores.ID]struct{}) bool {
+	for _, ids := range where {
+		var found bool
+		for id := range ids {
+			if _, ok := have[id]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+var _ WorkerSelector = &postSelector{}
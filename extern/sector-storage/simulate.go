@@ -0,0 +1,489 @@
+package sectorstorage
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// SimPipeline is the sequence of tasks a sector goes through in a simulation
+var SimPipeline = []sealtasks.TaskType{
+	sealtasks.TTAddPiece,
+	sealtasks.TTPreCommit1,
+	sealtasks.TTPreCommit2,
+	sealtasks.TTCommit1,
+	sealtasks.TTCommit2,
+	sealtasks.TTFinalize,
+}
+
+// SimWorker is a worker taking part in a scheduling simulation
+type SimWorker struct {
+	Name  string
+	Info  storiface.WorkerInfo
+	Tasks []sealtasks.TaskType
+
+	// Task durations on this worker, overriding SimConfig.Durations
+	Durations map[sealtasks.TaskType]time.Duration
+}
+
+// SimConfig describes a sealing pipeline simulation
+type SimConfig struct {
+	ProofType abi.RegisteredSealProof
+	Workers   []SimWorker
+
+	// Default task durations
+	Durations map[sealtasks.TaskType]time.Duration
+
+	// Time it takes to move sealing data to another worker
+	FetchDuration time.Duration
+
+	// Time between PreCommit2 finishing and Commit1 becoming schedulable
+	// (precommit message landing on chain + interactive seed delay)
+	SeedWait time.Duration
+
+	// Number of sectors being sealed at once, 0 for no limit
+	MaxSealing int
+
+	// Simulated time
+	Duration time.Duration
+}
+
+type SimWorkerStats struct {
+	Name string
+
+	Tasks       map[sealtasks.TaskType]int // tasks finished
+	Busy        time.Duration              // time with at least one task running
+	Utilization float64                    // time-averaged resource utilization
+}
+
+type SimQueueStats struct {
+	MaxDepth int
+	AvgDepth float64
+	AvgWait  time.Duration
+}
+
+type SimResult struct {
+	Sealed        int
+	SectorsPerDay float64
+
+	Workers []SimWorkerStats
+	Queues  map[sealtasks.TaskType]SimQueueStats
+
+	// Task with the longest average queue wait, and the most utilized worker
+	// able to run it
+	BottleneckTask   sealtasks.TaskType
+	BottleneckWorker string
+}
+
+type simSector struct {
+	number int
+	stage  int
+	worker int // worker holding sealing data, -1 if none
+}
+
+type simRequest struct {
+	sector *simSector
+	task   sealtasks.TaskType
+	queued time.Duration
+}
+
+type simWorker struct {
+	SimWorker
+
+	id     WorkerID
+	tasks  map[sealtasks.TaskType]struct{}
+	active activeResources
+
+	running int
+	done    map[sealtasks.TaskType]int
+	busy    time.Duration
+	util    float64
+}
+
+type simEvent struct {
+	at     time.Duration
+	sector *simSector
+	worker int // -1 for events not tied to a worker (seed wait)
+	task   sealtasks.TaskType
+}
+
+type simEvents []*simEvent
+
+func (e simEvents) Len() int            { return len(e) }
+func (e simEvents) Less(i, j int) bool  { return e[i].at < e[j].at }
+func (e simEvents) Swap(i, j int)       { e[i], e[j] = e[j], e[i] }
+func (e *simEvents) Push(x interface{}) { *e = append(*e, x.(*simEvent)) }
+func (e *simEvents) Pop() interface{} {
+	old := *e
+	n := len(old)
+	x := old[n-1]
+	*e = old[:n-1]
+	return x
+}
+
+type simulation struct {
+	cfg SimConfig
+
+	now     time.Duration
+	workers []*simWorker
+	queue   []*simRequest
+	events  simEvents
+
+	sectors  int
+	inflight int
+	sealed   int
+
+	depthSum map[sealtasks.TaskType]float64 // depth * ns
+	depthMax map[sealtasks.TaskType]int
+	waitSum  map[sealtasks.TaskType]time.Duration
+	started  map[sealtasks.TaskType]int
+}
+
+// Simulate runs a discrete-event simulation of the sealing pipeline on the
+// given workers, using the same resource accounting as the scheduler
+func Simulate(cfg SimConfig) (*SimResult, error) {
+	s := &simulation{
+		cfg: cfg,
+
+		depthSum: map[sealtasks.TaskType]float64{},
+		depthMax: map[sealtasks.TaskType]int{},
+		waitSum:  map[sealtasks.TaskType]time.Duration{},
+		started:  map[sealtasks.TaskType]int{},
+	}
+
+	for i, w := range cfg.Workers {
+		sw := &simWorker{
+			SimWorker: w,
+			id:        WorkerID{byte(i >> 8), byte(i)},
+			tasks:     map[sealtasks.TaskType]struct{}{},
+			done:      map[sealtasks.TaskType]int{},
+		}
+		for _, t := range w.Tasks {
+			sw.tasks[t] = struct{}{}
+		}
+		s.workers = append(s.workers, sw)
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	s.fill()
+	s.schedule()
+
+	for len(s.events) > 0 {
+		ev := heap.Pop(&s.events).(*simEvent)
+		if ev.at > cfg.Duration {
+			break
+		}
+
+		s.advance(ev.at)
+		s.handle(ev)
+
+		s.fill()
+		s.schedule()
+	}
+	s.advance(cfg.Duration)
+
+	return s.result(), nil
+}
+
+func (s *simulation) validate() error {
+	if s.cfg.Duration <= 0 {
+		return xerrors.Errorf("simulation duration must be positive")
+	}
+
+	if _, ok := ResourceTable[sealtasks.TTPreCommit1][s.cfg.ProofType]; !ok {
+		return xerrors.Errorf("no resource requirements for proof type %d", s.cfg.ProofType)
+	}
+
+	for _, task := range SimPipeline {
+		var can bool
+		for _, w := range s.workers {
+			if _, ok := w.tasks[task]; !ok {
+				continue
+			}
+
+			if _, err := s.duration(w, task); err != nil {
+				return err
+			}
+
+			// like in the scheduler, tasks missing from the table (finalize) don't
+			// use any resources
+			need := taskResources(w.Info.Resources, task, s.cfg.ProofType)
+			can = can || (&activeResources{}).canHandleRequest(need, task, w.id, "simulate", w.Info.Resources)
+		}
+
+		if !can {
+			return xerrors.Errorf("no worker can run %s", task)
+		}
+	}
+
+	return nil
+}
+
+func (s *simulation) duration(w *simWorker, task sealtasks.TaskType) (time.Duration, error) {
+	d, ok := w.Durations[task]
+	if !ok {
+		d, ok = s.cfg.Durations[task]
+	}
+	if !ok {
+		return 0, xerrors.Errorf("no duration for %s on worker %s", task, w.Name)
+	}
+	// the clock wouldn't advance on tasks taking no time
+	if d <= 0 {
+		return 0, xerrors.Errorf("duration of %s on worker %s must be positive, got %s", task, w.Name, d)
+	}
+	return d, nil
+}
+
+// fill starts new sectors as allowed by MaxSealing; without a limit there is
+// always one sector waiting for AddPiece
+func (s *simulation) fill() {
+	for {
+		if s.cfg.MaxSealing > 0 {
+			if s.inflight >= s.cfg.MaxSealing {
+				return
+			}
+		} else {
+			for _, r := range s.queue {
+				if r.task == sealtasks.TTAddPiece {
+					return
+				}
+			}
+		}
+
+		s.sectors++
+		s.inflight++
+		s.enqueue(&simSector{number: s.sectors, worker: -1})
+	}
+}
+
+func (s *simulation) enqueue(sector *simSector) {
+	s.queue = append(s.queue, &simRequest{
+		sector: sector,
+		task:   SimPipeline[sector.stage],
+		queued: s.now,
+	})
+}
+
+// data locality of each task: needsData tasks use the sealing data, and prefer
+// running where it is; for pinned tasks (existing selector without fetching)
+// only the holding worker is used if it can run the task at all
+func simLocality(task sealtasks.TaskType) (needsData bool, pinned bool) {
+	switch task {
+	case sealtasks.TTPreCommit1, sealtasks.TTPreCommit2:
+		return true, false
+	case sealtasks.TTCommit1, sealtasks.TTFinalize:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func (s *simulation) schedule() {
+	sort.SliceStable(s.queue, func(i, j int) bool {
+		a, b := s.queue[i], s.queue[j]
+
+		oneMuchLess, muchLess := a.task.MuchLess(b.task)
+		if oneMuchLess {
+			return muchLess
+		}
+
+		if a.task != b.task {
+			return a.task.Less(b.task)
+		}
+
+		return a.sector.number < b.sector.number
+	})
+
+	remaining := s.queue[:0]
+	for _, req := range s.queue {
+		if !s.assign(req) {
+			remaining = append(remaining, req)
+		}
+	}
+	s.queue = remaining
+}
+
+func (s *simulation) assign(req *simRequest) bool {
+	needsData, pinned := simLocality(req.task)
+
+	holder := req.sector.worker
+	if pinned && holder >= 0 {
+		if _, ok := s.workers[holder].tasks[req.task]; !ok {
+			pinned = false
+		}
+	}
+
+	best := -1
+	for i, w := range s.workers {
+		if _, ok := w.tasks[req.task]; !ok {
+			continue
+		}
+		if pinned && holder >= 0 && i != holder {
+			continue
+		}
+		need := taskResources(w.Info.Resources, req.task, s.cfg.ProofType)
+		if !w.active.canHandleRequest(need, req.task, w.id, "simulate", w.Info.Resources) {
+			continue
+		}
+
+		if best < 0 || s.better(i, best, holder, needsData) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return false
+	}
+
+	w := s.workers[best]
+	d, _ := s.duration(w, req.task) // checked in validate
+	if needsData && holder >= 0 && holder != best {
+		d += s.cfg.FetchDuration
+	}
+
+	w.active.add(w.Info.Resources, req.task, taskResources(w.Info.Resources, req.task, s.cfg.ProofType))
+	w.running++
+
+	s.waitSum[req.task] += s.now - req.queued
+	s.started[req.task]++
+
+	heap.Push(&s.events, &simEvent{
+		at:     s.now + d,
+		sector: req.sector,
+		worker: best,
+		task:   req.task,
+	})
+
+	return true
+}
+
+// better mirrors the scheduler worker preference: data locality first, then
+// workers supporting fewer task types, then lower utilization
+func (s *simulation) better(a, b, holder int, needsData bool) bool {
+	if needsData && (a == holder) != (b == holder) {
+		return a == holder
+	}
+
+	wa, wb := s.workers[a], s.workers[b]
+	if len(wa.tasks) != len(wb.tasks) {
+		return len(wa.tasks) < len(wb.tasks)
+	}
+
+	return wa.active.utilization(wa.Info.Resources) < wb.active.utilization(wb.Info.Resources)
+}
+
+func (s *simulation) handle(ev *simEvent) {
+	sector := ev.sector
+
+	if ev.worker >= 0 {
+		w := s.workers[ev.worker]
+		w.active.free(w.Info.Resources, ev.task, taskResources(w.Info.Resources, ev.task, s.cfg.ProofType))
+		w.running--
+		w.done[ev.task]++
+
+		if needsData, _ := simLocality(ev.task); needsData || ev.task == sealtasks.TTAddPiece {
+			sector.worker = ev.worker
+		}
+	} else {
+		// seed wait over
+		s.enqueue(sector)
+		return
+	}
+
+	sector.stage++
+
+	switch {
+	case sector.stage >= len(SimPipeline):
+		s.sealed++
+		s.inflight--
+	case SimPipeline[sector.stage] == sealtasks.TTCommit1:
+		heap.Push(&s.events, &simEvent{
+			at:     s.now + s.cfg.SeedWait,
+			sector: sector,
+			worker: -1,
+		})
+	default:
+		s.enqueue(sector)
+	}
+}
+
+// advance moves the simulation clock, accumulating time-weighted stats
+func (s *simulation) advance(to time.Duration) {
+	dt := to - s.now
+	if dt <= 0 {
+		return
+	}
+
+	depth := map[sealtasks.TaskType]int{}
+	for _, r := range s.queue {
+		depth[r.task]++
+	}
+	for task, n := range depth {
+		s.depthSum[task] += float64(n) * float64(dt)
+		if n > s.depthMax[task] {
+			s.depthMax[task] = n
+		}
+	}
+
+	for _, w := range s.workers {
+		if w.running > 0 {
+			w.busy += dt
+		}
+		w.util += w.active.utilization(w.Info.Resources) * float64(dt)
+	}
+
+	s.now = to
+}
+
+func (s *simulation) result() *SimResult {
+	out := &SimResult{
+		Sealed:        s.sealed,
+		SectorsPerDay: float64(s.sealed) / (float64(s.cfg.Duration) / float64(24*time.Hour)),
+		Queues:        map[sealtasks.TaskType]SimQueueStats{},
+	}
+
+	var worstWait time.Duration
+	for _, task := range SimPipeline {
+		qs := SimQueueStats{
+			MaxDepth: s.depthMax[task],
+			AvgDepth: s.depthSum[task] / float64(s.cfg.Duration),
+		}
+		if s.started[task] > 0 {
+			qs.AvgWait = s.waitSum[task] / time.Duration(s.started[task])
+		}
+		out.Queues[task] = qs
+
+		if qs.AvgWait > worstWait {
+			worstWait = qs.AvgWait
+			out.BottleneckTask = task
+		}
+	}
+
+	bestUtil := -1.0
+	for _, w := range s.workers {
+		ws := SimWorkerStats{
+			Name:        w.Name,
+			Tasks:       w.done,
+			Busy:        w.busy,
+			Utilization: w.util / float64(s.cfg.Duration),
+		}
+		out.Workers = append(out.Workers, ws)
+
+		if _, ok := w.tasks[out.BottleneckTask]; ok && out.BottleneckTask != "" && ws.Utilization > bestUtil {
+			bestUtil = ws.Utilization
+			out.BottleneckWorker = w.Name
+		}
+	}
+
+	return out
+}
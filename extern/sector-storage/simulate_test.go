@@ -0,0 +1,97 @@
+package sectorstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestSimulate(t *testing.T) {
+	durations := map[sealtasks.TaskType]time.Duration{}
+	for _, task := range SimPipeline {
+		durations[task] = time.Minute
+	}
+
+	worker := SimWorker{
+		Name:  "all",
+		Info:  storiface.WorkerInfo{Hostname: "all", Resources: decentWorkerResources},
+		Tasks: SimPipeline,
+	}
+
+	cfg := SimConfig{
+		ProofType:  abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Workers:    []SimWorker{worker},
+		Durations:  durations,
+		MaxSealing: 1,
+		Duration:   time.Hour,
+	}
+
+	// one sector at a time, 6 one-minute tasks each
+	res, err := Simulate(cfg)
+	require.NoError(t, err)
+	require.Equal(t, 10, res.Sealed)
+	require.Equal(t, 240.0, res.SectorsPerDay)
+	require.Equal(t, 10, res.Workers[0].Tasks[sealtasks.TTPreCommit1])
+
+	// a slow PC1 on the only worker able to run it is the bottleneck
+	pc1 := worker
+	pc1.Name = "pc1"
+	pc1.Tasks = []sealtasks.TaskType{sealtasks.TTPreCommit1}
+	pc1.Durations = map[sealtasks.TaskType]time.Duration{sealtasks.TTPreCommit1: 30 * time.Minute}
+	pc1.Info.Resources.CPUs = 1 // one PC1 at a time
+
+	worker.Tasks = []sealtasks.TaskType{sealtasks.TTAddPiece, sealtasks.TTPreCommit2, sealtasks.TTCommit1, sealtasks.TTCommit2, sealtasks.TTFinalize}
+
+	cfg.Workers = []SimWorker{worker, pc1}
+	cfg.MaxSealing = 0
+
+	res, err = Simulate(cfg)
+	require.NoError(t, err)
+	require.Equal(t, sealtasks.TTPreCommit1, res.BottleneckTask)
+	require.Equal(t, "pc1", res.BottleneckWorker)
+
+	cfg.Workers = []SimWorker{worker}
+	_, err = Simulate(cfg)
+	require.Error(t, err)
+}
+
+func TestSimulateRejectsEmptyDurations(t *testing.T) {
+	durations := map[sealtasks.TaskType]time.Duration{}
+	for _, task := range SimPipeline {
+		durations[task] = time.Minute
+	}
+
+	worker := SimWorker{
+		Name:  "all",
+		Info:  storiface.WorkerInfo{Hostname: "all", Resources: decentWorkerResources},
+		Tasks: SimPipeline,
+	}
+
+	cfg := SimConfig{
+		ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Workers:   []SimWorker{worker},
+		Durations: durations,
+		Duration:  time.Hour,
+	}
+
+	durations[sealtasks.TTCommit1] = 0
+	_, err := Simulate(cfg)
+	require.Error(t, err)
+
+	durations[sealtasks.TTCommit1] = time.Minute
+	worker.Durations = map[sealtasks.TaskType]time.Duration{sealtasks.TTPreCommit2: -time.Second}
+	cfg.Workers = []SimWorker{worker}
+	_, err = Simulate(cfg)
+	require.Error(t, err)
+
+	cfg.Duration = 0
+	cfg.Workers = []SimWorker{{Name: "all", Info: worker.Info, Tasks: SimPipeline}}
+	_, err = Simulate(cfg)
+	require.Error(t, err)
+}
@@ -1,16 +1,22 @@
 package sectorstorage
 
 import (
+	"context"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
+// timeout for worker calls made while collecting stats
+var workerRpcTimeout = 5 * time.Second
+
 func (m *Manager) WorkerStats() map[uuid.UUID]storiface.WorkerStats {
 	m.sched.workersLk.RLock()
-	defer m.sched.workersLk.RUnlock()
 
 	out := map[uuid.UUID]storiface.WorkerStats{}
 
@@ -26,9 +32,66 @@ func (m *Manager) WorkerStats() map[uuid.UUID]storiface.WorkerStats {
 		}
 	}
 
+	m.sched.workersLk.RUnlock()
+
+	m.addWorkerTasks(out)
+
 	return out
 }
 
+// addWorkerTasks fills in task types accepted by the workers. Workers are
+// queried in parallel, with a deadline shared by all calls.
+func (m *Manager) addWorkerTasks(stats map[uuid.UUID]storiface.WorkerStats) {
+	type result struct {
+		wid   uuid.UUID
+		tasks map[sealtasks.TaskType]struct{}
+		err   error
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), workerRpcTimeout)
+	defer cancel()
+
+	results := make(chan result, len(stats))
+	var wg sync.WaitGroup
+
+	m.sched.workersLk.RLock()
+	for wid := range stats {
+		handle, ok := m.sched.workers[WorkerID(wid)]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(wid uuid.UUID, w Worker) {
+			defer wg.Done()
+
+			tasks, err := w.TaskTypes(ctx)
+			results <- result{wid: wid, tasks: tasks, err: err}
+		}(wid, handle.workerRpc)
+	}
+	m.sched.workersLk.RUnlock()
+
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		if r.err != nil {
+			log.Warnw("getting worker task types", "worker", r.wid, "error", r.err)
+			continue
+		}
+
+		st := stats[r.wid]
+		for tt := range r.tasks {
+			st.Tasks = append(st.Tasks, tt)
+		}
+		sort.Slice(st.Tasks, func(i, j int) bool {
+			return st.Tasks[i].Less(st.Tasks[j])
+		})
+
+		stats[r.wid] = st
+	}
+}
+
 func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 	out := map[uuid.UUID][]storiface.WorkerJob{}
 	calls := map[storiface.CallID]struct{}{}
@@ -38,6 +101,8 @@ func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 		calls[t.job.ID] = struct{}{}
 	}
 
+	m.addFetchProgress(out)
+
 	m.sched.workersLk.RLock()
 
 	for id, handle := range m.sched.workers {
@@ -92,3 +157,57 @@ func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 
 	return out
 }
+
+// addFetchProgress attaches the state of sector fetches to running jobs.
+// Workers are queried in parallel, with a deadline shared by all calls.
+func (m *Manager) addFetchProgress(running map[uuid.UUID][]storiface.WorkerJob) {
+	type result struct {
+		wid uuid.UUID
+		fps []storiface.FetchProgress
+		err error
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), workerRpcTimeout)
+	defer cancel()
+
+	results := make(chan result, len(running))
+	var wg sync.WaitGroup
+
+	m.sched.workersLk.RLock()
+	for wid := range running {
+		handle, ok := m.sched.workers[WorkerID(wid)]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(wid uuid.UUID, w Worker) {
+			defer wg.Done()
+
+			fps, err := w.FetchProgress(ctx)
+			results <- result{wid: wid, fps: fps, err: err}
+		}(wid, handle.workerRpc)
+	}
+	m.sched.workersLk.RUnlock()
+
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		if r.err != nil {
+			// older workers don't report fetch progress
+			log.Debugw("getting worker fetch progress", "worker", r.wid, "error", r.err)
+			continue
+		}
+
+		jobs := running[r.wid]
+		for i := range jobs {
+			for _, fp := range r.fps {
+				if fp.Sector == jobs[i].Sector {
+					fp := fp
+					jobs[i].Fetch = &fp
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package stores
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// ChecksumTrailer is the HTTP trailer carrying the hex-encoded sha256 of the
+// sector data sent in a response
+const ChecksumTrailer = "X-Sector-Sha256"
+
+// FetchChunkSize is the amount of data requested (and verified) at once by
+// resumable fetches; an interrupted transfer restarts from the last chunk
+var FetchChunkSize int64 = 256 << 20
+
+// how many times a single chunk is retried before giving up on the source
+var FetchChunkRetries = 5
+
+const fetchStateSuffix = ".fetchstate"
+
+var errNoManifest = xerrors.New("remote doesn't support resumable fetches")
+
+// FetchManifest lists the files making up a stored sector file type
+type FetchManifest struct {
+	Dir   bool
+	Files []FetchFile
+}
+
+type FetchFile struct {
+	Name string // empty when the sector file isn't a directory
+	Size int64
+}
+
+type fetchState struct {
+	Size     int64
+	Verified int64
+}
+
+func readManifest(path string) (*FetchManifest, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !st.IsDir() {
+		return &FetchManifest{Files: []FetchFile{{Size: st.Size()}}}, nil
+	}
+
+	ents, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &FetchManifest{Dir: true, Files: make([]FetchFile, 0, len(ents))}
+	for _, ent := range ents {
+		if ent.IsDir() {
+			continue
+		}
+
+		out.Files = append(out.Files, FetchFile{Name: ent.Name(), Size: ent.Size()})
+	}
+
+	return out, nil
+}
+
+type fetchTracker struct {
+	lk sync.Mutex
+	p  storiface.FetchProgress
+}
+
+func (t *fetchTracker) update(cb func(p *storiface.FetchProgress)) {
+	if t == nil {
+		return
+	}
+
+	t.lk.Lock()
+	cb(&t.p)
+	t.lk.Unlock()
+}
+
+func (t *fetchTracker) add(n int64) {
+	t.update(func(p *storiface.FetchProgress) {
+		p.Fetched += n
+	})
+}
+
+type progressWriter struct {
+	t *fetchTracker
+	n int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	w.t.add(int64(len(p)))
+	return len(p), nil
+}
+
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	lim *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > l.lim.Burst() {
+		p = p[:l.lim.Burst()]
+	}
+
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.lim.WaitN(l.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *Remote) limitReader(ctx context.Context, rd io.Reader) io.Reader {
+	if r.bandwidth == nil {
+		return rd
+	}
+
+	return &limitedReader{ctx: ctx, r: rd, lim: r.bandwidth}
+}
+
+// FetchProgress returns the state of sector fetches currently running
+func (r *Remote) FetchProgress() []storiface.FetchProgress {
+	r.progressLk.Lock()
+	defer r.progressLk.Unlock()
+
+	out := make([]storiface.FetchProgress, 0, len(r.progress))
+	for _, t := range r.progress {
+		t.lk.Lock()
+		out = append(out, t.p)
+		t.lk.Unlock()
+	}
+
+	return out
+}
+
+func (r *Remote) fetchManifest(ctx context.Context, url string) (*FetchManifest, error) {
+	req, err := http.NewRequest("GET", url+"/files", nil)
+	if err != nil {
+		return nil, xerrors.Errorf("request: %w", err)
+	}
+	req.Header = r.auth
+	req = req.WithContext(ctx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	switch resp.StatusCode {
+	case 200:
+	case 404:
+		return nil, errNoManifest
+	default:
+		return nil, xerrors.Errorf("non-200 code: %d", resp.StatusCode)
+	}
+
+	var man FetchManifest
+	if err := json.NewDecoder(resp.Body).Decode(&man); err != nil {
+		return nil, xerrors.Errorf("decoding manifest: %w", err)
+	}
+
+	if !man.Dir && len(man.Files) != 1 {
+		return nil, xerrors.Errorf("expected one file in manifest, got %d", len(man.Files))
+	}
+	for _, f := range man.Files {
+		if man.Dir && (f.Name == "" || f.Name == "." || f.Name == ".." || filepath.Base(f.Name) != f.Name) {
+			return nil, xerrors.Errorf("invalid file name in manifest: '%s'", f.Name)
+		}
+	}
+
+	return &man, nil
+}
+
+// fetchResumable fetches the sector file described by the manifest into
+// outname, keeping (verified) data already fetched by earlier attempts
+func (r *Remote) fetchResumable(ctx context.Context, surl, outname string, man *FetchManifest, t *fetchTracker) error {
+	var total int64
+	for _, f := range man.Files {
+		total += f.Size
+	}
+	t.update(func(p *storiface.FetchProgress) {
+		p.Fetched = 0
+		p.Size = total
+	})
+
+	st, err := os.Stat(outname)
+	if err == nil && st.IsDir() != man.Dir {
+		if err := os.RemoveAll(outname); err != nil {
+			return xerrors.Errorf("removing dest: %w", err)
+		}
+	}
+
+	if !man.Dir {
+		return r.fetchFile(ctx, surl, outname, man.Files[0].Size, t)
+	}
+
+	if err := os.MkdirAll(outname, 0755); err != nil { // nolint
+		return xerrors.Errorf("mkdir: %w", err)
+	}
+
+	// drop leftovers which aren't part of the sector anymore
+	keep := map[string]struct{}{}
+	for _, f := range man.Files {
+		keep[f.Name] = struct{}{}
+		keep[f.Name+fetchStateSuffix] = struct{}{}
+	}
+
+	ents, err := ioutil.ReadDir(outname)
+	if err != nil {
+		return err
+	}
+	for _, ent := range ents {
+		if _, ok := keep[ent.Name()]; !ok {
+			if err := os.RemoveAll(filepath.Join(outname, ent.Name())); err != nil {
+				return xerrors.Errorf("removing stale file: %w", err)
+			}
+		}
+	}
+
+	for _, f := range man.Files {
+		if err := r.fetchFile(ctx, surl+"/files/"+url.PathEscape(f.Name), filepath.Join(outname, f.Name), f.Size, t); err != nil {
+			return xerrors.Errorf("fetching %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Remote) fetchFile(ctx context.Context, url, dest string, size int64, t *fetchTracker) error {
+	statePath := dest + fetchStateSuffix
+
+	var verified int64
+	if b, err := ioutil.ReadFile(statePath); err == nil {
+		var fst fetchState
+		if err := json.Unmarshal(b, &fst); err == nil && fst.Size == size && fst.Verified <= size {
+			verified = fst.Verified
+		}
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY, 0644) // nolint
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() < verified {
+		verified = 0
+	}
+
+	if err := f.Truncate(verified); err != nil {
+		return xerrors.Errorf("truncating to verified data: %w", err)
+	}
+
+	if verified > 0 {
+		log.Infow("resuming fetch", "url", url, "verified", verified, "size", size)
+		t.update(func(p *storiface.FetchProgress) {
+			p.Resumed++
+		})
+	}
+	t.add(verified)
+
+	for verified < size {
+		end := verified + FetchChunkSize
+		if end > size {
+			end = size
+		}
+
+		for attempt := 0; ; attempt++ {
+			n, err := r.fetchRange(ctx, url, f, verified, end, t)
+			if err == nil {
+				break
+			}
+
+			t.add(-n)
+			if ctx.Err() != nil || attempt >= FetchChunkRetries {
+				return err
+			}
+
+			log.Warnw("fetching sector data failed, retrying", "url", url, "offset", verified, "attempt", attempt+1, "error", err)
+
+			if err := f.Truncate(verified); err != nil {
+				return xerrors.Errorf("truncating to verified data: %w", err)
+			}
+			t.update(func(p *storiface.FetchProgress) {
+				p.Resumed++
+			})
+
+			select {
+			case <-time.After(time.Duration(attempt+1) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		verified = end
+
+		b, err := json.Marshal(&fetchState{Size: size, Verified: verified})
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(statePath, b, 0644); err != nil {
+			return xerrors.Errorf("writing fetch state: %w", err)
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("removing fetch state: %w", err)
+	}
+
+	return nil
+}
+
+// fetchRange fetches [start, end) of the file at url into f, verifying the
+// checksum sent by the remote. Returns the number of bytes written.
+func (r *Remote) fetchRange(ctx context.Context, url string, f *os.File, start, end int64, t *fetchTracker) (int64, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, xerrors.Errorf("request: %w", err)
+	}
+	req.Header = r.auth.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	req = req.WithContext(ctx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, xerrors.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, xerrors.Errorf("non-206 code: %d", resp.StatusCode)
+	}
+
+	if cr := resp.Header.Get("Content-Range"); !strings.HasPrefix(cr, fmt.Sprintf("bytes %d-%d/", start, end-1)) {
+		return 0, xerrors.Errorf("unexpected content range '%s'", cr)
+	}
+
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	h := sha256.New()
+	pw := &progressWriter{t: t}
+
+	// read one byte past the range to catch oversized responses, and to make
+	// sure the body is read to EOF so that the trailer is available
+	n, err := io.CopyBuffer(io.MultiWriter(f, h, pw), r.limitReader(ctx, io.LimitReader(resp.Body, end-start+1)), make([]byte, CopyBuf))
+	if err != nil {
+		return pw.n, xerrors.Errorf("copying data: %w", err)
+	}
+	if n != end-start {
+		return pw.n, xerrors.Errorf("expected %d bytes, got %d", end-start, n)
+	}
+
+	sum := resp.Trailer.Get(ChecksumTrailer)
+	if sum == "" {
+		return pw.n, xerrors.Errorf("remote didn't send a checksum")
+	}
+	if sum != hex.EncodeToString(h.Sum(nil)) {
+		return pw.n, xerrors.Errorf("checksum mismatch for range %d-%d", start, end)
+	}
+
+	return pw.n, nil
+}
@@ -0,0 +1,96 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	for _, tc := range []struct {
+		rh         string
+		start, end int64
+		err        bool
+	}{
+		{rh: "bytes=0-", start: 0, end: 100},
+		{rh: "bytes=10-19", start: 10, end: 20},
+		{rh: "bytes=90-200", start: 90, end: 100},
+		{rh: "bytes=100-", err: true},
+		{rh: "bytes=20-10", err: true},
+		{rh: "bytes=0-1,5-6", err: true},
+		{rh: "items=0-1", err: true},
+	} {
+		start, end, err := parseRange(tc.rh, 100)
+		if tc.err {
+			require.Error(t, err, tc.rh)
+			continue
+		}
+		require.NoError(t, err, tc.rh)
+		require.Equal(t, tc.start, start, tc.rh)
+		require.Equal(t, tc.end, end, tc.rh)
+	}
+}
+
+func TestFetchFileResume(t *testing.T) {
+	defer func(cs int64) { FetchChunkSize = cs }(FetchChunkSize)
+	FetchChunkSize = 1 << 10
+
+	dir, err := ioutil.TempDir("", "sector-storage-testfetch-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	data := make([]byte, 5<<10+17)
+	_, _ = rand.New(rand.NewSource(1)).Read(data)
+
+	src := filepath.Join(dir, "src")
+	require.NoError(t, ioutil.WriteFile(src, data, 0644))
+
+	var requests, corrupt int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if atomic.CompareAndSwapInt64(&corrupt, 1, 0) {
+			// serve a chunk of garbage with a valid-looking range
+			w.Header().Set("Trailer", ChecksumTrailer)
+			w.Header().Set("Content-Range", "bytes 2048-3071/5137")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(make([]byte, 1<<10))
+			w.Header().Set(ChecksumTrailer, "00")
+			return
+		}
+		serveChecksummed(w, r, src)
+	}))
+	defer srv.Close()
+
+	r := NewRemote(nil, nil, nil, 1, 0)
+	dest := filepath.Join(dir, "dest")
+
+	// pretend an earlier attempt fetched and verified the first two chunks
+	require.NoError(t, ioutil.WriteFile(dest, append(append([]byte{}, data[:2<<10]...), 0xff, 0xff), 0644))
+	require.NoError(t, ioutil.WriteFile(dest+fetchStateSuffix, []byte(`{"Size":5137,"Verified":2048}`), 0644))
+
+	atomic.StoreInt64(&corrupt, 1)
+
+	tr := &fetchTracker{}
+	require.NoError(t, r.fetchFile(context.TODO(), srv.URL, dest, int64(len(data)), tr))
+
+	got, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(data, got))
+
+	// 4 chunks left, one of them retried after the corrupted response
+	require.EqualValues(t, 5, atomic.LoadInt64(&requests))
+	require.EqualValues(t, len(data), tr.p.Fetched)
+	require.Equal(t, 2, tr.p.Resumed)
+
+	_, err = os.Stat(dest + fetchStateSuffix)
+	require.True(t, os.IsNotExist(err))
+}
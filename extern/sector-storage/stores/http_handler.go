@@ -1,10 +1,16 @@
 package stores
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	logging "github.com/ipfs/go-log/v2"
@@ -26,6 +32,8 @@ func (handler *FetchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	mux := mux.NewRouter()
 
 	mux.HandleFunc("/remote/stat/{id}", handler.remoteStatFs).Methods("GET")
+	mux.HandleFunc("/remote/{type}/{id}/files", handler.remoteListSector).Methods("GET")
+	mux.HandleFunc("/remote/{type}/{id}/files/{name}", handler.remoteGetSectorFile).Methods("GET")
 	mux.HandleFunc("/remote/{type}/{id}", handler.remoteGetSector).Methods("GET")
 	mux.HandleFunc("/remote/{type}/{id}", handler.remoteDeleteSector).Methods("DELETE")
 
@@ -54,22 +62,23 @@ func (handler *FetchHandler) remoteStatFs(w http.ResponseWriter, r *http.Request
 	}
 }
 
-func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Request) {
-	log.Infof("SERVE GET %s", r.URL)
+// acquirePath returns the local path of the sector file requested in r; it
+// writes an error response and returns false when that fails
+func (handler *FetchHandler) acquirePath(w http.ResponseWriter, r *http.Request) (string, bool) {
 	vars := mux.Vars(r)
 
 	id, err := storiface.ParseSectorID(vars["id"])
 	if err != nil {
 		log.Errorf("%+v", err)
 		w.WriteHeader(500)
-		return
+		return "", false
 	}
 
 	ft, err := ftFromString(vars["type"])
 	if err != nil {
 		log.Errorf("%+v", err)
 		w.WriteHeader(500)
-		return
+		return "", false
 	}
 
 	// The caller has a lock on this sector already, no need to get one here
@@ -84,7 +93,7 @@ func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Requ
 	if err != nil {
 		log.Errorf("%+v", err)
 		w.WriteHeader(500)
-		return
+		return "", false
 	}
 
 	// TODO: reserve local storage here
@@ -93,6 +102,17 @@ func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Requ
 	if path == "" {
 		log.Error("acquired path was empty")
 		w.WriteHeader(500)
+		return "", false
+	}
+
+	return path, true
+}
+
+func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Request) {
+	log.Infof("SERVE GET %s", r.URL)
+
+	path, ok := handler.acquirePath(w, r)
+	if !ok {
 		return
 	}
 
@@ -103,27 +123,20 @@ func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	var rd io.Reader
-	if stat.IsDir() {
-		rd, err = tarutil.TarDirectory(path)
-		w.Header().Set("Content-Type", "application/x-tar")
-	} else {
-		rd, err = os.OpenFile(path, os.O_RDONLY, 0644) // nolint
-		w.Header().Set("Content-Type", "application/octet-stream")
+	if !stat.IsDir() {
+		serveChecksummed(w, r, path)
+		return
 	}
+
+	rd, err := tarutil.TarDirectory(path)
 	if err != nil {
 		log.Errorf("%+v", err)
 		w.WriteHeader(500)
 		return
 	}
-	if !stat.IsDir() {
-		defer func() {
-			if err := rd.(*os.File).Close(); err != nil {
-				log.Errorf("closing source file: %+v", err)
-			}
-		}()
-	}
+	defer rd.Close() // nolint
 
+	w.Header().Set("Content-Type", "application/x-tar")
 	w.WriteHeader(200)
 	if _, err := io.CopyBuffer(w, rd, make([]byte, CopyBuf)); err != nil {
 		log.Errorf("%+v", err)
@@ -131,6 +144,140 @@ func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// remoteListSector returns the FetchManifest of the requested sector file
+func (handler *FetchHandler) remoteListSector(w http.ResponseWriter, r *http.Request) {
+	log.Infof("SERVE LIST %s", r.URL)
+
+	path, ok := handler.acquirePath(w, r)
+	if !ok {
+		return
+	}
+
+	man, err := readManifest(path)
+	if err != nil {
+		log.Errorf("%+v", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(man); err != nil {
+		log.Warnf("error writing list response: %+v", err)
+	}
+}
+
+// remoteGetSectorFile serves a single file from a sector directory (cache)
+func (handler *FetchHandler) remoteGetSectorFile(w http.ResponseWriter, r *http.Request) {
+	log.Infof("SERVE GET %s", r.URL)
+
+	name := mux.Vars(r)["name"]
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		log.Errorf("invalid sector file name '%s'", name)
+		w.WriteHeader(400)
+		return
+	}
+
+	path, ok := handler.acquirePath(w, r)
+	if !ok {
+		return
+	}
+
+	serveChecksummed(w, r, filepath.Join(path, name))
+}
+
+// serveChecksummed serves the requested range (or all) of the file at path,
+// and sends the sha256 of the bytes sent in the ChecksumTrailer trailer
+func serveChecksummed(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0644) // nolint
+	if err != nil {
+		log.Errorf("%+v", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Errorf("closing source file: %+v", err)
+		}
+	}()
+
+	stat, err := f.Stat()
+	if err != nil {
+		log.Errorf("%+v", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	start, end := int64(0), stat.Size()
+	status := 200
+
+	if rh := r.Header.Get("Range"); rh != "" {
+		start, end, err = parseRange(rh, stat.Size())
+		if err != nil {
+			log.Warnf("serving %s: %+v", path, err)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", stat.Size()))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		if _, err := f.Seek(start, io.SeekStart); err != nil {
+			log.Errorf("%+v", err)
+			w.WriteHeader(500)
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, stat.Size()))
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Trailer", ChecksumTrailer)
+	w.WriteHeader(status)
+
+	h := sha256.New()
+	if _, err := io.CopyBuffer(io.MultiWriter(w, h), io.LimitReader(f, end-start), make([]byte, CopyBuf)); err != nil {
+		log.Errorf("%+v", err)
+		return
+	}
+
+	w.Header().Set(ChecksumTrailer, hex.EncodeToString(h.Sum(nil)))
+}
+
+// parseRange parses a single 'bytes=start-[end]' range, returning the
+// [start, end) interval
+func parseRange(rh string, size int64) (int64, int64, error) {
+	spec := strings.TrimPrefix(rh, "bytes=")
+	if spec == rh || strings.Contains(spec, ",") {
+		return 0, 0, xerrors.Errorf("unsupported range '%s'", rh)
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, xerrors.Errorf("malformed range '%s'", rh)
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, xerrors.Errorf("parsing range start: %w", err)
+	}
+
+	end := size
+	if parts[1] != "" {
+		last, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, xerrors.Errorf("parsing range end: %w", err)
+		}
+		if last+1 < end {
+			end = last + 1
+		}
+	}
+
+	if start < 0 || start >= end {
+		return 0, 0, xerrors.Errorf("range '%s' not satisfiable for size %d", rh, size)
+	}
+
+	return start, end, nil
+}
+
 func (handler *FetchHandler) remoteDeleteSector(w http.ResponseWriter, r *http.Request) {
 	log.Infof("SERVE DELETE %s", r.URL)
 	vars := mux.Vars(r)
@@ -28,6 +28,9 @@ type StoragePath struct {
 
 	CanSeal  bool
 	CanStore bool
+
+	Groups []string
+	Tier   string
 }
 
 // LocalStorageMeta [path]/sectorstore.json
@@ -46,6 +49,14 @@ type LocalStorageMeta struct {
 	// MaxStorage specifies the maximum number of bytes to use for sector storage
 	// (0 = unlimited)
 	MaxStorage uint64
+
+	// Groups this path belongs to. When picking a destination for a sector, the
+	// tier mover prefers paths sharing a group with the sector's current path
+	Groups []string
+
+	// Tier tags the path with a storage tier (e.g. "hot" or "cold"). Sectors are
+	// only migrated between tagged paths; empty means the path isn't tiered
+	Tier string
 }
 
 // StorageConfig .lotusstorage/storage.json
@@ -205,6 +216,8 @@ func (st *Local) OpenPath(ctx context.Context, p string) error {
 		MaxStorage: meta.MaxStorage,
 		CanSeal:    meta.CanSeal,
 		CanStore:   meta.CanStore,
+		Groups:     meta.Groups,
+		Tier:       meta.Tier,
 	}, fst)
 	if err != nil {
 		return xerrors.Errorf("declaring storage in index: %w", err)
@@ -269,6 +282,8 @@ func (st *Local) Redeclare(ctx context.Context) error {
 			MaxStorage: meta.MaxStorage,
 			CanSeal:    meta.CanSeal,
 			CanStore:   meta.CanStore,
+			Groups:     meta.Groups,
+			Tier:       meta.Tier,
 		}, fst)
 		if err != nil {
 			return xerrors.Errorf("redeclaring storage in index: %w", err)
@@ -531,6 +546,8 @@ func (st *Local) Local(ctx context.Context) ([]StoragePath, error) {
 			LocalPath: p.local,
 			CanSeal:   si.CanSeal,
 			CanStore:  si.CanStore,
+			Groups:    si.Groups,
+			Tier:      si.Tier,
 		})
 	}
 
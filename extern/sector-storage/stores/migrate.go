@@ -0,0 +1,301 @@
+package stores
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/time/rate"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// MigrateProgress is called with the number of bytes copied so far and the
+// total number of bytes to copy
+type MigrateProgress func(moved, total int64)
+
+type migrateFile struct {
+	fileType storiface.SectorFileType
+	src      ID
+	from, to string
+	size     int64
+}
+
+// MigrateSector moves sector files between two local storage paths. Data is
+// copied while holding a read lock on the sector, so proving can continue
+// reading the old copy; the sector is then switched to the new location under
+// a write lock. lim (optional) throttles the copy.
+func (st *Local) MigrateSector(ctx context.Context, s storage.SectorRef, types storiface.SectorFileType, dest ID, lim *rate.Limiter, progress MigrateProgress) error {
+	st.localLk.RLock()
+	dp, ok := st.paths[dest]
+	st.localLk.RUnlock()
+	if !ok {
+		return xerrors.Errorf("destination path %s is not local", dest)
+	}
+
+	rctx, rcancel := context.WithCancel(ctx)
+	defer rcancel()
+
+	if err := st.index.StorageLock(rctx, s.ID, types, storiface.FTNone); err != nil {
+		return xerrors.Errorf("acquiring sector read lock: %w", err)
+	}
+
+	files, err := st.migrateSources(ctx, s, types, dest, dp)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	stat, err := st.FsStat(ctx, dest)
+	if err != nil {
+		return xerrors.Errorf("getting destination stat: %w", err)
+	}
+	if stat.Available < total {
+		return xerrors.Errorf("not enough space in %s: need %d, have %d", dest, total, stat.Available)
+	}
+
+	st.localLk.Lock()
+	dp.reserved += total
+	st.localLk.Unlock()
+	defer func() {
+		st.localLk.Lock()
+		dp.reserved -= total
+		st.localLk.Unlock()
+	}()
+
+	var moved int64
+	report := func(n int64) {
+		moved += n
+		if progress != nil {
+			progress(moved, total)
+		}
+	}
+
+	temps := make([]string, len(files))
+	for i, f := range files {
+		temps[i], err = tempFetchDest(f.to, true)
+		if err != nil {
+			return err
+		}
+
+		if err := copyThrottled(ctx, f.from, temps[i], lim, report); err != nil {
+			removeAll(temps[:i+1])
+			return xerrors.Errorf("copying %s: %w", f.from, err)
+		}
+	}
+
+	// switch over to the new copy; this waits for readers (e.g. WindowPoSt)
+	// of the old copy to finish
+	rcancel()
+
+	wctx, wcancel := context.WithCancel(ctx)
+	defer wcancel()
+
+	if err := st.index.StorageLock(wctx, s.ID, storiface.FTNone, types); err != nil {
+		removeAll(temps)
+		return xerrors.Errorf("acquiring sector write lock: %w", err)
+	}
+
+	for i, f := range files {
+		si, err := st.index.StorageFindSector(ctx, s.ID, f.fileType, 0, false)
+		if err != nil {
+			removeAll(temps[i:])
+			return xerrors.Errorf("finding sector %v(%d): %w", s.ID, f.fileType, err)
+		}
+
+		var found bool
+		for _, info := range si {
+			found = found || info.ID == f.src
+		}
+		if !found {
+			removeAll(temps[i:])
+			return xerrors.Errorf("sector %v(%d) was removed from %s during migration", s.ID, f.fileType, f.src)
+		}
+
+		if err := os.Rename(temps[i], f.to); err != nil {
+			removeAll(temps[i:])
+			return xerrors.Errorf("moving migrated sector data into place: %w", err)
+		}
+
+		if err := st.index.StorageDeclareSector(ctx, dest, s.ID, f.fileType, true); err != nil {
+			return xerrors.Errorf("declare sector %d(t:%d) -> %s: %w", s.ID, f.fileType, dest, err)
+		}
+
+		if err := st.index.StorageDropSector(ctx, f.src, s.ID, f.fileType); err != nil {
+			return xerrors.Errorf("dropping source sector from index: %w", err)
+		}
+
+		log.Infof("migrated %s -> %s", f.from, f.to)
+
+		if err := os.RemoveAll(f.from); err != nil {
+			log.Errorf("removing migrated sector (%v) from %s: %+v", s.ID, f.from, err)
+		}
+	}
+
+	st.reportStorage(ctx) // report space use changes
+
+	return nil
+}
+
+func (st *Local) migrateSources(ctx context.Context, s storage.SectorRef, types storiface.SectorFileType, dest ID, dp *path) ([]migrateFile, error) {
+	st.localLk.RLock()
+	defer st.localLk.RUnlock()
+
+	var out []migrateFile
+	for _, fileType := range storiface.PathTypes {
+		if fileType&types == 0 {
+			continue
+		}
+
+		si, err := st.index.StorageFindSector(ctx, s.ID, fileType, 0, false)
+		if err != nil {
+			return nil, xerrors.Errorf("finding sector %v(%d): %w", s.ID, fileType, err)
+		}
+
+		var src *path
+		var srcID ID
+		for _, info := range si {
+			if info.ID == dest {
+				src = nil
+				break
+			}
+
+			if p, ok := st.paths[info.ID]; ok && p.local != "" && src == nil {
+				src, srcID = p, info.ID
+			}
+		}
+
+		if src == nil {
+			continue
+		}
+
+		// the copy isn't sparse-aware, so account for the apparent size
+		from := src.sectorPath(s.ID, fileType)
+		size, err := apparentSize(from)
+		if err != nil {
+			return nil, xerrors.Errorf("getting size of %s: %w", from, err)
+		}
+
+		out = append(out, migrateFile{
+			fileType: fileType,
+			src:      srcID,
+			from:     from,
+			to:       dp.sectorPath(s.ID, fileType),
+			size:     size,
+		})
+	}
+
+	return out, nil
+}
+
+func apparentSize(p string) (int64, error) {
+	var size int64
+	err := filepath.Walk(p, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func removeAll(paths []string) {
+	for _, p := range paths {
+		if err := os.RemoveAll(p); err != nil {
+			log.Errorf("removing %s: %+v", p, err)
+		}
+	}
+}
+
+// copyThrottled copies a file or a directory tree from src to dst, waiting on
+// lim (if not nil) before writing each chunk
+func copyThrottled(ctx context.Context, src, dst string, lim *rate.Limiter, progress func(n int64)) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		if err := os.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+
+		ents, err := ioutil.ReadDir(src)
+		if err != nil {
+			return err
+		}
+
+		for _, ent := range ents {
+			if err := copyThrottled(ctx, filepath.Join(src, ent.Name()), filepath.Join(dst, ent.Name()), lim, progress); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() // nolint
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	chunk := CopyBuf
+	if lim != nil && lim.Burst() < chunk {
+		chunk = lim.Burst()
+	}
+	buf := make([]byte, chunk)
+
+	for {
+		n, rerr := in.Read(buf)
+		if n > 0 {
+			if lim != nil {
+				if err := lim.WaitN(ctx, n); err != nil {
+					_ = out.Close()
+					return err
+				}
+			}
+
+			if _, err := out.Write(buf[:n]); err != nil {
+				_ = out.Close()
+				return err
+			}
+
+			progress(int64(n))
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			_ = out.Close()
+			return rerr
+		}
+	}
+
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,93 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestMigrateSector(t *testing.T) {
+	ctx := context.TODO()
+
+	root, err := ioutil.TempDir("", "sector-storage-testmigrate-")
+	require.NoError(t, err)
+	defer os.RemoveAll(root) // nolint
+
+	tstor := &TestingLocalStorage{root: root}
+	index := NewIndex()
+
+	st, err := NewLocal(ctx, tstor, index, nil)
+	require.NoError(t, err)
+
+	for _, p := range []struct {
+		id   ID
+		tier string
+	}{{"hot", "hot"}, {"cold", "cold"}} {
+		dir := filepath.Join(root, string(p.id))
+		require.NoError(t, os.Mkdir(dir, 0755))
+
+		mb, err := json.Marshal(&LocalStorageMeta{
+			ID:       p.id,
+			Weight:   1,
+			CanStore: true,
+			Tier:     p.tier,
+		})
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, MetaFile), mb, 0644))
+
+		require.NoError(t, st.OpenPath(ctx, dir))
+	}
+
+	si, err := index.StorageInfo(ctx, "cold")
+	require.NoError(t, err)
+	require.Equal(t, "cold", si.Tier)
+
+	sector := storage.SectorRef{
+		ID:        abi.SectorID{Miner: 1000, Number: 1},
+		ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1,
+	}
+
+	sealed := filepath.Join(root, "hot", storiface.FTSealed.String(), storiface.SectorName(sector.ID))
+	cache := filepath.Join(root, "hot", storiface.FTCache.String(), storiface.SectorName(sector.ID))
+	require.NoError(t, ioutil.WriteFile(sealed, make([]byte, 4<<10), 0644))
+	require.NoError(t, os.Mkdir(cache, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(cache, "p_aux"), []byte("aux"), 0644))
+
+	require.NoError(t, index.StorageDeclareSector(ctx, "hot", sector.ID, storiface.FTSealed|storiface.FTCache, true))
+
+	var lastMoved, lastTotal int64
+	lim := rate.NewLimiter(rate.Inf, 1<<10)
+	err = st.MigrateSector(ctx, sector, storiface.FTSealed|storiface.FTCache, "cold", lim, func(moved, total int64) {
+		lastMoved, lastTotal = moved, total
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(4<<10+3), lastMoved)
+	require.Equal(t, lastMoved, lastTotal)
+
+	for _, ft := range []storiface.SectorFileType{storiface.FTSealed, storiface.FTCache} {
+		ids, err := index.FindSector(sector.ID, ft)
+		require.NoError(t, err)
+		require.Equal(t, []ID{"cold"}, ids)
+	}
+
+	_, err = os.Stat(sealed)
+	require.True(t, os.IsNotExist(err))
+
+	b, err := ioutil.ReadFile(filepath.Join(root, "cold", storiface.FTCache.String(), storiface.SectorName(sector.ID), "p_aux"))
+	require.NoError(t, err)
+	require.Equal(t, "aux", string(b))
+
+	// migrating again is a no-op
+	require.NoError(t, st.MigrateSector(ctx, sector, storiface.FTSealed|storiface.FTCache, "cold", nil, nil))
+}
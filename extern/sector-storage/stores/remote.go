@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
@@ -23,6 +24,7 @@ import (
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/time/rate"
 	"golang.org/x/xerrors"
 )
 
@@ -35,10 +37,21 @@ type Remote struct {
 	index SectorIndex
 	auth  http.Header
 
-	limit chan struct{}
+	limit     chan struct{}
+	bandwidth *rate.Limiter // nil if unlimited
 
 	fetchLk  sync.Mutex
 	fetching map[abi.SectorID]chan struct{}
+
+	progressLk sync.Mutex
+	progress   map[fetchKey]*fetchTracker
+}
+
+// fetchKey identifies a running fetch, the file types of a sector are
+// fetched separately, and may be fetched at the same time
+type fetchKey struct {
+	sector   abi.SectorID
+	fileType storiface.SectorFileType
 }
 
 func (r *Remote) RemoveCopies(ctx context.Context, s abi.SectorID, types storiface.SectorFileType) error {
@@ -49,15 +62,25 @@ func (r *Remote) RemoveCopies(ctx context.Context, s abi.SectorID, types storifa
 	return r.local.RemoveCopies(ctx, s, types)
 }
 
-func NewRemote(local *Local, index SectorIndex, auth http.Header, fetchLimit int) *Remote {
+// NewRemote creates a sector store fetching missing sector data from remote
+// storage paths. fetchBandwidth limits the total fetch rate in bytes per
+// second, 0 means unlimited.
+func NewRemote(local *Local, index SectorIndex, auth http.Header, fetchLimit int, fetchBandwidth uint64) *Remote {
+	var bw *rate.Limiter
+	if fetchBandwidth > 0 {
+		bw = rate.NewLimiter(rate.Limit(fetchBandwidth), CopyBuf)
+	}
+
 	return &Remote{
 		local: local,
 		index: index,
 		auth:  auth,
 
-		limit: make(chan struct{}, fetchLimit),
+		limit:     make(chan struct{}, fetchLimit),
+		bandwidth: bw,
 
 		fetching: map[abi.SectorID]chan struct{}{},
+		progress: map[fetchKey]*fetchTracker{},
 	}
 }
 
@@ -186,6 +209,24 @@ func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType
 		return si[i].Weight < si[j].Weight
 	})
 
+	t := &fetchTracker{p: storiface.FetchProgress{
+		Sector:   s,
+		FileType: fileType,
+		Start:    time.Now(),
+	}}
+
+	key := fetchKey{sector: s, fileType: fileType}
+
+	r.progressLk.Lock()
+	r.progress[key] = t
+	r.progressLk.Unlock()
+
+	defer func() {
+		r.progressLk.Lock()
+		delete(r.progress, key)
+		r.progressLk.Unlock()
+	}()
+
 	var merr error
 	for _, info := range si {
 		// TODO: see what we have local, prefer that
@@ -200,7 +241,11 @@ func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType
 				return "", xerrors.Errorf("removing dest: %w", err)
 			}
 
-			err = r.fetch(ctx, url, tempDest)
+			t.update(func(p *storiface.FetchProgress) {
+				p.URL = url
+			})
+
+			err = r.fetch(ctx, url, tempDest, t)
 			if err != nil {
 				merr = multierror.Append(merr, xerrors.Errorf("fetch error %s (storage %s) -> %s: %w", url, info.ID, tempDest, err))
 				continue
@@ -220,7 +265,7 @@ func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType
 	return "", xerrors.Errorf("failed to acquire sector %v from remote (tried %v): %w", s, si, merr)
 }
 
-func (r *Remote) fetch(ctx context.Context, url, outname string) error {
+func (r *Remote) fetch(ctx context.Context, url, outname string, t *fetchTracker) error {
 	log.Infof("Fetch %s -> %s", url, outname)
 
 	if len(r.limit) >= cap(r.limit) {
@@ -238,6 +283,16 @@ func (r *Remote) fetch(ctx context.Context, url, outname string) error {
 		return xerrors.Errorf("context error while waiting for fetch limiter: %w", ctx.Err())
 	}
 
+	man, err := r.fetchManifest(ctx, url)
+	switch err {
+	case nil:
+		return r.fetchResumable(ctx, url, outname, man, t)
+	case errNoManifest:
+		// remote runs an older version, fall back to fetching everything at once
+	default:
+		return xerrors.Errorf("fetching manifest: %w", err)
+	}
+
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return xerrors.Errorf("request: %w", err)
@@ -274,15 +329,24 @@ func (r *Remote) fetch(ctx context.Context, url, outname string) error {
 		return xerrors.Errorf("removing dest: %w", err)
 	}
 
+	t.update(func(p *storiface.FetchProgress) {
+		p.Fetched = 0
+		if resp.ContentLength > 0 {
+			p.Size = resp.ContentLength
+		}
+	})
+
+	body := io.TeeReader(r.limitReader(ctx, resp.Body), &progressWriter{t: t})
+
 	switch mediatype {
 	case "application/x-tar":
-		return tarutil.ExtractTar(resp.Body, outname)
+		return tarutil.ExtractTar(body, outname)
 	case "application/octet-stream":
 		f, err := os.Create(outname)
 		if err != nil {
 			return err
 		}
-		_, err = io.CopyBuffer(f, resp.Body, make([]byte, CopyBuf))
+		_, err = io.CopyBuffer(f, body, make([]byte, CopyBuf))
 		if err != nil {
 			f.Close() // nolint
 			return err
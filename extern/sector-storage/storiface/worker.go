@@ -11,6 +11,7 @@ import (
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
@@ -30,11 +31,29 @@ type WorkerResources struct {
 
 	CPUs uint64 // Logical cores
 	GPUs []string
+
+	// Per task type overrides of the default resource requirements, and
+	// concurrency limits
+	Tasks map[sealtasks.TaskType]TaskResources `json:",omitempty"`
+}
+
+// TaskResources overrides the default resource requirements of a task type on
+// a worker; unset fields keep the default for the sector's seal proof
+type TaskResources struct {
+	MinMemory      *uint64 `json:",omitempty"`
+	MaxMemory      *uint64 `json:",omitempty"`
+	MaxParallelism *int    `json:",omitempty"`
+	CanGPU         *bool   `json:",omitempty"`
+	BaseMinMemory  *uint64 `json:",omitempty"`
+
+	// Maximum number of tasks of this type running at once, 0 for no limit
+	MaxConcurrent int `json:",omitempty"`
 }
 
 type WorkerStats struct {
 	Info    WorkerInfo
 	Enabled bool
+	Tasks   []sealtasks.TaskType `json:",omitempty"` // task types the worker accepts, empty if not known
 
 	MemUsedMin uint64
 	MemUsedMax uint64
@@ -62,6 +81,28 @@ type WorkerJob struct {
 	Start   time.Time
 
 	Hostname string `json:",omitempty"` // optional, set for ret-wait jobs
+
+	Fetch *FetchProgress `json:",omitempty"` // optional, set when the worker is fetching sector data
+}
+
+// FetchProgress describes a sector file transfer from a remote storage path
+type FetchProgress struct {
+	Sector   abi.SectorID
+	FileType SectorFileType
+	URL      string
+
+	Fetched int64 // bytes, including data kept from interrupted attempts
+	Size    int64 // bytes, 0 if not known yet
+	Resumed int   // number of times the transfer was resumed
+
+	Start time.Time
+}
+
+// WindowPoStResult is the outcome of a WindowPoSt computed on a worker
+type WindowPoStResult struct {
+	PoStProofs []proof2.PoStProof
+	// Sectors which couldn't be read; no proofs are generated when set
+	Skipped []abi.SectorID
 }
 
 type CallID struct {
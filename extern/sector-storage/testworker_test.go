@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 	"github.com/google/uuid"
 
@@ -116,6 +117,22 @@ func (t *testWorker) Info(ctx context.Context) (storiface.WorkerInfo, error) {
 	}, nil
 }
 
+func (t *testWorker) FetchProgress(context.Context) ([]storiface.FetchProgress, error) {
+	return nil, nil
+}
+
+func (t *testWorker) GenerateWindowPoSt(ctx context.Context, mid abi.ActorID, sectors []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.WindowPoStResult, error) {
+	proofs, skipped, err := t.mockSeal.GenerateWindowPoSt(ctx, mid, sectors, randomness)
+	if len(skipped) > 0 {
+		return storiface.WindowPoStResult{Skipped: skipped}, nil
+	}
+	if err != nil {
+		return storiface.WindowPoStResult{}, err
+	}
+
+	return storiface.WindowPoStResult{PoStProofs: proofs}, nil
+}
+
 func (t *testWorker) Session(context.Context) (uuid.UUID, error) {
 	return t.session, nil
 }
@@ -20,6 +20,7 @@ import (
 	ffi "github.com/filecoin-project/filecoin-ffi"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-statestore"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	storage "github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
@@ -33,6 +34,10 @@ var pathTypes = []storiface.SectorFileType{storiface.FTUnsealed, storiface.FTSea
 type WorkerConfig struct {
 	TaskTypes []sealtasks.TaskType
 	NoSwap    bool
+
+	// TaskResources overrides the default resource requirements of task
+	// types, see storiface.TaskResources
+	TaskResources map[sealtasks.TaskType]storiface.TaskResources
 }
 
 // used do provide custom proofs impl (mostly used in testing)
@@ -44,7 +49,9 @@ type LocalWorker struct {
 	sindex     stores.SectorIndex
 	ret        storiface.WorkerReturn
 	executor   ExecutorFunc
+	postExec   ExecutorFunc
 	noSwap     bool
+	taskRes    map[sealtasks.TaskType]storiface.TaskResources
 
 	ct          *workerCallTracker
 	acceptTasks map[sealtasks.TaskType]struct{}
@@ -74,6 +81,7 @@ func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store
 		acceptTasks: acceptTasks,
 		executor:    executor,
 		noSwap:      wcfg.NoSwap,
+		taskRes:     wcfg.TaskResources,
 
 		session: uuid.New(),
 		closing: make(chan struct{}),
@@ -81,6 +89,9 @@ func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store
 
 	if w.executor == nil {
 		w.executor = w.ffiExec
+		w.postExec = w.ffiPostExec
+	} else {
+		w.postExec = executor
 	}
 
 	unfinished, err := w.ct.unfinished()
@@ -149,6 +160,32 @@ func (l *LocalWorker) ffiExec() (ffiwrapper.Storage, error) {
 	return ffiwrapper.New(&localWorkerPathProvider{w: l})
 }
 
+// postPathProvider gives read access to sectors in worker-local storage, and
+// never fetches sector data
+type postPathProvider struct {
+	readonlyProvider
+}
+
+func (p *postPathProvider) AcquireSector(ctx context.Context, id storage.SectorRef, existing storiface.SectorFileType, allocate storiface.SectorFileType, sealing storiface.PathType) (storiface.SectorPaths, func(), error) {
+	paths, done, err := p.readonlyProvider.AcquireSector(ctx, id, existing, allocate, sealing)
+	if err != nil {
+		return storiface.SectorPaths{}, nil, err
+	}
+
+	for _, fileType := range pathTypes {
+		if fileType&existing != 0 && storiface.PathByType(paths, fileType) == "" {
+			done()
+			return storiface.SectorPaths{}, nil, xerrors.Errorf("sector %s file not found in local storage", fileType)
+		}
+	}
+
+	return paths, done, nil
+}
+
+func (l *LocalWorker) ffiPostExec() (ffiwrapper.Storage, error) {
+	return ffiwrapper.New(&postPathProvider{readonlyProvider{index: l.sindex, stor: l.localStore}})
+}
+
 type ReturnType string
 
 const (
@@ -518,10 +555,43 @@ func (l *LocalWorker) Info(context.Context) (storiface.WorkerInfo, error) {
 			MemReserved: mem.VirtualUsed + mem.Total - mem.Available, // TODO: sub this process
 			CPUs:        uint64(runtime.NumCPU()),
 			GPUs:        gpus,
+			Tasks:       l.taskRes,
 		},
 	}, nil
 }
 
+func (l *LocalWorker) GenerateWindowPoSt(ctx context.Context, mid abi.ActorID, sectors []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.WindowPoStResult, error) {
+	sb, err := l.postExec()
+	if err != nil {
+		return storiface.WindowPoStResult{}, err
+	}
+
+	start := time.Now()
+
+	proofs, skipped, err := sb.GenerateWindowPoSt(ctx, mid, sectors, randomness)
+	if len(skipped) > 0 {
+		log.Warnw("window post skipped sectors", "skipped", len(skipped), "error", err)
+		return storiface.WindowPoStResult{Skipped: skipped}, nil
+	}
+	if err != nil {
+		return storiface.WindowPoStResult{}, xerrors.Errorf("generating window post: %w", err)
+	}
+
+	log.Infow("computed window post", "sectors", len(sectors), "took", time.Since(start))
+
+	return storiface.WindowPoStResult{PoStProofs: proofs}, nil
+}
+
+// FetchProgress returns the state of sector fetches this worker is running
+func (l *LocalWorker) FetchProgress(context.Context) ([]storiface.FetchProgress, error) {
+	rs, ok := l.storage.(*stores.Remote)
+	if !ok {
+		return nil, nil
+	}
+
+	return rs.FetchProgress(), nil
+}
+
 func (l *LocalWorker) Session(ctx context.Context) (uuid.UUID, error) {
 	if atomic.LoadInt64(&l.testDisable) == 1 {
 		return uuid.UUID{}, xerrors.Errorf("disabled")
@@ -0,0 +1,282 @@
+package miner_test
+
+import (
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestBeneficiary(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	nominee := tutil.NewIDAddr(t, 2000)
+	other := tutil.NewIDAddr(t, 2001)
+	quota := abi.NewTokenAmount(1e18)
+	expiration := abi.ChainEpoch(1000)
+
+	// setBeneficiary proposes the nominee as the owner, and approves it as the nominee
+	setBeneficiary := func(t *testing.T, rt *mock.Runtime) {
+		actor.changeBeneficiary(rt, actor.owner, nominee, quota, expiration)
+		actor.changeBeneficiary(rt, nominee, nominee, quota, expiration)
+
+		info := actor.getInfo(rt)
+		require.Equal(t, nominee, info.Beneficiary)
+		require.Nil(t, info.PendingBeneficiaryTerm)
+	}
+
+	t.Run("owner is the beneficiary of a new miner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Beneficiary)
+		assert.True(t, info.BeneficiaryTerm.Quota.IsZero())
+		assert.True(t, info.BeneficiaryTerm.UsedQuota.IsZero())
+		assert.Nil(t, info.PendingBeneficiaryTerm)
+		actor.checkState(rt)
+	})
+
+	t.Run("nominee approval applies a change from the owner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		actor.changeBeneficiary(rt, actor.owner, nominee, quota, expiration)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Beneficiary)
+		require.NotNil(t, info.PendingBeneficiaryTerm)
+		assert.Equal(t, nominee, info.PendingBeneficiaryTerm.NewBeneficiary)
+		assert.True(t, info.PendingBeneficiaryTerm.ApprovedByBeneficiary)
+		assert.False(t, info.PendingBeneficiaryTerm.ApprovedByNominee)
+
+		actor.changeBeneficiary(rt, nominee, nominee, quota, expiration)
+
+		info = actor.getInfo(rt)
+		assert.Equal(t, nominee, info.Beneficiary)
+		assert.Equal(t, miner.BeneficiaryTerm{Quota: quota, UsedQuota: big.Zero(), Expiration: expiration}, info.BeneficiaryTerm)
+		assert.Nil(t, info.PendingBeneficiaryTerm)
+		actor.checkState(rt)
+	})
+
+	t.Run("invalid proposals are rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be positive", func() {
+			actor.changeBeneficiary(rt, actor.owner, nominee, big.Zero(), expiration)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be in the future", func() {
+			actor.changeBeneficiary(rt, actor.owner, nominee, quota, rt.Epoch())
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "owner beneficiary", func() {
+			actor.changeBeneficiary(rt, actor.owner, actor.owner, quota, expiration)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("only the beneficiary and the nominee approve the proposal", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "no pending beneficiary change", func() {
+			actor.changeBeneficiary(rt, nominee, nominee, quota, expiration)
+		})
+
+		actor.changeBeneficiary(rt, actor.owner, nominee, quota, expiration)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "neither the beneficiary nor the nominee", func() {
+			actor.changeBeneficiary(rt, other, nominee, quota, expiration)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "expected approval", func() {
+			actor.changeBeneficiary(rt, nominee, nominee, big.Add(quota, big.NewInt(1)), expiration)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("active beneficiary must approve its replacement", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(t, rt)
+
+		actor.changeBeneficiary(rt, actor.owner, other, quota, expiration)
+		actor.changeBeneficiary(rt, other, other, quota, expiration)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, nominee, info.Beneficiary)
+		require.NotNil(t, info.PendingBeneficiaryTerm)
+		assert.True(t, info.PendingBeneficiaryTerm.ApprovedByNominee)
+		assert.False(t, info.PendingBeneficiaryTerm.ApprovedByBeneficiary)
+
+		actor.changeBeneficiary(rt, nominee, other, quota, expiration)
+
+		info = actor.getInfo(rt)
+		assert.Equal(t, other, info.Beneficiary)
+		assert.Nil(t, info.PendingBeneficiaryTerm)
+		actor.checkState(rt)
+	})
+
+	t.Run("expired beneficiary doesn't need to approve reverting to the owner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(t, rt)
+
+		rt.SetEpoch(expiration)
+		actor.changeBeneficiary(rt, actor.owner, actor.owner, big.Zero(), 0)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Beneficiary)
+		assert.Nil(t, info.PendingBeneficiaryTerm)
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary withdraws up to its quota", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(t, rt)
+
+		half := big.Div(quota, big.NewInt(2))
+		actor.withdrawFundsBy(rt, nominee, half, half, big.Zero())
+		// the owner's withdrawals are sent to the beneficiary, and count against its quota
+		actor.withdrawFundsBy(rt, actor.owner, quota, half, big.Zero())
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, quota, info.BeneficiaryTerm.UsedQuota)
+
+		actor.withdrawFundsBy(rt, nominee, quota, big.Zero(), big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary can't withdraw after expiration", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(t, rt)
+
+		rt.SetEpoch(expiration)
+		actor.withdrawFundsBy(rt, nominee, quota, big.Zero(), big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("others can't withdraw", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(t, rt)
+
+		rt.SetCaller(other, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner, nominee)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.WithdrawBalance, &miner.WithdrawBalanceParams{AmountRequested: quota})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("owner withdraws the pos deposits", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.addPosDeposits(rt, quota)
+
+		actor.withdrawPos(rt, actor.owner, quota, quota)
+		assert.True(t, getState(rt).PosDeposits.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("others can't withdraw the pos deposits", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.addPosDeposits(rt, quota)
+
+		rt.SetCaller(other, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner, actor.owner)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.WithdrawPos, &miner.WithdrawBalanceParams{AmountRequested: quota})
+		})
+		assert.Equal(t, quota, getState(rt).PosDeposits)
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary receives the pos deposits up to its quota", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.addPosDeposits(rt, big.Mul(quota, big.NewInt(2)))
+		posExpiration := rt.Epoch() + expiration
+		actor.changeBeneficiary(rt, actor.owner, nominee, quota, posExpiration)
+		actor.changeBeneficiary(rt, nominee, nominee, quota, posExpiration)
+
+		// the owner's withdrawals are sent to the beneficiary too
+		actor.withdrawPos(rt, actor.owner, big.Mul(quota, big.NewInt(2)), quota)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, quota, info.BeneficiaryTerm.UsedQuota)
+		assert.Equal(t, quota, getState(rt).PosDeposits)
+
+		rt.SetCaller(other, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner, nominee)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.WithdrawPos, &miner.WithdrawBalanceParams{AmountRequested: quota})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("owner beneficiary follows a change of owner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		newOwner := tutil.NewIDAddr(t, 1001)
+
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newOwner)
+		rt.SetCaller(newOwner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newOwner)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, newOwner, info.Owner)
+		assert.Equal(t, newOwner, info.Beneficiary)
+		actor.checkState(rt)
+	})
+}
+
+func (h *actorHarness) changeBeneficiary(rt *mock.Runtime, caller, beneficiary addr.Address, quota abi.TokenAmount, expiration abi.ChainEpoch) {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	rt.Call(h.a.ChangeBeneficiary, &miner.ChangeBeneficiaryParams{
+		NewBeneficiary: beneficiary,
+		NewQuota:       quota,
+		NewExpiration:  expiration,
+	})
+	rt.Verify()
+}
+
+// addPosDeposits locks deposits in the state, as AddPos does, and moves past their vesting period.
+func (h *actorHarness) addPosDeposits(rt *mock.Runtime, amount abi.TokenAmount) {
+	st := getState(rt)
+	_, err := st.AddPosLockedFunds(rt.AdtStore(), rt.Epoch(), amount)
+	require.NoError(h.t, err)
+	rt.ReplaceState(st)
+	rt.SetEpoch(rt.Epoch() + miner.PosVestPeriod + 1)
+}
+
+func (h *actorHarness) withdrawPos(rt *mock.Runtime, caller addr.Address, amountRequested, amountWithdrawn abi.TokenAmount) {
+	info := h.getInfo(rt)
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(info.Owner, info.Beneficiary)
+
+	rt.ExpectSend(info.Beneficiary, builtin.MethodSend, nil, amountWithdrawn, nil, exitcode.Ok)
+	posDelta := amountWithdrawn.Neg()
+	rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePosTotal, &posDelta, big.Zero(), nil, exitcode.Ok)
+
+	rt.Call(h.a.WithdrawPos, &miner.WithdrawBalanceParams{
+		AmountRequested: amountRequested,
+	})
+	rt.Verify()
+}
@@ -0,0 +1,5 @@
+package multisig
+
+// SignersMax is the maximum number of signers allowed in a multisig. If more
+// are required, please use a combining tree of multisigs.
+const SignersMax = 256
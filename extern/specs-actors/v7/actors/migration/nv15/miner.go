@@ -0,0 +1,85 @@
+package nv15
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-state-types/big"
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+)
+
+type minerMigrator struct{}
+
+func (m minerMigrator) migrateState(ctx context.Context, store cbor.IpldStore, in actorMigrationInput) (*actorMigrationResult, error) {
+	var inState miner6.State
+	if err := store.Get(ctx, in.head, &inState); err != nil {
+		return nil, err
+	}
+
+	var inInfo miner6.MinerInfo
+	if err := store.Get(ctx, inState.Info, &inInfo); err != nil {
+		return nil, err
+	}
+
+	// The owner is the beneficiary of every existing miner, without a quota.
+	outInfo := miner7.MinerInfo{
+		Owner:                      inInfo.Owner,
+		Worker:                     inInfo.Worker,
+		ControlAddresses:           inInfo.ControlAddresses,
+		PendingWorkerKey:           (*miner7.WorkerKeyChange)(inInfo.PendingWorkerKey),
+		PeerId:                     inInfo.PeerId,
+		Multiaddrs:                 inInfo.Multiaddrs,
+		WindowPoStProofType:        inInfo.WindowPoStProofType,
+		SectorSize:                 inInfo.SectorSize,
+		WindowPoStPartitionSectors: inInfo.WindowPoStPartitionSectors,
+		ConsensusFaultElapsed:      inInfo.ConsensusFaultElapsed,
+		PendingOwnerAddress:        inInfo.PendingOwnerAddress,
+		Beneficiary:                inInfo.Owner,
+		BeneficiaryTerm: miner7.BeneficiaryTerm{
+			Quota:      big.Zero(),
+			UsedQuota:  big.Zero(),
+			Expiration: 0,
+		},
+		PendingBeneficiaryTerm: nil,
+	}
+	outInfoCid, err := store.Put(ctx, &outInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	outState := miner7.State{
+		Info:                      outInfoCid,
+		PreCommitDeposits:         inState.PreCommitDeposits,
+		LockedFunds:               inState.LockedFunds,
+		VestingFunds:              inState.VestingFunds,
+		FeeDebt:                   inState.FeeDebt,
+		InitialPledge:             inState.InitialPledge,
+		PreCommittedSectors:       inState.PreCommittedSectors,
+		PreCommittedSectorsExpiry: inState.PreCommittedSectorsExpiry,
+		AllocatedSectors:          inState.AllocatedSectors,
+		Sectors:                   inState.Sectors,
+		ProvingPeriodStart:        inState.ProvingPeriodStart,
+		CurrentDeadline:           inState.CurrentDeadline,
+		Deadlines:                 inState.Deadlines,
+		EarlyTerminations:         inState.EarlyTerminations,
+		PosDeposits:               inState.PosDeposits,
+		PosVestingFunds:           inState.PosVestingFunds,
+		EmptyPreCommitSectors:     inState.EmptyPreCommitSectors,
+		EmptyCommitSectors:        inState.EmptyCommitSectors,
+		TotalSectorSize:           inState.TotalSectorSize,
+		DeadlineCronActive:        inState.DeadlineCronActive,
+	}
+	newHead, err := store.Put(ctx, &outState)
+	return &actorMigrationResult{
+		newCodeCID: m.migratedCodeCID(),
+		newHead:    newHead,
+	}, err
+}
+
+func (m minerMigrator) migratedCodeCID() cid.Cid {
+	return builtin7.StorageMinerActorCodeID
+}
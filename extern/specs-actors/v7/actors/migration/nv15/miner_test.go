@@ -0,0 +1,79 @@
+package nv15_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestMinerBeneficiaryMigration(t *testing.T) {
+	ctx := context.Background()
+	store := ipld.NewADTStore(ctx)
+	log := nv15.TestLogger{TB: t}
+
+	owner := tutil.NewIDAddr(t, 100)
+	worker := tutil.NewIDAddr(t, 101)
+	minerAddr := tutil.NewIDAddr(t, 1000)
+
+	info, err := miner6.ConstructMinerInfo(owner, worker, nil, []byte("peer"), nil, abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+	require.NoError(t, err)
+	infoCid, err := store.Put(ctx, info)
+	require.NoError(t, err)
+	st, err := miner6.ConstructState(store, infoCid, 0, 0)
+	require.NoError(t, err)
+	st.PosDeposits = abi.NewTokenAmount(42)
+	head, err := store.Put(ctx, st)
+	require.NoError(t, err)
+
+	actorsIn, err := states6.NewTree(store)
+	require.NoError(t, err)
+	balance := abi.NewTokenAmount(1e18)
+	require.NoError(t, actorsIn.SetActor(minerAddr, &states6.Actor{
+		Code:    builtin6.StorageMinerActorCodeID,
+		Head:    head,
+		Balance: balance,
+	}))
+	rootIn, err := actorsIn.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, 0, nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	actorsOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := actorsOut.GetActor(minerAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin7.StorageMinerActorCodeID, actor.Code)
+	assert.Equal(t, balance, actor.Balance)
+
+	var outState miner7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &outState))
+	assert.Equal(t, st.PosDeposits, outState.PosDeposits)
+	assert.Equal(t, st.Deadlines, outState.Deadlines)
+
+	var outInfo miner7.MinerInfo
+	require.NoError(t, store.Get(ctx, outState.Info, &outInfo))
+	assert.Equal(t, owner, outInfo.Owner)
+	assert.Equal(t, worker, outInfo.Worker)
+	assert.Equal(t, info.SectorSize, outInfo.SectorSize)
+	assert.Equal(t, owner, outInfo.Beneficiary)
+	assert.True(t, outInfo.BeneficiaryTerm.Quota.Equals(big.Zero()))
+	assert.True(t, outInfo.BeneficiaryTerm.UsedQuota.Equals(big.Zero()))
+	assert.Nil(t, outInfo.PendingBeneficiaryTerm)
+}
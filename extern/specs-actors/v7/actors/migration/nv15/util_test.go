@@ -0,0 +1,36 @@
+package nv15_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	atesting "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestMemMigrationCache(t *testing.T) {
+	cache := nv15.NewMemMigrationCache()
+	cid1 := atesting.MakeCID("foo", nil)
+	cid2 := atesting.MakeCID("bar", nil)
+	require.NoError(t, cache.Write("first", cid1))
+
+	found, result, err := cache.Read("first")
+	require.True(t, found)
+	require.NoError(t, err)
+	require.Equal(t, cid1, result)
+
+	found, _, err = cache.Read("other")
+	require.False(t, found)
+	require.NoError(t, err)
+
+	newCache := cache.Clone()
+	require.NoError(t, newCache.Write("second", cid2))
+	found, _, err = cache.Read("second")
+	require.False(t, found)
+	require.NoError(t, err)
+	found, result, err = newCache.Read("first")
+	require.True(t, found)
+	require.NoError(t, err)
+	require.Equal(t, cid1, result)
+}
@@ -48,7 +48,8 @@ require (
 	github.com/filecoin-project/specs-actors/v3 v3.1.0
 	github.com/filecoin-project/specs-actors/v4 v4.0.0
 	github.com/filecoin-project/specs-actors/v5 v5.0.0
-	github.com/filecoin-project/specs-actors/v6 v6.0.1 // indirect
+	github.com/filecoin-project/specs-actors/v6 v6.0.1
+	github.com/filecoin-project/specs-actors/v7 v7.0.0
 	github.com/filecoin-project/specs-storage v0.1.1-0.20201105051918-5188d9774506
 	github.com/filecoin-project/test-vectors/schema v0.0.5
 	github.com/gbrlsnchs/jwt/v3 v3.0.0-beta.1
@@ -148,6 +149,7 @@ require (
 	go.uber.org/fx v1.9.0
 	go.uber.org/multierr v1.6.0
 	go.uber.org/zap v1.16.0
+	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 	golang.org/x/net v0.0.0-20201022231255-08b38378de70
 	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a
 	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
@@ -181,4 +183,6 @@ replace github.com/filecoin-project/specs-actors/v5 => ./extern/specs-actors/v5
 
 replace github.com/filecoin-project/specs-actors/v6 => ./extern/specs-actors/v6
 
+replace github.com/filecoin-project/specs-actors/v7 => ./extern/specs-actors/v7
+
 replace github.com/filecoin-project/go-address => ./extern/go-address
@@ -1,10 +1,14 @@
 package journal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"golang.org/x/xerrors"
 
@@ -14,15 +18,35 @@ import (
 
 const RFC3339nocolon = "2006-01-02T150405Z0700"
 
-// fsJournal is a basic journal backed by files on a filesystem.
+// journal files are rolled at least this often, so that retention can remove
+// old entries from journals which don't grow quickly
+const maxFileAge = 24 * time.Hour
+
+const subscriberBuffer = 128
+
+// fsJournal is a basic journal backed by files on a filesystem. It keeps an
+// index of the event types and time range in each file, used to answer
+// queries without reading unrelated files.
 type fsJournal struct {
 	EventTypeRegistry
 
 	dir       string
 	sizeLimit int64
+	retention Retention
+
+	fi      *os.File
+	fOpened time.Time
+
+	lk     sync.Mutex
+	index  map[string]*fileSummary // finished files
+	active *fileSummary            // file being written
 
-	fi    *os.File
-	fSize int64
+	// serializes indexing of files missing from the index
+	scanLk sync.Mutex
+
+	subLk   sync.Mutex
+	subs    map[uint64]*subscriber
+	nextSub uint64
 
 	incoming chan *Event
 
@@ -30,18 +54,39 @@ type fsJournal struct {
 	closed  chan struct{}
 }
 
+type subscriber struct {
+	q  Query
+	ch chan RecordedEvent
+}
+
+var _ Reader = (*fsJournal)(nil)
+
 // OpenFSJournal constructs a rolling filesystem journal, with a default
-// per-file size limit of 1GiB.
+// per-file size limit of 1GiB and no retention limits.
 func OpenFSJournal(lr repo.LockedRepo, disabled DisabledEvents) (Journal, error) {
+	return OpenFSJournalWithRetention(lr, disabled, Retention{})
+}
+
+// OpenFSJournalWithRetention constructs a rolling filesystem journal which
+// removes the oldest files to stay within the retention limits.
+func OpenFSJournalWithRetention(lr repo.LockedRepo, disabled DisabledEvents, retention Retention) (Journal, error) {
 	dir := filepath.Join(lr.Path(), "journal")
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to mk directory %s for file journal: %w", dir, err)
 	}
 
+	index, err := loadIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	f := &fsJournal{
 		EventTypeRegistry: NewEventTypeRegistry(disabled),
 		dir:               dir,
 		sizeLimit:         1 << 30,
+		retention:         retention,
+		index:             index,
+		subs:              map[uint64]*subscriber{},
 		incoming:          make(chan *Event, 32),
 		closing:           make(chan struct{}),
 		closed:            make(chan struct{}),
@@ -51,6 +96,15 @@ func OpenFSJournal(lr repo.LockedRepo, disabled DisabledEvents) (Journal, error)
 		return nil, err
 	}
 
+	go func() {
+		// files written by older versions, or by a process which crashed,
+		// need to be scanned before retention can be applied to them
+		if err := f.indexMissing(context.TODO()); err != nil {
+			log.Errorw("indexing journal files", "error", err)
+		}
+		f.prune()
+	}()
+
 	go f.runLoop()
 
 	return f, nil
@@ -95,10 +149,18 @@ func (f *fsJournal) putEvent(evt *Event) error {
 		return err
 	}
 
-	f.fSize += int64(n)
+	f.lk.Lock()
+	f.active.add(evt.System, evt.Event, evt.Timestamp, int64(n))
+	fSize := f.active.Size
+	f.lk.Unlock()
+
+	f.publish(b)
 
-	if f.fSize >= f.sizeLimit {
-		_ = f.rollJournalFile()
+	if fSize >= f.sizeLimit {
+		if err := f.rollJournalFile(); err != nil {
+			log.Errorw("rolling journal file", "error", err)
+		}
+		f.prune()
 	}
 
 	return nil
@@ -109,27 +171,249 @@ func (f *fsJournal) rollJournalFile() error {
 		_ = f.fi.Close()
 	}
 
-	nfi, err := os.Create(filepath.Join(f.dir, fmt.Sprintf("lotus-journal-%s.ndjson", build.Clock.Now().Format(RFC3339nocolon))))
+	now := build.Clock.Now()
+	name := fmt.Sprintf("%s%s%s", journalFilePrefix, now.Format(RFC3339nocolon), journalFileSuffix)
+
+	nfi, err := os.Create(filepath.Join(f.dir, name))
 	if err != nil {
 		return xerrors.Errorf("failed to open journal file: %w", err)
 	}
 
 	f.fi = nfi
-	f.fSize = 0
-	return nil
+	f.fOpened = now
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	if f.active != nil {
+		f.index[f.active.Name] = f.active
+	}
+	f.active = newFileSummary(name)
+	delete(f.index, name) // in case a file was truncated by os.Create
+
+	return saveIndex(f.dir, f.index)
+}
+
+// prune removes the oldest journal files which are outside the retention
+// limits.
+func (f *fsJournal) prune() {
+	if f.retention.MaxAge <= 0 && f.retention.MaxSize <= 0 {
+		return
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	files := make([]*fileSummary, 0, len(f.index))
+	for _, s := range f.index {
+		files = append(files, s)
+	}
+	sortSummaries(files)
+
+	expired := expiredFiles(files, f.active.Size, f.retention, build.Clock.Now())
+	if len(expired) == 0 {
+		return
+	}
+
+	for _, s := range expired {
+		if err := os.Remove(filepath.Join(f.dir, s.Name)); err != nil && !os.IsNotExist(err) {
+			log.Errorw("removing expired journal file", "file", s.Name, "error", err)
+			continue
+		}
+
+		delete(f.index, s.Name)
+	}
+
+	if err := saveIndex(f.dir, f.index); err != nil {
+		log.Errorw("saving journal index", "error", err)
+	}
+}
+
+// indexMissing adds the journal files which aren't in the index.
+func (f *fsJournal) indexMissing(ctx context.Context) error {
+	f.scanLk.Lock()
+	defer f.scanLk.Unlock()
+
+	ents, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return xerrors.Errorf("listing journal files: %w", err)
+	}
+
+	var missing []string
+	f.lk.Lock()
+	for _, ent := range ents {
+		name := ent.Name()
+		if _, ok := f.index[name]; ok || !isJournalFile(name) || name == f.active.Name {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	f.lk.Unlock()
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for _, name := range missing {
+		log.Infow("indexing journal file", "file", name)
+
+		s, err := summarizeFile(ctx, f.dir, name)
+		if err != nil {
+			return xerrors.Errorf("indexing journal file %s: %w", name, err)
+		}
+
+		f.lk.Lock()
+		f.index[name] = s
+		f.lk.Unlock()
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return saveIndex(f.dir, f.index)
+}
+
+func (f *fsJournal) Query(ctx context.Context, q Query) ([]RecordedEvent, error) {
+	if err := f.indexMissing(ctx); err != nil {
+		return nil, err
+	}
+
+	f.lk.Lock()
+	var files []*fileSummary
+	for _, s := range f.index {
+		if s.mayMatch(q) {
+			files = append(files, s)
+		}
+	}
+	if f.active.mayMatch(q) {
+		files = append(files, f.active)
+	}
+	sortSummaries(files)
+
+	names := make([]string, len(files))
+	for i, s := range files {
+		names[i] = s.Name
+	}
+	f.lk.Unlock()
+
+	var out []RecordedEvent
+	for _, name := range names {
+		err := readEvents(ctx, filepath.Join(f.dir, name), func(e *RecordedEvent, _ int64) error {
+			if !q.matches(e) {
+				return nil
+			}
+			out = append(out, *e)
+			if q.Limit > 0 && len(out) >= 2*q.Limit {
+				// drop the oldest events, keeping memory bounded
+				out = append(out[:0], out[len(out)-q.Limit:]...)
+			}
+			return nil
+		})
+		if os.IsNotExist(err) {
+			continue // removed by retention in the meantime
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("reading journal file %s: %w", name, err)
+		}
+	}
+
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out, nil
+}
+
+func (f *fsJournal) Subscribe(ctx context.Context, q Query) (<-chan RecordedEvent, error) {
+	sub := &subscriber{
+		q:  Query{System: q.System, Event: q.Event},
+		ch: make(chan RecordedEvent, subscriberBuffer),
+	}
+
+	f.subLk.Lock()
+	select {
+	case <-f.closing:
+		f.subLk.Unlock()
+		return nil, xerrors.New("journal closed")
+	default:
+	}
+	id := f.nextSub
+	f.nextSub++
+	f.subs[id] = sub
+	f.subLk.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-f.closing:
+		}
+
+		f.subLk.Lock()
+		defer f.subLk.Unlock()
+		if _, ok := f.subs[id]; ok {
+			delete(f.subs, id)
+			close(sub.ch)
+		}
+	}()
+
+	return sub.ch, nil
+}
+
+// publish sends a serialized event to the matching subscribers
+func (f *fsJournal) publish(b []byte) {
+	f.subLk.Lock()
+	defer f.subLk.Unlock()
+
+	if len(f.subs) == 0 {
+		return
+	}
+
+	var e RecordedEvent
+	if err := json.Unmarshal(b, &e); err != nil {
+		log.Errorw("decoding journal event for subscribers", "error", err)
+		return
+	}
+
+	for _, sub := range f.subs {
+		if !sub.q.matches(&e) {
+			continue
+		}
+
+		select {
+		case sub.ch <- e:
+		default:
+			log.Warnw("journal subscriber too slow, dropping event", "event", e.System+":"+e.Event)
+		}
+	}
 }
 
 func (f *fsJournal) runLoop() {
 	defer close(f.closed)
 
+	tick := build.Clock.Ticker(time.Hour)
+	defer tick.Stop()
+
 	for {
 		select {
 		case je := <-f.incoming:
 			if err := f.putEvent(je); err != nil {
 				log.Errorw("failed to write out journal event", "event", je, "err", err)
 			}
+		case <-tick.C:
+			if build.Clock.Since(f.fOpened) > maxFileAge {
+				if err := f.rollJournalFile(); err != nil {
+					log.Errorw("rolling journal file", "error", err)
+				}
+			}
+			f.prune()
 		case <-f.closing:
 			_ = f.fi.Close()
+
+			f.lk.Lock()
+			f.index[f.active.Name] = f.active
+			if err := saveIndex(f.dir, f.index); err != nil {
+				log.Errorw("saving journal index", "error", err)
+			}
+			f.lk.Unlock()
+
 			return
 		}
 	}
@@ -0,0 +1,211 @@
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	journalFilePrefix = "lotus-journal-"
+	journalFileSuffix = ".ndjson"
+
+	indexFileName = "index.json"
+)
+
+// fileSummary describes the contents of a journal file, so that queries only
+// need to read the files which can contain matching entries.
+type fileSummary struct {
+	Name  string
+	Size  int64
+	First time.Time
+	Last  time.Time
+
+	// Events counts the entries in the file by event type (system:event)
+	Events map[string]uint64
+}
+
+func newFileSummary(name string) *fileSummary {
+	return &fileSummary{
+		Name:   name,
+		Events: map[string]uint64{},
+	}
+}
+
+func (s *fileSummary) add(system, event string, ts time.Time, size int64) {
+	if s.First.IsZero() || ts.Before(s.First) {
+		s.First = ts
+	}
+	if ts.After(s.Last) {
+		s.Last = ts
+	}
+
+	s.Size += size
+	s.Events[system+":"+event]++
+}
+
+// mayMatch returns whether the file can contain entries matching the query.
+func (s *fileSummary) mayMatch(q Query) bool {
+	if len(s.Events) == 0 {
+		return false
+	}
+	if !q.Since.IsZero() && s.Last.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && s.First.After(q.Until) {
+		return false
+	}
+
+	for et := range s.Events {
+		p := strings.SplitN(et, ":", 2)
+		if len(p) == 2 && q.matchesType(p[0], p[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isJournalFile(name string) bool {
+	return strings.HasPrefix(name, journalFilePrefix) && strings.HasSuffix(name, journalFileSuffix)
+}
+
+// readEvents calls cb with every well-formed entry in the journal file.
+// Malformed lines, like a partially written last line, are skipped.
+func readEvents(ctx context.Context, path string, cb func(e *RecordedEvent, size int64) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	rd := bufio.NewReader(f)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		line, err := rd.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var e RecordedEvent
+			if jerr := json.Unmarshal(line, &e); jerr != nil {
+				log.Warnw("skipping malformed journal entry", "file", path, "error", jerr)
+			} else if cerr := cb(&e, int64(len(line))); cerr != nil {
+				return cerr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Errorf("reading journal file: %w", err)
+		}
+	}
+}
+
+// summarizeFile builds the summary of a journal file which isn't in the index,
+// e.g. because it was written before the index existed, or the process
+// writing it crashed.
+func summarizeFile(ctx context.Context, dir, name string) (*fileSummary, error) {
+	s := newFileSummary(name)
+	err := readEvents(ctx, filepath.Join(dir, name), func(e *RecordedEvent, size int64) error {
+		s.add(e.System, e.Event, e.Timestamp, size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func loadIndex(dir string) (map[string]*fileSummary, error) {
+	idx := map[string]*fileSummary{}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, indexFileName))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("reading journal index: %w", err)
+	}
+
+	var files []*fileSummary
+	if err := json.Unmarshal(b, &files); err != nil {
+		log.Warnw("journal index is corrupted, rebuilding", "error", err)
+		return idx, nil
+	}
+
+	for _, s := range files {
+		if _, err := os.Stat(filepath.Join(dir, s.Name)); err != nil {
+			continue // removed by hand
+		}
+		idx[s.Name] = s
+	}
+
+	return idx, nil
+}
+
+func saveIndex(dir string, idx map[string]*fileSummary) error {
+	files := make([]*fileSummary, 0, len(idx))
+	for _, s := range idx {
+		files = append(files, s)
+	}
+	sortSummaries(files)
+
+	b, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, indexFileName+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return xerrors.Errorf("writing journal index: %w", err)
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, indexFileName))
+}
+
+// sortSummaries sorts journal files oldest first.
+func sortSummaries(files []*fileSummary) {
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].First.Equal(files[j].First) {
+			return files[i].First.Before(files[j].First)
+		}
+		return files[i].Name < files[j].Name
+	})
+}
+
+// expiredFiles returns the files to remove to satisfy the retention policy,
+// given the candidate files (oldest first) and the size of the journal file
+// currently being written.
+func expiredFiles(files []*fileSummary, activeSize int64, r Retention, now time.Time) []*fileSummary {
+	var total int64 = activeSize
+	for _, s := range files {
+		total += s.Size
+	}
+
+	var out []*fileSummary
+	for _, s := range files {
+		expired := r.MaxAge > 0 && now.Sub(s.Last) > r.MaxAge
+		oversize := r.MaxSize > 0 && total > r.MaxSize
+		if !expired && !oversize {
+			break
+		}
+
+		out = append(out, s)
+		total -= s.Size
+	}
+
+	return out
+}
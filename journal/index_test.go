@@ -0,0 +1,121 @@
+package journal
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSummaryMatch(t *testing.T) {
+	t0 := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	s := newFileSummary("lotus-journal-x.ndjson")
+	require.False(t, s.mayMatch(Query{}))
+
+	s.add("wdpost", "scheduler", t0, 10)
+	s.add("mpool", "add", t0.Add(time.Hour), 20)
+
+	require.Equal(t, int64(30), s.Size)
+	require.Equal(t, t0, s.First)
+	require.Equal(t, t0.Add(time.Hour), s.Last)
+
+	require.True(t, s.mayMatch(Query{}))
+	require.True(t, s.mayMatch(Query{System: "wdpost", Event: "*"}))
+	require.True(t, s.mayMatch(Query{Event: "add"}))
+	require.False(t, s.mayMatch(Query{System: "sync"}))
+	require.False(t, s.mayMatch(Query{System: "wdpost", Event: "add"}))
+
+	require.True(t, s.mayMatch(Query{Since: t0.Add(time.Minute), Until: t0.Add(2 * time.Hour)}))
+	require.False(t, s.mayMatch(Query{Since: t0.Add(2 * time.Hour)}))
+	require.False(t, s.mayMatch(Query{Until: t0.Add(-time.Minute)}))
+}
+
+func TestExpiredFiles(t *testing.T) {
+	now := time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	files := []*fileSummary{
+		{Name: "a", Size: 100, Last: now.Add(-5 * day)},
+		{Name: "b", Size: 100, Last: now.Add(-3 * day)},
+		{Name: "c", Size: 100, Last: now.Add(-1 * day)},
+	}
+
+	names := func(fs []*fileSummary) []string {
+		var out []string
+		for _, s := range fs {
+			out = append(out, s.Name)
+		}
+		return out
+	}
+
+	require.Empty(t, expiredFiles(files, 50, Retention{}, now))
+	require.Equal(t, []string{"a", "b"}, names(expiredFiles(files, 50, Retention{MaxAge: 2 * day}, now)))
+	require.Equal(t, []string{"a"}, names(expiredFiles(files, 50, Retention{MaxSize: 300}, now)))
+	require.Equal(t, []string{"a", "b", "c"}, names(expiredFiles(files, 50, Retention{MaxSize: 60}, now)))
+}
+
+func TestReadEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	data := `{"System":"wdpost","Event":"scheduler","Timestamp":"2021-03-01T00:00:00Z","Data":{"State":"started"}}
+not json
+{"System":"sync","Event":"head","Timestamp":"2021-03-01T00:01:00Z","Data":null}
+{"System":"sync","Event":"he`
+	name := journalFilePrefix + "test" + journalFileSuffix
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+
+	s, err := summarizeFile(context.Background(), dir, name)
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{"wdpost:scheduler": 1, "sync:head": 1}, s.Events)
+
+	var evts []RecordedEvent
+	err = readEvents(context.Background(), filepath.Join(dir, name), func(e *RecordedEvent, _ int64) error {
+		if (Query{System: "wdpost"}).matches(e) {
+			evts = append(evts, *e)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, evts, 1)
+	require.Equal(t, "scheduler", evts[0].Event)
+	require.JSONEq(t, `{"State":"started"}`, string(evts[0].Data))
+}
+
+func TestQueryLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	data := `{"System":"sync","Event":"head","Timestamp":"2021-03-01T00:00:00Z","Data":1}
+{"System":"sync","Event":"head","Timestamp":"2021-03-01T00:01:00Z","Data":2}
+{"System":"sync","Event":"head","Timestamp":"2021-03-01T00:02:00Z","Data":3}
+{"System":"sync","Event":"head","Timestamp":"2021-03-01T00:03:00Z","Data":4}
+{"System":"sync","Event":"head","Timestamp":"2021-03-01T00:04:00Z","Data":5}
+`
+	name := journalFilePrefix + "test" + journalFileSuffix
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+
+	f := &fsJournal{
+		dir:    dir,
+		index:  map[string]*fileSummary{},
+		active: newFileSummary(journalFilePrefix + "active" + journalFileSuffix),
+	}
+
+	evts, err := f.Query(context.Background(), Query{})
+	require.NoError(t, err)
+	require.Len(t, evts, 5)
+
+	// the latest events are kept
+	evts, err = f.Query(context.Background(), Query{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, evts, 2)
+	require.Equal(t, "4", string(evts[0].Data))
+	require.Equal(t, "5", string(evts[1].Data))
+}
@@ -1,6 +1,8 @@
 package journal
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -100,3 +102,69 @@ type Event struct {
 	Timestamp time.Time
 	Data      interface{}
 }
+
+// Reader is implemented by journals which can be read back.
+type Reader interface {
+	// Query returns the recorded events matching the query, oldest first.
+	Query(ctx context.Context, q Query) ([]RecordedEvent, error)
+
+	// Subscribe returns a channel receiving the events matching the query (the
+	// time bounds are ignored) as they get recorded. The channel is closed
+	// when the context is cancelled or the journal is closed. Events are
+	// dropped if the subscriber doesn't keep up.
+	Subscribe(ctx context.Context, q Query) (<-chan RecordedEvent, error)
+}
+
+// Query selects journal entries. Empty fields match everything.
+type Query struct {
+	// System and Event match the event type; "*" matches any value
+	System string
+	Event  string
+
+	Since time.Time
+	Until time.Time
+
+	// Limit is the maximum number of events returned, the latest ones are
+	// kept; zero means no limit
+	Limit int
+}
+
+// MaxQueryEvents is the maximum number of events returned by the
+// JournalQuery API, the latest ones are kept.
+const MaxQueryEvents = 10000
+
+func (q Query) matchesType(system, event string) bool {
+	return (q.System == "" || q.System == "*" || q.System == system) &&
+		(q.Event == "" || q.Event == "*" || q.Event == event)
+}
+
+func (q Query) matches(e *RecordedEvent) bool {
+	if !q.matchesType(e.System, e.Event) {
+		return false
+	}
+	if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Timestamp.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// RecordedEvent is a journal entry read back from the journal, with the
+// payload left in its serialized form.
+type RecordedEvent struct {
+	System    string
+	Event     string
+	Timestamp time.Time
+	Data      json.RawMessage
+}
+
+// Retention limits how much history a journal keeps. Zero values disable the
+// respective limit.
+type Retention struct {
+	// MaxAge is the age after which entries are removed
+	MaxAge time.Duration
+	// MaxSize is the total size of the journal in bytes
+	MaxSize int64
+}
@@ -2,6 +2,7 @@ package peermgr
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -29,6 +30,9 @@ const (
 	MinFilPeers = 12
 )
 
+// scoreTag is the connection manager tag holding the peer score
+const scoreTag = "peermgr-score"
+
 type MaybePeerMgr struct {
 	fx.In
 
@@ -44,6 +48,7 @@ type PeerMgr struct {
 
 	peersLk sync.Mutex
 	peers   map[peer.ID]time.Duration
+	scores  map[peer.ID]float64
 
 	maxFilPeers int
 	minFilPeers int
@@ -78,6 +83,7 @@ func NewPeerMgr(lc fx.Lifecycle, h host.Host, dht *dht.IpfsDHT, bootstrap dtypes
 		bootstrappers: bootstrap,
 
 		peers:     make(map[peer.ID]time.Duration),
+		scores:    make(map[peer.ID]float64),
 		expanding: make(chan struct{}, 1),
 
 		maxFilPeers: MaxFilPeers,
@@ -134,6 +140,31 @@ func (pmgr *PeerMgr) SetPeerLatency(p peer.ID, latency time.Duration) {
 
 }
 
+// SetPeerScore records the score given to a peer from the chain data it
+// served. The connection manager prefers keeping well scored peers, and peers
+// with a negative score are the first disconnected when there are more peers
+// than needed.
+func (pmgr *PeerMgr) SetPeerScore(p peer.ID, score float64) {
+	pmgr.peersLk.Lock()
+	_, ok := pmgr.peers[p]
+	if ok {
+		pmgr.scores[p] = score
+	}
+	pmgr.peersLk.Unlock()
+
+	if ok {
+		pmgr.h.ConnManager().TagPeer(p, scoreTag, int(score))
+	}
+}
+
+// GetPeerScore returns the score of a peer, see SetPeerScore.
+func (pmgr *PeerMgr) GetPeerScore(p peer.ID) (float64, bool) {
+	pmgr.peersLk.Lock()
+	defer pmgr.peersLk.Unlock()
+	score, ok := pmgr.scores[p]
+	return score, ok
+}
+
 func (pmgr *PeerMgr) Disconnect(p peer.ID) {
 	disconnected := false
 
@@ -142,6 +173,7 @@ func (pmgr *PeerMgr) Disconnect(p peer.ID) {
 		_, disconnected = pmgr.peers[p]
 		if disconnected {
 			delete(pmgr.peers, p)
+			delete(pmgr.scores, p)
 		}
 		pmgr.peersLk.Unlock()
 	}
@@ -167,6 +199,7 @@ func (pmgr *PeerMgr) Run(ctx context.Context) {
 				pmgr.expandPeers()
 			} else if pcount > pmgr.maxFilPeers {
 				log.Debugf("peer count about threshold: %d > %d", pcount, pmgr.maxFilPeers)
+				pmgr.trimBadPeers(pcount - pmgr.maxFilPeers)
 			}
 			stats.Record(ctx, metrics.PeerCount.M(int64(pmgr.getPeerCount())))
 		case <-pmgr.done:
@@ -176,6 +209,37 @@ func (pmgr *PeerMgr) Run(ctx context.Context) {
 	}
 }
 
+// trimBadPeers disconnects up to n peers with a negative score, worst first.
+func (pmgr *PeerMgr) trimBadPeers(n int) {
+	type scored struct {
+		p     peer.ID
+		score float64
+	}
+
+	pmgr.peersLk.Lock()
+	var bad []scored
+	for p, score := range pmgr.scores {
+		if score < 0 {
+			bad = append(bad, scored{p, score})
+		}
+	}
+	pmgr.peersLk.Unlock()
+
+	sort.Slice(bad, func(i, j int) bool {
+		return bad[i].score < bad[j].score
+	})
+	if len(bad) > n {
+		bad = bad[:n]
+	}
+
+	for _, b := range bad {
+		log.Infow("disconnecting badly scored peer", "peer", b.p, "score", b.score)
+		if err := pmgr.h.Network().ClosePeer(b.p); err != nil {
+			log.Warnw("disconnecting peer", "peer", b.p, "error", err)
+		}
+	}
+}
+
 func (pmgr *PeerMgr) getPeerCount() int {
 	pmgr.peersLk.Lock()
 	defer pmgr.peersLk.Unlock()
@@ -0,0 +1,57 @@
+package peermgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrimBadPeers(t *testing.T) {
+	ctx := context.Background()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 5)
+	require.NoError(t, err)
+
+	h := mn.Hosts()[0]
+	emitter, err := h.EventBus().Emitter(new(FilPeerEvt))
+	require.NoError(t, err)
+
+	pmgr := &PeerMgr{
+		h:       h,
+		emitter: emitter,
+		peers:   map[peer.ID]time.Duration{},
+		scores:  map[peer.ID]float64{},
+	}
+
+	others := mn.Hosts()[1:]
+	for _, o := range others {
+		pmgr.AddFilecoinPeer(o.ID())
+	}
+
+	pmgr.SetPeerScore(others[0].ID(), 10)
+	pmgr.SetPeerScore(others[1].ID(), -5)
+	pmgr.SetPeerScore(others[2].ID(), -50)
+	// others[3] has no score yet
+
+	score, ok := pmgr.GetPeerScore(others[2].ID())
+	require.True(t, ok)
+	require.Equal(t, -50.0, score)
+
+	// only the worst peer goes
+	pmgr.trimBadPeers(1)
+	require.Equal(t, network.NotConnected, h.Network().Connectedness(others[2].ID()))
+	for _, o := range []int{0, 1, 3} {
+		require.Equal(t, network.Connected, h.Network().Connectedness(others[o].ID()), "peer %d", o)
+	}
+
+	// peers with a positive or no score are kept
+	pmgr.trimBadPeers(10)
+	require.Equal(t, network.NotConnected, h.Network().Connectedness(others[1].ID()))
+	require.Equal(t, network.Connected, h.Network().Connectedness(others[0].ID()))
+	require.Equal(t, network.Connected, h.Network().Connectedness(others[3].ID()))
+}
@@ -45,6 +45,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/gen/slashfilter"
+	"github.com/filecoin-project/lotus/chain/index"
 	"github.com/filecoin-project/lotus/chain/market"
 	"github.com/filecoin-project/lotus/chain/messagepool"
 	"github.com/filecoin-project/lotus/chain/messagesigner"
@@ -133,6 +134,7 @@ const (
 	HandleIncomingMessagesKey
 	HandleMigrateClientFundsKey
 	HandlePaymentChannelManagerKey
+	RunStuckMessageReplacerKey
 
 	// miner
 	GetParamsKey
@@ -303,6 +305,8 @@ var ChainNode = Options(
 
 	// Service: Wallet
 	Override(new(*messagesigner.MessageSigner), messagesigner.NewMessageSigner),
+	Override(new(*messagesigner.NonceTracker), messagesigner.NewNonceTracker),
+	Override(new(messagesigner.NonceProvider), From(new(*messagesigner.NonceTracker))),
 	Override(new(*wallet.LocalWallet), wallet.NewWallet),
 	Override(new(wallet.Default), From(new(*wallet.LocalWallet))),
 	Override(new(api.Wallet), From(new(wallet.MultiWallet))),
@@ -392,6 +396,10 @@ var MinerNode = Options(
 	Override(new(gen.WinningPoStProver), storage.NewWinningPoStProver),
 
 	Override(new(*storage.AddressSelector), modules.AddressSelector(nil)),
+	Override(new(*storage.TierMover), modules.TierMover(config.DefaultStorageMiner().Tiering)),
+	Override(new(*storage.Scrubber), modules.Scrubber(config.DefaultStorageMiner().Scrub, config.DefaultStorageMiner().Fees)),
+	Override(new(*storage.ControlTopUp), modules.ControlTopUp(config.DefaultStorageMiner().Addresses)),
+	Override(new(*storage.Treasury), modules.Treasury(config.DefaultStorageMiner().Treasury)),
 
 	// Markets
 	Override(new(dtypes.StagingMultiDstore), modules.StagingMultiDatastore),
@@ -488,6 +496,7 @@ func ConfigCommon(cfg *config.Common) Option {
 		Override(SetApiEndpointKey, func(lr repo.LockedRepo, e dtypes.APIEndpoint) error {
 			return lr.SetAPIEndpoint(e)
 		}),
+		Override(new(journal.Journal), modules.OpenFilesystemJournalWithRetention(cfg.Journal)),
 		Override(new(sectorstorage.URLs), func(e dtypes.APIEndpoint) (sectorstorage.URLs, error) {
 			ip := cfg.API.RemoteListenAddress
 
@@ -534,13 +543,27 @@ func ConfigFullNode(c interface{}) Option {
 		),
 		Override(new(dtypes.Graphsync), modules.Graphsync(cfg.Client.SimultaneousTransfers)),
 
+		If(cfg.Index.EnableMsgIndex,
+			Override(new(index.MsgIndex), modules.MsgIndex),
+		),
+		If(cfg.Index.EnableAddrIndex,
+			Override(new(index.AddrIndex), modules.AddrIndex),
+		),
+
 		If(cfg.Metrics.HeadNotifs,
 			Override(HeadMetricsKey, metrics.SendHeadNotifs(cfg.Metrics.Nickname)),
 		),
 
+		If(cfg.Fees.StuckMessages.EnableReplace,
+			Override(RunStuckMessageReplacerKey, modules.RunStuckMessageReplacer(cfg.Fees.StuckMessages)),
+		),
+
 		If(cfg.Wallet.RemoteBackend != "",
 			Override(new(*remotewallet.RemoteWallet), remotewallet.SetupRemoteWallet(cfg.Wallet.RemoteBackend)),
 		),
+		If(cfg.Wallet.NonceServer != "",
+			Override(new(messagesigner.NonceProvider), modules.RemoteNonceProvider(cfg.Wallet.NonceServer)),
+		),
 		If(cfg.Wallet.EnableLedger,
 			Override(new(*ledgerwallet.LedgerWallet), ledgerwallet.NewWallet),
 		),
@@ -577,6 +600,14 @@ func ConfigStorageMiner(c interface{}) Option {
 		Override(new(sectorstorage.SealerConfig), cfg.Storage),
 		Override(new(*storage.AddressSelector), modules.AddressSelector(&cfg.Addresses)),
 		Override(new(*storage.Miner), modules.StorageMiner(cfg.Fees)),
+		Override(new(*storage.TierMover), modules.TierMover(cfg.Tiering)),
+		Override(new(*storage.Scrubber), modules.Scrubber(cfg.Scrub, cfg.Fees)),
+		Override(new(*storage.ControlTopUp), modules.ControlTopUp(cfg.Addresses)),
+		Override(new(*storage.Treasury), modules.Treasury(cfg.Treasury)),
+
+		If(cfg.Fees.StuckMessages.EnableReplace,
+			Override(RunStuckMessageReplacerKey, modules.RunMinerStuckMessageReplacer(cfg.Fees.StuckMessages)),
+		),
 	)
 }
 
@@ -12,10 +12,11 @@ import (
 
 // Common is common config between full node and miner
 type Common struct {
-	API    API
-	Backup Backup
-	Libp2p Libp2p
-	Pubsub Pubsub
+	API     API
+	Backup  Backup
+	Libp2p  Libp2p
+	Pubsub  Pubsub
+	Journal JournalConfig
 }
 
 // FullNode is a full node config
@@ -26,6 +27,8 @@ type FullNode struct {
 	Wallet     Wallet
 	Fees       FeeConfig
 	Chainstore Chainstore
+	Checkpoint CheckpointConfig
+	Index      IndexConfig
 }
 
 // // Common
@@ -34,6 +37,16 @@ type Backup struct {
 	DisableMetadataLog bool
 }
 
+// JournalConfig limits the history kept in the event journal (<repo>/journal)
+type JournalConfig struct {
+	// Remove journal files once all their entries are older than this
+	// (0 = keep forever)
+	MaxAge Duration
+	// Remove the oldest journal files once the journal takes more than this
+	// many bytes (0 = no limit)
+	MaxSizeBytes uint64
+}
+
 // StorageMiner is a miner config
 type StorageMiner struct {
 	Common
@@ -43,6 +56,9 @@ type StorageMiner struct {
 	Storage    sectorstorage.SealerConfig
 	Fees       MinerFeeConfig
 	Addresses  MinerAddressConfig
+	Tiering    StorageTieringConfig
+	Scrub      StorageScrubConfig
+	Treasury   TreasuryConfig
 }
 
 type DealmakingConfig struct {
@@ -95,6 +111,8 @@ type MinerFeeConfig struct {
 	MaxWindowPoStGasFee    types.FIL
 	MaxPublishDealsFee     types.FIL
 	MaxMarketBalanceAddFee types.FIL
+
+	StuckMessages StuckMessageConfig
 }
 
 type MinerAddressConfig struct {
@@ -110,6 +128,95 @@ type MinerAddressConfig struct {
 	// A control address that doesn't have enough funds will still be chosen
 	// over the worker address if this flag is set.
 	DisableWorkerFallback bool
+
+	// Minimum balances of the control addresses of each role, or of the
+	// worker when a role has no control addresses. When TopUpFrom is set,
+	// addresses which drop below their minimum are topped up to twice the
+	// minimum
+	PreCommitMinBalance types.FIL
+	CommitMinBalance    types.FIL
+	TerminateMinBalance types.FIL
+	// Wallet address, such as the owner, which control addresses are topped
+	// up from. Top ups are disabled when empty
+	TopUpFrom string
+	// Maximum amount sent in top ups in any 24 hours
+	MaxDailyTopUp types.FIL
+}
+
+// StorageTieringConfig configures the background mover which migrates
+// finalized sectors between storage tiers (the `Tier` tag in sectorstore.json)
+type StorageTieringConfig struct {
+	// Move sectors according to Rules. Sectors can still be moved by hand
+	// with `lotus-miner storage migrate` when this is disabled
+	EnableAutoMigration bool
+	// How often to check whether any sectors should be moved
+	CheckInterval Duration
+	// Limits the combined throughput of sector moves, in bytes per second
+	// (0 = unlimited)
+	MaxMoveBytesPerSecond uint64
+	// Sectors whose WindowPoSt deadline opens within this many epochs are
+	// not moved
+	DeadlineGuardEpochs uint64
+
+	Rules []TierRule
+}
+
+type TierRule struct {
+	// Tier to move sectors out of
+	From string
+	// Tier to move sectors into
+	To string
+	// Only move sectors which were activated at least this long ago
+	MinAge Duration
+	// Which sectors the rule applies to: "any", "cc" or "deals"
+	Sectors string
+	// Only move sectors while free space in the From tier is below this
+	// percentage (0 = move regardless of free space)
+	FromMinFreePercent uint64
+}
+
+// TreasuryConfig configures the scheduled withdrawal of the available miner
+// balance, which is split between re-voting it with AddPos, a cold wallet,
+// and collateral for KPledge
+type TreasuryConfig struct {
+	EnableTreasury bool
+	// How often available balance is withdrawn
+	WithdrawInterval Duration
+	// Available balance left in the miner actor
+	Reserve types.FIL
+	// Withdrawals smaller than this are skipped
+	MinWithdraw types.FIL
+
+	// Shares of the withdrawn funds, relative to each other, voted back to
+	// the miner, sent to ColdWallet, and kept for KPledge collateral
+	VoteShare   uint64
+	ColdShare   uint64
+	PledgeShare uint64
+
+	ColdWallet string
+	// Wallet the KPledge share is sent to. When empty it's kept by the owner
+	// (or beneficiary) the funds are withdrawn to
+	PledgeWallet string
+}
+
+// StorageScrubConfig configures the background scrubber which periodically
+// checks that sealed and cache files of proving sectors are readable
+type StorageScrubConfig struct {
+	EnableScrub bool
+	// How often a batch of sectors is checked
+	CheckInterval Duration
+	// Number of sectors checked in each batch
+	SectorsPerCheck int
+	// Also generate a vanilla proof for a random challenge, which reads parts
+	// of the sealed and cache files instead of only checking that they exist
+	// and have the right size
+	GenerateVanillaProofs bool
+	// Sectors whose WindowPoSt deadline is open, or opens within this many
+	// epochs, are not checked
+	DeadlineGuardEpochs uint64
+	// Declare faults for sectors failing the check. When disabled, failures
+	// are only logged and recorded in the journal
+	DeclareFaults bool
 }
 
 // API contains configs for API endpoint
@@ -144,6 +251,29 @@ type Chainstore struct {
 	Splitstore       Splitstore
 }
 
+// CheckpointConfig lists the keys trusted to sign the checkpoints accepted by
+// `lotus daemon --import-checkpoint`
+type CheckpointConfig struct {
+	// Addresses of the trusted signers (BLS or secp256k1)
+	TrustedSigners []string
+	// Number of trusted signers which must have signed a checkpoint
+	// (0 means 1)
+	MinSignatures int
+}
+
+// IndexConfig configures the chain indexes kept by the node (in
+// <repo>/datastore). Messages executed before an index was enabled can be
+// indexed with `lotus chain index backfill`
+type IndexConfig struct {
+	// Index message receipts by message CID as the chain is synced, so that
+	// StateSearchMsg and StateWaitMsg don't need to walk the chain
+	EnableMsgIndex bool
+	// Index the messages and value transfers of each address, including
+	// transfers made by actors, for StateAddressHistory. Computing them
+	// requires executing every tipset twice
+	EnableAddrIndex bool
+}
+
 type Splitstore struct {
 	HotStoreType         string
 	TrackingStoreType    string
@@ -172,10 +302,41 @@ type Wallet struct {
 	RemoteBackend string
 	EnableLedger  bool
 	DisableLocal  bool
+
+	// API info of a nonce server, such as 'lotus-wallet run --serve-nonces',
+	// handing out the nonces of the messages this node signs, so that several
+	// nodes can send messages from the same addresses. The token needs the
+	// sign permission.
+	NonceServer string
 }
 
 type FeeConfig struct {
 	DefaultMaxFee types.FIL
+
+	StuckMessages StuckMessageConfig
+}
+
+// StuckMessageConfig configures the watchdog which replaces our own messages
+// that have been pending in the mpool for too long, raising their fees by the
+// mpool ReplaceByFeeRatio on each replacement. When it's enabled on both the
+// miner and its daemon, the miner addresses are watched by both.
+type StuckMessageConfig struct {
+	EnableReplace bool
+	// How many epochs a message may be pending before it's replaced
+	PendingEpochs uint64
+	// Maximum fee a replacement may spend, by method name (e.g.
+	// SubmitWindowedPoSt). Messages which would need a higher fee are left
+	// pending
+	MaxFee []MethodMaxFee
+	// Maximum fee for methods not listed in MaxFee
+	DefaultMaxFee types.FIL
+}
+
+// MethodMaxFee is the maximum fee a stuck message replacement may spend on a
+// method, given as a [[...MaxFee]] table in the config file
+type MethodMaxFee struct {
+	Method string
+	MaxFee types.FIL
 }
 
 func defCommon() Common {
@@ -214,6 +375,12 @@ func DefaultFullNode() *FullNode {
 		Common: defCommon(),
 		Fees: FeeConfig{
 			DefaultMaxFee: DefaultDefaultMaxFee,
+			StuckMessages: StuckMessageConfig{
+				EnableReplace: false,
+				PendingEpochs: 10,
+				MaxFee:        []MethodMaxFee{},
+				DefaultMaxFee: DefaultDefaultMaxFee,
+			},
 		},
 		Client: Client{
 			SimultaneousTransfers: DefaultSimultaneousTransfers,
@@ -273,11 +440,51 @@ func DefaultStorageMiner() *StorageMiner {
 			MaxWindowPoStGasFee:    types.MustParseFIL("5"),
 			MaxPublishDealsFee:     types.MustParseFIL("0.05"),
 			MaxMarketBalanceAddFee: types.MustParseFIL("0.007"),
+
+			StuckMessages: StuckMessageConfig{
+				EnableReplace: false,
+				PendingEpochs: 10,
+				MaxFee: []MethodMaxFee{
+					{Method: "SubmitWindowedPoSt", MaxFee: types.MustParseFIL("5")},
+					{Method: "AddPos", MaxFee: types.MustParseFIL("0.007")},
+				},
+				DefaultMaxFee: DefaultDefaultMaxFee,
+			},
 		},
 
 		Addresses: MinerAddressConfig{
 			PreCommitControl: []string{},
 			CommitControl:    []string{},
+
+			PreCommitMinBalance: types.MustParseFIL("0"),
+			CommitMinBalance:    types.MustParseFIL("0"),
+			TerminateMinBalance: types.MustParseFIL("0"),
+			MaxDailyTopUp:       types.MustParseFIL("10"),
+		},
+
+		Tiering: StorageTieringConfig{
+			EnableAutoMigration: false,
+			CheckInterval:       Duration(10 * time.Minute),
+			DeadlineGuardEpochs: 60,
+		},
+
+		Scrub: StorageScrubConfig{
+			EnableScrub:           false,
+			CheckInterval:         Duration(5 * time.Minute),
+			SectorsPerCheck:       10,
+			GenerateVanillaProofs: true,
+			DeadlineGuardEpochs:   120,
+			DeclareFaults:         false,
+		},
+
+		Treasury: TreasuryConfig{
+			EnableTreasury:   false,
+			WithdrawInterval: Duration(24 * time.Hour),
+			Reserve:          types.MustParseFIL("0"),
+			MinWithdraw:      types.MustParseFIL("1"),
+			VoteShare:        1,
+			ColdShare:        0,
+			PledgeShare:      0,
 		},
 	}
 	cfg.Common.API.ListenAddress = "/ip4/127.0.0.1/tcp/2345/http"
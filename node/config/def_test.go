@@ -52,3 +52,44 @@ func TestDefaultMinerRoundtrip(t *testing.T) {
 
 	require.True(t, reflect.DeepEqual(c, c2))
 }
+
+func TestStuckMessagesMaxFeeDecode(t *testing.T) {
+	cfgString := `
+		[Fees.StuckMessages]
+		DefaultMaxFee = "0.1 KAKH"
+
+		[[Fees.StuckMessages.MaxFee]]
+		Method = "SubmitWindowedPoSt"
+		MaxFee = "7 KAKH"
+
+		[[Fees.StuckMessages.MaxFee]]
+		Method = "ProveCommitSector"
+		MaxFee = "0.5 KAKH"
+		`
+
+	c, err := FromReader(strings.NewReader(cfgString), DefaultStorageMiner())
+	require.NoError(t, err)
+
+	sm := c.(*StorageMiner).Fees.StuckMessages
+	require.Equal(t, "0.1 KAKH", sm.DefaultMaxFee.String())
+	require.Len(t, sm.MaxFee, 2)
+	require.Equal(t, "SubmitWindowedPoSt", sm.MaxFee[0].Method)
+	require.Equal(t, "7 KAKH", sm.MaxFee[0].MaxFee.String())
+	require.Equal(t, "ProveCommitSector", sm.MaxFee[1].Method)
+	require.Equal(t, "0.5 KAKH", sm.MaxFee[1].MaxFee.String())
+
+	// the defaults share their values, decoding one mustn't change the others
+	require.Equal(t, "0.07 KAKH", DefaultDefaultMaxFee.String())
+	require.Equal(t, "0.07 KAKH", DefaultFullNode().Fees.DefaultMaxFee.String())
+
+	var s string
+	{
+		buf := new(bytes.Buffer)
+		require.NoError(t, toml.NewEncoder(buf).Encode(c))
+		s = buf.String()
+	}
+
+	c2, err := FromReader(strings.NewReader(s), DefaultStorageMiner())
+	require.NoError(t, err)
+	require.True(t, reflect.DeepEqual(c, c2))
+}
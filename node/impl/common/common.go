@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/google/uuid"
@@ -26,6 +27,7 @@ import (
 	"github.com/filecoin-project/lotus/api"
 	apitypes "github.com/filecoin-project/lotus/api/types"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/lp2p"
 )
@@ -43,6 +45,7 @@ type CommonAPI struct {
 	Reporter     metrics.Reporter
 	Sk           *dtypes.ScoreKeeper
 	ShutdownChan dtypes.ShutdownChan
+	Journal      journal.Journal
 }
 
 type jwtPayload struct {
@@ -238,6 +241,57 @@ func (a *CommonAPI) LogSetLevel(ctx context.Context, subsystem, level string) er
 	return logging.SetLogLevel(subsystem, level)
 }
 
+func (a *CommonAPI) JournalQuery(ctx context.Context, system, event string, since, until time.Time) ([]api.JournalEvent, error) {
+	jr, ok := a.Journal.(journal.Reader)
+	if !ok {
+		return nil, xerrors.New("the journal doesn't support queries")
+	}
+
+	evts, err := jr.Query(ctx, journal.Query{
+		System: system,
+		Event:  event,
+		Since:  since,
+		Until:  until,
+		Limit:  journal.MaxQueryEvents,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.JournalEvent, len(evts))
+	for i, e := range evts {
+		out[i] = api.JournalEvent(e)
+	}
+	return out, nil
+}
+
+func (a *CommonAPI) JournalSubscribe(ctx context.Context, system, event string) (<-chan api.JournalEvent, error) {
+	jr, ok := a.Journal.(journal.Reader)
+	if !ok {
+		return nil, xerrors.New("the journal doesn't support subscriptions")
+	}
+
+	sub, err := jr.Subscribe(ctx, journal.Query{System: system, Event: event})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan api.JournalEvent)
+	go func() {
+		defer close(out)
+
+		for e := range sub {
+			select {
+			case out <- api.JournalEvent(e):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
 func (a *CommonAPI) Shutdown(ctx context.Context) error {
 	a.ShutdownChan <- struct{}{}
 	return nil
@@ -32,6 +32,7 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/index"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
@@ -81,6 +82,11 @@ type ChainAPI struct {
 	// expose externally. In the future, this will be segregated into two
 	// blockstores.
 	ExposedBlockstore dtypes.ExposedBlockstore
+
+	// MsgIndex is only set when Index.EnableMsgIndex is set in the config
+	MsgIndex index.MsgIndex `optional:"true"`
+	// AddrIndex is only set when Index.EnableAddrIndex is set in the config
+	AddrIndex index.AddrIndex `optional:"true"`
 }
 
 func (m *ChainModule) ChainNotify(ctx context.Context) (<-chan []*api.HeadChange, error) {
@@ -605,3 +611,32 @@ func (a *ChainAPI) ChainExport(ctx context.Context, nroots abi.ChainEpoch, skipo
 
 	return out, nil
 }
+
+func (a *ChainAPI) ChainIndexBackfill(ctx context.Context, tsk types.TipSetKey, epochs abi.ChainEpoch) (int, error) {
+	if a.MsgIndex == nil && a.AddrIndex == nil {
+		return 0, xerrors.Errorf("chain indexes are disabled, set Index.EnableMsgIndex or Index.EnableAddrIndex in the config to enable them")
+	}
+
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return 0, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	var n int
+	if a.MsgIndex != nil {
+		c, err := a.MsgIndex.Backfill(ctx, ts, epochs)
+		if err != nil {
+			return n, xerrors.Errorf("backfilling message index: %w", err)
+		}
+		n += c
+	}
+	if a.AddrIndex != nil {
+		c, err := a.AddrIndex.Backfill(ctx, ts, epochs)
+		if err != nil {
+			return n, xerrors.Errorf("backfilling address index: %w", err)
+		}
+		n += c
+	}
+
+	return n, nil
+}
@@ -3,6 +3,7 @@ package full
 import (
 	"bytes"
 	"context"
+	"sort"
 	"strconv"
 
 	cid "github.com/ipfs/go-cid"
@@ -28,6 +29,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors/policy"
 	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/chain/index"
 	"github.com/filecoin-project/lotus/chain/state"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
@@ -88,6 +90,9 @@ type StateAPI struct {
 	StateManager  *stmgr.StateManager
 	Chain         *store.ChainStore
 	Beacon        beacon.Schedule
+
+	// AddrIndex is only set when Index.EnableAddrIndex is set in the config
+	AddrIndex index.AddrIndex `optional:"true"`
 }
 
 func (a *StateAPI) StateNetworkName(ctx context.Context) (dtypes.NetworkName, error) {
@@ -902,6 +907,48 @@ func (a *StateAPI) StateListMessages(ctx context.Context, match *api.MessageMatc
 	return out, nil
 }
 
+func (a *StateAPI) StateAddressHistory(ctx context.Context, addr address.Address, cursor string, limit int) (*api.AddressHistory, error) {
+	if a.AddrIndex == nil {
+		return nil, xerrors.Errorf("address index is disabled, set Index.EnableAddrIndex in the config to enable it")
+	}
+
+	// transfers are indexed by ID address
+	idAddr, err := a.StateManager.LookupID(ctx, addr, a.Chain.GetHeaviestTipSet())
+	if xerrors.Is(err, types.ErrActorNotFound) {
+		return &api.AddressHistory{}, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("looking up address: %w", err)
+	}
+
+	transfers, next, err := a.AddrIndex.History(ctx, idAddr, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &api.AddressHistory{
+		Transfers: make([]api.AddressTransfer, len(transfers)),
+		Cursor:    next,
+	}
+	for i, t := range transfers {
+		out.Transfers[i] = api.AddressTransfer{
+			Message:  t.Message,
+			TipSet:   t.TipSet,
+			Epoch:    t.Epoch,
+			From:     t.From,
+			To:       t.To,
+			Value:    t.Value,
+			Method:   t.Method,
+			ExitCode: t.ExitCode,
+			GasCost:  t.GasCost,
+			Internal: t.Internal,
+			Implicit: t.Implicit,
+		}
+	}
+
+	return out, nil
+}
+
 func (a *StateAPI) StateCompute(ctx context.Context, height abi.ChainEpoch, msgs []*types.Message, tsk types.TipSetKey) (*api.ComputeStateOutput, error) {
 	ts, err := a.Chain.GetTipSetFromKey(tsk)
 	if err != nil {
@@ -1220,6 +1267,79 @@ func (a *StateAPI) StateMinerAvailableBalance(ctx context.Context, maddr address
 	return types.BigAdd(abal, vested), nil
 }
 
+func (a *StateAPI) StateMinerVestingSchedule(ctx context.Context, maddr address.Address, tsk types.TipSetKey) (*api.MinerVestingSchedule, error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	act, err := a.StateManager.LoadActor(ctx, maddr, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load miner actor: %w", err)
+	}
+
+	mas, err := miner.Load(a.StateManager.ChainStore().ActorStore(ctx), act)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load miner actor state: %w", err)
+	}
+
+	out := &api.MinerVestingSchedule{
+		Epoch:   ts.Height(),
+		Balance: act.Balance,
+	}
+
+	vested, err := mas.VestedFunds(ts.Height())
+	if err != nil {
+		return nil, err
+	}
+	abal, err := mas.AvailableBalance(act.Balance)
+	if err != nil {
+		return nil, err
+	}
+	out.Available = types.BigAdd(abal, vested)
+
+	if out.Locked, err = mas.LockedFunds(); err != nil {
+		return nil, xerrors.Errorf("getting locked funds: %w", err)
+	}
+	if out.Vesting, err = mas.VestingFunds(); err != nil {
+		return nil, xerrors.Errorf("loading vesting funds: %w", err)
+	}
+	if out.PosVesting, err = mas.PosVestingFunds(); err != nil {
+		return nil, xerrors.Errorf("loading pos vesting funds: %w", err)
+	}
+
+	sectors, err := mas.LoadSectors(nil)
+	if err != nil {
+		return nil, xerrors.Errorf("loading sectors: %w", err)
+	}
+
+	out.Pledge = pledgeSchedule(sectors)
+
+	return out, nil
+}
+
+// pledgeSchedule sums the initial pledge of the sectors by expiration
+func pledgeSchedule(sectors []*miner.SectorOnChainInfo) []miner.VestingFund {
+	pledge := map[abi.ChainEpoch]abi.TokenAmount{}
+	for _, s := range sectors {
+		if p, ok := pledge[s.Expiration]; ok {
+			pledge[s.Expiration] = big.Add(p, s.InitialPledge)
+		} else {
+			pledge[s.Expiration] = s.InitialPledge
+		}
+	}
+
+	out := make([]miner.VestingFund, 0, len(pledge))
+	for e, p := range pledge {
+		out = append(out, miner.VestingFund{Epoch: e, Amount: p})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Epoch < out[j].Epoch
+	})
+
+	return out
+}
+
 func (a *StateAPI) StateMinerSectorAllocated(ctx context.Context, maddr address.Address, s abi.SectorNumber, tsk types.TipSetKey) (bool, error) {
 	ts, err := a.Chain.GetTipSetFromKey(tsk)
 	if err != nil {
@@ -1409,6 +1529,20 @@ func stateVMCirculatingSupplyInternal(
 	return smgr.GetVMCirculatingSupplyDetailed(ctx, ts.Height(), sTree)
 }
 
+func (a *StateAPI) StateSupply(ctx context.Context, tsk types.TipSetKey) (*api.NetworkSupply, error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	sTree, err := a.StateManager.ParentState(ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.StateManager.GetSupplyDetailed(ctx, ts.Height(), sTree)
+}
+
 func (m *StateModule) StateNetworkVersion(ctx context.Context, tsk types.TipSetKey) (network.Version, error) {
 	ts, err := m.Chain.GetTipSetFromKey(tsk)
 	if err != nil {
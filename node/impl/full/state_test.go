@@ -0,0 +1,35 @@
+package full
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+)
+
+func TestPledgeSchedule(t *testing.T) {
+	sector := func(expiration abi.ChainEpoch, pledge int64) *miner.SectorOnChainInfo {
+		return &miner.SectorOnChainInfo{Expiration: expiration, InitialPledge: abi.NewTokenAmount(pledge)}
+	}
+
+	require.Empty(t, pledgeSchedule(nil))
+
+	ps := pledgeSchedule([]*miner.SectorOnChainInfo{
+		sector(300, 1),
+		sector(100, 2),
+		sector(300, 4),
+		sector(200, 8),
+	})
+	require.Len(t, ps, 3)
+
+	for i, e := range []struct {
+		epoch  abi.ChainEpoch
+		amount int64
+	}{{100, 2}, {200, 8}, {300, 5}} {
+		require.Equal(t, e.epoch, ps[i].Epoch)
+		require.True(t, ps[i].Amount.Equals(abi.NewTokenAmount(e.amount)), "epoch %d: %s", e.epoch, ps[i].Amount)
+	}
+}
@@ -47,6 +47,20 @@ func (a *SyncAPI) SyncState(ctx context.Context) (*api.SyncState, error) {
 			Message:  ss.Message,
 		})
 	}
+
+	for _, ps := range a.Syncer.Exchange.PeerStats() {
+		out.Peers = append(out.Peers, api.SyncPeerStats{
+			Peer:          ps.Peer,
+			Score:         ps.Score,
+			Successes:     ps.Successes,
+			Failures:      ps.Failures,
+			Invalid:       ps.Invalid,
+			Slow:          ps.Slow,
+			AvgTipSetTime: ps.AvgTipSetTime,
+			FirstSeen:     ps.FirstSeen,
+		})
+	}
+
 	return out, nil
 }
 
@@ -22,6 +22,7 @@ type WalletAPI struct {
 
 	StateManagerAPI stmgr.StateManagerAPI
 	Default         wallet.Default
+	LocalWallet     *wallet.LocalWallet `optional:"true"`
 	api.Wallet
 }
 
@@ -85,3 +86,17 @@ func (a *WalletAPI) WalletSetDefault(ctx context.Context, addr address.Address)
 func (a *WalletAPI) WalletValidateAddress(ctx context.Context, str string) (address.Address, error) {
 	return address.NewFromString(str)
 }
+
+func (a *WalletAPI) WalletLock(ctx context.Context) error {
+	if a.LocalWallet == nil {
+		return xerrors.Errorf("node has no local wallet")
+	}
+	return a.LocalWallet.Lock()
+}
+
+func (a *WalletAPI) WalletUnlock(ctx context.Context, passphrase string) error {
+	if a.LocalWallet == nil {
+		return xerrors.Errorf("node has no local wallet")
+	}
+	return a.LocalWallet.Unlock([]byte(passphrase))
+}
@@ -64,6 +64,10 @@ type StorageMinerAPI struct {
 	Host          host.Host
 	AddrSel       *storage.AddressSelector
 	DealPublisher *storageadapter.DealPublisher
+	TierMover     *storage.TierMover
+	Scrubber      *storage.Scrubber
+	ControlTopUp  *storage.ControlTopUp
+	Treasury      *storage.Treasury
 
 	Epp gen.WinningPoStProver
 	DS  dtypes.MetadataDS
@@ -327,6 +331,14 @@ func (sm *StorageMinerAPI) StorageStat(ctx context.Context, id stores.ID) (fsuti
 	return sm.StorageMgr.FsStat(ctx, id)
 }
 
+func (sm *StorageMinerAPI) StorageMigrations(ctx context.Context) ([]api.StorageMigration, error) {
+	return sm.TierMover.Migrations(), nil
+}
+
+func (sm *StorageMinerAPI) StorageMigrateSector(ctx context.Context, sector abi.SectorNumber, tier string) error {
+	return sm.TierMover.Migrate(ctx, sector, tier)
+}
+
 func (sm *StorageMinerAPI) SectorStartSealing(ctx context.Context, number abi.SectorNumber) error {
 	return sm.Miner.StartPackingSector(number)
 }
@@ -696,10 +708,26 @@ func (sm *StorageMinerAPI) CheckProvable(ctx context.Context, pp abi.RegisteredP
 	return out, nil
 }
 
+func (sm *StorageMinerAPI) ProvingScrubStatus(ctx context.Context) ([]api.SectorScrubResult, error) {
+	return sm.Scrubber.Status(), nil
+}
+
+func (sm *StorageMinerAPI) ProvingScrubSectors(ctx context.Context, sectors []abi.SectorNumber) error {
+	return sm.Scrubber.Prioritize(sectors)
+}
+
 func (sm *StorageMinerAPI) ActorAddressConfig(ctx context.Context) (api.AddressConfig, error) {
 	return sm.AddrSel.AddressConfig, nil
 }
 
+func (sm *StorageMinerAPI) ActorControlStatus(ctx context.Context) (api.ControlStatus, error) {
+	return sm.ControlTopUp.Status(), nil
+}
+
+func (sm *StorageMinerAPI) ActorTreasuryStatus(ctx context.Context) (api.TreasuryStatus, error) {
+	return sm.Treasury.Status(), nil
+}
+
 func (sm *StorageMinerAPI) Discover(ctx context.Context) (apitypes.OpenRPCDocument, error) {
 	return build.OpenRPCDiscoverJSON_Miner(), nil
 }
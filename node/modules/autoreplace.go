@@ -0,0 +1,85 @@
+package modules
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api/v1api"
+	"github.com/filecoin-project/lotus/chain/messagepool/autoreplace"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/impl/full"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+)
+
+type ReplacerAPI struct {
+	fx.In
+
+	full.ChainModuleAPI
+	full.StateModuleAPI
+	full.MpoolAPI
+}
+
+var _ autoreplace.ReplacerAPI = &ReplacerAPI{}
+
+// RunStuckMessageReplacer replaces the stuck messages sent from the addresses
+// in the wallet of the node
+func RunStuckMessageReplacer(cfg config.StuckMessageConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rapi ReplacerAPI, j journal.Journal) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rapi ReplacerAPI, j journal.Journal) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		r := autoreplace.NewReplacer(&rapi, rapi.WalletList, cfg, j)
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go r.Run(ctx)
+				return nil
+			},
+		})
+	}
+}
+
+// RunMinerStuckMessageReplacer replaces the stuck messages sent from the
+// owner, worker and control addresses of the miner
+func RunMinerStuckMessageReplacer(cfg config.StuckMessageConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, j journal.Journal) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, j journal.Journal) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		addrs := func(ctx context.Context) ([]address.Address, error) {
+			mi, err := api.StateMinerInfo(ctx, address.Address(maddr), types.EmptyTSK)
+			if err != nil {
+				return nil, xerrors.Errorf("getting miner info: %w", err)
+			}
+
+			ids := append([]address.Address{mi.Owner, mi.Worker}, mi.ControlAddresses...)
+			out := make([]address.Address, 0, 2*len(ids))
+			for _, id := range ids {
+				out = append(out, id)
+
+				ka, err := api.StateAccountKey(ctx, id, types.EmptyTSK)
+				if err != nil {
+					// not an account, e.g. a multisig owner
+					continue
+				}
+				out = append(out, ka)
+			}
+
+			return out, nil
+		}
+
+		r := autoreplace.NewReplacer(api, addrs, cfg, j)
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go r.Run(ctx)
+				return nil
+			},
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/ipfs/go-bitswap/network"
 	"github.com/ipfs/go-blockservice"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/routing"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"go.uber.org/fx"
@@ -20,6 +21,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/exchange"
 	"github.com/filecoin-project/lotus/chain/gen/slashfilter"
+	"github.com/filecoin-project/lotus/chain/index"
 	"github.com/filecoin-project/lotus/chain/messagepool"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
@@ -28,8 +30,13 @@ import (
 	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
+	"github.com/filecoin-project/lotus/node/repo"
 )
 
+// checkpointRetryInterval is how long to wait before retrying to bootstrap
+// from a checkpoint, e.g. when the node isn't connected to peers yet
+const checkpointRetryInterval = 30 * time.Second
+
 // ChainBitswap uses a blockstore that bypasses all caches.
 func ChainBitswap(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt routing.Routing, bs dtypes.ExposedBlockstore) dtypes.ChainBitswap {
 	// prefix protocol for chain bitswap
@@ -59,6 +66,29 @@ func ChainBlockService(bs dtypes.ExposedBlockstore, rem dtypes.ChainBitswap) dty
 	return blockservice.New(bs, rem)
 }
 
+// MsgIndex opens the message index kept in the repo. The state manager is
+// constructed with it, and uses it for message searches.
+func MsgIndex(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo, cs *store.ChainStore) (index.MsgIndex, error) {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	ds, err := r.Datastore(ctx, "/msgindex")
+	if err != nil {
+		return nil, xerrors.Errorf("getting datastore out of repo: %w", err)
+	}
+
+	return index.NewMsgIndex(ds, cs), nil
+}
+
+// AddrIndex opens the address index kept in the repo.
+func AddrIndex(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo, cs *store.ChainStore, sm *stmgr.StateManager) (index.AddrIndex, error) {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	ds, err := r.Datastore(ctx, "/addrindex")
+	if err != nil {
+		return nil, xerrors.Errorf("getting datastore out of repo: %w", err)
+	}
+
+	return index.NewAddrIndex(ctx, ds, cs, sm), nil
+}
+
 func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS, nn dtypes.NetworkName, j journal.Journal) (*messagepool.MessagePool, error) {
 	mpp := messagepool.NewProvider(sm, ps)
 	mp, err := messagepool.New(mpp, ds, nn, j)
@@ -128,6 +158,8 @@ type SyncerParams struct {
 	Host         host.Host
 	Beacon       beacon.Schedule
 	Verifier     ffiwrapper.Verifier
+	Graphsync    dtypes.Graphsync
+	BlockService dtypes.ChainBlockService
 }
 
 func NewSyncer(params SyncerParams) (*chain.Syncer, error) {
@@ -146,12 +178,35 @@ func NewSyncer(params SyncerParams) (*chain.Syncer, error) {
 		return nil, err
 	}
 
+	cp, err := chain.LoadBootstrapCheckpoint(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapCtx, cancelBootstrap := context.WithCancel(context.Background())
 	lc.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
-			syncer.Start()
+			if cp == nil {
+				syncer.Start()
+				return nil
+			}
+
+			// syncing only starts once the node has bootstrapped from the
+			// checkpoint, which needs peers to fetch it from
+			go func() {
+				if err := bootstrapFromCheckpoint(bootstrapCtx, syncer, cp, params); err != nil {
+					log.Errorw("bootstrapping from checkpoint", "error", err)
+					return
+				}
+				if bootstrapCtx.Err() != nil {
+					return // stopping
+				}
+				syncer.Start()
+			}()
 			return nil
 		},
 		OnStop: func(_ context.Context) error {
+			cancelBootstrap()
 			syncer.Stop()
 			return nil
 		},
@@ -159,6 +214,37 @@ func NewSyncer(params SyncerParams) (*chain.Syncer, error) {
 	return syncer, nil
 }
 
+func bootstrapFromCheckpoint(ctx context.Context, syncer *chain.Syncer, cp *chain.Checkpoint, params SyncerParams) error {
+	peers := func() []peer.ID {
+		var out []peer.ID
+		for _, p := range params.Host.Network().Peers() {
+			protos, err := params.Host.Peerstore().SupportsProtocols(p, exchange.ChainExchangeProtocolID)
+			if err == nil && len(protos) > 0 {
+				out = append(out, p)
+			}
+		}
+		return out
+	}
+
+	for {
+		err := syncer.BootstrapFromCheckpoint(ctx, cp, params.Graphsync, params.BlockService, peers)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Warnw("bootstrapping from checkpoint failed, retrying", "error", err)
+
+		select {
+		case <-build.Clock.After(checkpointRetryInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 func NewSlashFilter(ds dtypes.MetadataDS) *slashfilter.SlashFilter {
 	return slashfilter.New(ds)
 }
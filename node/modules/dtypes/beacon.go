@@ -13,4 +13,10 @@ type DrandConfig struct {
 	Servers       []string
 	Relays        []string
 	ChainInfoJSON string
+
+	// Group selects a beacon group run by the network operators instead of a
+	// drand network (see chain/beacon/group). ChainInfoJSON then holds the
+	// group info, and Servers the member endpoints to fetch rounds from,
+	// defaulting to the members listed in the group info.
+	Group bool
 }
@@ -190,6 +190,10 @@ func GossipSub(in GossipIn) (service *pubsub.PubSub, err error) {
 
 	var drandTopics []string
 	for _, d := range in.Dr {
+		if d.Config.Group {
+			continue // beacon groups are served over http
+		}
+
 		topic, err := getDrandTopic(d.Config.ChainInfoJSON)
 		if err != nil {
 			return nil, err
@@ -96,4 +96,9 @@ func (a *MpoolNonceAPI) GetNonce(ctx context.Context, addr address.Address, tsk
 	return highestNonce, nil
 }
 
+// PendingFor returns no messages, there is no mpool to look into
+func (a *MpoolNonceAPI) PendingFor(address.Address) ([]*types.SignedMessage, *types.TipSet) {
+	return nil, nil
+}
+
 var _ messagesigner.MpoolNonceAPI = (*MpoolNonceAPI)(nil)
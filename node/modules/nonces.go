@@ -0,0 +1,39 @@
+package modules
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/messagesigner"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+)
+
+// RemoteNonceProvider connects the message signer to the nonce server with
+// the api info.
+func RemoteNonceProvider(info string) func(mctx helpers.MetricsCtx, lc fx.Lifecycle) (messagesigner.NonceProvider, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle) (messagesigner.NonceProvider, error) {
+		ai := cliutil.ParseApiInfo(info)
+
+		url, err := ai.DialArgs("v0")
+		if err != nil {
+			return nil, err
+		}
+
+		np, closer, err := messagesigner.NewRemoteNonceProvider(mctx, url, ai.AuthHeader())
+		if err != nil {
+			return nil, xerrors.Errorf("creating nonce server jsonrpc client: %w", err)
+		}
+
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				closer()
+				return nil
+			},
+		})
+
+		return np, nil
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/beacon/drand"
+	"github.com/filecoin-project/lotus/chain/beacon/group"
 	"github.com/filecoin-project/lotus/chain/exchange"
 	"github.com/filecoin-project/lotus/chain/messagepool"
 	"github.com/filecoin-project/lotus/chain/stmgr"
@@ -32,6 +33,7 @@ import (
 	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/lib/peermgr"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/hello"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
@@ -226,6 +228,15 @@ func RandomSchedule(p RandomBeaconParams, _ dtypes.AfterGenesisSet) (beacon.Sche
 
 	shd := beacon.Schedule{}
 	for _, dc := range p.DrandConfig {
+		if dc.Config.Group {
+			bc, err := group.NewGroupBeacon(gen.Timestamp, build.BlockDelaySecs, dc.Config)
+			if err != nil {
+				return nil, xerrors.Errorf("creating group beacon: %w", err)
+			}
+			shd = append(shd, beacon.BeaconPoint{Start: dc.Start, Beacon: bc})
+			continue
+		}
+
 		bc, err := drand.NewDrandBeacon(gen.Timestamp, build.BlockDelaySecs, p.PubSub, dc.Config)
 		if err != nil {
 			return nil, xerrors.Errorf("creating drand beacon: %w", err)
@@ -248,3 +259,21 @@ func OpenFilesystemJournal(lr repo.LockedRepo, lc fx.Lifecycle, disabled journal
 
 	return jrnl, err
 }
+
+func OpenFilesystemJournalWithRetention(cfg config.JournalConfig) func(lr repo.LockedRepo, lc fx.Lifecycle, disabled journal.DisabledEvents) (journal.Journal, error) {
+	return func(lr repo.LockedRepo, lc fx.Lifecycle, disabled journal.DisabledEvents) (journal.Journal, error) {
+		jrnl, err := journal.OpenFSJournalWithRetention(lr, disabled, journal.Retention{
+			MaxAge:  time.Duration(cfg.MaxAge),
+			MaxSize: int64(cfg.MaxSizeBytes),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		lc.Append(fx.Hook{
+			OnStop: func(_ context.Context) error { return jrnl.Close() },
+		})
+
+		return jrnl, nil
+	}
+}
@@ -3,12 +3,25 @@ package modules
 import (
 	"go.uber.org/fx"
 
+	"github.com/filecoin-project/lotus/chain/index"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 )
 
-func StateManager(lc fx.Lifecycle, cs *store.ChainStore, us stmgr.UpgradeSchedule) (*stmgr.StateManager, error) {
-	sm, err := stmgr.NewStateManagerWithUpgradeSchedule(cs, us)
+type StateManagerParams struct {
+	fx.In
+
+	Lifecycle       fx.Lifecycle
+	ChainStore      *store.ChainStore
+	UpgradeSchedule stmgr.UpgradeSchedule
+
+	// MsgIndex is only set when Index.EnableMsgIndex is set in the config
+	MsgIndex index.MsgIndex `optional:"true"`
+}
+
+func StateManager(params StateManagerParams) (*stmgr.StateManager, error) {
+	lc := params.Lifecycle
+	sm, err := stmgr.NewStateManagerWithMsgIndex(params.ChainStore, params.UpgradeSchedule, params.MsgIndex)
 	if err != nil {
 		return nil, err
 	}
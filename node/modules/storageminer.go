@@ -253,6 +253,86 @@ func StorageMiner(fc config.MinerFeeConfig) func(params StorageMinerParams) (*st
 	}
 }
 
+func TierMover(cfg config.StorageTieringConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, idx *stores.Index, mgr *sectorstorage.Manager, sm *storage.Miner) *storage.TierMover {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, idx *stores.Index, mgr *sectorstorage.Manager, sm *storage.Miner) *storage.TierMover {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		tm := storage.NewTierMover(api, idx, mgr, sm, address.Address(maddr), cfg)
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go tm.Run(ctx)
+				return nil
+			},
+		})
+
+		return tm
+	}
+}
+
+var ScrubResultsPrefix = datastore.NewKey("/scrub/results")
+
+func Scrubber(cfg config.StorageScrubConfig, fc config.MinerFeeConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, mgr *sectorstorage.Manager, as *storage.AddressSelector, j journal.Journal) *storage.Scrubber {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, mgr *sectorstorage.Manager, as *storage.AddressSelector, j journal.Journal) *storage.Scrubber {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		s := storage.NewScrubber(api, mgr, as, namespace.Wrap(ds, ScrubResultsPrefix), address.Address(maddr), cfg, fc, j)
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go s.Run(ctx)
+				return nil
+			},
+		})
+
+		return s
+	}
+}
+
+var ControlTopUpPrefix = datastore.NewKey("/control-topup")
+
+func ControlTopUp(cfg config.MinerAddressConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, as *storage.AddressSelector) (*storage.ControlTopUp, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, as *storage.AddressSelector) (*storage.ControlTopUp, error) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		t, err := storage.NewControlTopUp(api, as, namespace.Wrap(ds, ControlTopUpPrefix), address.Address(maddr), cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go t.Run(ctx)
+				return nil
+			},
+		})
+
+		return t, nil
+	}
+}
+
+var TreasuryPrefix = datastore.NewKey("/treasury")
+
+func Treasury(cfg config.TreasuryConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, j journal.Journal) (*storage.Treasury, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api v1api.FullNode, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, j journal.Journal) (*storage.Treasury, error) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		t, err := storage.NewTreasury(api, namespace.Wrap(ds, TreasuryPrefix), address.Address(maddr), cfg, j)
+		if err != nil {
+			return nil, err
+		}
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go t.Run(ctx)
+				return nil
+			},
+		})
+
+		return t, nil
+	}
+}
+
 func HandleRetrieval(host host.Host, lc fx.Lifecycle, m retrievalmarket.RetrievalProvider, j journal.Journal) {
 	m.OnReady(marketevents.ReadyLogger("retrieval provider"))
 	lc.Append(fx.Hook{
@@ -39,6 +39,7 @@ const (
 	fsDatastore     = "datastore"
 	fsLock          = "repo.lock"
 	fsKeystore      = "keystore"
+	fsKeystoreCrypt = "keystore.crypt"
 )
 
 type RepoType int
@@ -74,6 +75,10 @@ var ErrRepoExists = xerrors.New("repo exists")
 type FsRepo struct {
 	path       string
 	configPath string
+
+	// ksKey is the key of the encrypted keystore, set by UnlockKeyStore. It's
+	// moved to the locked repo while the repo is locked.
+	ksKey []byte
 }
 
 var _ Repo = &FsRepo{}
@@ -231,12 +236,30 @@ func (fsr *FsRepo) Lock(repoType RepoType) (LockedRepo, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("could not lock the repo: %w", err)
 	}
-	return &fsLockedRepo{
+
+	if err := recoverKeyStoreEncryption(fsr.path); err != nil {
+		_ = closer.Close()
+		return nil, xerrors.Errorf("recovering interrupted keystore encryption: %w", err)
+	}
+
+	kc, err := readKeystoreCrypt(fsr.path)
+	if err != nil {
+		_ = closer.Close()
+		return nil, err
+	}
+
+	lr := &fsLockedRepo{
 		path:       fsr.path,
 		configPath: fsr.configPath,
 		repoType:   repoType,
 		closer:     closer,
-	}, nil
+		fsr:        fsr,
+		ksCrypt:    kc,
+		ksKey:      fsr.ksKey,
+	}
+	fsr.ksKey = nil
+
+	return lr, nil
 }
 
 // Like Lock, except datastores will work in read-only mode
@@ -270,6 +293,15 @@ type fsLockedRepo struct {
 
 	storageLk sync.Mutex
 	configLk  sync.Mutex
+
+	// fsr is the repo this was locked from, which gets ksKey back on Close
+	fsr *FsRepo
+
+	// ksCrypt is set if the keystore is encrypted, and ksKey while it's
+	// unlocked
+	ksLk    sync.RWMutex
+	ksCrypt *keystoreCrypt
+	ksKey   []byte
 }
 
 func (fsr *fsLockedRepo) Readonly() bool {
@@ -301,6 +333,15 @@ func (fsr *fsLockedRepo) Close() error {
 		}
 	}
 
+	// hand the keystore key back, so that the keystore is still unlocked when
+	// the repo is locked again
+	fsr.ksLk.Lock()
+	if fsr.fsr != nil {
+		fsr.fsr.ksKey = fsr.ksKey
+	}
+	fsr.ksKey = nil
+	fsr.ksLk.Unlock()
+
 	err = fsr.closer.Close()
 	fsr.closer = nil
 	return err
@@ -536,6 +577,11 @@ func (fsr *fsLockedRepo) Get(name string) (types.KeyInfo, error) {
 		return types.KeyInfo{}, xerrors.Errorf("reading key '%s': %w", name, err)
 	}
 
+	data, err = fsr.decryptKey(name, data)
+	if err != nil {
+		return types.KeyInfo{}, xerrors.Errorf("decrypting key '%s': %w", name, err)
+	}
+
 	var res types.KeyInfo
 	err = json.Unmarshal(data, &res)
 	if err != nil {
@@ -580,6 +626,11 @@ func (fsr *fsLockedRepo) put(rawName string, info types.KeyInfo, retries int) er
 		return xerrors.Errorf("encoding key '%s': %w", name, err)
 	}
 
+	keyData, err = fsr.encryptKey(name, keyData)
+	if err != nil {
+		return xerrors.Errorf("encrypting key '%s': %w", name, err)
+	}
+
 	err = ioutil.WriteFile(keyPath, keyData, 0600)
 	if err != nil {
 		return xerrors.Errorf("writing key '%s': %w", name, err)
@@ -18,7 +18,9 @@ import (
 type dsCtor func(path string, readonly bool) (datastore.Batching, error)
 
 var fsDatastores = map[string]dsCtor{
-	"metadata": levelDs,
+	"metadata":  levelDs,
+	"msgindex":  levelDs,
+	"addrindex": levelDs,
 
 	// Those need to be fast for large writes... but also need a really good GC :c
 	"staging": badgerDs, // miner specific
@@ -0,0 +1,396 @@
+package repo
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	fslock "github.com/ipfs/go-fs-lock"
+	"github.com/multiformats/go-base32"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// scrypt parameters used when encrypting a keystore
+var (
+	scryptN = 1 << 18
+	scryptR = 8
+	scryptP = 1
+)
+
+// keystoreCheck is sealed with the key of an encrypted keystore, so that
+// passphrases can be checked without reading any key
+const keystoreCheck = "lotus keystore"
+
+// keystoreCrypt holds what's needed to derive the key of an encrypted
+// keystore from its passphrase.
+type keystoreCrypt struct {
+	KDF  string
+	Salt []byte
+	N    int
+	R    int
+	P    int
+
+	Check []byte
+}
+
+// readKeystoreCrypt reads the encryption parameters of the keystore of the
+// repo at path, returning nil if the keystore isn't encrypted.
+func readKeystoreCrypt(path string) (*keystoreCrypt, error) {
+	data, err := ioutil.ReadFile(filepath.Join(path, fsKeystoreCrypt))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("reading keystore encryption parameters: %w", err)
+	}
+
+	var kc keystoreCrypt
+	if err := json.Unmarshal(data, &kc); err != nil {
+		return nil, xerrors.Errorf("decoding keystore encryption parameters: %w", err)
+	}
+	return &kc, nil
+}
+
+// deriveKey derives the key from the passphrase, returning
+// types.ErrBadPassphrase if it isn't the keystore's.
+func (kc *keystoreCrypt) deriveKey(passphrase []byte) ([]byte, error) {
+	if kc.KDF != "scrypt" {
+		return nil, xerrors.Errorf("unsupported keystore key derivation function %q", kc.KDF)
+	}
+
+	key, err := scrypt.Key(passphrase, kc.Salt, kc.N, kc.R, kc.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, xerrors.Errorf("deriving keystore key: %w", err)
+	}
+
+	if _, err := openKey(key, "", kc.Check); err != nil {
+		return nil, types.ErrBadPassphrase
+	}
+	return key, nil
+}
+
+// sealKey encrypts the data of the named key, which is authenticated along
+// with it, so that key files can't be swapped.
+func sealKey(key []byte, name string, data []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, xerrors.Errorf("generating nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, data, []byte(name)), nil
+}
+
+func openKey(key []byte, name string, sealed []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, xerrors.Errorf("encrypted key too short")
+	}
+
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+}
+
+// KeyStoreEncrypted returns whether the keystore of the repo is encrypted.
+func (fsr *FsRepo) KeyStoreEncrypted() (bool, error) {
+	kc, err := readKeystoreCrypt(fsr.path)
+	if err != nil {
+		return false, err
+	}
+	return kc != nil, nil
+}
+
+// UnlockKeyStore checks the passphrase of the encrypted keystore of the repo,
+// which is then unlocked when the repo is locked.
+func (fsr *FsRepo) UnlockKeyStore(passphrase []byte) error {
+	kc, err := readKeystoreCrypt(fsr.path)
+	if err != nil {
+		return err
+	}
+	if kc == nil {
+		return xerrors.Errorf("keystore isn't encrypted")
+	}
+
+	key, err := kc.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	fsr.ksKey = key
+	return nil
+}
+
+// EncryptKeyStore encrypts the keys of the repo with a key derived from the
+// passphrase. The repo must not be in use.
+func (fsr *FsRepo) EncryptKeyStore(passphrase []byte) error {
+	closer, err := fslock.Lock(fsr.path, fsLock)
+	if err != nil {
+		return xerrors.Errorf("could not lock the repo: %w", err)
+	}
+	defer closer.Close() //nolint:errcheck
+
+	if err := recoverKeyStoreEncryption(fsr.path); err != nil {
+		return xerrors.Errorf("recovering interrupted keystore encryption: %w", err)
+	}
+
+	kc, err := readKeystoreCrypt(fsr.path)
+	if err != nil {
+		return err
+	}
+	if kc != nil {
+		return xerrors.Errorf("keystore is already encrypted")
+	}
+
+	kc = &keystoreCrypt{
+		KDF:  "scrypt",
+		Salt: make([]byte, 32),
+		N:    scryptN,
+		R:    scryptR,
+		P:    scryptP,
+	}
+	if _, err := rand.Read(kc.Salt); err != nil {
+		return xerrors.Errorf("generating salt: %w", err)
+	}
+
+	key, err := scrypt.Key(passphrase, kc.Salt, kc.N, kc.R, kc.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return xerrors.Errorf("deriving keystore key: %w", err)
+	}
+	kc.Check, err = sealKey(key, "", []byte(keystoreCheck))
+	if err != nil {
+		return err
+	}
+
+	// write the encrypted keys to a new directory, which then replaces the
+	// keystore. Writing the encryption parameters is the commit point, an
+	// interrupted encryption is rolled back or finished by
+	// recoverKeyStoreEncryption depending on whether they were written.
+	kstorePath := filepath.Join(fsr.path, fsKeystore)
+	encPath := kstorePath + fsKeystoreEncrypting
+	if err := os.RemoveAll(encPath); err != nil {
+		return err
+	}
+	if err := os.Mkdir(encPath, 0700); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(kstorePath)
+	if err != nil {
+		return xerrors.Errorf("reading keystore dir: %w", err)
+	}
+	for _, f := range files {
+		name, err := base32.RawStdEncoding.DecodeString(f.Name())
+		if err != nil {
+			return xerrors.Errorf("decoding key: '%s': %w", f.Name(), err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(kstorePath, f.Name()))
+		if err != nil {
+			return xerrors.Errorf("reading key '%s': %w", name, err)
+		}
+
+		sealed, err := sealKey(key, string(name), data)
+		if err != nil {
+			return xerrors.Errorf("encrypting key '%s': %w", name, err)
+		}
+
+		if err := writeFileSync(filepath.Join(encPath, f.Name()), sealed); err != nil {
+			return xerrors.Errorf("writing key '%s': %w", name, err)
+		}
+	}
+	if err := syncDir(encPath); err != nil {
+		return err
+	}
+
+	kcData, err := json.Marshal(kc)
+	if err != nil {
+		return err
+	}
+	kcPath := filepath.Join(fsr.path, fsKeystoreCrypt)
+	if err := writeFileSync(kcPath+".tmp", kcData); err != nil {
+		return xerrors.Errorf("writing keystore encryption parameters: %w", err)
+	}
+
+	plainPath := kstorePath + fsKeystorePlaintext
+	if err := os.Rename(kstorePath, plainPath); err != nil {
+		return err
+	}
+	if err := os.Rename(encPath, kstorePath); err != nil {
+		return err
+	}
+	if err := syncDir(fsr.path); err != nil {
+		return err
+	}
+	if err := os.Rename(kcPath+".tmp", kcPath); err != nil {
+		return err
+	}
+	if err := syncDir(fsr.path); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(plainPath)
+}
+
+const (
+	fsKeystoreEncrypting = ".encrypting"
+	fsKeystorePlaintext  = ".plaintext"
+)
+
+// recoverKeyStoreEncryption cleans up after an interrupted EncryptKeyStore. If
+// the encryption parameters were written the keystore was swapped for the
+// encrypted one, and only the plaintext keys are left to be removed.
+// Otherwise the plaintext keystore is moved back in place. The repo must be
+// locked.
+func recoverKeyStoreEncryption(path string) error {
+	kstorePath := filepath.Join(path, fsKeystore)
+	encPath := kstorePath + fsKeystoreEncrypting
+	plainPath := kstorePath + fsKeystorePlaintext
+	kcPath := filepath.Join(path, fsKeystoreCrypt)
+
+	_, err := os.Stat(kcPath)
+	switch {
+	case err == nil:
+		if err := os.RemoveAll(plainPath); err != nil {
+			return err
+		}
+	case os.IsNotExist(err):
+		_, err := os.Stat(plainPath)
+		switch {
+		case err == nil:
+			log.Warnw("rolling back interrupted keystore encryption", "path", kstorePath)
+
+			// the keystore is either missing or already the encrypted one
+			if err := os.RemoveAll(kstorePath); err != nil {
+				return err
+			}
+			if err := os.Rename(plainPath, kstorePath); err != nil {
+				return err
+			}
+		case !os.IsNotExist(err):
+			return err
+		}
+	default:
+		return err
+	}
+
+	if err := os.RemoveAll(encPath); err != nil {
+		return err
+	}
+	if err := os.Remove(kcPath + ".tmp"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+func (fsr *fsLockedRepo) Encrypted() bool {
+	return fsr.ksCrypt != nil
+}
+
+func (fsr *fsLockedRepo) Unlock(passphrase []byte) error {
+	if fsr.ksCrypt == nil {
+		return xerrors.Errorf("keystore isn't encrypted")
+	}
+
+	key, err := fsr.ksCrypt.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	fsr.ksLk.Lock()
+	fsr.ksKey = key
+	fsr.ksLk.Unlock()
+	return nil
+}
+
+func (fsr *fsLockedRepo) Lock() error {
+	if fsr.ksCrypt == nil {
+		return xerrors.Errorf("keystore isn't encrypted")
+	}
+
+	fsr.ksLk.Lock()
+	defer fsr.ksLk.Unlock()
+
+	for i := range fsr.ksKey {
+		fsr.ksKey[i] = 0
+	}
+	fsr.ksKey = nil
+	return nil
+}
+
+func (fsr *fsLockedRepo) Locked() bool {
+	fsr.ksLk.RLock()
+	defer fsr.ksLk.RUnlock()
+
+	return fsr.ksCrypt != nil && fsr.ksKey == nil
+}
+
+func (fsr *fsLockedRepo) encryptKey(name string, data []byte) ([]byte, error) {
+	if fsr.ksCrypt == nil {
+		return data, nil
+	}
+
+	fsr.ksLk.RLock()
+	defer fsr.ksLk.RUnlock()
+
+	if fsr.ksKey == nil {
+		return nil, types.ErrKeyStoreLocked
+	}
+	return sealKey(fsr.ksKey, name, data)
+}
+
+func (fsr *fsLockedRepo) decryptKey(name string, data []byte) ([]byte, error) {
+	if fsr.ksCrypt == nil {
+		return data, nil
+	}
+
+	fsr.ksLk.RLock()
+	defer fsr.ksLk.RUnlock()
+
+	if fsr.ksKey == nil {
+		return nil, types.ErrKeyStoreLocked
+	}
+	return openKey(fsr.ksKey, name, data)
+}
+
+var _ types.LockableKeyStore = (*fsLockedRepo)(nil)
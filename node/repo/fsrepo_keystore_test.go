@@ -0,0 +1,226 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/multiformats/go-base32"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestFsEncryptedKeyStore(t *testing.T) {
+	scryptN = 1 << 10
+
+	repo, closer := genFsRepo(t)
+	defer closer()
+
+	k1 := types.KeyInfo{Type: "foo", PrivateKey: []byte("secret1")}
+	k2 := types.KeyInfo{Type: "bar", PrivateKey: []byte("secret2")}
+
+	lrepo, err := repo.Lock(FullNode)
+	require.NoError(t, err)
+	ks, err := lrepo.KeyStore()
+	require.NoError(t, err)
+	require.NoError(t, ks.Put("k1", k1))
+	require.NoError(t, lrepo.Close())
+
+	enc, err := repo.KeyStoreEncrypted()
+	require.NoError(t, err)
+	require.False(t, enc)
+
+	require.NoError(t, repo.EncryptKeyStore([]byte("passphrase")))
+	require.Error(t, repo.EncryptKeyStore([]byte("passphrase")), "encrypting twice should fail")
+
+	enc, err = repo.KeyStoreEncrypted()
+	require.NoError(t, err)
+	require.True(t, enc)
+
+	data, err := ioutil.ReadFile(filepath.Join(repo.path, fsKeystore, base32.RawStdEncoding.EncodeToString([]byte("k1"))))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret1")
+
+	// locked
+	lrepo, err = repo.Lock(FullNode)
+	require.NoError(t, err)
+	ks, err = lrepo.KeyStore()
+	require.NoError(t, err)
+	lks := ks.(types.LockableKeyStore)
+	require.True(t, lks.Encrypted())
+	require.True(t, lks.Locked())
+
+	list, err := ks.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"k1"}, list)
+
+	_, err = ks.Get("k1")
+	require.True(t, xerrors.Is(err, types.ErrKeyStoreLocked), err)
+	require.True(t, xerrors.Is(ks.Put("k2", k2), types.ErrKeyStoreLocked))
+
+	require.Equal(t, types.ErrBadPassphrase, lks.Unlock([]byte("wrong")))
+	require.NoError(t, lks.Unlock([]byte("passphrase")))
+	require.False(t, lks.Locked())
+
+	ki, err := ks.Get("k1")
+	require.NoError(t, err)
+	require.Equal(t, k1, ki)
+	require.NoError(t, ks.Put("k2", k2))
+
+	require.NoError(t, lks.Lock())
+	_, err = ks.Get("k2")
+	require.True(t, xerrors.Is(err, types.ErrKeyStoreLocked), err)
+	require.NoError(t, lrepo.Close())
+
+	// unlocked before locking the repo
+	require.Equal(t, types.ErrBadPassphrase, repo.UnlockKeyStore([]byte("wrong")))
+	require.NoError(t, repo.UnlockKeyStore([]byte("passphrase")))
+
+	lrepo, err = repo.Lock(FullNode)
+	require.NoError(t, err)
+	ks, err = lrepo.KeyStore()
+	require.NoError(t, err)
+	ki, err = ks.Get("k2")
+	require.NoError(t, err)
+	require.Equal(t, k2, ki)
+	require.NoError(t, lrepo.Close())
+}
+
+func TestFsKeyStoreLockZeroesKey(t *testing.T) {
+	scryptN = 1 << 10
+
+	repo, closer := genFsRepo(t)
+	defer closer()
+
+	require.NoError(t, repo.EncryptKeyStore([]byte("passphrase")))
+	require.NoError(t, repo.UnlockKeyStore([]byte("passphrase")))
+	key := repo.ksKey
+
+	// the key is moved to the locked repo, and back when it's closed
+	lrepo, err := repo.Lock(FullNode)
+	require.NoError(t, err)
+	require.Nil(t, repo.ksKey)
+	require.NoError(t, lrepo.Close())
+	require.Equal(t, key, repo.ksKey)
+
+	lrepo, err = repo.Lock(FullNode)
+	require.NoError(t, err)
+	ks, err := lrepo.KeyStore()
+	require.NoError(t, err)
+	lks := ks.(types.LockableKeyStore)
+	require.False(t, lks.Locked())
+
+	require.NoError(t, lks.Lock())
+	require.Equal(t, make([]byte, len(key)), key, "key should be zeroed")
+	require.NoError(t, lrepo.Close())
+	require.Nil(t, repo.ksKey)
+
+	// locking the repo again doesn't unlock the keystore
+	lrepo, err = repo.Lock(FullNode)
+	require.NoError(t, err)
+	ks, err = lrepo.KeyStore()
+	require.NoError(t, err)
+	require.True(t, ks.(types.LockableKeyStore).Locked())
+	require.NoError(t, lrepo.Close())
+}
+
+func TestFsKeyStoreEncryptionRecovery(t *testing.T) {
+	scryptN = 1 << 10
+
+	k1 := types.KeyInfo{Type: "foo", PrivateKey: []byte("secret1")}
+	k1File := base32.RawStdEncoding.EncodeToString([]byte("k1"))
+
+	// setup creates a repo with a plaintext keystore holding k1
+	setup := func(t *testing.T) (*FsRepo, func()) {
+		repo, closer := genFsRepo(t)
+
+		lrepo, err := repo.Lock(FullNode)
+		require.NoError(t, err)
+		ks, err := lrepo.KeyStore()
+		require.NoError(t, err)
+		require.NoError(t, ks.Put("k1", k1))
+		require.NoError(t, lrepo.Close())
+
+		return repo, closer
+	}
+
+	kstorePath := func(repo *FsRepo) string {
+		return filepath.Join(repo.path, fsKeystore)
+	}
+
+	requireKey := func(t *testing.T, repo *FsRepo, encrypted bool) {
+		lrepo, err := repo.Lock(FullNode)
+		require.NoError(t, err)
+		defer lrepo.Close() //nolint:errcheck
+
+		ks, err := lrepo.KeyStore()
+		require.NoError(t, err)
+		require.Equal(t, encrypted, ks.(types.LockableKeyStore).Encrypted())
+
+		ki, err := ks.Get("k1")
+		require.NoError(t, err)
+		require.Equal(t, k1, ki)
+
+		for _, suffix := range []string{fsKeystoreEncrypting, fsKeystorePlaintext} {
+			_, err := os.Stat(kstorePath(repo) + suffix)
+			require.True(t, os.IsNotExist(err), suffix)
+		}
+		_, err = os.Stat(filepath.Join(repo.path, fsKeystoreCrypt+".tmp"))
+		require.True(t, os.IsNotExist(err))
+	}
+
+	t.Run("before swapping", func(t *testing.T) {
+		repo, closer := setup(t)
+		defer closer()
+
+		require.NoError(t, os.Mkdir(kstorePath(repo)+fsKeystoreEncrypting, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(kstorePath(repo)+fsKeystoreEncrypting, k1File), []byte("sealed"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repo.path, fsKeystoreCrypt+".tmp"), []byte("{}"), 0600))
+
+		requireKey(t, repo, false)
+	})
+
+	t.Run("keystore moved away", func(t *testing.T) {
+		repo, closer := setup(t)
+		defer closer()
+
+		require.NoError(t, os.Rename(kstorePath(repo), kstorePath(repo)+fsKeystorePlaintext))
+		require.NoError(t, os.Mkdir(kstorePath(repo)+fsKeystoreEncrypting, 0700))
+
+		requireKey(t, repo, false)
+	})
+
+	t.Run("swapped before commit", func(t *testing.T) {
+		repo, closer := setup(t)
+		defer closer()
+
+		require.NoError(t, os.Rename(kstorePath(repo), kstorePath(repo)+fsKeystorePlaintext))
+		require.NoError(t, os.Mkdir(kstorePath(repo), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(kstorePath(repo), k1File), []byte("sealed"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repo.path, fsKeystoreCrypt+".tmp"), []byte("{}"), 0600))
+
+		requireKey(t, repo, false)
+
+		// encrypting works after the rollback
+		require.NoError(t, repo.EncryptKeyStore([]byte("passphrase")))
+		require.NoError(t, repo.UnlockKeyStore([]byte("passphrase")))
+		requireKey(t, repo, true)
+	})
+
+	t.Run("committed", func(t *testing.T) {
+		repo, closer := setup(t)
+		defer closer()
+
+		require.NoError(t, repo.EncryptKeyStore([]byte("passphrase")))
+
+		// the plaintext keys weren't removed yet
+		require.NoError(t, os.Mkdir(kstorePath(repo)+fsKeystorePlaintext, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(kstorePath(repo)+fsKeystorePlaintext, k1File), []byte("plaintext"), 0600))
+
+		require.NoError(t, repo.UnlockKeyStore([]byte("passphrase")))
+		requireKey(t, repo, true)
+	})
+}
@@ -0,0 +1,485 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+// ScrubFaultEvt is the journal event recorded when the scrubber finds a
+// sector which can't be proven
+type ScrubFaultEvt struct {
+	Sector   abi.SectorNumber
+	Deadline uint64
+	Err      string
+	Failures int
+}
+
+type scrubCandidate struct {
+	sector    abi.SectorNumber
+	deadline  uint64
+	partition uint64
+}
+
+// Scrubber rotates through proving sectors in the background, checking that
+// their sealed and cache files are readable. Only sectors whose WindowPoSt
+// deadline isn't about to open are checked, so that the checks don't compete
+// with proving. Results are kept in the miner datastore.
+type Scrubber struct {
+	api          storageMinerApi
+	faultTracker sectorstorage.FaultTracker
+	addrSel      *AddressSelector
+	ds           datastore.Batching
+	maddr        address.Address
+
+	cfg    config.StorageScrubConfig
+	feeCfg config.MinerFeeConfig
+
+	journal     journal.Journal
+	evtTypeFail journal.EventType
+
+	lk       sync.Mutex
+	results  map[abi.SectorNumber]api.SectorScrubResult
+	priority map[abi.SectorNumber]struct{}
+}
+
+func NewScrubber(sapi storageMinerApi, ft sectorstorage.FaultTracker, as *AddressSelector, ds datastore.Batching, maddr address.Address, cfg config.StorageScrubConfig, fc config.MinerFeeConfig, j journal.Journal) *Scrubber {
+	return &Scrubber{
+		api:          sapi,
+		faultTracker: ft,
+		addrSel:      as,
+		ds:           ds,
+		maddr:        maddr,
+
+		cfg:    cfg,
+		feeCfg: fc,
+
+		journal:     j,
+		evtTypeFail: j.RegisterEventType("scrub", "sector_fault"),
+
+		results:  map[abi.SectorNumber]api.SectorScrubResult{},
+		priority: map[abi.SectorNumber]struct{}{},
+	}
+}
+
+func (s *Scrubber) Run(ctx context.Context) {
+	if err := s.load(); err != nil {
+		log.Errorf("loading scrub results: %+v", err)
+	}
+
+	if !s.cfg.EnableScrub {
+		return
+	}
+
+	interval := time.Duration(s.cfg.CheckInterval)
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	ticker := build.Clock.Ticker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := s.scrub(ctx); err != nil {
+			log.Errorf("scrubbing sectors: %+v", err)
+		}
+	}
+}
+
+// Status returns the latest check result of each sector
+func (s *Scrubber) Status() []api.SectorScrubResult {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	out := make([]api.SectorScrubResult, 0, len(s.results))
+	for _, r := range s.results {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Sector < out[j].Sector
+	})
+
+	return out
+}
+
+// Prioritize makes the sectors be checked before any other sectors
+func (s *Scrubber) Prioritize(sectors []abi.SectorNumber) error {
+	if !s.cfg.EnableScrub {
+		return xerrors.Errorf("the scrubber is disabled (Scrub.EnableScrub in the miner config)")
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for _, sn := range sectors {
+		s.priority[sn] = struct{}{}
+	}
+
+	return nil
+}
+
+func (s *Scrubber) load() error {
+	res, err := s.ds.Query(query.Query{})
+	if err != nil {
+		return err
+	}
+	defer res.Close() // nolint
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return e.Error
+		}
+
+		var r api.SectorScrubResult
+		if err := json.Unmarshal(e.Value, &r); err != nil {
+			return xerrors.Errorf("decoding scrub result (%s): %w", e.Key, err)
+		}
+		s.results[r.Sector] = r
+	}
+
+	return nil
+}
+
+func (s *Scrubber) scrub(ctx context.Context) error {
+	head, err := s.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	di, err := s.api.StateMinerProvingDeadline(ctx, s.maddr, head.Key())
+	if err != nil {
+		return xerrors.Errorf("getting proving deadline: %w", err)
+	}
+
+	var candidates []scrubCandidate
+	for dlIdx := uint64(0); dlIdx < di.WPoStPeriodDeadlines; dlIdx++ {
+		if !scrubDeadlineAllowed(di, dlIdx, abi.ChainEpoch(s.cfg.DeadlineGuardEpochs)) {
+			continue
+		}
+
+		partitions, err := s.api.StateMinerPartitions(ctx, s.maddr, dlIdx, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting partitions of deadline %d: %w", dlIdx, err)
+		}
+
+		for partIdx, part := range partitions {
+			// faulty sectors are checked for recovery by the WindowPoSt scheduler
+			toCheck, err := bitfield.SubtractBitField(part.LiveSectors, part.FaultySectors)
+			if err != nil {
+				return xerrors.Errorf("determining non faulty sectors: %w", err)
+			}
+
+			err = toCheck.ForEach(func(sn uint64) error {
+				candidates = append(candidates, scrubCandidate{
+					sector:    abi.SectorNumber(sn),
+					deadline:  dlIdx,
+					partition: uint64(partIdx),
+				})
+				return nil
+			})
+			if err != nil {
+				return xerrors.Errorf("iterating sectors: %w", err)
+			}
+		}
+	}
+
+	s.lk.Lock()
+	batch := pickScrubBatch(candidates, s.results, s.priority, s.cfg.SectorsPerCheck)
+	s.lk.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return s.check(ctx, head, di, batch)
+}
+
+func (s *Scrubber) check(ctx context.Context, head *types.TipSet, di *dline.Info, batch []scrubCandidate) error {
+	mid, err := address.IDFromAddress(s.maddr)
+	if err != nil {
+		return err
+	}
+
+	mi, err := s.api.StateMinerInfo(ctx, s.maddr, head.Key())
+	if err != nil {
+		return xerrors.Errorf("getting miner info: %w", err)
+	}
+
+	bf := bitfield.New()
+	for _, c := range batch {
+		bf.Set(uint64(c.sector))
+	}
+
+	infos, err := s.api.StateMinerSectors(ctx, s.maddr, &bf, head.Key())
+	if err != nil {
+		return xerrors.Errorf("getting sector infos: %w", err)
+	}
+
+	commR := map[abi.SectorNumber]cid.Cid{}
+	var refs []storage.SectorRef
+	for _, info := range infos {
+		commR[info.SectorNumber] = info.SealedCID
+		refs = append(refs, storage.SectorRef{
+			ID: abi.SectorID{
+				Miner:  abi.ActorID(mid),
+				Number: info.SectorNumber,
+			},
+			ProofType: info.SealProof,
+		})
+	}
+
+	var rg storiface.RGetter
+	if s.cfg.GenerateVanillaProofs {
+		rg = func(ctx context.Context, id abi.SectorID) (cid.Cid, error) {
+			c, ok := commR[id.Number]
+			if !ok {
+				return cid.Undef, xerrors.Errorf("sector %d not found on chain", id.Number)
+			}
+			return c, nil
+		}
+	}
+
+	bad, err := s.faultTracker.CheckProvable(ctx, mi.WindowPoStProofType, refs, rg)
+	if err != nil {
+		return xerrors.Errorf("checking sectors: %w", err)
+	}
+
+	now := build.Clock.Now()
+
+	var failed []scrubCandidate
+	for _, c := range batch {
+		if _, ok := commR[c.sector]; !ok {
+			// not live anymore
+			continue
+		}
+
+		s.lk.Lock()
+		r := s.results[c.sector]
+		s.lk.Unlock()
+
+		r.Sector = c.sector
+		r.Deadline = c.deadline
+		r.Partition = c.partition
+		r.Checked = now
+		r.Epoch = head.Height()
+		r.VanillaProof = s.cfg.GenerateVanillaProofs
+		r.Err = ""
+		r.FaultMsg = nil
+
+		if e, isBad := bad[abi.SectorID{Miner: abi.ActorID(mid), Number: c.sector}]; isBad {
+			r.Err = e
+			r.Failures++
+			failed = append(failed, c)
+
+			log.Errorw("SECTOR FAILED INTEGRITY CHECK", "sector", c.sector, "deadline", c.deadline, "partition", c.partition, "failures", r.Failures, "error", e)
+			s.journal.RecordEvent(s.evtTypeFail, func() interface{} {
+				return &ScrubFaultEvt{
+					Sector:   c.sector,
+					Deadline: c.deadline,
+					Err:      e,
+					Failures: r.Failures,
+				}
+			})
+		} else {
+			r.Failures = 0
+		}
+
+		if err := s.record(r); err != nil {
+			return err
+		}
+	}
+
+	log.Infow("scrubbed sectors", "checked", len(refs), "bad", len(failed))
+
+	if len(failed) == 0 || !s.cfg.DeclareFaults {
+		return nil
+	}
+
+	return s.declareFaults(ctx, di, failed)
+}
+
+func (s *Scrubber) record(r api.SectorScrubResult) error {
+	b, err := json.Marshal(&r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.ds.Put(scrubKey(r.Sector), b); err != nil {
+		return xerrors.Errorf("storing scrub result of sector %d: %w", r.Sector, err)
+	}
+
+	s.lk.Lock()
+	s.results[r.Sector] = r
+	delete(s.priority, r.Sector)
+	s.lk.Unlock()
+
+	return nil
+}
+
+func (s *Scrubber) declareFaults(ctx context.Context, di *dline.Info, failed []scrubCandidate) error {
+	// faults can't be declared for deadlines past the declaration cutoff; the
+	// WindowPoSt scheduler will find them when it checks the deadline
+	var declare []scrubCandidate
+	for _, c := range failed {
+		if epochsToDeadline(di, c.deadline) <= miner.FaultDeclarationCutoff {
+			log.Warnw("not declaring scrubber fault, deadline past the fault declaration cutoff", "sector", c.sector, "deadline", c.deadline)
+			continue
+		}
+		declare = append(declare, c)
+	}
+
+	if len(declare) == 0 {
+		return nil
+	}
+
+	params := &miner.DeclareFaultsParams{
+		Faults: groupFaults(declare),
+	}
+
+	enc, aerr := actors.SerializeParams(params)
+	if aerr != nil {
+		return xerrors.Errorf("could not serialize declare faults parameters: %w", aerr)
+	}
+
+	msg := &types.Message{
+		To:     s.maddr,
+		Method: miner.Methods.DeclareFaults,
+		Params: enc,
+		Value:  types.NewInt(0),
+	}
+	spec := &api.MessageSendSpec{MaxFee: abi.TokenAmount(s.feeCfg.MaxWindowPoStGasFee)}
+	if err := setPoStSender(ctx, s.api, s.addrSel, s.maddr, msg, spec); err != nil {
+		return err
+	}
+
+	sm, err := s.api.MpoolPushMessage(ctx, msg, spec)
+	if err != nil {
+		return xerrors.Errorf("pushing message to mpool: %w", err)
+	}
+
+	log.Warnw("declared faults for sectors failing integrity checks", "cid", sm.Cid(), "sectors", len(declare))
+
+	mcid := sm.Cid()
+	for _, c := range declare {
+		s.lk.Lock()
+		r := s.results[c.sector]
+		s.lk.Unlock()
+
+		r.FaultMsg = &mcid
+		if err := s.record(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scrubKey(sn abi.SectorNumber) datastore.Key {
+	return datastore.NewKey(strconv.FormatUint(uint64(sn), 10))
+}
+
+// scrubDeadlineAllowed returns whether sectors in the deadline can be checked,
+// that is the deadline isn't open and doesn't open within the guard
+func scrubDeadlineAllowed(di *dline.Info, deadline uint64, guard abi.ChainEpoch) bool {
+	return epochsToDeadline(di, deadline) > guard
+}
+
+// pickScrubBatch picks up to n sectors to check; prioritized sectors first,
+// then sectors which were never checked, then the ones checked longest ago
+func pickScrubBatch(candidates []scrubCandidate, results map[abi.SectorNumber]api.SectorScrubResult, priority map[abi.SectorNumber]struct{}, n int) []scrubCandidate {
+	if n <= 0 {
+		n = 1
+	}
+
+	sorted := make([]scrubCandidate, len(candidates))
+	copy(sorted, candidates)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		_, pi := priority[sorted[i].sector]
+		_, pj := priority[sorted[j].sector]
+		if pi != pj {
+			return pi
+		}
+
+		ci, cj := results[sorted[i].sector].Checked, results[sorted[j].sector].Checked
+		if !ci.Equal(cj) {
+			return ci.Before(cj)
+		}
+
+		return sorted[i].sector < sorted[j].sector
+	})
+
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	return sorted
+}
+
+// groupFaults builds fault declarations for the sectors, one per partition
+func groupFaults(sectors []scrubCandidate) []miner.FaultDeclaration {
+	type dlPart struct {
+		deadline, partition uint64
+	}
+
+	byPart := map[dlPart][]uint64{}
+	for _, c := range sectors {
+		k := dlPart{c.deadline, c.partition}
+		byPart[k] = append(byPart[k], uint64(c.sector))
+	}
+
+	out := make([]miner.FaultDeclaration, 0, len(byPart))
+	for k, sns := range byPart {
+		out = append(out, miner.FaultDeclaration{
+			Deadline:  k.deadline,
+			Partition: k.partition,
+			Sectors:   bitfield.NewFromSet(sns),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Deadline != out[j].Deadline {
+			return out[i].Deadline < out[j].Deadline
+		}
+		return out[i].Partition < out[j].Partition
+	})
+
+	return out
+}
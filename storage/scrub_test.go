@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestPickScrubBatch(t *testing.T) {
+	now := time.Now()
+
+	candidates := []scrubCandidate{
+		{sector: 1}, {sector: 2}, {sector: 3}, {sector: 4}, {sector: 5},
+	}
+	results := map[abi.SectorNumber]api.SectorScrubResult{
+		1: {Sector: 1, Checked: now.Add(-time.Hour)},
+		2: {Sector: 2, Checked: now.Add(-2 * time.Hour)},
+		3: {Sector: 3, Checked: now},
+		// 4 and 5 were never checked
+	}
+
+	sectors := func(b []scrubCandidate) []abi.SectorNumber {
+		var out []abi.SectorNumber
+		for _, c := range b {
+			out = append(out, c.sector)
+		}
+		return out
+	}
+
+	require.Equal(t, []abi.SectorNumber{4, 5, 2}, sectors(pickScrubBatch(candidates, results, nil, 3)))
+	require.Equal(t, []abi.SectorNumber{4, 5, 2, 1, 3}, sectors(pickScrubBatch(candidates, results, nil, 10)))
+
+	prio := map[abi.SectorNumber]struct{}{3: {}}
+	require.Equal(t, []abi.SectorNumber{3, 4}, sectors(pickScrubBatch(candidates, results, prio, 2)))
+
+	require.Empty(t, pickScrubBatch(nil, results, prio, 2))
+}
+
+func TestGroupFaults(t *testing.T) {
+	faults := groupFaults([]scrubCandidate{
+		{sector: 7, deadline: 3, partition: 1},
+		{sector: 2, deadline: 1, partition: 0},
+		{sector: 5, deadline: 3, partition: 1},
+		{sector: 9, deadline: 3, partition: 0},
+	})
+
+	require.Len(t, faults, 3)
+
+	expect := []struct {
+		deadline, partition uint64
+		sectors             []uint64
+	}{
+		{1, 0, []uint64{2}},
+		{3, 0, []uint64{9}},
+		{3, 1, []uint64{5, 7}},
+	}
+	for i, e := range expect {
+		require.Equal(t, e.deadline, faults[i].Deadline)
+		require.Equal(t, e.partition, faults[i].Partition)
+
+		sns, err := faults[i].Sectors.All(10)
+		require.NoError(t, err)
+		require.Equal(t, e.sectors, sns)
+	}
+}
+
+func TestScrubDeadlineAllowed(t *testing.T) {
+	di := NewDeadlineInfo(0, 2, 130)
+
+	require.False(t, scrubDeadlineAllowed(di, 2, 20))
+	require.True(t, scrubDeadlineAllowed(di, 3, 20))
+	require.False(t, scrubDeadlineAllowed(di, 3, di.Open+di.WPoStChallengeWindow-130))
+	require.True(t, scrubDeadlineAllowed(di, 1, 20))
+}
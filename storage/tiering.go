@@ -0,0 +1,644 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+// how many finished migrations are kept around for `storage migrate`
+const migrationHistory = 100
+
+// sector files moved between tiers
+const tierMoveTypes = storiface.FTSealed | storiface.FTCache | storiface.FTUnsealed
+
+const (
+	TierSectorsAny   = "any"
+	TierSectorsCC    = "cc"
+	TierSectorsDeals = "deals"
+)
+
+type tierMoverAPI interface {
+	ChainHead(context.Context) (*types.TipSet, error)
+	StateMinerSectors(context.Context, address.Address, *bitfield.BitField, types.TipSetKey) ([]*miner.SectorOnChainInfo, error)
+	StateSectorPartition(ctx context.Context, maddr address.Address, sectorNumber abi.SectorNumber, tok types.TipSetKey) (*miner.SectorLocation, error)
+	StateMinerProvingDeadline(context.Context, address.Address, types.TipSetKey) (*dline.Info, error)
+}
+
+type tierMoverIndex interface {
+	StorageList(ctx context.Context) (map[stores.ID][]stores.Decl, error)
+	StorageInfo(context.Context, stores.ID) (stores.StorageInfo, error)
+}
+
+type tierMoverStorage interface {
+	StorageLocal(ctx context.Context) (map[stores.ID]string, error)
+	FsStat(ctx context.Context, id stores.ID) (fsutil.FsStat, error)
+	MigrateSector(ctx context.Context, sector storage.SectorRef, types storiface.SectorFileType, dest stores.ID, lim *rate.Limiter, progress stores.MigrateProgress) error
+}
+
+type tierMoverSectors interface {
+	ListSectors() ([]sealing.SectorInfo, error)
+	GetSectorInfo(sid abi.SectorNumber) (sealing.SectorInfo, error)
+}
+
+// TierMover migrates finalized sectors between storage paths tagged with
+// different tiers, either according to the configured rules or on request.
+// Sectors are moved one at a time, with optionally throttled IO, and never
+// shortly before their WindowPoSt deadline opens.
+type TierMover struct {
+	api     tierMoverAPI
+	index   tierMoverIndex
+	storage tierMoverStorage
+	sectors tierMoverSectors
+	maddr   address.Address
+
+	cfg config.StorageTieringConfig
+	lim *rate.Limiter
+
+	trigger chan struct{}
+
+	lk         sync.Mutex
+	queue      []*api.StorageMigration
+	active     *api.StorageMigration
+	migrations []*api.StorageMigration // finished
+}
+
+func NewTierMover(fapi tierMoverAPI, index tierMoverIndex, st tierMoverStorage, sectors tierMoverSectors, maddr address.Address, cfg config.StorageTieringConfig) *TierMover {
+	var lim *rate.Limiter
+	if cfg.MaxMoveBytesPerSecond > 0 {
+		lim = rate.NewLimiter(rate.Limit(cfg.MaxMoveBytesPerSecond), stores.CopyBuf)
+	}
+
+	return &TierMover{
+		api:     fapi,
+		index:   index,
+		storage: st,
+		sectors: sectors,
+		maddr:   maddr,
+
+		cfg: cfg,
+		lim: lim,
+
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+func (tm *TierMover) Run(ctx context.Context) {
+	interval := time.Duration(tm.cfg.CheckInterval)
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	ticker := build.Clock.Ticker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-tm.trigger:
+		case <-ctx.Done():
+			return
+		}
+
+		tm.processQueue(ctx)
+
+		if !tm.cfg.EnableAutoMigration {
+			continue
+		}
+
+		for _, rule := range tm.cfg.Rules {
+			if err := tm.applyRule(ctx, rule); err != nil {
+				log.Errorf("applying tier rule %s -> %s: %+v", rule.From, rule.To, err)
+			}
+		}
+	}
+}
+
+// Migrate queues a move of the sector into a local path tagged with the tier
+func (tm *TierMover) Migrate(ctx context.Context, sector abi.SectorNumber, tier string) error {
+	if tier == "" {
+		return xerrors.Errorf("destination tier not specified")
+	}
+
+	si, err := tm.sectors.GetSectorInfo(sector)
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+	if si.State != sealing.Proving {
+		return xerrors.Errorf("sector %d is in state %s, only sectors in %s state can be migrated", sector, si.State, sealing.Proving)
+	}
+
+	tm.lk.Lock()
+	if tm.active != nil && tm.active.Sector == sector {
+		tm.lk.Unlock()
+		return xerrors.Errorf("sector %d is already being migrated", sector)
+	}
+	for _, m := range tm.queue {
+		if m.Sector == sector {
+			tm.lk.Unlock()
+			return xerrors.Errorf("sector %d is already queued for migration", sector)
+		}
+	}
+	tm.queue = append(tm.queue, &api.StorageMigration{
+		Sector: sector,
+		ToTier: tier,
+		Reason: "manual",
+		State:  api.MigrationQueued,
+		Queued: build.Clock.Now(),
+	})
+	tm.lk.Unlock()
+
+	select {
+	case tm.trigger <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Migrations returns queued, running and recently finished migrations
+func (tm *TierMover) Migrations() []api.StorageMigration {
+	tm.lk.Lock()
+	defer tm.lk.Unlock()
+
+	out := make([]api.StorageMigration, 0, len(tm.queue)+len(tm.migrations)+1)
+	for _, m := range tm.migrations {
+		out = append(out, *m)
+	}
+	if tm.active != nil {
+		out = append(out, *tm.active)
+	}
+	for _, m := range tm.queue {
+		out = append(out, *m)
+	}
+
+	return out
+}
+
+// processQueue runs queued migrations. Migrations of sectors whose deadline
+// opens soon stay queued, and are retried on the next run.
+func (tm *TierMover) processQueue(ctx context.Context) {
+	postponed := map[*api.StorageMigration]bool{}
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// migrations leave the queue when they become active or finish
+		var mig *api.StorageMigration
+		tm.lk.Lock()
+		for _, m := range tm.queue {
+			if !postponed[m] {
+				mig = m
+				break
+			}
+		}
+		tm.lk.Unlock()
+		if mig == nil {
+			return
+		}
+
+		paths, err := tm.tierPaths(ctx)
+		if err != nil {
+			tm.finish(mig, xerrors.Errorf("listing storage paths: %w", err))
+			continue
+		}
+
+		src, found := sectorPath(paths, mig.Sector, tm.maddr)
+		if !found {
+			tm.finish(mig, xerrors.Errorf("sector not found in local storage"))
+			continue
+		}
+		if src.info.Tier == mig.ToTier {
+			tm.finish(mig, nil)
+			continue
+		}
+
+		tooSoon, err := tm.deadlineTooSoon(ctx, mig.Sector)
+		if err != nil {
+			tm.finish(mig, err)
+			continue
+		}
+		if tooSoon {
+			log.Debugf("postponing migration of sector %d, its deadline opens too soon", mig.Sector)
+			postponed[mig] = true
+			continue
+		}
+
+		si, err := tm.sectors.GetSectorInfo(mig.Sector)
+		if err != nil {
+			tm.finish(mig, xerrors.Errorf("getting sector info: %w", err))
+			continue
+		}
+
+		dest, err := pickTierDest(paths, src, mig.ToTier, si.SectorType, localFileTypes(paths, mig.Sector, tm.maddr))
+		if err != nil {
+			tm.finish(mig, err)
+			continue
+		}
+
+		tm.migrate(ctx, mig, si.SectorType, src.info.ID, dest)
+	}
+}
+
+func (tm *TierMover) applyRule(ctx context.Context, rule config.TierRule) error {
+	paths, err := tm.tierPaths(ctx)
+	if err != nil {
+		return xerrors.Errorf("listing storage paths: %w", err)
+	}
+
+	var hasDest bool
+	for _, p := range paths {
+		hasDest = hasDest || (p.info.Tier == rule.To && p.info.CanStore)
+	}
+	if !hasDest || tierFreePercent(paths, rule.From) < 0 {
+		return nil
+	}
+	if rule.FromMinFreePercent > 0 && tierFreePercent(paths, rule.From) >= int64(rule.FromMinFreePercent) {
+		return nil
+	}
+
+	head, err := tm.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	onChain, err := tm.api.StateMinerSectors(ctx, tm.maddr, nil, head.Key())
+	if err != nil {
+		return xerrors.Errorf("getting miner sectors: %w", err)
+	}
+
+	local, err := tm.sectors.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+	proving := map[abi.SectorNumber]sealing.SectorInfo{}
+	for _, si := range local {
+		if si.State == sealing.Proving {
+			proving[si.SectorNumber] = si
+		}
+	}
+
+	minAge := abi.ChainEpoch(time.Duration(rule.MinAge) / (time.Duration(build.BlockDelaySecs) * time.Second))
+
+	var candidates []*miner.SectorOnChainInfo
+	for _, soc := range onChain {
+		if _, ok := proving[soc.SectorNumber]; !ok {
+			continue
+		}
+		if !ruleMatches(rule, soc, head.Height(), minAge) {
+			continue
+		}
+		if p, found := sectorPath(paths, soc.SectorNumber, tm.maddr); !found || p.info.Tier != rule.From {
+			continue
+		}
+
+		candidates = append(candidates, soc)
+	}
+
+	// oldest sectors first
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Activation < candidates[j].Activation
+	})
+
+	for _, soc := range candidates {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if rule.FromMinFreePercent > 0 {
+			if paths, err = tm.tierPaths(ctx); err != nil {
+				return xerrors.Errorf("listing storage paths: %w", err)
+			}
+			if tierFreePercent(paths, rule.From) >= int64(rule.FromMinFreePercent) {
+				return nil
+			}
+		}
+
+		tooSoon, err := tm.deadlineTooSoon(ctx, soc.SectorNumber)
+		if err != nil {
+			return err
+		}
+		if tooSoon {
+			log.Debugf("not migrating sector %d, its deadline opens too soon", soc.SectorNumber)
+			continue
+		}
+
+		src, found := sectorPath(paths, soc.SectorNumber, tm.maddr)
+		if !found || src.info.Tier != rule.From {
+			continue
+		}
+
+		dest, err := pickTierDest(paths, src, rule.To, soc.SealProof, localFileTypes(paths, soc.SectorNumber, tm.maddr))
+		if err != nil {
+			log.Warnf("tier rule %s -> %s: %s", rule.From, rule.To, err)
+			return nil
+		}
+
+		tm.migrate(ctx, &api.StorageMigration{
+			Sector: soc.SectorNumber,
+			ToTier: rule.To,
+			Reason: fmt.Sprintf("rule %s -> %s", rule.From, rule.To),
+			State:  api.MigrationQueued,
+			Queued: build.Clock.Now(),
+		}, soc.SealProof, src.info.ID, dest)
+	}
+
+	return nil
+}
+
+// deadlineTooSoon checks whether the WindowPoSt deadline of the sector opens
+// within DeadlineGuardEpochs
+func (tm *TierMover) deadlineTooSoon(ctx context.Context, sector abi.SectorNumber) (bool, error) {
+	di, err := tm.api.StateMinerProvingDeadline(ctx, tm.maddr, types.EmptyTSK)
+	if err != nil {
+		return false, xerrors.Errorf("getting proving deadline: %w", err)
+	}
+
+	loc, err := tm.api.StateSectorPartition(ctx, tm.maddr, sector, types.EmptyTSK)
+	if err != nil {
+		return false, xerrors.Errorf("getting sector %d location: %w", sector, err)
+	}
+
+	return epochsToDeadline(di, loc.Deadline) < abi.ChainEpoch(tm.cfg.DeadlineGuardEpochs), nil
+}
+
+func (tm *TierMover) migrate(ctx context.Context, mig *api.StorageMigration, spt abi.RegisteredSealProof, src, dest stores.ID) {
+	mid, err := address.IDFromAddress(tm.maddr)
+	if err != nil {
+		tm.finish(mig, err)
+		return
+	}
+
+	tm.lk.Lock()
+	mig.From = src
+	mig.To = dest
+	mig.State = api.MigrationMoving
+	mig.Started = build.Clock.Now()
+	tm.active = mig
+	tm.dequeue(mig)
+	tm.lk.Unlock()
+
+	log.Infow("migrating sector", "sector", mig.Sector, "from", src, "to", dest, "reason", mig.Reason)
+
+	ref := storage.SectorRef{
+		ID: abi.SectorID{
+			Miner:  abi.ActorID(mid),
+			Number: mig.Sector,
+		},
+		ProofType: spt,
+	}
+
+	err = tm.storage.MigrateSector(ctx, ref, tierMoveTypes, dest, tm.lim, func(moved, total int64) {
+		tm.lk.Lock()
+		mig.Moved, mig.Total = moved, total
+		tm.lk.Unlock()
+	})
+	if err != nil {
+		log.Errorf("migrating sector %d to %s: %+v", mig.Sector, dest, err)
+	}
+
+	tm.finish(mig, err)
+}
+
+func (tm *TierMover) finish(mig *api.StorageMigration, err error) {
+	tm.lk.Lock()
+	defer tm.lk.Unlock()
+
+	mig.State = api.MigrationDone
+	if err != nil {
+		mig.State = api.MigrationFailed
+		mig.Err = err.Error()
+	}
+	mig.Finished = build.Clock.Now()
+
+	if tm.active == mig {
+		tm.active = nil
+	}
+	tm.dequeue(mig)
+
+	tm.migrations = append(tm.migrations, mig)
+	if len(tm.migrations) > migrationHistory {
+		tm.migrations = tm.migrations[len(tm.migrations)-migrationHistory:]
+	}
+}
+
+// dequeue removes the migration from the queue, tm.lk must be held
+func (tm *TierMover) dequeue(mig *api.StorageMigration) {
+	for i, m := range tm.queue {
+		if m == mig {
+			tm.queue = append(tm.queue[:i:i], tm.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+type tierPath struct {
+	info stores.StorageInfo
+	stat fsutil.FsStat
+
+	sectors map[abi.SectorID]storiface.SectorFileType
+}
+
+// tierPaths returns storage paths local to the miner process
+func (tm *TierMover) tierPaths(ctx context.Context) ([]tierPath, error) {
+	local, err := tm.storage.StorageLocal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	decls, err := tm.index.StorageList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []tierPath
+	for id := range local {
+		info, err := tm.index.StorageInfo(ctx, id)
+		if err != nil {
+			return nil, xerrors.Errorf("getting storage info for %s: %w", id, err)
+		}
+
+		st, err := tm.storage.FsStat(ctx, id)
+		if err != nil {
+			return nil, xerrors.Errorf("getting storage stat for %s: %w", id, err)
+		}
+
+		sectors := map[abi.SectorID]storiface.SectorFileType{}
+		for _, decl := range decls[id] {
+			sectors[decl.SectorID] |= decl.SectorFileType
+		}
+
+		out = append(out, tierPath{
+			info:    info,
+			stat:    st,
+			sectors: sectors,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].info.ID < out[j].info.ID
+	})
+
+	return out, nil
+}
+
+// sectorPath finds the path holding the sealed replica of the sector
+func sectorPath(paths []tierPath, sector abi.SectorNumber, maddr address.Address) (tierPath, bool) {
+	mid, err := address.IDFromAddress(maddr)
+	if err != nil {
+		return tierPath{}, false
+	}
+
+	sid := abi.SectorID{Miner: abi.ActorID(mid), Number: sector}
+	for _, p := range paths {
+		if p.sectors[sid]&storiface.FTSealed != 0 {
+			return p, true
+		}
+	}
+
+	return tierPath{}, false
+}
+
+// localFileTypes returns the files of the sector held in the local paths,
+// limited to the ones a migration moves
+func localFileTypes(paths []tierPath, sector abi.SectorNumber, maddr address.Address) storiface.SectorFileType {
+	mid, err := address.IDFromAddress(maddr)
+	if err != nil {
+		return tierMoveTypes
+	}
+
+	sid := abi.SectorID{Miner: abi.ActorID(mid), Number: sector}
+	var ft storiface.SectorFileType
+	for _, p := range paths {
+		ft |= p.sectors[sid]
+	}
+
+	return ft & tierMoveTypes
+}
+
+// tierFreePercent returns free space in the tier as a percentage of its
+// capacity, or -1 if there are no paths in the tier
+func tierFreePercent(paths []tierPath, tier string) int64 {
+	var capacity, avail int64
+	for _, p := range paths {
+		if p.info.Tier != tier {
+			continue
+		}
+
+		capacity += p.stat.Capacity
+		avail += p.stat.Available
+		if p.stat.Max > 0 {
+			capacity += p.stat.Max - p.stat.Capacity
+		}
+	}
+
+	if capacity <= 0 {
+		return -1
+	}
+
+	return avail * 100 / capacity
+}
+
+// pickTierDest picks a path in the tier with enough space for the given
+// sector files, preferring paths which share a group with the source path,
+// then ones with the most weighted free space
+func pickTierDest(paths []tierPath, src tierPath, tier string, spt abi.RegisteredSealProof, ft storiface.SectorFileType) (stores.ID, error) {
+	ssize, err := spt.SectorSize()
+	if err != nil {
+		return "", err
+	}
+
+	need, err := ft.StoreSpaceUse(ssize)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []tierPath
+	for _, p := range paths {
+		if p.info.Tier != tier || !p.info.CanStore || p.info.ID == src.info.ID {
+			continue
+		}
+		if uint64(p.stat.Available) < need {
+			continue
+		}
+
+		candidates = append(candidates, p)
+	}
+
+	if len(candidates) == 0 {
+		return "", xerrors.Errorf("no path in tier '%s' with %d bytes available", tier, need)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		gi, gj := sharesGroup(candidates[i].info, src.info), sharesGroup(candidates[j].info, src.info)
+		if gi != gj {
+			return gi
+		}
+
+		wi := uint64(candidates[i].stat.Available) * candidates[i].info.Weight
+		wj := uint64(candidates[j].stat.Available) * candidates[j].info.Weight
+		return wi > wj
+	})
+
+	return candidates[0].info.ID, nil
+}
+
+func sharesGroup(a, b stores.StorageInfo) bool {
+	for _, ga := range a.Groups {
+		for _, gb := range b.Groups {
+			if ga == gb {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ruleMatches(rule config.TierRule, soc *miner.SectorOnChainInfo, height, minAge abi.ChainEpoch) bool {
+	switch rule.Sectors {
+	case TierSectorsCC:
+		if len(soc.DealIDs) > 0 {
+			return false
+		}
+	case TierSectorsDeals:
+		if len(soc.DealIDs) == 0 {
+			return false
+		}
+	}
+
+	return height-soc.Activation >= minAge
+}
+
+// epochsToDeadline returns the number of epochs until the given deadline
+// opens; zero or less if it's currently open
+func epochsToDeadline(di *dline.Info, deadline uint64) abi.ChainEpoch {
+	if di.WPoStPeriodDeadlines == 0 {
+		return 0
+	}
+
+	n := (deadline + di.WPoStPeriodDeadlines - di.Index) % di.WPoStPeriodDeadlines
+	return di.Open + abi.ChainEpoch(n)*di.WPoStChallengeWindow - di.CurrentEpoch
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+func TestPickTierDest(t *testing.T) {
+	mkPath := func(id stores.ID, tier string, weight uint64, avail int64, groups ...string) tierPath {
+		return tierPath{
+			info: stores.StorageInfo{ID: id, Tier: tier, Weight: weight, CanStore: true, Groups: groups},
+			stat: fsutil.FsStat{Capacity: 1 << 40, Available: avail},
+		}
+	}
+
+	src := mkPath("src", "hot", 10, 1<<30, "rack1")
+	paths := []tierPath{
+		src,
+		mkPath("full", "cold", 100, 1<<10),
+		mkPath("big", "cold", 10, 1<<30),
+		mkPath("rack", "cold", 10, 3<<10, "rack1"),
+		mkPath("other", "archive", 100, 1<<30),
+	}
+
+	sealed := storiface.FTSealed | storiface.FTCache
+
+	// same group wins over available space
+	dest, err := pickTierDest(paths, src, "cold", abi.RegisteredSealProof_StackedDrg2KiBV1, sealed)
+	require.NoError(t, err)
+	require.Equal(t, stores.ID("rack"), dest)
+
+	// an unsealed copy doesn't fit in the same group path
+	dest, err = pickTierDest(paths, src, "cold", abi.RegisteredSealProof_StackedDrg2KiBV1, sealed|storiface.FTUnsealed)
+	require.NoError(t, err)
+	require.Equal(t, stores.ID("big"), dest)
+
+	// without a shared group, available space times weight decides
+	src.info.Groups = nil
+	dest, err = pickTierDest(paths, src, "cold", abi.RegisteredSealProof_StackedDrg2KiBV1, sealed)
+	require.NoError(t, err)
+	require.Equal(t, stores.ID("big"), dest)
+
+	_, err = pickTierDest(paths, src, "nope", abi.RegisteredSealProof_StackedDrg2KiBV1, sealed)
+	require.Error(t, err)
+}
+
+type tierTestAPI struct {
+	di  *dline.Info
+	loc miner.SectorLocation
+}
+
+func (a *tierTestAPI) ChainHead(context.Context) (*types.TipSet, error) {
+	panic("not used")
+}
+
+func (a *tierTestAPI) StateMinerSectors(context.Context, address.Address, *bitfield.BitField, types.TipSetKey) ([]*miner.SectorOnChainInfo, error) {
+	panic("not used")
+}
+
+func (a *tierTestAPI) StateSectorPartition(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (*miner.SectorLocation, error) {
+	return &a.loc, nil
+}
+
+func (a *tierTestAPI) StateMinerProvingDeadline(context.Context, address.Address, types.TipSetKey) (*dline.Info, error) {
+	return a.di, nil
+}
+
+type tierTestStorage struct {
+	infos map[stores.ID]stores.StorageInfo
+	decls map[stores.ID][]stores.Decl
+
+	migrate func(storage.SectorRef, storiface.SectorFileType, stores.ID)
+}
+
+func (s *tierTestStorage) StorageList(context.Context) (map[stores.ID][]stores.Decl, error) {
+	return s.decls, nil
+}
+
+func (s *tierTestStorage) StorageInfo(_ context.Context, id stores.ID) (stores.StorageInfo, error) {
+	return s.infos[id], nil
+}
+
+func (s *tierTestStorage) StorageLocal(context.Context) (map[stores.ID]string, error) {
+	out := map[stores.ID]string{}
+	for id := range s.infos {
+		out[id] = string(id)
+	}
+	return out, nil
+}
+
+func (s *tierTestStorage) FsStat(context.Context, stores.ID) (fsutil.FsStat, error) {
+	return fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}, nil
+}
+
+func (s *tierTestStorage) MigrateSector(_ context.Context, sector storage.SectorRef, ft storiface.SectorFileType, dest stores.ID, _ *rate.Limiter, _ stores.MigrateProgress) error {
+	s.migrate(sector, ft, dest)
+	return nil
+}
+
+type tierTestSectors struct{}
+
+func (tierTestSectors) ListSectors() ([]sealing.SectorInfo, error) {
+	panic("not used")
+}
+
+func (tierTestSectors) GetSectorInfo(sid abi.SectorNumber) (sealing.SectorInfo, error) {
+	return sealing.SectorInfo{
+		State:        sealing.Proving,
+		SectorNumber: sid,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+	}, nil
+}
+
+func TestTierMoverQueue(t *testing.T) {
+	ctx := context.Background()
+
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	sid := abi.SectorID{Miner: 1000, Number: 1}
+
+	fapi := &tierTestAPI{
+		// the sector deadline opens in one challenge window
+		di:  NewDeadlineInfo(0, 0, 0),
+		loc: miner.SectorLocation{Deadline: 1},
+	}
+	st := &tierTestStorage{
+		infos: map[stores.ID]stores.StorageInfo{
+			"hot":  {ID: "hot", Tier: "hot", Weight: 10, CanStore: true},
+			"cold": {ID: "cold", Tier: "cold", Weight: 10, CanStore: true},
+		},
+		decls: map[stores.ID][]stores.Decl{
+			"hot": {{SectorID: sid, SectorFileType: storiface.FTSealed | storiface.FTCache}},
+		},
+	}
+
+	tm := NewTierMover(fapi, st, st, tierTestSectors{}, maddr, config.StorageTieringConfig{
+		DeadlineGuardEpochs: uint64(miner.WPoStChallengeWindow) + 1,
+	})
+
+	var migrated int
+	st.migrate = func(sector storage.SectorRef, ft storiface.SectorFileType, dest stores.ID) {
+		migrated++
+		require.Equal(t, sid, sector.ID)
+		require.Equal(t, stores.ID("cold"), dest)
+
+		// the sector can't be queued again while it's being moved
+		require.Error(t, tm.Migrate(ctx, 1, "cold"))
+	}
+
+	require.NoError(t, tm.Migrate(ctx, 1, "cold"))
+	require.Error(t, tm.Migrate(ctx, 1, "cold"))
+
+	// the deadline opens too soon, the migration stays queued
+	tm.processQueue(ctx)
+	require.Equal(t, 0, migrated)
+	migs := tm.Migrations()
+	require.Len(t, migs, 1)
+	require.Equal(t, api.MigrationQueued, migs[0].State)
+
+	tm.cfg.DeadlineGuardEpochs = uint64(miner.WPoStChallengeWindow) - 1
+	tm.processQueue(ctx)
+	require.Equal(t, 1, migrated)
+	migs = tm.Migrations()
+	require.Len(t, migs, 1)
+	require.Equal(t, api.MigrationDone, migs[0].State)
+}
+
+func TestRuleMatches(t *testing.T) {
+	cc := &miner.SectorOnChainInfo{Activation: 100}
+	deal := &miner.SectorOnChainInfo{Activation: 100, DealIDs: []abi.DealID{1}}
+
+	require.True(t, ruleMatches(config.TierRule{Sectors: TierSectorsAny}, cc, 200, 100))
+	require.False(t, ruleMatches(config.TierRule{Sectors: TierSectorsAny}, cc, 199, 100))
+	require.True(t, ruleMatches(config.TierRule{Sectors: TierSectorsCC}, cc, 200, 0))
+	require.False(t, ruleMatches(config.TierRule{Sectors: TierSectorsCC}, deal, 200, 0))
+	require.True(t, ruleMatches(config.TierRule{Sectors: TierSectorsDeals}, deal, 200, 0))
+	require.False(t, ruleMatches(config.TierRule{Sectors: TierSectorsDeals}, cc, 200, 0))
+}
+
+func TestEpochsToDeadline(t *testing.T) {
+	di := NewDeadlineInfo(0, 2, 130)
+
+	// current deadline is already open
+	require.Less(t, int64(epochsToDeadline(di, 2)), int64(0))
+	require.EqualValues(t, di.Open+di.WPoStChallengeWindow-130, epochsToDeadline(di, 3))
+	// wraps around into the next proving period
+	require.EqualValues(t, di.Open+abi.ChainEpoch(di.WPoStPeriodDeadlines-1)*di.WPoStChallengeWindow-130, epochsToDeadline(di, 1))
+
+	require.EqualValues(t, 0, epochsToDeadline(&dline.Info{}, 1))
+}
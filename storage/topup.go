@@ -0,0 +1,431 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+var (
+	// TopUpCheckInterval is how often control address balances are checked
+	TopUpCheckInterval = 10 * time.Minute
+	// topUpWindow is the window MaxDailyTopUp applies to, and over which the
+	// burn of control addresses is measured
+	topUpWindow = 24 * time.Hour
+	// minBurnWindow is how long balances must be watched before their burn is
+	// projected
+	minBurnWindow = time.Hour
+	// topUpExpiry is how long a top up may take to land on chain, after which
+	// its message is considered lost and the address may be topped up again.
+	// The expired top up still counts towards MaxDailyTopUp.
+	topUpExpiry = time.Hour
+)
+
+type balanceSample struct {
+	at      time.Time
+	balance abi.TokenAmount
+}
+
+// ControlTopUp watches the balances of the control addresses the
+// AddressSelector picks from, and tops up the ones which drop below the
+// minimum balance of their role from the TopUpFrom wallet, at most
+// MaxDailyTopUp in any 24 hours. Sent top ups are kept in the miner
+// datastore.
+type ControlTopUp struct {
+	api     storageMinerApi
+	addrSel *AddressSelector
+	ds      datastore.Batching
+	maddr   address.Address
+
+	from     address.Address
+	maxDaily abi.TokenAmount
+	minBal   map[api.AddrUse]abi.TokenAmount
+
+	lk      sync.Mutex
+	samples map[address.Address][]balanceSample
+	topUps  map[string]api.ControlTopUp
+	status  []api.ControlAddressStatus
+}
+
+func NewControlTopUp(sapi storageMinerApi, as *AddressSelector, ds datastore.Batching, maddr address.Address, cfg config.MinerAddressConfig) (*ControlTopUp, error) {
+	t := &ControlTopUp{
+		api:     sapi,
+		addrSel: as,
+		ds:      ds,
+		maddr:   maddr,
+
+		maxDaily: abi.TokenAmount(cfg.MaxDailyTopUp),
+		minBal: map[api.AddrUse]abi.TokenAmount{
+			api.PreCommitAddr:        abi.TokenAmount(cfg.PreCommitMinBalance),
+			api.CommitAddr:           abi.TokenAmount(cfg.CommitMinBalance),
+			api.TerminateSectorsAddr: abi.TokenAmount(cfg.TerminateMinBalance),
+		},
+
+		samples: map[address.Address][]balanceSample{},
+		topUps:  map[string]api.ControlTopUp{},
+	}
+
+	if cfg.TopUpFrom != "" {
+		from, err := address.NewFromString(cfg.TopUpFrom)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing top up address: %w", err)
+		}
+		t.from = from
+	}
+
+	return t, nil
+}
+
+func (t *ControlTopUp) Run(ctx context.Context) {
+	if err := t.load(); err != nil {
+		log.Errorf("loading control address top ups: %+v", err)
+	}
+
+	ticker := build.Clock.Ticker(TopUpCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.check(ctx); err != nil {
+			log.Errorf("checking control address balances: %+v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the balances of the control addresses with a minimum
+// balance as of the last check, and the recent top ups
+func (t *ControlTopUp) Status() api.ControlStatus {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	out := api.ControlStatus{
+		Addresses:     append([]api.ControlAddressStatus{}, t.status...),
+		TopUpFrom:     t.from,
+		MaxDailyTopUp: t.maxDaily,
+		SentLastDay:   t.sentSince(build.Clock.Now().Add(-topUpWindow)),
+	}
+	for _, tu := range t.topUps {
+		out.TopUps = append(out.TopUps, tu)
+	}
+	sort.Slice(out.TopUps, func(i, j int) bool {
+		return out.TopUps[i].Sent.Before(out.TopUps[j].Sent)
+	})
+
+	return out
+}
+
+// roleAddrs returns the addresses used for each role with a minimum balance,
+// and the minimum balance of each address
+func (t *ControlTopUp) roleAddrs(ctx context.Context) (map[address.Address][]string, map[address.Address]abi.TokenAmount, error) {
+	mi, err := t.api.StateMinerInfo(ctx, t.maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("getting miner info: %w", err)
+	}
+
+	roles := map[address.Address][]string{}
+	mins := map[address.Address]abi.TokenAmount{}
+
+	for _, r := range []struct {
+		use   api.AddrUse
+		name  string
+		addrs []address.Address
+	}{
+		{api.PreCommitAddr, "precommit", t.addrSel.PreCommitControl},
+		{api.CommitAddr, "commit", t.addrSel.CommitControl},
+		{api.TerminateSectorsAddr, "terminate", t.addrSel.TerminateControl},
+	} {
+		min := t.minBal[r.use]
+		if min.Int == nil || min.IsZero() {
+			continue
+		}
+
+		addrs := r.addrs
+		if len(addrs) == 0 {
+			addrs = []address.Address{mi.Worker}
+		}
+
+		for _, a := range addrs {
+			id, err := t.api.StateLookupID(ctx, a, types.EmptyTSK)
+			if err != nil {
+				log.Warnw("looking up control address", "address", a, "error", err)
+				continue
+			}
+
+			roles[id] = append(roles[id], r.name)
+			if cur, ok := mins[id]; !ok || min.GreaterThan(cur) {
+				mins[id] = min
+			}
+		}
+	}
+
+	return roles, mins, nil
+}
+
+func (t *ControlTopUp) check(ctx context.Context) error {
+	roles, mins, err := t.roleAddrs(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.updatePending(ctx)
+
+	now := build.Clock.Now()
+	balances := map[address.Address]abi.TokenAmount{}
+	for a := range mins {
+		b, err := t.api.WalletBalance(ctx, a)
+		if err != nil {
+			log.Errorw("checking control address balance", "address", a, "error", err)
+			continue
+		}
+		balances[a] = b
+	}
+
+	t.lk.Lock()
+	status := make([]api.ControlAddressStatus, 0, len(balances))
+	for a, b := range balances {
+		status = append(status, api.ControlAddressStatus{
+			Address:    a,
+			Roles:      roles[a],
+			Balance:    b,
+			MinBalance: mins[a],
+			BurnPerDay: t.addSample(a, now, b),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Address.String() < status[j].Address.String()
+	})
+	t.status = status
+	t.lk.Unlock()
+
+	if t.from == address.Undef {
+		for a, b := range balances {
+			if b.LessThan(mins[a]) {
+				log.Warnw("control address balance below minimum", "address", a, "balance", types.FIL(b), "min", types.FIL(mins[a]))
+			}
+		}
+		return nil
+	}
+
+	for a, b := range balances {
+		if b.GreaterThanEqual(mins[a]) || t.hasPending(a) {
+			continue
+		}
+
+		amt := big.Sub(big.Mul(mins[a], big.NewInt(2)), b)
+
+		t.lk.Lock()
+		left := big.Sub(t.maxDaily, t.sentSince(now.Add(-topUpWindow)))
+		t.lk.Unlock()
+
+		if left.LessThanEqual(big.Zero()) {
+			log.Errorw("control address balance below minimum, daily top up limit reached", "address", a, "balance", types.FIL(b), "min", types.FIL(mins[a]), "limit", types.FIL(t.maxDaily))
+			continue
+		}
+		if amt.GreaterThan(left) {
+			log.Warnw("top up limited by the daily top up limit", "address", a, "amount", types.FIL(amt), "left", types.FIL(left))
+			amt = left
+		}
+
+		if err := t.topUp(ctx, a, amt); err != nil {
+			log.Errorw("topping up control address", "address", a, "amount", types.FIL(amt), "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *ControlTopUp) topUp(ctx context.Context, to address.Address, amt abi.TokenAmount) error {
+	sm, err := t.api.MpoolPushMessage(ctx, &types.Message{
+		From:   t.from,
+		To:     to,
+		Value:  amt,
+		Method: 0,
+	}, nil)
+	if err != nil {
+		return xerrors.Errorf("pushing message: %w", err)
+	}
+
+	log.Infow("topping up control address", "address", to, "amount", types.FIL(amt), "from", t.from, "msg", sm.Cid())
+
+	return t.save(api.ControlTopUp{
+		To:     to,
+		Amount: amt,
+		Msg:    sm.Cid(),
+		Sent:   build.Clock.Now(),
+	})
+}
+
+// updatePending checks whether the pending top ups landed on chain, expires
+// the ones which didn't land in time, and forgets the landed and expired ones
+// older than a day
+func (t *ControlTopUp) updatePending(ctx context.Context) {
+	t.lk.Lock()
+	var pending []api.ControlTopUp
+	for _, tu := range t.topUps {
+		if !tu.Landed {
+			pending = append(pending, tu)
+		}
+	}
+	t.lk.Unlock()
+
+	for _, tu := range pending {
+		ml, err := t.api.StateSearchMsg(ctx, types.EmptyTSK, tu.Msg, api.LookbackNoLimit, true)
+		if err != nil {
+			log.Errorw("looking up top up message", "msg", tu.Msg, "error", err)
+			continue
+		}
+		if ml == nil {
+			if tu.Expired || build.Clock.Since(tu.Sent) < topUpExpiry {
+				continue
+			}
+
+			log.Warnw("top up message didn't land in time", "msg", tu.Msg, "to", tu.To, "sent", tu.Sent)
+			tu.Expired = true
+			if err := t.save(tu); err != nil {
+				log.Errorw("saving top up", "msg", tu.Msg, "error", err)
+			}
+			continue
+		}
+		if ml.Receipt.ExitCode.IsError() {
+			log.Errorw("top up message failed", "msg", ml.Message, "to", tu.To, "exit", ml.Receipt.ExitCode)
+		}
+
+		tu.Landed = true
+		if err := t.save(tu); err != nil {
+			log.Errorw("saving top up", "msg", tu.Msg, "error", err)
+		}
+	}
+
+	cutoff := build.Clock.Now().Add(-topUpWindow)
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	for k, tu := range t.topUps {
+		if !(tu.Landed || tu.Expired) || tu.Sent.After(cutoff) {
+			continue
+		}
+		if err := t.ds.Delete(datastore.NewKey(k)); err != nil {
+			log.Errorw("deleting top up", "msg", tu.Msg, "error", err)
+			continue
+		}
+		delete(t.topUps, k)
+	}
+}
+
+func (t *ControlTopUp) hasPending(a address.Address) bool {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	for _, tu := range t.topUps {
+		if tu.To == a && !tu.Landed && !tu.Expired {
+			return true
+		}
+	}
+	return false
+}
+
+// sentSince must be called with the lock held
+func (t *ControlTopUp) sentSince(since time.Time) abi.TokenAmount {
+	sent := big.Zero()
+	for _, tu := range t.topUps {
+		if tu.Sent.After(since) {
+			sent = big.Add(sent, tu.Amount)
+		}
+	}
+	return sent
+}
+
+// addSample records the balance of the address, and returns its average burn
+// per day over the sampled window. Must be called with the lock held
+func (t *ControlTopUp) addSample(a address.Address, at time.Time, b abi.TokenAmount) *abi.TokenAmount {
+	samples := append(t.samples[a], balanceSample{at: at, balance: b})
+	for len(samples) > 0 && at.Sub(samples[0].at) > topUpWindow {
+		samples = samples[1:]
+	}
+	t.samples[a] = samples
+
+	window := at.Sub(samples[0].at)
+	if window < minBurnWindow {
+		return nil
+	}
+
+	// balance increases are top ups and other incoming transfers, only count
+	// the decreases
+	burn := big.Zero()
+	for i := 1; i < len(samples); i++ {
+		if d := big.Sub(samples[i-1].balance, samples[i].balance); d.GreaterThan(big.Zero()) {
+			burn = big.Add(burn, d)
+		}
+	}
+
+	perDay := big.Div(big.Mul(burn, big.NewInt(int64(topUpWindow))), big.NewInt(int64(window)))
+	return &perDay
+}
+
+func (t *ControlTopUp) load() error {
+	res, err := t.ds.Query(query.Query{})
+	if err != nil {
+		return err
+	}
+	defer res.Close() // nolint
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return e.Error
+		}
+
+		var tu api.ControlTopUp
+		if err := json.Unmarshal(e.Value, &tu); err != nil {
+			return xerrors.Errorf("decoding top up (%s): %w", e.Key, err)
+		}
+		t.topUps[e.Key] = tu
+	}
+
+	return nil
+}
+
+func (t *ControlTopUp) save(tu api.ControlTopUp) error {
+	k := datastore.NewKey(tu.Msg.String())
+
+	b, err := json.Marshal(tu)
+	if err != nil {
+		return xerrors.Errorf("encoding top up: %w", err)
+	}
+	if err := t.ds.Put(k, b); err != nil {
+		return xerrors.Errorf("saving top up: %w", err)
+	}
+
+	t.lk.Lock()
+	t.topUps[k.String()] = tu
+	t.lk.Unlock()
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/raulk/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+func TestControlTopUpBurn(t *testing.T) {
+	tu := &ControlTopUp{
+		samples: map[address.Address][]balanceSample{},
+		topUps:  map[string]api.ControlTopUp{},
+	}
+	a, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.Nil(t, tu.addSample(a, start, types.NewInt(1000)))
+	require.Nil(t, tu.addSample(a, start.Add(30*time.Minute), types.NewInt(900)))
+
+	// a top up in between doesn't count as negative burn
+	require.Nil(t, tu.addSample(a, start.Add(45*time.Minute), types.NewInt(2900)))
+
+	burn := tu.addSample(a, start.Add(2*time.Hour), types.NewInt(2800))
+	require.NotNil(t, burn)
+	// 200 in 2 hours
+	require.True(t, burn.Equals(types.NewInt(2400)), burn.String())
+
+	// samples older than a day are dropped
+	burn = tu.addSample(a, start.Add(26*time.Hour), types.NewInt(2800))
+	require.NotNil(t, burn)
+	require.True(t, burn.Equals(abi.NewTokenAmount(0)), burn.String())
+}
+
+func TestControlTopUpSentSince(t *testing.T) {
+	now := time.Now()
+	tu := &ControlTopUp{
+		topUps: map[string]api.ControlTopUp{
+			"/a": {Amount: types.NewInt(5), Sent: now.Add(-25 * time.Hour), Landed: true},
+			"/b": {Amount: types.NewInt(7), Sent: now.Add(-time.Hour), Landed: true},
+			"/c": {Amount: types.NewInt(11), Sent: now},
+		},
+	}
+
+	sent := tu.sentSince(now.Add(-topUpWindow))
+	require.True(t, sent.Equals(types.NewInt(18)), sent.String())
+}
+
+// topUpTestAPI is a miner with its worker as the only control address
+type topUpTestAPI struct {
+	storageMinerApi
+
+	worker  address.Address
+	balance abi.TokenAmount
+
+	pushed []*types.SignedMessage
+	landed map[cid.Cid]bool
+}
+
+func (ta *topUpTestAPI) StateMinerInfo(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error) {
+	return miner.MinerInfo{Worker: ta.worker}, nil
+}
+
+func (ta *topUpTestAPI) StateLookupID(_ context.Context, a address.Address, _ types.TipSetKey) (address.Address, error) {
+	return a, nil
+}
+
+func (ta *topUpTestAPI) WalletBalance(context.Context, address.Address) (types.BigInt, error) {
+	return ta.balance, nil
+}
+
+func (ta *topUpTestAPI) MpoolPushMessage(_ context.Context, msg *types.Message, _ *api.MessageSendSpec) (*types.SignedMessage, error) {
+	msg.Nonce = uint64(len(ta.pushed))
+	sm := &types.SignedMessage{Message: *msg}
+	ta.pushed = append(ta.pushed, sm)
+	return sm, nil
+}
+
+func (ta *topUpTestAPI) StateSearchMsg(_ context.Context, _ types.TipSetKey, msg cid.Cid, _ abi.ChainEpoch, _ bool) (*api.MsgLookup, error) {
+	if !ta.landed[msg] {
+		return nil, nil
+	}
+	return &api.MsgLookup{Message: msg}, nil
+}
+
+func TestControlTopUpCheck(t *testing.T) {
+	ctx := context.Background()
+
+	mc := clock.NewMock()
+	defer func(c clock.Clock) { build.Clock = c }(build.Clock)
+	build.Clock = mc
+
+	from, worker := mock.Address(100), mock.Address(101)
+	ta := &topUpTestAPI{
+		worker:  worker,
+		balance: types.NewInt(50),
+		landed:  map[cid.Cid]bool{},
+	}
+
+	tu, err := NewControlTopUp(ta, &AddressSelector{}, datastore.NewMapDatastore(), mock.Address(1000), config.MinerAddressConfig{
+		PreCommitMinBalance: types.FIL(types.NewInt(100)),
+		MaxDailyTopUp:       types.FIL(types.NewInt(1000)),
+		TopUpFrom:           from.String(),
+	})
+	require.NoError(t, err)
+
+	// topped up to twice the minimum balance
+	require.NoError(t, tu.check(ctx))
+	require.Len(t, ta.pushed, 1)
+	require.Equal(t, worker, ta.pushed[0].Message.To)
+	require.True(t, ta.pushed[0].Message.Value.Equals(types.NewInt(150)))
+
+	// not again while the top up is pending
+	mc.Add(topUpExpiry / 2)
+	require.NoError(t, tu.check(ctx))
+	require.Len(t, ta.pushed, 1)
+
+	// the top up didn't land in time, the address is topped up again
+	mc.Add(topUpExpiry)
+	require.NoError(t, tu.check(ctx))
+	require.Len(t, ta.pushed, 2)
+
+	ta.landed[ta.pushed[1].Cid()] = true
+	ta.balance = types.NewInt(200)
+	mc.Add(time.Minute)
+	require.NoError(t, tu.check(ctx))
+	require.Len(t, ta.pushed, 2)
+
+	st := tu.Status()
+	require.Len(t, st.TopUps, 2)
+	require.True(t, st.TopUps[0].Expired)
+	require.False(t, st.TopUps[0].Landed)
+	require.True(t, st.TopUps[1].Landed)
+	// the expired top up still counts towards the daily limit
+	require.True(t, st.SentLastDay.Equals(types.NewInt(300)), st.SentLastDay.String())
+
+	// both are forgotten after a day
+	mc.Add(topUpWindow)
+	require.NoError(t, tu.check(ctx))
+	require.Empty(t, tu.Status().TopUps)
+}
@@ -0,0 +1,587 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+const (
+	TreasuryWithdraw = "withdraw"
+	TreasuryVote     = "vote"
+	TreasuryCold     = "cold"
+	TreasuryPledge   = "pledge"
+)
+
+// treasuryMaxActions is the number of recent actions kept for the status
+const treasuryMaxActions = 32
+
+var treasuryStateKey = datastore.NewKey("/state")
+
+type treasuryState struct {
+	LastRun time.Time
+	Actions []api.TreasuryAction
+
+	// Withdrawal is set from signing a withdrawal until it's split
+	Withdrawal *pendingWithdrawal `json:",omitempty"`
+	// Pending is set while a withdrawal is being split
+	Pending *pendingSplit `json:",omitempty"`
+}
+
+// pendingWithdrawal is a withdrawal message, saved before it's pushed, so that
+// the next run waits for it and splits it if the miner was stopped
+type pendingWithdrawal struct {
+	Msg       *types.SignedMessage
+	Recipient address.Address
+}
+
+// pendingSplit holds the parts of a withdrawal which weren't sent yet, so that
+// they are sent by the next run if sending failed, or the miner was stopped.
+// The message of each part is saved before it's pushed, and pushed again by
+// the next run, so that a part is never sent twice.
+type pendingSplit struct {
+	Withdrawal cid.Cid
+	From       address.Address
+
+	Vote   abi.TokenAmount
+	Cold   abi.TokenAmount
+	Pledge abi.TokenAmount
+
+	VoteMsg   *types.SignedMessage `json:",omitempty"`
+	ColdMsg   *types.SignedMessage `json:",omitempty"`
+	PledgeMsg *types.SignedMessage `json:",omitempty"`
+}
+
+// Treasury periodically withdraws the available miner balance above the
+// configured reserve, and splits it by the configured shares between voting
+// it back to the miner with AddPos, a cold wallet, and KPledge collateral.
+// Every action is recorded in the journal.
+type Treasury struct {
+	api   storageMinerApi
+	ds    datastore.Batching
+	maddr address.Address
+	cfg   config.TreasuryConfig
+
+	cold   address.Address
+	pledge address.Address
+
+	journal   journal.Journal
+	evtAction journal.EventType
+
+	lk sync.Mutex
+	st treasuryState
+}
+
+func NewTreasury(sapi storageMinerApi, ds datastore.Batching, maddr address.Address, cfg config.TreasuryConfig, j journal.Journal) (*Treasury, error) {
+	t := &Treasury{
+		api:   sapi,
+		ds:    ds,
+		maddr: maddr,
+		cfg:   cfg,
+
+		journal:   j,
+		evtAction: j.RegisterEventType("treasury", "action"),
+	}
+
+	if cfg.ColdWallet != "" {
+		a, err := address.NewFromString(cfg.ColdWallet)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing cold wallet address: %w", err)
+		}
+		t.cold = a
+	} else if cfg.EnableTreasury && cfg.ColdShare > 0 {
+		return nil, xerrors.Errorf("Treasury.ColdShare is set without a Treasury.ColdWallet")
+	}
+
+	if cfg.PledgeWallet != "" {
+		a, err := address.NewFromString(cfg.PledgeWallet)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing pledge wallet address: %w", err)
+		}
+		t.pledge = a
+	}
+
+	return t, nil
+}
+
+func (t *Treasury) Run(ctx context.Context) {
+	if err := t.load(); err != nil {
+		log.Errorf("loading treasury state: %+v", err)
+	}
+
+	if !t.cfg.EnableTreasury {
+		return
+	}
+
+	for {
+		timer := build.Clock.Timer(build.Clock.Until(t.nextRun()))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		if err := t.run(ctx); err != nil {
+			log.Errorf("treasury withdrawal: %+v", err)
+		}
+
+		t.lk.Lock()
+		t.st.LastRun = build.Clock.Now()
+		t.lk.Unlock()
+
+		if err := t.save(); err != nil {
+			log.Errorf("saving treasury state: %+v", err)
+		}
+	}
+}
+
+// Status returns the schedule and the recent actions of the treasury
+func (t *Treasury) Status() api.TreasuryStatus {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	out := api.TreasuryStatus{
+		Enabled: t.cfg.EnableTreasury,
+		LastRun: t.st.LastRun,
+		Actions: append([]api.TreasuryAction{}, t.st.Actions...),
+	}
+	if t.cfg.EnableTreasury {
+		out.NextRun = t.st.LastRun.Add(t.interval())
+	}
+
+	return out
+}
+
+func (t *Treasury) interval() time.Duration {
+	if t.cfg.WithdrawInterval <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(t.cfg.WithdrawInterval)
+}
+
+func (t *Treasury) nextRun() time.Time {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	return t.st.LastRun.Add(t.interval())
+}
+
+func (t *Treasury) run(ctx context.Context) error {
+	if err := t.splitWithdrawal(ctx); err != nil {
+		return xerrors.Errorf("splitting previous withdrawal: %w", err)
+	}
+
+	head, err := t.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	mi, err := t.api.StateMinerInfo(ctx, t.maddr, head.Key())
+	if err != nil {
+		return xerrors.Errorf("getting miner info: %w", err)
+	}
+
+	available, err := t.api.StateMinerAvailableBalance(ctx, t.maddr, head.Key())
+	if err != nil {
+		return xerrors.Errorf("getting available balance: %w", err)
+	}
+	if mi.Beneficiary != mi.Owner {
+		// the beneficiary can only receive up to its quota
+		available = big.Min(available, mi.BeneficiaryTerm.Available(head.Height()))
+	}
+
+	amount := big.Sub(available, abi.TokenAmount(t.cfg.Reserve))
+	if amount.LessThan(abi.TokenAmount(t.cfg.MinWithdraw)) || amount.LessThanEqual(big.Zero()) {
+		log.Infow("treasury: not enough available balance to withdraw", "available", types.FIL(available), "reserve", t.cfg.Reserve, "min", t.cfg.MinWithdraw)
+		return nil
+	}
+
+	owner, err := t.walletKey(ctx, mi.Owner)
+	if err != nil {
+		return xerrors.Errorf("owner: %w", err)
+	}
+	recipient, err := t.walletKey(ctx, mi.Beneficiary)
+	if err != nil {
+		return xerrors.Errorf("withdrawal recipient: %w", err)
+	}
+
+	params, err := actors.SerializeParams(&miner.WithdrawBalanceParams{
+		AmountRequested: amount,
+	})
+	if err != nil {
+		return xerrors.Errorf("serializing params: %w", err)
+	}
+
+	sm, err := t.sign(ctx, &types.Message{
+		To:     t.maddr,
+		From:   owner,
+		Value:  types.NewInt(0),
+		Method: miner.Methods.WithdrawBalance,
+		Params: params,
+	})
+	if err != nil {
+		return xerrors.Errorf("signing withdrawal: %w", err)
+	}
+
+	t.lk.Lock()
+	t.st.Withdrawal = &pendingWithdrawal{
+		Msg:       sm,
+		Recipient: recipient,
+	}
+	t.lk.Unlock()
+	if err := t.save(); err != nil {
+		return xerrors.Errorf("saving withdrawal: %w", err)
+	}
+
+	return t.splitWithdrawal(ctx)
+}
+
+// splitWithdrawal pushes the saved withdrawal, waits for it, and sends the
+// split of the amount withdrawn. Without a withdrawal, it sends what's left of
+// the pending split.
+func (t *Treasury) splitWithdrawal(ctx context.Context) error {
+	t.lk.Lock()
+	w := t.st.Withdrawal
+	t.lk.Unlock()
+
+	if w == nil {
+		return t.sendSplit(ctx)
+	}
+
+	var requested miner.WithdrawBalanceParams
+	if err := requested.UnmarshalCBOR(bytes.NewReader(w.Msg.Message.Params)); err != nil {
+		return xerrors.Errorf("decoding withdrawal params: %w", err)
+	}
+
+	// the withdrawal is forgotten when it can't land, or failed
+	forget := func(err error) error {
+		t.lk.Lock()
+		t.st.Withdrawal = nil
+		t.lk.Unlock()
+		if serr := t.save(); serr != nil {
+			return xerrors.Errorf("saving treasury state: %w (%s)", serr, err)
+		}
+		return err
+	}
+
+	wmsg := w.Msg.Cid()
+	dropped, err := t.push(ctx, TreasuryWithdraw, w.Msg, requested.AmountRequested)
+	if err != nil {
+		return err
+	}
+	if dropped {
+		return forget(xerrors.Errorf("withdrawal %s was dropped", wmsg))
+	}
+
+	ml, err := t.api.StateWaitMsg(ctx, wmsg, build.MessageConfidence, api.LookbackNoLimit, true)
+	if err != nil {
+		return xerrors.Errorf("waiting for withdrawal %s: %w", wmsg, err)
+	}
+	if ml.Receipt.ExitCode.IsError() {
+		return forget(xerrors.Errorf("withdrawal %s failed: exit %d", wmsg, ml.Receipt.ExitCode))
+	}
+
+	// the actor may withdraw less than requested, if the balance or the
+	// beneficiary quota changed meanwhile; actors not returning the amount
+	// withdraw the requested amount as long as it's available
+	withdrawn := requested.AmountRequested
+	if len(ml.Receipt.Return) > 0 {
+		if err := withdrawn.UnmarshalCBOR(bytes.NewReader(ml.Receipt.Return)); err != nil {
+			return xerrors.Errorf("decoding amount withdrawn by %s: %w", wmsg, err)
+		}
+	}
+
+	vote, cold, pledge := splitTreasury(withdrawn, t.cfg.VoteShare, t.cfg.ColdShare, t.cfg.PledgeShare)
+
+	t.lk.Lock()
+	t.st.Withdrawal = nil
+	t.st.Pending = &pendingSplit{
+		Withdrawal: wmsg,
+		From:       w.Recipient,
+		Vote:       vote,
+		Cold:       cold,
+		Pledge:     pledge,
+	}
+	t.lk.Unlock()
+	if err := t.save(); err != nil {
+		return xerrors.Errorf("saving pending split: %w", err)
+	}
+
+	return t.sendSplit(ctx)
+}
+
+// sendSplit sends the parts of the pending split, saving the progress after
+// each signed and each sent message.
+func (t *Treasury) sendSplit(ctx context.Context) error {
+	t.lk.Lock()
+	var ps pendingSplit
+	if t.st.Pending != nil {
+		ps = *t.st.Pending
+	}
+	t.lk.Unlock()
+
+	if ps.Withdrawal == cid.Undef {
+		return nil
+	}
+
+	saveSplit := func() error {
+		t.lk.Lock()
+		p := ps
+		t.st.Pending = &p
+		t.lk.Unlock()
+
+		return t.save()
+	}
+
+	// sendPart signs the message of the part, unless a signed message was
+	// saved already, and pushes it
+	sendPart := func(action string, amount *abi.TokenAmount, sm **types.SignedMessage, msg *types.Message) error {
+		if amount.IsZero() {
+			return nil
+		}
+
+		if *sm == nil {
+			signed, err := t.sign(ctx, msg)
+			if err != nil {
+				return xerrors.Errorf("signing %s message: %w", action, err)
+			}
+			*sm = signed
+			if err := saveSplit(); err != nil {
+				return err
+			}
+		}
+
+		dropped, err := t.push(ctx, action, *sm, *amount)
+		if err != nil {
+			return err
+		}
+
+		c := (*sm).Cid()
+		*sm = nil
+		if dropped {
+			// signed again by the next run
+			if err := saveSplit(); err != nil {
+				return err
+			}
+			return xerrors.Errorf("%s message %s was dropped", action, c)
+		}
+
+		*amount = big.Zero()
+		return saveSplit()
+	}
+
+	if !ps.Vote.IsZero() {
+		params, err := actors.SerializeParams(&miner.AddPosParams{Pos: ps.Vote})
+		if err != nil {
+			return xerrors.Errorf("serializing params: %w", err)
+		}
+
+		if err := sendPart(TreasuryVote, &ps.Vote, &ps.VoteMsg, &types.Message{
+			To:     t.maddr,
+			From:   ps.From,
+			Value:  ps.Vote,
+			Method: miner.Methods.AddPos,
+			Params: params,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := sendPart(TreasuryCold, &ps.Cold, &ps.ColdMsg, &types.Message{
+		To:    t.cold,
+		From:  ps.From,
+		Value: ps.Cold,
+	}); err != nil {
+		return err
+	}
+
+	if !ps.Pledge.IsZero() {
+		if t.pledge == address.Undef || t.pledge == ps.From {
+			t.record(api.TreasuryAction{
+				Action: TreasuryPledge,
+				Amount: ps.Pledge,
+				From:   ps.From,
+				To:     ps.From,
+			})
+			ps.Pledge = big.Zero()
+			if err := saveSplit(); err != nil {
+				return err
+			}
+		} else if err := sendPart(TreasuryPledge, &ps.Pledge, &ps.PledgeMsg, &types.Message{
+			To:    t.pledge,
+			From:  ps.From,
+			Value: ps.Pledge,
+		}); err != nil {
+			return err
+		}
+	}
+
+	t.lk.Lock()
+	t.st.Pending = nil
+	t.lk.Unlock()
+
+	return t.save()
+}
+
+// walletKey returns the key address of the account, which must be in the
+// node wallet
+func (t *Treasury) walletKey(ctx context.Context, a address.Address) (address.Address, error) {
+	k, err := t.api.StateAccountKey(ctx, a, types.EmptyTSK)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("getting account key of %s: %w", a, err)
+	}
+
+	have, err := t.api.WalletHas(ctx, k)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("checking wallet for %s: %w", k, err)
+	}
+	if !have {
+		return address.Undef, xerrors.Errorf("key %s of %s isn't in the node wallet", k, a)
+	}
+
+	return k, nil
+}
+
+// sign estimates gas for the message, and signs it with the next nonce of the
+// sender, so that it can be saved before it's pushed
+func (t *Treasury) sign(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+	nonce, err := t.api.MpoolGetNonce(ctx, msg.From)
+	if err != nil {
+		return nil, xerrors.Errorf("getting nonce: %w", err)
+	}
+	msg.Nonce = nonce
+
+	msg, err = t.api.GasEstimateMessageGas(ctx, msg, nil, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("estimating gas: %w", err)
+	}
+
+	return t.api.WalletSignMessage(ctx, msg.From, msg)
+}
+
+// push pushes a saved message. Pushing it again after a restart is harmless;
+// when that fails, the message is either pending or landed already, possibly
+// replaced, or its nonce was used by another message and it never will land,
+// which is reported as dropped.
+func (t *Treasury) push(ctx context.Context, action string, sm *types.SignedMessage, amount abi.TokenAmount) (dropped bool, err error) {
+	c := sm.Cid()
+	act := api.TreasuryAction{
+		Action: action,
+		Amount: amount,
+		From:   sm.Message.From,
+		To:     sm.Message.To,
+		Msg:    &c,
+	}
+
+	_, perr := t.api.MpoolPush(ctx, sm)
+	if perr == nil {
+		t.record(act)
+		log.Infow("treasury", "action", action, "amount", types.FIL(amount), "to", sm.Message.To, "msg", c)
+		return false, nil
+	}
+
+	ml, err := t.api.StateSearchMsg(ctx, types.EmptyTSK, c, api.LookbackNoLimit, true)
+	if err != nil {
+		return false, xerrors.Errorf("looking up %s message %s: %w", action, c, err)
+	}
+	if ml != nil {
+		return false, nil
+	}
+
+	pending, err := t.api.MpoolPending(ctx, types.EmptyTSK)
+	if err != nil {
+		return false, xerrors.Errorf("getting pending messages: %w", err)
+	}
+	for _, p := range pending {
+		if p.Message.From == sm.Message.From && p.Message.Nonce == sm.Message.Nonce {
+			return false, nil
+		}
+	}
+
+	act.Err = perr.Error()
+	t.record(act)
+
+	from, err := t.api.StateGetActor(ctx, sm.Message.From, types.EmptyTSK)
+	if err == nil && from.Nonce > sm.Message.Nonce {
+		log.Warnw("treasury message nonce was used by another message", "action", action, "msg", c, "nonce", sm.Message.Nonce)
+		return true, nil
+	}
+
+	return false, xerrors.Errorf("pushing %s message %s: %w", action, c, perr)
+}
+
+func (t *Treasury) record(act api.TreasuryAction) {
+	act.Time = build.Clock.Now()
+
+	t.journal.RecordEvent(t.evtAction, func() interface{} {
+		return act
+	})
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	t.st.Actions = append(t.st.Actions, act)
+	if len(t.st.Actions) > treasuryMaxActions {
+		t.st.Actions = t.st.Actions[len(t.st.Actions)-treasuryMaxActions:]
+	}
+}
+
+// splitTreasury splits the amount by the shares. Rounding leftovers stay
+// with the pledge share.
+func splitTreasury(amount abi.TokenAmount, voteShare, coldShare, pledgeShare uint64) (vote, cold, pledge abi.TokenAmount) {
+	total := voteShare + coldShare + pledgeShare
+	if total == 0 {
+		return big.Zero(), big.Zero(), amount
+	}
+
+	vote = big.Div(big.Mul(amount, big.NewIntUnsigned(voteShare)), big.NewIntUnsigned(total))
+	cold = big.Div(big.Mul(amount, big.NewIntUnsigned(coldShare)), big.NewIntUnsigned(total))
+	pledge = big.Sub(big.Sub(amount, vote), cold)
+
+	return vote, cold, pledge
+}
+
+func (t *Treasury) load() error {
+	b, err := t.ds.Get(treasuryStateKey)
+	if xerrors.Is(err, datastore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	return json.Unmarshal(b, &t.st)
+}
+
+func (t *Treasury) save() error {
+	t.lk.Lock()
+	b, err := json.Marshal(&t.st)
+	t.lk.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return t.ds.Put(treasuryStateKey, b)
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+func TestSplitTreasury(t *testing.T) {
+	vote, cold, pledge := splitTreasury(types.NewInt(1000), 5, 3, 2)
+	require.True(t, vote.Equals(types.NewInt(500)))
+	require.True(t, cold.Equals(types.NewInt(300)))
+	require.True(t, pledge.Equals(types.NewInt(200)))
+
+	// rounding leftovers stay with the pledge share
+	vote, cold, pledge = splitTreasury(types.NewInt(100), 1, 1, 1)
+	require.True(t, vote.Equals(types.NewInt(33)))
+	require.True(t, cold.Equals(types.NewInt(33)))
+	require.True(t, pledge.Equals(types.NewInt(34)))
+
+	// nothing is sent without shares
+	vote, cold, pledge = splitTreasury(types.NewInt(100), 0, 0, 0)
+	require.True(t, vote.IsZero())
+	require.True(t, cold.IsZero())
+	require.True(t, pledge.Equals(types.NewInt(100)))
+}
+
+// treasuryTestAPI is a miner with an available balance, which withdraws
+// at most withdrawable from it
+type treasuryTestAPI struct {
+	storageMinerApi
+
+	owner        address.Address
+	available    abi.TokenAmount
+	withdrawable abi.TokenAmount
+
+	// failTo makes pushing messages to the address fail
+	failTo address.Address
+	// waitErr makes waiting for messages fail
+	waitErr error
+
+	mpool  []*types.SignedMessage
+	pushed []*types.Message
+}
+
+func (ta *treasuryTestAPI) ChainHead(context.Context) (*types.TipSet, error) {
+	return mock.TipSet(mock.MkBlock(nil, 1, 1)), nil
+}
+
+func (ta *treasuryTestAPI) StateMinerInfo(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error) {
+	return miner.MinerInfo{
+		Owner:       ta.owner,
+		Worker:      ta.owner,
+		Beneficiary: ta.owner,
+	}, nil
+}
+
+func (ta *treasuryTestAPI) StateMinerAvailableBalance(context.Context, address.Address, types.TipSetKey) (types.BigInt, error) {
+	return ta.available, nil
+}
+
+func (ta *treasuryTestAPI) StateAccountKey(_ context.Context, a address.Address, _ types.TipSetKey) (address.Address, error) {
+	return a, nil
+}
+
+func (ta *treasuryTestAPI) WalletHas(context.Context, address.Address) (bool, error) {
+	return true, nil
+}
+
+func (ta *treasuryTestAPI) MpoolGetNonce(context.Context, address.Address) (uint64, error) {
+	return uint64(len(ta.mpool)), nil
+}
+
+func (ta *treasuryTestAPI) GasEstimateMessageGas(_ context.Context, msg *types.Message, _ *api.MessageSendSpec, _ types.TipSetKey) (*types.Message, error) {
+	return msg, nil
+}
+
+func (ta *treasuryTestAPI) WalletSignMessage(_ context.Context, _ address.Address, msg *types.Message) (*types.SignedMessage, error) {
+	return &types.SignedMessage{Message: *msg}, nil
+}
+
+func (ta *treasuryTestAPI) MpoolPush(_ context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+	if sm.Message.To == ta.failTo {
+		return cid.Undef, xerrors.New("mpool full")
+	}
+	for _, p := range ta.mpool {
+		if p.Cid() == sm.Cid() {
+			return cid.Undef, xerrors.New("message already in mpool")
+		}
+	}
+
+	ta.mpool = append(ta.mpool, sm)
+	msg := sm.Message
+	ta.pushed = append(ta.pushed, &msg)
+	return sm.Cid(), nil
+}
+
+func (ta *treasuryTestAPI) MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error) {
+	return ta.mpool, nil
+}
+
+func (ta *treasuryTestAPI) StateSearchMsg(context.Context, types.TipSetKey, cid.Cid, abi.ChainEpoch, bool) (*api.MsgLookup, error) {
+	return nil, nil
+}
+
+func (ta *treasuryTestAPI) StateGetActor(context.Context, address.Address, types.TipSetKey) (*types.Actor, error) {
+	return &types.Actor{}, nil
+}
+
+func (ta *treasuryTestAPI) StateWaitMsg(context.Context, cid.Cid, uint64, abi.ChainEpoch, bool) (*api.MsgLookup, error) {
+	if ta.waitErr != nil {
+		return nil, ta.waitErr
+	}
+
+	withdrawn := big.Min(ta.available, ta.withdrawable)
+	ta.available = big.Sub(ta.available, withdrawn)
+
+	var ret bytes.Buffer
+	if err := withdrawn.MarshalCBOR(&ret); err != nil {
+		return nil, err
+	}
+
+	return &api.MsgLookup{Receipt: types.MessageReceipt{Return: ret.Bytes()}}, nil
+}
+
+func TestTreasuryRunResumesSplit(t *testing.T) {
+	ctx := context.Background()
+
+	maddr, owner, cold, pledge := mock.Address(1000), mock.Address(100), mock.Address(200), mock.Address(300)
+	ds := datastore.NewMapDatastore()
+	cfg := config.TreasuryConfig{
+		EnableTreasury: true,
+		VoteShare:      1,
+		ColdShare:      1,
+		PledgeShare:    1,
+		ColdWallet:     cold.String(),
+		PledgeWallet:   pledge.String(),
+	}
+
+	ta := &treasuryTestAPI{
+		owner:        owner,
+		available:    types.NewInt(1000),
+		withdrawable: types.NewInt(900),
+		failTo:       cold,
+	}
+
+	tr, err := NewTreasury(ta, ds, maddr, cfg, journal.NilJournal())
+	require.NoError(t, err)
+
+	require.Error(t, tr.run(ctx))
+
+	// the amount actually withdrawn is split, and the vote share was sent
+	require.Len(t, ta.pushed, 2)
+	require.Equal(t, miner.Methods.WithdrawBalance, ta.pushed[0].Method)
+	require.Equal(t, miner.Methods.AddPos, ta.pushed[1].Method)
+	require.True(t, ta.pushed[1].Value.Equals(types.NewInt(300)))
+
+	// a restarted treasury sends the rest without withdrawing again
+	ta.failTo = address.Undef
+	ta.available = big.Zero()
+	tr, err = NewTreasury(ta, ds, maddr, cfg, journal.NilJournal())
+	require.NoError(t, err)
+	require.NoError(t, tr.load())
+	require.NotNil(t, tr.st.Pending)
+	require.True(t, tr.st.Pending.Vote.IsZero())
+	// the cold share message was saved before pushing it, and is pushed again
+	require.NotNil(t, tr.st.Pending.ColdMsg)
+	coldMsg := tr.st.Pending.ColdMsg.Cid()
+
+	require.NoError(t, tr.run(ctx))
+	require.Nil(t, tr.st.Pending)
+
+	require.Len(t, ta.pushed, 4)
+	require.Equal(t, coldMsg, ta.mpool[2].Cid())
+	require.Equal(t, cold, ta.pushed[2].To)
+	require.True(t, ta.pushed[2].Value.Equals(types.NewInt(300)))
+	require.Equal(t, pledge, ta.pushed[3].To)
+	require.True(t, ta.pushed[3].Value.Equals(types.NewInt(300)))
+	require.Equal(t, owner, ta.pushed[3].From)
+}
+
+func TestTreasuryRunResumesWithdrawal(t *testing.T) {
+	ctx := context.Background()
+
+	maddr, owner := mock.Address(1000), mock.Address(100)
+	ds := datastore.NewMapDatastore()
+	cfg := config.TreasuryConfig{
+		EnableTreasury: true,
+		VoteShare:      1,
+	}
+
+	ta := &treasuryTestAPI{
+		owner:        owner,
+		available:    types.NewInt(1000),
+		withdrawable: types.NewInt(1000),
+		waitErr:      xerrors.New("context canceled"),
+	}
+
+	tr, err := NewTreasury(ta, ds, maddr, cfg, journal.NilJournal())
+	require.NoError(t, err)
+
+	// stopped while waiting for the withdrawal
+	require.Error(t, tr.run(ctx))
+	require.Len(t, ta.pushed, 1)
+	require.Equal(t, miner.Methods.WithdrawBalance, ta.pushed[0].Method)
+
+	// a restarted treasury waits for the saved withdrawal instead of sending
+	// another one, and splits it
+	ta.waitErr = nil
+	tr, err = NewTreasury(ta, ds, maddr, cfg, journal.NilJournal())
+	require.NoError(t, err)
+	require.NoError(t, tr.load())
+	require.NotNil(t, tr.st.Withdrawal)
+	require.Equal(t, ta.mpool[0].Cid(), tr.st.Withdrawal.Msg.Cid())
+
+	require.NoError(t, tr.splitWithdrawal(ctx))
+	require.Nil(t, tr.st.Withdrawal)
+	require.Nil(t, tr.st.Pending)
+
+	require.Len(t, ta.pushed, 2)
+	require.Equal(t, miner.Methods.AddPos, ta.pushed[1].Method)
+	require.True(t, ta.pushed[1].Value.Equals(types.NewInt(1000)))
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/ipfs/go-cid"
 
 	"go.opencensus.io/trace"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/xerrors"
 
 	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
@@ -410,6 +411,12 @@ func (s *WindowPoStScheduler) checkNextFaults(ctx context.Context, dlIdx uint64,
 	return faults, sm, nil
 }
 
+// windowPoStParallelizer is implemented by provers which can compute more than
+// one window post at a time, like the sector manager with PoSt workers attached
+type windowPoStParallelizer interface {
+	WindowPoStParallelism(ctx context.Context) int
+}
+
 func (s *WindowPoStScheduler) runPost(ctx context.Context, di dline.Info, ts *types.TipSet) ([]miner.SubmitWindowedPoStParams, error) {
 	ctx, span := trace.StartSpan(ctx, "storage.runPost")
 	defer span.End()
@@ -504,179 +511,220 @@ func (s *WindowPoStScheduler) runPost(ctx context.Context, di dline.Info, ts *ty
 		return nil, err
 	}
 
+	// Provers with more than one PoSt worker can prove several batches at once
+	parallelism := 1
+	if pp, ok := s.prover.(windowPoStParallelizer); ok {
+		parallelism = pp.WindowPoStParallelism(ctx)
+	}
+
 	// Generate proofs in batches
-	posts := make([]miner.SubmitWindowedPoStParams, 0, len(partitionBatches))
+	results := make([]*miner.SubmitWindowedPoStParams, len(partitionBatches))
+	throttle := make(chan struct{}, parallelism)
+
+	eg, ectx := errgroup.WithContext(ctx)
 	for batchIdx, batch := range partitionBatches {
+		batchIdx, batch := batchIdx, batch
+
 		batchPartitionStartIdx := 0
 		for _, batch := range partitionBatches[:batchIdx] {
 			batchPartitionStartIdx += len(batch)
 		}
 
-		params := miner.SubmitWindowedPoStParams{
-			Deadline:   di.Index,
-			Partitions: make([]miner.PoStPartition, 0, len(batch)),
-			Proofs:     nil,
+		throttle <- struct{}{}
+		if ectx.Err() != nil {
+			// another batch failed
+			break
 		}
 
-		skipCount := uint64(0)
-		postSkipped := bitfield.New()
-		somethingToProve := false
+		eg.Go(func() error {
+			defer func() {
+				<-throttle
+			}()
 
-		// Retry until we run out of sectors to prove.
-		for retries := 0; ; retries++ {
-			var partitions []miner.PoStPartition
-			var sinfos []proof2.SectorInfo
-			for partIdx, partition := range batch {
-				// TODO: Can do this in parallel
-				toProve, err := bitfield.SubtractBitField(partition.LiveSectors, partition.FaultySectors)
-				if err != nil {
-					return nil, xerrors.Errorf("removing faults from set of sectors to prove: %w", err)
-				}
-				toProve, err = bitfield.MergeBitFields(toProve, partition.RecoveringSectors)
-				if err != nil {
-					return nil, xerrors.Errorf("adding recoveries to set of sectors to prove: %w", err)
-				}
-
-				good, err := s.checkSectors(ctx, toProve, ts.Key())
-				if err != nil {
-					return nil, xerrors.Errorf("checking sectors to skip: %w", err)
-				}
+			params, somethingToProve, err := s.proveBatch(ectx, di, ts, rand, buf.Bytes(), batchIdx, batchPartitionStartIdx, batch)
+			if err != nil {
+				return err
+			}
 
-				good, err = bitfield.SubtractBitField(good, postSkipped)
-				if err != nil {
-					return nil, xerrors.Errorf("toProve - postSkipped: %w", err)
-				}
+			// Batches with nothing to prove are left out
+			if somethingToProve {
+				results[batchIdx] = &params
+			}
+			return nil
+		})
+	}
 
-				skipped, err := bitfield.SubtractBitField(toProve, good)
-				if err != nil {
-					return nil, xerrors.Errorf("toProve - good: %w", err)
-				}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
 
-				sc, err := skipped.Count()
-				if err != nil {
-					return nil, xerrors.Errorf("getting skipped sector count: %w", err)
-				}
+	posts := make([]miner.SubmitWindowedPoStParams, 0, len(partitionBatches))
+	for _, params := range results {
+		if params != nil {
+			posts = append(posts, *params)
+		}
+	}
 
-				skipCount += sc
+	return posts, nil
+}
 
-				ssi, err := s.sectorsForProof(ctx, good, partition.AllSectors, ts)
-				if err != nil {
-					return nil, xerrors.Errorf("getting sorted sector info: %w", err)
-				}
+// proveBatch generates the proof for a single batch of partitions, retrying
+// without any sectors the prover skipped. It returns false if there was
+// nothing to prove in the batch.
+func (s *WindowPoStScheduler) proveBatch(ctx context.Context, di dline.Info, ts *types.TipSet, rand abi.Randomness, entropy []byte, batchIdx int, batchPartitionStartIdx int, batch []api.Partition) (miner.SubmitWindowedPoStParams, bool, error) {
+	params := miner.SubmitWindowedPoStParams{
+		Deadline:   di.Index,
+		Partitions: make([]miner.PoStPartition, 0, len(batch)),
+		Proofs:     nil,
+	}
+
+	skipCount := uint64(0)
+	postSkipped := bitfield.New()
+
+	// Retry until we run out of sectors to prove.
+	for retries := 0; ; retries++ {
+		var partitions []miner.PoStPartition
+		var sinfos []proof2.SectorInfo
+		for partIdx, partition := range batch {
+			// TODO: Can do this in parallel
+			toProve, err := bitfield.SubtractBitField(partition.LiveSectors, partition.FaultySectors)
+			if err != nil {
+				return params, false, xerrors.Errorf("removing faults from set of sectors to prove: %w", err)
+			}
+			toProve, err = bitfield.MergeBitFields(toProve, partition.RecoveringSectors)
+			if err != nil {
+				return params, false, xerrors.Errorf("adding recoveries to set of sectors to prove: %w", err)
+			}
 
-				if len(ssi) == 0 {
-					continue
-				}
+			good, err := s.checkSectors(ctx, toProve, ts.Key())
+			if err != nil {
+				return params, false, xerrors.Errorf("checking sectors to skip: %w", err)
+			}
 
-				sinfos = append(sinfos, ssi...)
-				partitions = append(partitions, miner.PoStPartition{
-					Index:   uint64(batchPartitionStartIdx + partIdx),
-					Skipped: skipped,
-				})
+			good, err = bitfield.SubtractBitField(good, postSkipped)
+			if err != nil {
+				return params, false, xerrors.Errorf("toProve - postSkipped: %w", err)
 			}
 
-			if len(sinfos) == 0 {
-				// nothing to prove for this batch
-				break
+			skipped, err := bitfield.SubtractBitField(toProve, good)
+			if err != nil {
+				return params, false, xerrors.Errorf("toProve - good: %w", err)
 			}
 
-			// Generate proof
-			log.Infow("running window post",
-				"chain-random", rand,
-				"deadline", di,
-				"height", ts.Height(),
-				"skipped", skipCount)
+			sc, err := skipped.Count()
+			if err != nil {
+				return params, false, xerrors.Errorf("getting skipped sector count: %w", err)
+			}
 
-			tsStart := build.Clock.Now()
+			skipCount += sc
 
-			mid, err := address.IDFromAddress(s.actor)
+			ssi, err := s.sectorsForProof(ctx, good, partition.AllSectors, ts)
 			if err != nil {
-				return nil, err
+				return params, false, xerrors.Errorf("getting sorted sector info: %w", err)
 			}
 
-			postOut, ps, err := s.prover.GenerateWindowPoSt(ctx, abi.ActorID(mid), sinfos, append(abi.PoStRandomness{}, rand...))
-			elapsed := time.Since(tsStart)
+			if len(ssi) == 0 {
+				continue
+			}
 
-			log.Infow("computing window post", "batch", batchIdx, "elapsed", elapsed)
+			sinfos = append(sinfos, ssi...)
+			partitions = append(partitions, miner.PoStPartition{
+				Index:   uint64(batchPartitionStartIdx + partIdx),
+				Skipped: skipped,
+			})
+		}
 
-			if err == nil {
-				// If we proved nothing, something is very wrong.
-				if len(postOut) == 0 {
-					return nil, xerrors.Errorf("received no proofs back from generate window post")
-				}
+		if len(sinfos) == 0 {
+			// nothing to prove for this batch
+			return params, false, nil
+		}
 
-				headTs, err := s.api.ChainHead(ctx)
-				if err != nil {
-					return nil, xerrors.Errorf("getting current head: %w", err)
-				}
+		// Generate proof
+		log.Infow("running window post",
+			"chain-random", rand,
+			"deadline", di,
+			"height", ts.Height(),
+			"skipped", skipCount)
 
-				checkRand, err := s.api.ChainGetRandomnessFromBeacon(ctx, headTs.Key(), crypto.DomainSeparationTag_WindowedPoStChallengeSeed, di.Challenge, buf.Bytes())
-				if err != nil {
-					return nil, xerrors.Errorf("failed to get chain randomness from beacon for window post (ts=%d; deadline=%d): %w", ts.Height(), di, err)
-				}
+		tsStart := build.Clock.Now()
 
-				if !bytes.Equal(checkRand, rand) {
-					log.Warnw("windowpost randomness changed", "old", rand, "new", checkRand, "ts-height", ts.Height(), "challenge-height", di.Challenge, "tsk", ts.Key())
-					continue
-				}
+		mid, err := address.IDFromAddress(s.actor)
+		if err != nil {
+			return params, false, err
+		}
 
-				// If we generated an incorrect proof, try again.
-				if correct, err := s.verifier.VerifyWindowPoSt(ctx, proof.WindowPoStVerifyInfo{
-					Randomness:        abi.PoStRandomness(checkRand),
-					Proofs:            postOut,
-					ChallengedSectors: sinfos,
-					Prover:            abi.ActorID(mid),
-				}); err != nil {
-					log.Errorw("window post verification failed", "post", postOut, "error", err)
-					time.Sleep(5 * time.Second)
-					continue
-				} else if !correct {
-					log.Errorw("generated incorrect window post proof", "post", postOut, "error", err)
-					continue
-				}
+		postOut, ps, err := s.prover.GenerateWindowPoSt(ctx, abi.ActorID(mid), sinfos, append(abi.PoStRandomness{}, rand...))
+		elapsed := time.Since(tsStart)
 
-				// Proof generation successful, stop retrying
-				somethingToProve = true
-				params.Partitions = partitions
-				params.Proofs = postOut
-				break
-			}
+		log.Infow("computing window post", "batch", batchIdx, "elapsed", elapsed)
 
-			// Proof generation failed, so retry
+		if err == nil {
+			// If we proved nothing, something is very wrong.
+			if len(postOut) == 0 {
+				return params, false, xerrors.Errorf("received no proofs back from generate window post")
+			}
 
-			if len(ps) == 0 {
-				// If we didn't skip any new sectors, we failed
-				// for some other reason and we need to abort.
-				return nil, xerrors.Errorf("running window post failed: %w", err)
+			headTs, err := s.api.ChainHead(ctx)
+			if err != nil {
+				return params, false, xerrors.Errorf("getting current head: %w", err)
 			}
-			// TODO: maybe mark these as faulty somewhere?
 
-			log.Warnw("generate window post skipped sectors", "sectors", ps, "error", err, "try", retries)
+			checkRand, err := s.api.ChainGetRandomnessFromBeacon(ctx, headTs.Key(), crypto.DomainSeparationTag_WindowedPoStChallengeSeed, di.Challenge, entropy)
+			if err != nil {
+				return params, false, xerrors.Errorf("failed to get chain randomness from beacon for window post (ts=%d; deadline=%d): %w", ts.Height(), di, err)
+			}
 
-			// Explicitly make sure we haven't aborted this PoSt
-			// (GenerateWindowPoSt may or may not check this).
-			// Otherwise, we could try to continue proving a
-			// deadline after the deadline has ended.
-			if ctx.Err() != nil {
-				log.Warnw("aborting PoSt due to context cancellation", "error", ctx.Err(), "deadline", di.Index)
-				return nil, ctx.Err()
+			if !bytes.Equal(checkRand, rand) {
+				log.Warnw("windowpost randomness changed", "old", rand, "new", checkRand, "ts-height", ts.Height(), "challenge-height", di.Challenge, "tsk", ts.Key())
+				continue
 			}
 
-			skipCount += uint64(len(ps))
-			for _, sector := range ps {
-				postSkipped.Set(uint64(sector.Number))
+			// If we generated an incorrect proof, try again.
+			if correct, err := s.verifier.VerifyWindowPoSt(ctx, proof.WindowPoStVerifyInfo{
+				Randomness:        abi.PoStRandomness(checkRand),
+				Proofs:            postOut,
+				ChallengedSectors: sinfos,
+				Prover:            abi.ActorID(mid),
+			}); err != nil {
+				log.Errorw("window post verification failed", "post", postOut, "error", err)
+				time.Sleep(5 * time.Second)
+				continue
+			} else if !correct {
+				log.Errorw("generated incorrect window post proof", "post", postOut, "error", err)
+				continue
 			}
+
+			// Proof generation successful
+			params.Partitions = partitions
+			params.Proofs = postOut
+			return params, true, nil
 		}
 
-		// Nothing to prove for this batch, try the next batch
-		if !somethingToProve {
-			continue
+		// Proof generation failed, so retry
+
+		if len(ps) == 0 {
+			// If we didn't skip any new sectors, we failed
+			// for some other reason and we need to abort.
+			return params, false, xerrors.Errorf("running window post failed: %w", err)
 		}
+		// TODO: maybe mark these as faulty somewhere?
 
-		posts = append(posts, params)
-	}
+		log.Warnw("generate window post skipped sectors", "sectors", ps, "error", err, "try", retries)
 
-	return posts, nil
+		// Explicitly make sure we haven't aborted this PoSt
+		// (GenerateWindowPoSt may or may not check this).
+		// Otherwise, we could try to continue proving a
+		// deadline after the deadline has ended.
+		if ctx.Err() != nil {
+			log.Warnw("aborting PoSt due to context cancellation", "error", ctx.Err(), "deadline", di.Index)
+			return params, false, ctx.Err()
+		}
+
+		skipCount += uint64(len(ps))
+		for _, sector := range ps {
+			postSkipped.Set(uint64(sector.Number))
+		}
+	}
 }
 
 func (s *WindowPoStScheduler) batchPartitions(partitions []api.Partition) ([][]api.Partition, error) {
@@ -804,14 +852,20 @@ func (s *WindowPoStScheduler) submitPost(ctx context.Context, proof *miner.Submi
 }
 
 func (s *WindowPoStScheduler) setSender(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) error {
-	mi, err := s.api.StateMinerInfo(ctx, s.actor, types.EmptyTSK)
+	return setPoStSender(ctx, s.api, s.addrSel, s.actor, msg, spec)
+}
+
+// setPoStSender estimates gas for the message and picks a PoSt control
+// address to send it from, capping the fee if the address is short on funds
+func setPoStSender(ctx context.Context, sapi storageMinerApi, addrSel *AddressSelector, actor address.Address, msg *types.Message, spec *api.MessageSendSpec) error {
+	mi, err := sapi.StateMinerInfo(ctx, actor, types.EmptyTSK)
 	if err != nil {
 		return xerrors.Errorf("error getting miner info: %w", err)
 	}
 	// use the worker as a fallback
 	msg.From = mi.Worker
 
-	gm, err := s.api.GasEstimateMessageGas(ctx, msg, spec, types.EmptyTSK)
+	gm, err := sapi.GasEstimateMessageGas(ctx, msg, spec, types.EmptyTSK)
 	if err != nil {
 		log.Errorw("estimating gas", "error", err)
 		return nil
@@ -821,13 +875,13 @@ func (s *WindowPoStScheduler) setSender(ctx context.Context, msg *types.Message,
 	// estimate
 	minGasFeeMsg := *msg
 
-	minGasFeeMsg.GasPremium, err = s.api.GasEstimateGasPremium(ctx, 5, msg.From, msg.GasLimit, types.TipSetKey{})
+	minGasFeeMsg.GasPremium, err = sapi.GasEstimateGasPremium(ctx, 5, msg.From, msg.GasLimit, types.TipSetKey{})
 	if err != nil {
 		log.Errorf("failed to estimate minimum gas premium: %+v", err)
 		minGasFeeMsg.GasPremium = msg.GasPremium
 	}
 
-	minGasFeeMsg.GasFeeCap, err = s.api.GasEstimateFeeCap(ctx, &minGasFeeMsg, 4, types.EmptyTSK)
+	minGasFeeMsg.GasFeeCap, err = sapi.GasEstimateFeeCap(ctx, &minGasFeeMsg, 4, types.EmptyTSK)
 	if err != nil {
 		log.Errorf("failed to estimate minimum gas fee cap: %+v", err)
 		minGasFeeMsg.GasFeeCap = msg.GasFeeCap
@@ -836,7 +890,7 @@ func (s *WindowPoStScheduler) setSender(ctx context.Context, msg *types.Message,
 	goodFunds := big.Add(msg.RequiredFunds(), msg.Value)
 	minFunds := big.Min(big.Add(minGasFeeMsg.RequiredFunds(), minGasFeeMsg.Value), goodFunds)
 
-	pa, avail, err := s.addrSel.AddressFor(ctx, s.api, mi, api.PoStAddr, goodFunds, minFunds)
+	pa, avail, err := addrSel.AddressFor(ctx, sapi, mi, api.PoStAddr, goodFunds, minFunds)
 	if err != nil {
 		log.Errorw("error selecting address for window post", "error", err)
 		return nil